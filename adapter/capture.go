@@ -0,0 +1,15 @@
+package adapter
+
+import (
+	"context"
+	"net"
+
+	N "github.com/sagernet/sing/common/network"
+)
+
+// ConnectionCapture records the payload of routed connections once both
+// sides are established. It returns conn itself if nothing is recorded.
+type ConnectionCapture interface {
+	CaptureConnection(ctx context.Context, conn net.Conn, remoteConn net.Conn, metadata InboundContext, outbound N.Dialer) net.Conn
+	CapturePacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, outbound N.Dialer) N.PacketConn
+}
@@ -3,6 +3,7 @@ package adapter
 import (
 	"context"
 	"net/netip"
+	"time"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -19,15 +20,61 @@ type DNSRouter interface {
 	Exchange(ctx context.Context, message *dns.Msg, options DNSQueryOptions) (*dns.Msg, error)
 	Lookup(ctx context.Context, domain string, options DNSQueryOptions) ([]netip.Addr, error)
 	ClearCache()
+	CacheEntries() []DNSCacheEntry
+	RemoveCache(match func(entry DNSCacheEntry) bool) int
+	IndependentCache() bool
 	LookupReverseMapping(ip netip.Addr) (string, bool)
 	ResetNetwork()
+	AppendTracker(tracker DNSQueryTracker)
 }
 
 type DNSClient interface {
 	Start()
+	AppendTracker(tracker DNSQueryTracker)
 	Exchange(ctx context.Context, transport DNSTransport, message *dns.Msg, options DNSQueryOptions, responseChecker func(responseAddrs []netip.Addr) bool) (*dns.Msg, error)
 	Lookup(ctx context.Context, transport DNSTransport, domain string, options DNSQueryOptions, responseChecker func(responseAddrs []netip.Addr) bool) ([]netip.Addr, error)
 	ClearCache()
+	CacheEntries() []DNSCacheEntry
+	RemoveCache(match func(entry DNSCacheEntry) bool) int
+	IndependentCache() bool
+}
+
+// DNSCacheEntry is a cached response. Transport and ClientSubnet are only
+// set with independent_cache, and ExpireAt is zero with disable_expire.
+type DNSCacheEntry struct {
+	Question     dns.Question
+	Transport    string
+	ClientSubnet netip.Prefix
+	Response     *dns.Msg
+	ExpireAt     time.Time
+}
+
+// DNSQueryTrace records how the router handled an exchange, if present in
+// the context of the query. Rule is nil and RuleIndex -1 if the query went
+// to the default server.
+type DNSQueryTrace struct {
+	Rule      DNSRule
+	RuleIndex int
+	Servers   []string
+}
+
+type dnsQueryTraceKey struct{}
+
+func WithDNSQueryTrace(ctx context.Context, trace *DNSQueryTrace) context.Context {
+	return context.WithValue(ctx, dnsQueryTraceKey{}, trace)
+}
+
+func DNSQueryTraceFromContext(ctx context.Context) *DNSQueryTrace {
+	trace, _ := ctx.Value(dnsQueryTraceKey{}).(*DNSQueryTrace)
+	return trace
+}
+
+// DNSQueryTracker observes queries handled by the DNS client. CacheLookup is
+// called once per cacheable query, and Exchanged once per query sent to a
+// transport, with a nil response if the exchange failed.
+type DNSQueryTracker interface {
+	CacheLookup(transport DNSTransport, hit bool)
+	Exchanged(transport DNSTransport, response *dns.Msg, elapsed time.Duration, err error)
 }
 
 type DNSQueryOptions struct {
@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing/common/observable"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	EventGroupSelected      = "group.selected"
+	EventGroupDown          = "group.down"
+	EventGroupUp            = "group.up"
+	EventOutboundHealthy    = "outbound.healthy"
+	EventOutboundUnhealthy  = "outbound.unhealthy"
+	EventRuleSetUpdated     = "rule_set.updated"
+	EventRuleSetFailed      = "rule_set.failed"
+	EventDNSFailing         = "dns.failing"
+	EventDNSRecovered       = "dns.recovered"
+	EventMemoryKilled       = "memory.killed"
+	EventConfigReloaded     = "config.reloaded"
+	EventConfigReloadFailed = "config.reload_failed"
+)
+
+// Event is a state change reported to the event bus. Tag is the group,
+// outbound, rule-set or DNS server the event is about.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Tag      string    `json:"tag,omitempty"`
+	Network  string    `json:"network,omitempty"`
+	Outbound string    `json:"outbound,omitempty"`
+	Previous string    `json:"previous,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+type EventBus interface {
+	Emit(event Event)
+	Subscribe() (subscription observable.Subscription[Event], done <-chan struct{}, err error)
+	UnSubscribe(subscription observable.Subscription[Event])
+}
+
+// EmitEvent sends the event to the event bus of the context, if any.
+func EmitEvent(ctx context.Context, event Event) {
+	eventBus := service.FromContext[EventBus](ctx)
+	if eventBus == nil {
+		return
+	}
+	eventBus.Emit(event)
+}
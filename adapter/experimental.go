@@ -52,6 +52,12 @@ type CacheFile interface {
 	StoreGroupExpand(group string, expand bool) error
 	LoadRuleSet(tag string) *SavedBinary
 	SaveRuleSet(tag string, set *SavedBinary) error
+	LoadLearnedOutbounds(group string) map[string]*LearnedOutbound
+	SaveLearnedOutbound(group string, key string, learned *LearnedOutbound) error
+	DeleteLearnedOutbound(group string, key string) error
+	AddTrafficHistory(entries []TrafficHistoryEntry) error
+	LoadTrafficHistory(period string, groupBy string, since time.Time, until time.Time) []TrafficHistoryEntry
+	PruneTrafficHistory(period string, before time.Time) error
 }
 
 type SavedBinary struct {
@@ -105,12 +111,108 @@ func (s *SavedBinary) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+type LearnedOutbound struct {
+	Succeeded   string    `json:"succeeded,omitempty"`
+	Failed      string    `json:"failed,omitempty"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+func (l *LearnedOutbound) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	if err != nil {
+		return nil, err
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, l.Succeeded)
+	if err != nil {
+		return nil, err
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, l.Failed)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(&buffer, binary.BigEndian, l.LastUpdated.Unix())
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (l *LearnedOutbound) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return err
+	}
+	err = varbin.Read(reader, binary.BigEndian, &l.Succeeded)
+	if err != nil {
+		return err
+	}
+	err = varbin.Read(reader, binary.BigEndian, &l.Failed)
+	if err != nil {
+		return err
+	}
+	var lastUpdated int64
+	err = binary.Read(reader, binary.BigEndian, &lastUpdated)
+	if err != nil {
+		return err
+	}
+	l.LastUpdated = time.Unix(lastUpdated, 0)
+	return nil
+}
+
+const (
+	TrafficPeriodHour  = "hour"
+	TrafficPeriodDay   = "day"
+	TrafficPeriodMonth = "month"
+)
+
+const (
+	TrafficGroupOutbound = "outbound"
+	TrafficGroupInbound  = "inbound"
+	TrafficGroupUser     = "user"
+	TrafficGroupRuleSet  = "rule_set"
+)
+
+// TrafficHistoryEntry is the traffic of one name in the bucket of the period
+// that starts at Time.
+type TrafficHistoryEntry struct {
+	Period   string
+	GroupBy  string
+	Name     string
+	Time     time.Time
+	Upload   int64
+	Download int64
+}
+
+type TrafficHistory interface {
+	TrafficHistory(period string, groupBy string, since time.Time, until time.Time) ([]TrafficHistoryEntry, error)
+}
+
 type OutboundGroup interface {
 	Outbound
 	Now() string
 	All() []string
 }
 
+type OutboundRelay interface {
+	Outbound
+	Hops() []string
+}
+
+type PinnableGroup interface {
+	OutboundGroup
+	Pin(tag string) bool
+	Pinned() string
+}
+
+type ExcludableGroup interface {
+	OutboundGroup
+	Exclude(tags []string) error
+	Excluded() []string
+}
+
 type URLTestGroup interface {
 	OutboundGroup
 	URLTest(ctx context.Context) (map[string]uint16, error)
@@ -30,4 +30,6 @@ type OutboundManager interface {
 	Default() Outbound
 	Remove(tag string) error
 	Create(ctx context.Context, router Router, logger log.ContextLogger, tag string, outboundType string, options any) error
+	// Options returns the type and options an outbound was created with.
+	Options(tag string) (outboundType string, options any, loaded bool)
 }
@@ -28,11 +28,17 @@ type Manager struct {
 	stage                   adapter.StartStage
 	outbounds               []adapter.Outbound
 	outboundByTag           map[string]adapter.Outbound
+	optionsByTag            map[string]outboundOptions
 	dependByTag             map[string][]string
 	defaultOutbound         adapter.Outbound
 	defaultOutboundFallback func() (adapter.Outbound, error)
 }
 
+type outboundOptions struct {
+	outboundType string
+	options      any
+}
+
 func NewManager(logger logger.ContextLogger, registry adapter.OutboundRegistry, endpoint adapter.EndpointManager, defaultTag string) *Manager {
 	return &Manager{
 		logger:        logger,
@@ -40,6 +46,7 @@ func NewManager(logger logger.ContextLogger, registry adapter.OutboundRegistry,
 		endpoint:      endpoint,
 		defaultTag:    defaultTag,
 		outboundByTag: make(map[string]adapter.Outbound),
+		optionsByTag:  make(map[string]outboundOptions),
 		dependByTag:   make(map[string][]string),
 	}
 }
@@ -197,6 +204,13 @@ func (m *Manager) Outbound(tag string) (adapter.Outbound, bool) {
 	return m.endpoint.Get(tag)
 }
 
+func (m *Manager) Options(tag string) (outboundType string, options any, loaded bool) {
+	m.access.RLock()
+	defer m.access.RUnlock()
+	createOptions, loaded := m.optionsByTag[tag]
+	return createOptions.outboundType, createOptions.options, loaded
+}
+
 func (m *Manager) Default() adapter.Outbound {
 	m.access.RLock()
 	defer m.access.RUnlock()
@@ -210,7 +224,12 @@ func (m *Manager) Remove(tag string) error {
 	if !found {
 		return os.ErrInvalid
 	}
+	dependBy := m.dependByTag[tag]
+	if len(dependBy) > 0 {
+		return E.New("outbound[", tag, "] is depended by ", strings.Join(dependBy, ", "))
+	}
 	delete(m.outboundByTag, tag)
+	delete(m.optionsByTag, tag)
 	index := common.Index(m.outbounds, func(it adapter.Outbound) bool {
 		return it == outbound
 	})
@@ -227,10 +246,6 @@ func (m *Manager) Remove(tag string) error {
 			m.defaultOutbound = nil
 		}
 	}
-	dependBy := m.dependByTag[tag]
-	if len(dependBy) > 0 {
-		return E.New("outbound[", tag, "] is depended by ", strings.Join(dependBy, ", "))
-	}
 	dependencies := outbound.Dependencies()
 	for _, dependency := range dependencies {
 		if len(m.dependByTag[dependency]) == 1 {
@@ -265,6 +280,7 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 	}
 	m.access.Lock()
 	defer m.access.Unlock()
+	var replacedDefault bool
 	if existsOutbound, loaded := m.outboundByTag[tag]; loaded {
 		if m.started {
 			err = common.Close(existsOutbound)
@@ -279,14 +295,21 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 			panic("invalid inbound index")
 		}
 		m.outbounds = append(m.outbounds[:existsIndex], m.outbounds[existsIndex+1:]...)
+		for _, dependency := range existsOutbound.Dependencies() {
+			m.dependByTag[dependency] = common.Filter(m.dependByTag[dependency], func(it string) bool {
+				return it != tag
+			})
+		}
+		replacedDefault = m.defaultOutbound == existsOutbound
 	}
 	m.outbounds = append(m.outbounds, outbound)
 	m.outboundByTag[tag] = outbound
+	m.optionsByTag[tag] = outboundOptions{inboundType, options}
 	dependencies := outbound.Dependencies()
 	for _, dependency := range dependencies {
 		m.dependByTag[dependency] = append(m.dependByTag[dependency], tag)
 	}
-	if tag == m.defaultTag || (m.defaultTag == "" && m.defaultOutbound == nil) {
+	if tag == m.defaultTag || replacedDefault || (m.defaultTag == "" && m.defaultOutbound == nil) {
 		m.defaultOutbound = outbound
 		if m.started {
 			m.logger.Info("updated default outbound to ", outbound.Tag())
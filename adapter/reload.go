@@ -0,0 +1,12 @@
+package adapter
+
+import "github.com/sagernet/sing-box/option"
+
+// ConfigReloader applies a new configuration to a running instance, keeping
+// the components whose options did not change.
+type ConfigReloader interface {
+	Reload(options option.Options) error
+	// ReloadFromSource loads the configuration again from where the instance
+	// was started from and reloads it.
+	ReloadFromSource() error
+}
@@ -16,6 +16,11 @@ type Rule interface {
 	Action() RuleAction
 }
 
+// RuleSetReferrer is implemented by rules that reference rule-sets.
+type RuleSetReferrer interface {
+	RuleSets() []string
+}
+
 type DNSRule interface {
 	Rule
 	WithAddressLimit() bool
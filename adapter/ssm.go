@@ -1,18 +1,170 @@
 package adapter
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"net"
 
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
 	N "github.com/sagernet/sing/common/network"
 )
 
-type ManagedSSMServer interface {
+// ManagedUser is a user pushed to an inbound by the SSM API or the V2Ray API
+// handler service. Inbounds read the credential fields their protocol uses
+// and ignore the others.
+type ManagedUser struct {
+	Name     string
+	Password string
+	UUID     string
+	Flow     string
+}
+
+type ManagedUserServer interface {
 	Inbound
 	SetTracker(tracker SSMTracker)
-	UpdateUsers(users []string, uPSKs []string) error
+	UpdateUsers(users []ManagedUser) error
+	ManagedUsers() []ManagedUser
+}
+
+// ManagedUserOwner is a service owning the users of the managed inbounds it
+// tracks, like the SSM API. Other services must edit those users through
+// it, as the owner pushes its own list on every change and would overwrite
+// theirs.
+type ManagedUserOwner interface {
+	Service
+	ManagedUserEditor(inboundTag string) (ManagedUserEditor, bool)
+}
+
+type ManagedUserEditor interface {
+	AddUser(user ManagedUser) error
+	RemoveUser(name string) error
+}
+
+// ManagedAuthenticator is the user list of a username/password inbound
+// together with its authenticator. Inbounds publish it as one value, so
+// that a connection never authenticates against a list it cannot see.
+type ManagedAuthenticator struct {
+	Authenticator *auth.Authenticator
+	Users         []auth.User
+}
+
+// NewManagedAuthenticator builds a username/password authenticator from
+// managed users. Unlike auth.NewAuthenticator it never returns nil, so
+// removing the last user does not turn authentication off.
+func NewManagedAuthenticator(users []ManagedUser) (*ManagedAuthenticator, error) {
+	authUsers := make([]auth.User, 0, len(users))
+	for _, user := range users {
+		if user.Password == "" {
+			return nil, E.New("missing password for user ", user.Name)
+		}
+		authUsers = append(authUsers, auth.User{
+			Username: user.Name,
+			Password: user.Password,
+		})
+	}
+	verifyUsers := authUsers
+	if len(verifyUsers) == 0 {
+		var password [32]byte
+		_, err := rand.Read(password[:])
+		if err != nil {
+			return nil, err
+		}
+		verifyUsers = []auth.User{{Password: hex.EncodeToString(password[:])}}
+	}
+	return &ManagedAuthenticator{
+		Authenticator: auth.NewAuthenticator(verifyUsers),
+		Users:         authUsers,
+	}, nil
+}
+
+func (a *ManagedAuthenticator) ManagedUsers() []ManagedUser {
+	return common.Map(a.Users, func(it auth.User) ManagedUser {
+		return ManagedUser{
+			Name:     it.Username,
+			Password: it.Password,
+		}
+	})
+}
+
+// ManagedUserKey is what index-keyed services authenticate users to. It
+// carries the name, so a connection is credited to the user it was
+// authenticated as even if the user list is replaced meanwhile.
+type ManagedUserKey struct {
+	Index int
+	Name  string
 }
 
+func NewManagedUserKeys[T any](users []T, name func(T) string) []ManagedUserKey {
+	return common.MapIndexed(users, func(index int, it T) ManagedUserKey {
+		return ManagedUserKey{index, name(it)}
+	})
+}
+
+// SSMTracker accounts connections of managed users. A non-nil error rejects
+// the connection; otherwise the returned close handler, which wraps onClose
+// and may be nil only if onClose is, must be called once the connection is
+// done.
 type SSMTracker interface {
-	TrackConnection(conn net.Conn, metadata InboundContext) net.Conn
-	TrackPacketConnection(conn N.PacketConn, metadata InboundContext) N.PacketConn
+	TrackConnection(conn net.Conn, metadata InboundContext, onClose N.CloseHandlerFunc) (net.Conn, N.CloseHandlerFunc, error)
+	TrackPacketConnection(conn N.PacketConn, metadata InboundContext, onClose N.CloseHandlerFunc) (N.PacketConn, N.CloseHandlerFunc, error)
+}
+
+// NewSSMTrackedRouter passes every routed connection through tracker, so
+// managed inbounds only need to set metadata.User before routing.
+func NewSSMTrackedRouter(router ConnectionRouterEx, tracker SSMTracker, logger logger.ContextLogger) ConnectionRouterEx {
+	return &ssmTrackedRouter{router, tracker, logger}
+}
+
+type ssmTrackedRouter struct {
+	ConnectionRouterEx
+	tracker SSMTracker
+	logger  logger.ContextLogger
+}
+
+func (r *ssmTrackedRouter) RouteConnection(ctx context.Context, conn net.Conn, metadata InboundContext) error {
+	trackedConn, onClose, err := r.tracker.TrackConnection(conn, metadata, nil)
+	if err != nil {
+		return err
+	}
+	err = r.ConnectionRouterEx.RouteConnection(ctx, trackedConn, metadata)
+	if onClose != nil {
+		onClose(err)
+	}
+	return err
+}
+
+func (r *ssmTrackedRouter) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error {
+	trackedConn, onClose, err := r.tracker.TrackPacketConnection(conn, metadata, nil)
+	if err != nil {
+		return err
+	}
+	err = r.ConnectionRouterEx.RoutePacketConnection(ctx, trackedConn, metadata)
+	if onClose != nil {
+		onClose(err)
+	}
+	return err
+}
+
+func (r *ssmTrackedRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata InboundContext, onClose N.CloseHandlerFunc) {
+	trackedConn, trackedOnClose, err := r.tracker.TrackConnection(conn, metadata, onClose)
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		r.logger.ErrorContext(ctx, E.Cause(err, "reject connection from ", metadata.Source))
+		return
+	}
+	r.ConnectionRouterEx.RouteConnectionEx(ctx, trackedConn, metadata, trackedOnClose)
+}
+
+func (r *ssmTrackedRouter) RoutePacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata InboundContext, onClose N.CloseHandlerFunc) {
+	trackedConn, trackedOnClose, err := r.tracker.TrackPacketConnection(conn, metadata, onClose)
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		r.logger.ErrorContext(ctx, E.Cause(err, "reject packet connection from ", metadata.Source))
+		return
+	}
+	r.ConnectionRouterEx.RoutePacketConnectionEx(ctx, trackedConn, metadata, trackedOnClose)
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -17,12 +18,18 @@ import (
 	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/taskmonitor"
 	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/common/urltest"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/dns"
 	"github.com/sagernet/sing-box/dns/transport/local"
 	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/experimental/accesslog"
 	"github.com/sagernet/sing-box/experimental/cachefile"
+	"github.com/sagernet/sing-box/experimental/capture"
+	"github.com/sagernet/sing-box/experimental/events"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/experimental/metrics"
+	"github.com/sagernet/sing-box/experimental/traffichistory"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-box/protocol/direct"
@@ -38,6 +45,11 @@ import (
 var _ adapter.SimpleLifecycle = (*Box)(nil)
 
 type Box struct {
+	ctx             context.Context
+	options         option.Options
+	configLoader    func() (option.Options, error)
+	reloadAccess    sync.Mutex
+	reloadBroken    bool
 	createdAt       time.Time
 	logFactory      log.Factory
 	logger          log.ContextLogger
@@ -58,6 +70,8 @@ type Options struct {
 	option.Options
 	Context           context.Context
 	PlatformLogWriter log.PlatformWriter
+	// ConfigLoader loads the configuration again for ReloadFromSource.
+	ConfigLoader func() (option.Options, error)
 }
 
 func Context(
@@ -130,6 +144,7 @@ func New(options Options) (*Box, error) {
 	var needCacheFile bool
 	var needClashAPI bool
 	var needV2RayAPI bool
+	var needMetrics bool
 	if experimentalOptions.CacheFile != nil && experimentalOptions.CacheFile.Enabled || options.PlatformLogWriter != nil {
 		needCacheFile = true
 	}
@@ -139,6 +154,9 @@ func New(options Options) (*Box, error) {
 	if experimentalOptions.V2RayAPI != nil && experimentalOptions.V2RayAPI.Listen != "" {
 		needV2RayAPI = true
 	}
+	if experimentalOptions.Metrics != nil && experimentalOptions.Metrics.Listen != "" {
+		needMetrics = true
+	}
 	platformInterface := service.FromContext[platform.Interface](ctx)
 	var defaultLogWriter io.Writer
 	if platformInterface != nil {
@@ -151,6 +169,7 @@ func New(options Options) (*Box, error) {
 		DefaultWriter:  defaultLogWriter,
 		BaseTime:       createdAt,
 		PlatformWriter: options.PlatformLogWriter,
+		ContextFields:  logContextFields,
 	})
 	if err != nil {
 		return nil, E.Cause(err, "create log factory")
@@ -351,20 +370,70 @@ func New(options Options) (*Box, error) {
 		router.AppendTracker(clashServer)
 		service.MustRegister[adapter.ClashServer](ctx, clashServer)
 		internalServices = append(internalServices, clashServer)
+		captureManager := capture.NewManager(ctx, logFactory.NewLogger("capture"))
+		connectionManager.SetCapture(captureManager)
+		service.MustRegisterPtr(ctx, captureManager)
+		internalServices = append(internalServices, captureManager)
 	}
 	if needV2RayAPI {
-		v2rayServer, err := experimental.NewV2RayServer(logFactory.NewLogger("v2ray-api"), common.PtrValueOrDefault(experimentalOptions.V2RayAPI))
+		v2rayServer, err := experimental.NewV2RayServer(ctx, logFactory, common.PtrValueOrDefault(experimentalOptions.V2RayAPI))
 		if err != nil {
 			return nil, E.Cause(err, "create v2ray-server")
 		}
 		if v2rayServer.StatsService() != nil {
 			router.AppendTracker(v2rayServer.StatsService())
-			internalServices = append(internalServices, v2rayServer)
-			service.MustRegister[adapter.V2RayServer](ctx, v2rayServer)
 		}
+		internalServices = append(internalServices, v2rayServer)
+		service.MustRegister[adapter.V2RayServer](ctx, v2rayServer)
+	}
+	if needMetrics {
+		if !needClashAPI && service.PtrFromContext[urltest.HistoryStorage](ctx) == nil {
+			service.MustRegisterPtr(ctx, urltest.NewHistoryStorage())
+		}
+		metricsServer := metrics.NewServer(ctx, logFactory.NewLogger("metrics"), common.PtrValueOrDefault(experimentalOptions.Metrics))
+		router.AppendTracker(metricsServer)
+		dnsRouter.AppendTracker(metricsServer)
+		internalServices = append(internalServices, metricsServer)
+	}
+	if experimentalOptions.Events != nil || needClashAPI {
+		if !needClashAPI && service.PtrFromContext[urltest.HistoryStorage](ctx) == nil {
+			service.MustRegisterPtr(ctx, urltest.NewHistoryStorage())
+		}
+		eventService, err := events.NewService(ctx, logFactory.NewLogger("events"), common.PtrValueOrDefault(experimentalOptions.Events))
+		if err != nil {
+			return nil, E.Cause(err, "create events")
+		}
+		dnsRouter.AppendTracker(eventService)
+		service.MustRegister[adapter.EventBus](ctx, eventService)
+		internalServices = append(internalServices, eventService)
+	}
+	if experimentalOptions.AccessLog != nil {
+		accessLog, err := accesslog.NewService(ctx, logFactory.NewLogger("access-log"), *experimentalOptions.AccessLog)
+		if err != nil {
+			return nil, E.Cause(err, "create access log")
+		}
+		router.AppendTracker(accessLog)
+		internalServices = append(internalServices, accessLog)
+	}
+	if experimentalOptions.TrafficHistory != nil && experimentalOptions.TrafficHistory.Enabled {
+		if !needCacheFile {
+			return nil, E.New("traffic history requires cache_file to be enabled")
+		}
+		trafficRecorder, err := traffichistory.NewRecorder(ctx, logFactory.NewLogger("traffic-history"), *experimentalOptions.TrafficHistory)
+		if err != nil {
+			return nil, E.Cause(err, "create traffic history")
+		}
+		router.AppendTracker(trafficRecorder)
+		service.MustRegister[adapter.TrafficHistory](ctx, trafficRecorder)
+		// closed before the cache file so that the last flush is stored
+		internalServices = append([]adapter.LifecycleService{trafficRecorder}, internalServices...)
 	}
 	if ntpOptions.Enabled {
-		ntpDialer, err := dialer.New(ctx, ntpOptions.DialerOptions, ntpOptions.ServerIsDomain())
+		ntpDialer, err := dialer.NewWithOptions(dialer.Options{
+			Context:        ctx,
+			Options:        ntpOptions.DialerOptions,
+			RemoteIsDomain: ntpOptions.ServerIsDomain(),
+		})
 		if err != nil {
 			return nil, E.Cause(err, "create NTP service")
 		}
@@ -379,7 +448,10 @@ func New(options Options) (*Box, error) {
 		timeService.TimeService = ntpService
 		internalServices = append(internalServices, adapter.NewLifecycleService(ntpService, "ntp service"))
 	}
-	return &Box{
+	box := &Box{
+		ctx:             ctx,
+		options:         options.Options,
+		configLoader:    options.ConfigLoader,
 		network:         networkManager,
 		endpoint:        endpointManager,
 		inbound:         inboundManager,
@@ -394,7 +466,9 @@ func New(options Options) (*Box, error) {
 		logger:          logFactory.Logger(),
 		internalService: internalServices,
 		done:            make(chan struct{}),
-	}, nil
+	}
+	service.MustRegister[adapter.ConfigReloader](ctx, box)
+	return box, nil
 }
 
 func (s *Box) PreStart() error {
@@ -497,6 +571,8 @@ func (s *Box) Close() error {
 	default:
 		close(s.done)
 	}
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
 	err := common.Close(
 		s.service, s.endpoint, s.inbound, s.outbound, s.router, s.connection, s.dnsRouter, s.dnsTransport, s.network,
 	)
@@ -526,3 +602,11 @@ func (s *Box) Inbound() adapter.InboundManager {
 func (s *Box) Outbound() adapter.OutboundManager {
 	return s.outbound
 }
+
+func logContextFields(ctx context.Context) (inbound string, outbound string) {
+	metadata := adapter.ContextFrom(ctx)
+	if metadata == nil {
+		return
+	}
+	return metadata.Inbound, metadata.Outbound
+}
@@ -0,0 +1,794 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+)
+
+var _ adapter.ConfigReloader = (*Box)(nil)
+
+// ErrReloadRequiresRestart is returned by Reload when a changed section can
+// only be applied by recreating the whole instance.
+var ErrReloadRequiresRestart = E.New("configuration change requires restart")
+
+type reloadComponent struct {
+	kind          string
+	tag           string
+	componentType string
+	content       []byte
+	create        func() error
+}
+
+type reloadDiff struct {
+	previous map[string]*reloadComponent
+	next     map[string]*reloadComponent
+	affected map[string]bool
+}
+
+func newReloadDiff(previous []*reloadComponent, next []*reloadComponent) *reloadDiff {
+	diff := &reloadDiff{
+		previous: make(map[string]*reloadComponent),
+		next:     make(map[string]*reloadComponent),
+		affected: make(map[string]bool),
+	}
+	for _, component := range previous {
+		diff.previous[component.tag] = component
+	}
+	for _, component := range next {
+		diff.next[component.tag] = component
+	}
+	for tag, component := range diff.previous {
+		nextComponent, loaded := diff.next[tag]
+		if !loaded || nextComponent.kind != component.kind || !bytes.Equal(nextComponent.content, component.content) {
+			diff.affected[tag] = true
+		}
+	}
+	return diff
+}
+
+// affect marks an unchanged component for recreation, returns whether it was
+// not marked before.
+func (d *reloadDiff) affect(tag string) bool {
+	if d.affected[tag] {
+		return false
+	}
+	if _, loaded := d.previous[tag]; !loaded {
+		return false
+	}
+	d.affected[tag] = true
+	return true
+}
+
+func (d *reloadDiff) affectedAny(tags []string) bool {
+	return common.Any(tags, func(it string) bool {
+		return d.affected[it]
+	})
+}
+
+// creating returns the components to create in configuration order: the
+// affected ones still present and the added ones.
+func (d *reloadDiff) creating(next []*reloadComponent) []*reloadComponent {
+	return common.Filter(next, func(it *reloadComponent) bool {
+		_, loaded := d.previous[it.tag]
+		return !loaded || d.affected[it.tag]
+	})
+}
+
+// restoring returns the previous components to create again when rolling
+// back: the affected ones, including the removed ones.
+func (d *reloadDiff) restoring(previous []*reloadComponent) []*reloadComponent {
+	return common.Filter(previous, func(it *reloadComponent) bool {
+		return d.affected[it.tag]
+	})
+}
+
+// added returns the tags that were not present before.
+func (d *reloadDiff) added() []string {
+	var tags []string
+	for tag := range d.next {
+		if _, loaded := d.previous[tag]; !loaded {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// removed returns the tags that are no longer present.
+func (d *reloadDiff) removed() []string {
+	var tags []string
+	for tag := range d.previous {
+		if _, loaded := d.next[tag]; !loaded {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (d *reloadDiff) changed() bool {
+	return len(d.affected) > 0 || len(d.previous) != len(d.next)
+}
+
+// reloadReferences returns the string values of key anywhere in the JSON
+// content, which is how detours and domain resolvers refer to other
+// components by tag.
+func reloadReferences(content []byte, key string) []string {
+	var value any
+	if json.Unmarshal(content, &value) != nil {
+		return nil
+	}
+	var references []string
+	var walk func(value any)
+	walk = func(value any) {
+		switch typedValue := value.(type) {
+		case map[string]any:
+			for name, child := range typedValue {
+				if name == key {
+					switch reference := child.(type) {
+					case string:
+						references = append(references, reference)
+						continue
+					case map[string]any:
+						if server, isString := reference["server"].(string); isString {
+							references = append(references, server)
+						}
+					}
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range typedValue {
+				walk(child)
+			}
+		}
+	}
+	walk(value)
+	return references
+}
+
+func reloadEqual(ctx context.Context, previous any, next any) (bool, error) {
+	previousContent, err := json.MarshalContext(ctx, previous)
+	if err != nil {
+		return false, err
+	}
+	nextContent, err := json.MarshalContext(ctx, next)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(previousContent, nextContent), nil
+}
+
+// checkRestart returns ErrReloadRequiresRestart if a section that is only
+// read when the instance is created has changed.
+func (s *Box) checkRestart(options option.Options) error {
+	previousRoute := common.PtrValueOrDefault(s.options.Route)
+	nextRoute := common.PtrValueOrDefault(options.Route)
+	previousRoute.Rules, previousRoute.RuleSet = nil, nil
+	nextRoute.Rules, nextRoute.RuleSet = nil, nil
+	previousDNS := common.PtrValueOrDefault(s.options.DNS)
+	nextDNS := common.PtrValueOrDefault(options.DNS)
+	previousDNS.Servers, previousDNS.Rules = nil, nil
+	nextDNS.Servers, nextDNS.Rules = nil, nil
+	sections := []struct {
+		name     string
+		previous any
+		next     any
+	}{
+		{"log", s.options.Log, options.Log},
+		{"ntp", s.options.NTP, options.NTP},
+		{"certificate", s.options.Certificate, options.Certificate},
+		{"experimental", s.options.Experimental, options.Experimental},
+		{"route", &previousRoute, &nextRoute},
+		{"dns", &previousDNS, &nextDNS},
+	}
+	for _, section := range sections {
+		equal, err := reloadEqual(s.ctx, section.previous, section.next)
+		if err != nil {
+			return E.Cause(err, "compare ", section.name)
+		}
+		if !equal {
+			return E.Extend(ErrReloadRequiresRestart, section.name)
+		}
+	}
+	return nil
+}
+
+func (s *Box) reloadComponents(options option.Options) (outbounds []*reloadComponent, dnsServers []*reloadComponent, inbounds []*reloadComponent, services []*reloadComponent, err error) {
+	for i := range options.Endpoints {
+		endpointOptions := options.Endpoints[i]
+		tag := endpointOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		component := &reloadComponent{
+			kind:          "endpoint",
+			tag:           tag,
+			componentType: endpointOptions.Type,
+			create: func() error {
+				return s.endpoint.Create(
+					adapter.WithContext(s.ctx, &adapter.InboundContext{
+						Outbound: tag,
+					}),
+					s.router,
+					s.logFactory.NewLogger(F.ToString("endpoint/", endpointOptions.Type, "[", tag, "]")),
+					tag,
+					endpointOptions.Type,
+					endpointOptions.Options,
+				)
+			},
+		}
+		contentOptions := endpointOptions
+		if contentOptions.Options == nil {
+			contentOptions.Options = struct{}{}
+		}
+		component.content, err = json.MarshalContext(s.ctx, &contentOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "marshal endpoint[", i, "]")
+		}
+		outbounds = append(outbounds, component)
+	}
+	for i := range options.Outbounds {
+		outboundOptions := options.Outbounds[i]
+		tag := outboundOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		component := &reloadComponent{
+			kind:          "outbound",
+			tag:           tag,
+			componentType: outboundOptions.Type,
+			create: func() error {
+				return s.outbound.Create(
+					adapter.WithContext(s.ctx, &adapter.InboundContext{
+						Outbound: tag,
+					}),
+					s.router,
+					s.logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
+					tag,
+					outboundOptions.Type,
+					outboundOptions.Options,
+				)
+			},
+		}
+		contentOptions := outboundOptions
+		if contentOptions.Options == nil {
+			contentOptions.Options = struct{}{}
+		}
+		component.content, err = json.MarshalContext(s.ctx, &contentOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "marshal outbound[", i, "]")
+		}
+		outbounds = append(outbounds, component)
+	}
+	dnsOptions := common.PtrValueOrDefault(options.DNS)
+	for i := range dnsOptions.Servers {
+		serverOptions := dnsOptions.Servers[i]
+		tag := serverOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		component := &reloadComponent{
+			kind:          "DNS server",
+			tag:           tag,
+			componentType: serverOptions.Type,
+			create: func() error {
+				return s.dnsTransport.Create(
+					s.ctx,
+					s.logFactory.NewLogger(F.ToString("dns/", serverOptions.Type, "[", tag, "]")),
+					tag,
+					serverOptions.Type,
+					serverOptions.Options,
+				)
+			},
+		}
+		contentOptions := serverOptions
+		if contentOptions.Options == nil {
+			contentOptions.Options = struct{}{}
+		}
+		component.content, err = json.MarshalContext(s.ctx, &contentOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "marshal DNS server[", i, "]")
+		}
+		dnsServers = append(dnsServers, component)
+	}
+	for i := range options.Inbounds {
+		inboundOptions := options.Inbounds[i]
+		tag := inboundOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		component := &reloadComponent{
+			kind:          "inbound",
+			tag:           tag,
+			componentType: inboundOptions.Type,
+			create: func() error {
+				return s.inbound.Create(
+					s.ctx,
+					s.router,
+					s.logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
+					tag,
+					inboundOptions.Type,
+					inboundOptions.Options,
+				)
+			},
+		}
+		contentOptions := inboundOptions
+		if contentOptions.Options == nil {
+			contentOptions.Options = struct{}{}
+		}
+		component.content, err = json.MarshalContext(s.ctx, &contentOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "marshal inbound[", i, "]")
+		}
+		inbounds = append(inbounds, component)
+	}
+	for i := range options.Services {
+		serviceOptions := options.Services[i]
+		tag := serviceOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		component := &reloadComponent{
+			kind:          "service",
+			tag:           tag,
+			componentType: serviceOptions.Type,
+			create: func() error {
+				return s.service.Create(
+					s.ctx,
+					s.logFactory.NewLogger(F.ToString("service/", serviceOptions.Type, "[", tag, "]")),
+					tag,
+					serviceOptions.Type,
+					serviceOptions.Options,
+				)
+			},
+		}
+		contentOptions := serviceOptions
+		if contentOptions.Options == nil {
+			contentOptions.Options = struct{}{}
+		}
+		component.content, err = json.MarshalContext(s.ctx, &contentOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "marshal service[", i, "]")
+		}
+		services = append(services, component)
+	}
+	return
+}
+
+// Reload applies options to the running instance. Inbounds, outbounds,
+// endpoints, DNS servers and services are compared by tag and only the
+// changed ones are removed, created or replaced, together with the
+// components that depend on a replaced outbound or DNS server. Rules and
+// rule-sets are swapped as a whole. Connections through components that are
+// kept are not interrupted.
+//
+// If a section that cannot be applied at runtime changed, an error wrapping
+// ErrReloadRequiresRestart is returned before anything is touched. If
+// applying the changes fails, the previous configuration is restored; if
+// that fails too, the error wraps ErrReloadRequiresRestart and so do all
+// later reloads.
+func (s *Box) Reload(options option.Options) error {
+	err := s.reload(options)
+	if err != nil {
+		adapter.EmitEvent(s.ctx, adapter.Event{
+			Type:    adapter.EventConfigReloadFailed,
+			Message: err.Error(),
+		})
+	}
+	return err
+}
+
+func (s *Box) reload(options option.Options) error {
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
+	select {
+	case <-s.done:
+		return E.New("service closed")
+	default:
+	}
+	if s.reloadBroken {
+		return E.Extend(ErrReloadRequiresRestart, "a previous reload could not be rolled back")
+	}
+	reloadAt := time.Now()
+	err := s.checkRestart(options)
+	if err != nil {
+		return err
+	}
+	previousOutbounds, previousDNSServers, previousInbounds, previousServices, err := s.reloadComponents(s.options)
+	if err != nil {
+		return err
+	}
+	nextOutbounds, nextDNSServers, nextInbounds, nextServices, err := s.reloadComponents(options)
+	if err != nil {
+		return err
+	}
+	outboundDiff := newReloadDiff(previousOutbounds, nextOutbounds)
+	dnsDiff := newReloadDiff(previousDNSServers, nextDNSServers)
+	inboundDiff := newReloadDiff(previousInbounds, nextInbounds)
+	serviceDiff := newReloadDiff(previousServices, nextServices)
+
+	// Outbounds and DNS servers resolve their detours, groups and domain
+	// resolvers once when started, so dependents of a replaced component are
+	// replaced too.
+	for {
+		var updated bool
+		for tag, component := range outboundDiff.previous {
+			if outboundDiff.affected[tag] {
+				continue
+			}
+			outbound, loaded := s.outbound.Outbound(tag)
+			if loaded && outboundDiff.affectedAny(outbound.Dependencies()) ||
+				dnsDiff.affectedAny(reloadReferences(component.content, "domain_resolver")) {
+				updated = outboundDiff.affect(tag) || updated
+			}
+		}
+		for tag, component := range dnsDiff.previous {
+			if dnsDiff.affected[tag] {
+				continue
+			}
+			transport, loaded := s.dnsTransport.Transport(tag)
+			if loaded && dnsDiff.affectedAny(transport.Dependencies()) ||
+				outboundDiff.affectedAny(reloadReferences(component.content, "detour")) {
+				updated = dnsDiff.affect(tag) || updated
+			}
+		}
+		if !updated {
+			break
+		}
+	}
+	for _, diff := range []*reloadDiff{inboundDiff, serviceDiff} {
+		for tag, component := range diff.previous {
+			if outboundDiff.affectedAny(reloadReferences(component.content, "detour")) ||
+				dnsDiff.affectedAny(reloadReferences(component.content, "domain_resolver")) {
+				diff.affect(tag)
+			}
+		}
+	}
+	routeOptions := common.PtrValueOrDefault(options.Route)
+	if routeOptions.DefaultDomainResolver != nil && dnsDiff.affected[routeOptions.DefaultDomainResolver.Server] {
+		return E.Extend(ErrReloadRequiresRestart, "default domain resolver")
+	}
+	for tag, component := range dnsDiff.previous {
+		if dnsDiff.affected[tag] && component.componentType == C.DNSTypeFakeIP {
+			return E.Extend(ErrReloadRequiresRestart, "fakeip server")
+		}
+	}
+	for _, component := range dnsDiff.creating(nextDNSServers) {
+		if component.componentType == C.DNSTypeFakeIP {
+			return E.Extend(ErrReloadRequiresRestart, "fakeip server")
+		}
+	}
+	previousRoute := common.PtrValueOrDefault(s.options.Route)
+	previousDNS := common.PtrValueOrDefault(s.options.DNS)
+	dnsOptions := common.PtrValueOrDefault(options.DNS)
+	rulesEqual, err := reloadEqual(s.ctx, []any{previousRoute.Rules, previousRoute.RuleSet, previousDNS.Rules}, []any{routeOptions.Rules, routeOptions.RuleSet, dnsOptions.Rules})
+	if err != nil {
+		return E.Cause(err, "compare rules")
+	}
+
+	plan := &reloadPlan{
+		previousOptions:    s.options,
+		options:            options,
+		outboundDiff:       outboundDiff,
+		dnsDiff:            dnsDiff,
+		inboundDiff:        inboundDiff,
+		serviceDiff:        serviceDiff,
+		previousOutbounds:  previousOutbounds,
+		previousDNSServers: previousDNSServers,
+		previousInbounds:   previousInbounds,
+		previousServices:   previousServices,
+		nextOutbounds:      nextOutbounds,
+		nextDNSServers:     nextDNSServers,
+		nextInbounds:       nextInbounds,
+		nextServices:       nextServices,
+		rulesEqual:         rulesEqual,
+	}
+	err = s.applyReload(plan)
+	if err != nil {
+		rollbackErr := s.rollbackReload(plan)
+		if rollbackErr != nil {
+			s.reloadBroken = true
+			return E.Extend(ErrReloadRequiresRestart, E.Errors(err, E.Cause(rollbackErr, "roll back")))
+		}
+		return E.Cause(err, "configuration rolled back")
+	}
+	s.options = options
+	var replaced, added, removed int
+	for _, diff := range []*reloadDiff{outboundDiff, dnsDiff, inboundDiff, serviceDiff} {
+		removedTags := len(diff.removed())
+		replaced += len(diff.affected) - removedTags
+		added += len(diff.next) - len(diff.previous) + removedTags
+		removed += removedTags
+	}
+	summary := F.ToString(replaced, " replaced, ", added, " added, ", removed, " removed")
+	s.logger.Info("configuration reloaded (", F.Seconds(time.Since(reloadAt).Seconds()), "s): ", summary)
+	adapter.EmitEvent(s.ctx, adapter.Event{
+		Type:    adapter.EventConfigReloaded,
+		Message: summary,
+	})
+	return nil
+}
+
+// reloadPlan is a reload that passed validation.
+type reloadPlan struct {
+	previousOptions    option.Options
+	options            option.Options
+	outboundDiff       *reloadDiff
+	dnsDiff            *reloadDiff
+	inboundDiff        *reloadDiff
+	serviceDiff        *reloadDiff
+	previousOutbounds  []*reloadComponent
+	previousDNSServers []*reloadComponent
+	previousInbounds   []*reloadComponent
+	previousServices   []*reloadComponent
+	nextOutbounds      []*reloadComponent
+	nextDNSServers     []*reloadComponent
+	nextInbounds       []*reloadComponent
+	nextServices       []*reloadComponent
+	rulesEqual         bool
+	rulesReloaded      bool
+}
+
+func (s *Box) outboundDependencies(tag string) []string {
+	outbound, loaded := s.outbound.Outbound(tag)
+	if !loaded {
+		return nil
+	}
+	return outbound.Dependencies()
+}
+
+func (s *Box) dnsDependencies(tag string) []string {
+	transport, loaded := s.dnsTransport.Transport(tag)
+	if !loaded {
+		return nil
+	}
+	return transport.Dependencies()
+}
+
+func (s *Box) applyReload(plan *reloadPlan) error {
+	outboundDiff, dnsDiff, inboundDiff, serviceDiff := plan.outboundDiff, plan.dnsDiff, plan.inboundDiff, plan.serviceDiff
+	// Inbounds, services and endpoints may listen on the same address after
+	// the change, so they are removed before being created again. Outbounds
+	// and DNS servers are replaced in place.
+	for tag := range inboundDiff.affected {
+		err := s.inbound.Remove(tag)
+		if err != nil {
+			return E.Cause(err, "remove inbound[", tag, "]")
+		}
+	}
+	for tag := range serviceDiff.affected {
+		err := s.service.Remove(tag)
+		if err != nil {
+			return E.Cause(err, "remove service[", tag, "]")
+		}
+	}
+	for tag := range outboundDiff.affected {
+		if outboundDiff.previous[tag].kind != "endpoint" {
+			continue
+		}
+		err := s.endpoint.Remove(tag)
+		if err != nil {
+			return E.Cause(err, "remove endpoint[", tag, "]")
+		}
+	}
+	err := createDependenciesFirst(outboundDiff.creating(plan.nextOutbounds), s.outboundDependencies)
+	if err != nil {
+		return err
+	}
+	err = removeDependentsFirst(outboundDiff.removed(), s.outboundDependencies, func(tag string) error {
+		if outboundDiff.previous[tag].kind == "endpoint" {
+			return nil
+		}
+		return s.outbound.Remove(tag)
+	})
+	if err != nil {
+		return E.Cause(err, "remove outbound")
+	}
+	err = createDependenciesFirst(dnsDiff.creating(plan.nextDNSServers), s.dnsDependencies)
+	if err != nil {
+		return err
+	}
+	err = removeDependentsFirst(dnsDiff.removed(), s.dnsDependencies, s.dnsTransport.Remove)
+	if err != nil {
+		return E.Cause(err, "remove DNS server")
+	}
+	for _, component := range inboundDiff.creating(plan.nextInbounds) {
+		err = component.create()
+		if err != nil {
+			return E.Cause(err, "create inbound[", component.tag, "]")
+		}
+	}
+	for _, component := range serviceDiff.creating(plan.nextServices) {
+		err = component.create()
+		if err != nil {
+			return E.Cause(err, "create service[", component.tag, "]")
+		}
+	}
+	routeOptions := common.PtrValueOrDefault(plan.options.Route)
+	dnsOptions := common.PtrValueOrDefault(plan.options.DNS)
+	if !plan.rulesEqual {
+		plan.rulesReloaded = true
+		return s.reloadRules(routeOptions, dnsOptions)
+	} else if dnsDiff.changed() {
+		err = s.dnsRouter.Reload(dnsOptions)
+		if err != nil {
+			return E.Cause(err, "reload DNS rules")
+		}
+	}
+	return nil
+}
+
+func (s *Box) reloadRules(routeOptions option.RouteOptions, dnsOptions option.DNSOptions) error {
+	previousRuleSets, err := s.router.Reload(routeOptions.Rules, routeOptions.RuleSet)
+	if err != nil {
+		return E.Cause(err, "reload rules")
+	}
+	err = s.dnsRouter.Reload(dnsOptions)
+	if err == nil {
+		err = s.router.Start(adapter.StartStateStarted)
+	}
+	for _, ruleSet := range previousRuleSets {
+		ruleSet.Close()
+	}
+	if err != nil {
+		return E.Cause(err, "reload DNS rules")
+	}
+	return nil
+}
+
+// rollbackReload restores the previous configuration after applyReload
+// failed part way: components created for the new configuration are removed
+// and the replaced and removed ones are created again from their previous
+// options.
+func (s *Box) rollbackReload(plan *reloadPlan) error {
+	outboundDiff, dnsDiff, inboundDiff, serviceDiff := plan.outboundDiff, plan.dnsDiff, plan.inboundDiff, plan.serviceDiff
+	var errors []error
+	// listeners of the new configuration are closed first, as the previous
+	// ones may need the same address
+	for _, component := range inboundDiff.creating(plan.nextInbounds) {
+		if _, loaded := s.inbound.Get(component.tag); loaded {
+			errors = append(errors, s.inbound.Remove(component.tag))
+		}
+	}
+	for _, component := range serviceDiff.creating(plan.nextServices) {
+		if _, loaded := s.service.Get(component.tag); loaded {
+			errors = append(errors, s.service.Remove(component.tag))
+		}
+	}
+	for _, component := range outboundDiff.creating(plan.nextOutbounds) {
+		if component.kind != "endpoint" {
+			continue
+		}
+		if _, loaded := s.endpoint.Get(component.tag); loaded {
+			errors = append(errors, s.endpoint.Remove(component.tag))
+		}
+	}
+	errors = append(errors, createDependenciesFirst(outboundDiff.restoring(plan.previousOutbounds), s.outboundDependencies))
+	errors = append(errors, removeDependentsFirst(outboundDiff.added(), s.outboundDependencies, func(tag string) error {
+		if _, loaded := s.outbound.Outbound(tag); !loaded || outboundDiff.next[tag].kind == "endpoint" {
+			return nil
+		}
+		return s.outbound.Remove(tag)
+	}))
+	errors = append(errors, createDependenciesFirst(dnsDiff.restoring(plan.previousDNSServers), s.dnsDependencies))
+	errors = append(errors, removeDependentsFirst(dnsDiff.added(), s.dnsDependencies, func(tag string) error {
+		if _, loaded := s.dnsTransport.Transport(tag); !loaded {
+			return nil
+		}
+		return s.dnsTransport.Remove(tag)
+	}))
+	for _, component := range inboundDiff.restoring(plan.previousInbounds) {
+		err := component.create()
+		if err != nil {
+			errors = append(errors, E.Cause(err, "create inbound[", component.tag, "]"))
+		}
+	}
+	for _, component := range serviceDiff.restoring(plan.previousServices) {
+		err := component.create()
+		if err != nil {
+			errors = append(errors, E.Cause(err, "create service[", component.tag, "]"))
+		}
+	}
+	routeOptions := common.PtrValueOrDefault(plan.previousOptions.Route)
+	dnsOptions := common.PtrValueOrDefault(plan.previousOptions.DNS)
+	if plan.rulesReloaded {
+		errors = append(errors, s.reloadRules(routeOptions, dnsOptions))
+	} else if dnsDiff.changed() {
+		err := s.dnsRouter.Reload(dnsOptions)
+		if err != nil {
+			errors = append(errors, E.Cause(err, "reload DNS rules"))
+		}
+	}
+	return E.Errors(errors...)
+}
+
+func (s *Box) ReloadFromSource() error {
+	if s.configLoader == nil {
+		return E.New("missing configuration source")
+	}
+	options, err := s.configLoader()
+	if err != nil {
+		return E.Cause(err, "load configuration")
+	}
+	return s.Reload(options)
+}
+
+// removeDependentsFirst removes tags so that no component is removed while
+// another pending one still depends on it.
+func removeDependentsFirst(tags []string, dependencies func(tag string) []string, remove func(tag string) error) error {
+	for len(tags) > 0 {
+		var pending []string
+		for _, tag := range tags {
+			if common.Any(tags, func(it string) bool {
+				return it != tag && common.Contains(dependencies(it), tag)
+			}) {
+				pending = append(pending, tag)
+				continue
+			}
+			err := remove(tag)
+			if err != nil {
+				return E.Cause(err, tag)
+			}
+		}
+		if len(pending) == len(tags) {
+			return E.New("circular dependency between ", strings.Join(pending, ", "))
+		}
+		tags = pending
+	}
+	return nil
+}
+
+// createDependenciesFirst creates components in order, retrying the ones
+// that fail to start while a detour or group member they reference is still
+// missing. A component created before one of its dependencies is created
+// again, since it may still hold the replaced instance. Without cycles both
+// settle within one pass per component each.
+func createDependenciesFirst(components []*reloadComponent, dependencies func(tag string) []string) error {
+	var sequence int
+	createdAt := make(map[string]int)
+	all := components
+	for pass := 0; len(components) > 0; pass++ {
+		if pass > 2*len(all) {
+			return E.New("circular dependency between ", strings.Join(common.Map(components, func(it *reloadComponent) string {
+				return it.kind + "[" + it.tag + "]"
+			}), ", "))
+		}
+		var (
+			pending []*reloadComponent
+			lastErr error
+		)
+		for _, component := range components {
+			err := component.create()
+			if err != nil {
+				lastErr = E.Cause(err, "create ", component.kind, "[", component.tag, "]")
+				pending = append(pending, component)
+				continue
+			}
+			sequence++
+			createdAt[component.tag] = sequence
+		}
+		if len(pending) == len(components) {
+			return lastErr
+		}
+		if len(pending) == 0 {
+			for _, component := range all {
+				if common.Any(dependencies(component.tag), func(it string) bool {
+					return createdAt[it] > createdAt[component.tag]
+				}) {
+					pending = append(pending, component)
+				}
+			}
+		}
+		components = pending
+	}
+	return nil
+}
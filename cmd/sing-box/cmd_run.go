@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/signal"
@@ -127,10 +128,10 @@ func readConfigAndMerge() (option.Options, error) {
 	return mergedOptions, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func loadOptions() (option.Options, error) {
 	options, err := readConfigAndMerge()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -138,10 +139,19 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := loadOptions()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
-		Context: ctx,
-		Options: options,
+		Context:      ctx,
+		Options:      options,
+		ConfigLoader: loadOptions,
 	})
 	if err != nil {
 		cancel()
@@ -184,6 +194,16 @@ func run() error {
 		for {
 			osSignal := <-osSignals
 			if osSignal == syscall.SIGHUP {
+				err = reload(instance)
+				if err == nil {
+					runtimeDebug.FreeOSMemory()
+					continue
+				}
+				if errors.Is(err, box.ErrReloadRequiresRestart) {
+					log.Info(err, ", restarting")
+				} else {
+					log.Error(E.Cause(err, "reload service"), ", restarting")
+				}
 				err = check()
 				if err != nil {
 					log.Error(E.Cause(err, "reload service"))
@@ -206,6 +226,14 @@ func run() error {
 	}
 }
 
+func reload(instance *box.Box) error {
+	options, err := loadOptions()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
+
 func closeMonitor(ctx context.Context) {
 	time.Sleep(C.FatalStopTimeout)
 	select {
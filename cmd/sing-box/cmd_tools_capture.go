@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sagernet/sing-box/common/pcapng"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commandCaptureFlagWrite         string
+	commandCaptureFlagController    string
+	commandCaptureFlagSecret        string
+	commandCaptureFlagDuration      time.Duration
+	commandCaptureFlagInbound       []string
+	commandCaptureFlagNetwork       []string
+	commandCaptureFlagDomain        []string
+	commandCaptureFlagDomainSuffix  []string
+	commandCaptureFlagDomainKeyword []string
+	commandCaptureFlagIPCIDR        []string
+	commandCaptureFlagPort          []string
+)
+
+var commandCapture = &cobra.Command{
+	Use:   "capture",
+	Short: "Capture routed traffic of a running instance to a pcapng file",
+	Long:  "Capture routed traffic of a running instance to a pcapng file through its Clash API.\nThe outbound flag selects connections passing through the outbound.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := capture()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandCapture.Flags().StringVarP(&commandCaptureFlagWrite, "write", "w", "capture.pcapng", "Output file, - for stdout")
+	commandCapture.Flags().StringVar(&commandCaptureFlagController, "controller", "", "Clash API address, read from configuration by default")
+	commandCapture.Flags().StringVar(&commandCaptureFlagSecret, "secret", "", "Clash API secret, read from configuration with the address")
+	commandCapture.Flags().DurationVar(&commandCaptureFlagDuration, "duration", 0, "Stop after duration, or on interrupt")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagInbound, "inbound", nil, "Match inbound tag")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagNetwork, "network", nil, "Match network (tcp/udp)")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagDomain, "domain", nil, "Match full domain")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagDomainSuffix, "domain-suffix", nil, "Match domain suffix")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagDomainKeyword, "domain-keyword", nil, "Match domain keyword")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagIPCIDR, "ip-cidr", nil, "Match destination IP CIDR")
+	commandCapture.Flags().StringSliceVar(&commandCaptureFlagPort, "port", nil, "Match destination port")
+	commandTools.AddCommand(commandCapture)
+}
+
+func capture() error {
+	controller, secret := commandCaptureFlagController, commandCaptureFlagSecret
+	if controller == "" {
+		options, err := readConfigAndMerge()
+		if err != nil {
+			return err
+		}
+		clashOptions := common.PtrValueOrDefault(common.PtrValueOrDefault(options.Experimental).ClashAPI)
+		controller = clashOptions.ExternalController
+		if secret == "" {
+			secret = clashOptions.Secret
+		}
+	}
+	if controller == "" {
+		return E.New("missing Clash API address in configuration")
+	}
+	host, port, err := net.SplitHostPort(controller)
+	if err != nil {
+		return E.Cause(err, "parse Clash API address")
+	}
+	if host == "" || net.ParseIP(host) != nil && net.ParseIP(host).IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	query := make(url.Values)
+	query["inbound"] = commandCaptureFlagInbound
+	query["network"] = commandCaptureFlagNetwork
+	query["domain"] = commandCaptureFlagDomain
+	query["domain_suffix"] = commandCaptureFlagDomainSuffix
+	query["domain_keyword"] = commandCaptureFlagDomainKeyword
+	query["ip_cidr"] = commandCaptureFlagIPCIDR
+	query["port"] = commandCaptureFlagPort
+	if commandToolsFlagOutbound != "" {
+		query.Set("outbound", commandToolsFlagOutbound)
+	}
+	if commandCaptureFlagDuration > 0 {
+		query.Set("duration", commandCaptureFlagDuration.String())
+	}
+	requestURL := url.URL{
+		Scheme:   "http",
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/capture",
+		RawQuery: query.Encode(),
+	}
+
+	ctx, cancel := signal.NotifyContext(globalCtx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if secret != "" {
+		request.Header.Set("Authorization", "Bearer "+secret)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return E.Cause(err, "start capture")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(response.Body).Decode(&apiError)
+		return E.New("start capture: ", response.Status, " ", apiError.Message)
+	}
+
+	var writer io.Writer
+	if commandCaptureFlagWrite == "-" {
+		writer = os.Stdout
+	} else {
+		file, err := os.Create(commandCaptureFlagWrite)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	log.Info("capturing, interrupt to stop")
+	packets, err := pcapng.CopyBlocks(writer, response.Body)
+	if err != nil && ctx.Err() == nil {
+		return E.Cause(err, "capture")
+	}
+	if commandCaptureFlagWrite != "-" {
+		log.Info("captured ", packets, " packets to ", commandCaptureFlagWrite)
+	}
+	return nil
+}
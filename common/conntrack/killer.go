@@ -2,18 +2,38 @@ package conntrack
 
 import (
 	runtimeDebug "runtime/debug"
+	"sync"
 	"time"
 
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/memory"
+	"github.com/sagernet/sing/common/x/list"
 )
 
+// KillerCallback is called after the killer closed all tracked connections
+// because memory usage exceeded the limit.
+type KillerCallback = func(usage uint64, closed int)
+
 var (
 	KillerEnabled   bool
 	MemoryLimit     uint64
 	killerLastCheck time.Time
+	killerAccess    sync.Mutex
+	killerCallbacks list.List[KillerCallback]
 )
 
+func RegisterKillerCallback(callback KillerCallback) *list.Element[KillerCallback] {
+	killerAccess.Lock()
+	defer killerAccess.Unlock()
+	return killerCallbacks.PushBack(callback)
+}
+
+func UnregisterKillerCallback(element *list.Element[KillerCallback]) {
+	killerAccess.Lock()
+	defer killerAccess.Unlock()
+	killerCallbacks.Remove(element)
+}
+
 func KillerCheck() error {
 	if !KillerEnabled {
 		return nil
@@ -23,12 +43,19 @@ func KillerCheck() error {
 		return nil
 	}
 	killerLastCheck = nowTime
-	if memory.Total() > MemoryLimit {
+	if usage := memory.Total(); usage > MemoryLimit {
+		closed := Count()
 		Close()
 		go func() {
 			time.Sleep(time.Second)
 			runtimeDebug.FreeOSMemory()
 		}()
+		killerAccess.Lock()
+		callbacks := killerCallbacks.Array()
+		killerAccess.Unlock()
+		for _, callback := range callbacks {
+			callback(usage, closed)
+		}
 		return E.New("out of memory")
 	}
 	return nil
@@ -348,6 +348,14 @@ func (d *DefaultDialer) ListenSerialInterfacePacket(ctx context.Context, destina
 	return trackPacketConn(packetConn, nil)
 }
 
+// ListenPacketAddr listens on a fixed address with the dialer's socket options
+// only, for binds that read plain datagrams.
+func (d *DefaultDialer) ListenPacketAddr(network, address string) (net.PacketConn, error) {
+	return trackPacketConn(listener.ListenNetworkNamespace[net.PacketConn](d.netns, func() (net.PacketConn, error) {
+		return d.udpListener.ListenPacket(context.Background(), network, address)
+	}))
+}
+
 func (d *DefaultDialer) ListenPacketCompat(network, address string) (net.PacketConn, error) {
 	udpListener := d.udpListener
 	udpListener.Control = control.Append(udpListener.Control, func(network, address string, conn syscall.RawConn) error {
@@ -25,14 +25,19 @@ type Options struct {
 	NewDialer        bool
 	LegacyDNSDialer  bool
 	DirectOutbound   bool
+	// Outbound marks dialers carrying the proxied connections of an outbound
+	// or endpoint, the only ones allowed to send a PROXY protocol header.
+	Outbound bool
 }
 
+// New creates the dialer of an outbound.
 // TODO: merge with NewWithOptions
 func New(ctx context.Context, options option.DialerOptions, remoteIsDomain bool) (N.Dialer, error) {
 	return NewWithOptions(Options{
 		Context:        ctx,
 		Options:        options,
 		RemoteIsDomain: remoteIsDomain,
+		Outbound:       true,
 	})
 }
 
@@ -54,6 +59,15 @@ func NewWithOptions(options Options) (N.Dialer, error) {
 			return nil, err
 		}
 	}
+	if dialOptions.ProxyProtocol != 0 {
+		if !options.Outbound {
+			return nil, E.New("proxy_protocol is only supported in outbound and endpoint dial fields")
+		}
+		dialer, err = NewProxyProtocol(dialer, dialOptions.ProxyProtocol)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if options.RemoteIsDomain && (dialOptions.Detour == "" || options.ResolverOnDetour || dialOptions.DomainResolver != nil && dialOptions.DomainResolver.Server != "") {
 		networkManager := service.FromContext[adapter.NetworkManager](options.Context)
 		dnsTransport := service.FromContext[adapter.DNSTransportManager](options.Context)
@@ -0,0 +1,162 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/proxyproto"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ N.Dialer                = (*proxyProtocolDialer)(nil)
+	_ ParallelInterfaceDialer = (*proxyProtocolParallelInterfaceDialer)(nil)
+)
+
+// proxyProtocolDialer sends a PROXY protocol header carrying the inbound
+// source of the routed connection ahead of the outbound stream, and with
+// version 2, ahead of every UDP datagram.
+type proxyProtocolDialer struct {
+	dialer  N.Dialer
+	version uint8
+}
+
+func NewProxyProtocol(dialer N.Dialer, version uint8) (N.Dialer, error) {
+	if version != 1 && version != 2 {
+		return nil, E.New("unknown PROXY protocol version: ", version)
+	}
+	proxyDialer := proxyProtocolDialer{
+		dialer:  dialer,
+		version: version,
+	}
+	if parallelDialer, isParallel := dialer.(ParallelInterfaceDialer); isParallel {
+		return &proxyProtocolParallelInterfaceDialer{proxyDialer, parallelDialer}, nil
+	}
+	return &proxyDialer, nil
+}
+
+func (d *proxyProtocolDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	return d.newConn(ctx, network, conn)
+}
+
+func (d *proxyProtocolDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	packetConn, err := d.dialer.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	return d.newPacketConn(ctx, packetConn), nil
+}
+
+func (d *proxyProtocolDialer) newConn(ctx context.Context, network string, conn net.Conn) (net.Conn, error) {
+	source, destination := proxyProtocolAddresses(ctx, M.SocksaddrFromNet(conn.RemoteAddr()))
+	if N.NetworkName(network) == N.NetworkUDP {
+		if d.version != 2 {
+			return conn, nil
+		}
+		return &proxyProtocolUDPConn{Conn: conn, header: proxyproto.EncodePacket(source, destination)}, nil
+	}
+	header, err := proxyproto.Encode(d.version, source, destination)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = conn.Write(header)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "write PROXY protocol header")
+	}
+	return conn, nil
+}
+
+func (d *proxyProtocolDialer) newPacketConn(ctx context.Context, packetConn net.PacketConn) net.PacketConn {
+	if d.version != 2 {
+		return packetConn
+	}
+	source, _ := proxyProtocolAddresses(ctx, M.Socksaddr{})
+	return &proxyProtocolPacketConn{PacketConn: packetConn, source: source}
+}
+
+func (d *proxyProtocolDialer) Upstream() any {
+	return d.dialer
+}
+
+type proxyProtocolParallelInterfaceDialer struct {
+	proxyProtocolDialer
+	parallelDialer ParallelInterfaceDialer
+}
+
+func (d *proxyProtocolParallelInterfaceDialer) DialParallelInterface(ctx context.Context, network string, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.Conn, error) {
+	conn, err := d.parallelDialer.DialParallelInterface(ctx, network, destination, strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	return d.newConn(ctx, network, conn)
+}
+
+func (d *proxyProtocolParallelInterfaceDialer) ListenSerialInterfacePacket(ctx context.Context, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.PacketConn, error) {
+	packetConn, err := d.parallelDialer.ListenSerialInterfacePacket(ctx, destination, strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	return d.newPacketConn(ctx, packetConn), nil
+}
+
+// proxyProtocolAddresses returns the addresses announced for a connection
+// dialed to remote: the inbound source, and the original destination if it is
+// an IP address or remote otherwise.
+func proxyProtocolAddresses(ctx context.Context, remote M.Socksaddr) (source M.Socksaddr, destination M.Socksaddr) {
+	destination = remote
+	metadata := adapter.ContextFrom(ctx)
+	if metadata == nil {
+		return
+	}
+	source = metadata.Source
+	if metadata.Destination.IsIP() {
+		destination = metadata.Destination
+	}
+	return
+}
+
+type proxyProtocolUDPConn struct {
+	net.Conn
+	header []byte
+}
+
+func (c *proxyProtocolUDPConn) Write(p []byte) (n int, err error) {
+	_, err = c.Conn.Write(append(append(make([]byte, 0, len(c.header)+len(p)), c.header...), p...))
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *proxyProtocolUDPConn) Upstream() any {
+	return c.Conn
+}
+
+type proxyProtocolPacketConn struct {
+	net.PacketConn
+	source M.Socksaddr
+}
+
+func (c *proxyProtocolPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	header := proxyproto.EncodePacket(c.source, M.SocksaddrFromNet(addr))
+	_, err = c.PacketConn.WriteTo(append(append(make([]byte, 0, len(header)+len(p)), header...), p...), addr)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *proxyProtocolPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,117 @@
+package dialer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/proxyproto"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type netDialer struct{}
+
+func (d netDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, destination.String())
+}
+
+func (d netDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return net.ListenPacket(N.NetworkUDP, "127.0.0.1:0")
+}
+
+func TestProxyProtocolDialer_TCP(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	dialer, err := NewProxyProtocol(netDialer{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, metadata := adapter.ExtendContext(context.Background())
+	metadata.Source = M.ParseSocksaddr("192.0.2.1:50000")
+	metadata.Destination = M.ParseSocksaddr("198.51.100.1:443")
+	conn, err := dialer.DialContext(ctx, N.NetworkTCP, M.SocksaddrFromNet(listener.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+	expected := "PROXY TCP4 192.0.2.1 198.51.100.1 50000 443\r\nhello"
+	received := make([]byte, len(expected))
+	_, err = io.ReadFull(serverConn, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != expected {
+		t.Fatalf("unexpected stream: %q", received)
+	}
+}
+
+func TestProxyProtocolDialer_UDP(t *testing.T) {
+	t.Parallel()
+	server, err := net.ListenPacket(N.NetworkUDP, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	serverAddr := M.SocksaddrFromNet(server.LocalAddr())
+	for _, version := range []uint8{1, 2} {
+		dialer, err := NewProxyProtocol(netDialer{}, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, metadata := adapter.ExtendContext(context.Background())
+		metadata.Source = M.ParseSocksaddr("192.0.2.1:50000")
+		packetConn, err := dialer.ListenPacket(ctx, serverAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = packetConn.WriteTo([]byte("hello"), serverAddr.UDPAddr())
+		packetConn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer := make([]byte, 1024)
+		n, _, err := server.ReadFrom(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []byte("hello")
+		if version == 2 {
+			expected = append(proxyproto.EncodePacket(metadata.Source, serverAddr), expected...)
+		}
+		if !bytes.Equal(buffer[:n], expected) {
+			t.Fatalf("unexpected datagram for version %d: %x", version, buffer[:n])
+		}
+	}
+}
+
+func TestProxyProtocolDialer_OutboundOnly(t *testing.T) {
+	t.Parallel()
+	options := option.DialerOptions{ProxyProtocol: 2}
+	_, err := NewWithOptions(Options{Context: context.Background(), Options: options, DirectResolver: true})
+	if err == nil {
+		t.Fatal("expected proxy_protocol to be rejected outside outbounds")
+	}
+	_, err = NewWithOptions(Options{Context: context.Background(), Options: options, Outbound: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
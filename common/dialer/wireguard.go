@@ -4,6 +4,7 @@ import (
 	"net"
 
 	"github.com/sagernet/sing/common/control"
+	N "github.com/sagernet/sing/common/network"
 )
 
 type WireGuardListener interface {
@@ -11,3 +12,27 @@ type WireGuardListener interface {
 }
 
 var WgControlFns []control.Func
+
+// PacketAddrListener listens on a fixed address with the socket options of
+// the dialer, it is implemented by the default dialer.
+type PacketAddrListener interface {
+	ListenPacketAddr(network, address string) (net.PacketConn, error)
+}
+
+// PacketAddrListenerOf returns the default dialer wrapped by dialer when only
+// domain resolving is in between. Other wrappers, such as detours and PROXY
+// protocol, change how packets are sent and are never bypassed.
+func PacketAddrListenerOf(dialer N.Dialer) (PacketAddrListener, bool) {
+	for {
+		switch current := dialer.(type) {
+		case PacketAddrListener:
+			return current, true
+		case *resolveDialer:
+			dialer = current.dialer
+		case *resolveParallelNetworkDialer:
+			dialer = current.dialer
+		default:
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package dialer
+
+import (
+	"net"
+	"testing"
+
+	N "github.com/sagernet/sing/common/network"
+)
+
+type addrListenerDialer struct {
+	netDialer
+}
+
+func (d addrListenerDialer) ListenPacketAddr(network, address string) (net.PacketConn, error) {
+	return net.ListenPacket(network, address)
+}
+
+func TestPacketAddrListenerOf(t *testing.T) {
+	t.Parallel()
+	var listener addrListenerDialer
+	resolved := &resolveDialer{dialer: listener}
+	if found, loaded := PacketAddrListenerOf(resolved); !loaded || found != PacketAddrListener(listener) {
+		t.Fatal("resolve dialer should be unwrapped")
+	}
+	proxyDialer, err := NewProxyProtocol(listener, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dialer := range []N.Dialer{proxyDialer, &resolveDialer{dialer: proxyDialer}} {
+		if _, loaded := PacketAddrListenerOf(dialer); loaded {
+			t.Fatal("PROXY protocol dialer should not be bypassed")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package fallback
+
+import (
+	"context"
+	"net"
+)
+
+const maxRecordSize = 64 * 1024
+
+// Conn records what a protocol server reads during its handshake, so that the
+// stream can be replayed to a fallback if the handshake fails.
+type Conn struct {
+	net.Conn
+	recorded  []byte
+	committed bool
+	overflow  bool
+}
+
+func (c *Conn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 && !c.committed && !c.overflow {
+		if len(c.recorded)+n > maxRecordSize {
+			c.recorded = nil
+			c.overflow = true
+		} else {
+			c.recorded = append(c.recorded, p[:n]...)
+		}
+	}
+	return
+}
+
+// Commit stops recording once the connection is accepted by the protocol.
+func (c *Conn) Commit() {
+	c.committed = true
+	c.recorded = nil
+}
+
+func (c *Conn) Upstream() any {
+	return c.Conn
+}
+
+func (c *Conn) ReaderReplaceable() bool {
+	return c.committed
+}
+
+func (c *Conn) WriterReplaceable() bool {
+	return c.committed
+}
+
+type connKey struct{}
+
+func ContextWithConn(ctx context.Context, conn *Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// Commit stops recording the fallback connection carried by ctx, if any.
+// Protocol handlers call it once a connection has been authenticated.
+func Commit(ctx context.Context) {
+	if conn, loaded := ctx.Value(connKey{}).(*Conn); loaded {
+		conn.Commit()
+	}
+}
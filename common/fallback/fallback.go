@@ -0,0 +1,192 @@
+package fallback
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/proxyproto"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const maxRequestLineSize = 8 * 1024
+
+// Handler forwards connections that failed the protocol handshake to the
+// first matching fallback, replaying everything already read from them.
+type Handler struct {
+	router    adapter.ConnectionRouterEx
+	logger    logger.ContextLogger
+	fallbacks []fallback
+	matchPath bool
+}
+
+type fallback struct {
+	destination   M.Socksaddr
+	inbound       string
+	serverName    []string
+	alpn          []string
+	path          string
+	proxyProtocol uint8
+}
+
+func NewHandler(router adapter.ConnectionRouterEx, logger logger.ContextLogger, options []option.V2RayFallbackOptions, tlsEnabled bool) (*Handler, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	handler := &Handler{
+		router: router,
+		logger: logger,
+	}
+	for i, fallbackOptions := range options {
+		item := fallback{
+			inbound:       fallbackOptions.Inbound,
+			serverName:    fallbackOptions.ServerName,
+			alpn:          fallbackOptions.ALPN,
+			path:          fallbackOptions.Path,
+			proxyProtocol: fallbackOptions.ProxyProtocol,
+		}
+		if item.inbound != "" {
+			if fallbackOptions.Server != "" {
+				return nil, E.New("fallback[", i, "]: server and inbound are mutually exclusive")
+			}
+			if item.proxyProtocol != 0 {
+				return nil, E.New("fallback[", i, "]: proxy_protocol is not supported with inbound")
+			}
+		} else {
+			item.destination = fallbackOptions.Build()
+			if !item.destination.IsValid() {
+				return nil, E.New("fallback[", i, "]: missing server or inbound")
+			}
+		}
+		if item.proxyProtocol > 2 {
+			return nil, E.New("fallback[", i, "]: unknown PROXY protocol version: ", item.proxyProtocol)
+		}
+		if !tlsEnabled && (len(item.serverName) > 0 || len(item.alpn) > 0) {
+			return nil, E.New("fallback[", i, "]: server_name and alpn require TLS")
+		}
+		if item.path != "" {
+			if !strings.HasPrefix(item.path, "/") {
+				return nil, E.New("fallback[", i, "]: path must start with /")
+			}
+			handler.matchPath = true
+		}
+		handler.fallbacks = append(handler.fallbacks, item)
+	}
+	return handler, nil
+}
+
+func (h *Handler) NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn}
+}
+
+// NewConnectionEx takes over conn if it can still be replayed, returning
+// false if the caller should handle the handshake error itself.
+func (h *Handler) NewConnectionEx(ctx context.Context, conn *Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) bool {
+	if conn.committed || conn.overflow || len(conn.recorded) == 0 {
+		return false
+	}
+	cached := conn.recorded
+	conn.recorded = nil
+	var serverName, nextProto string
+	if tlsConn, loaded := common.Cast[tls.Conn](conn.Conn); loaded {
+		connectionState := tlsConn.ConnectionState()
+		serverName = connectionState.ServerName
+		nextProto = connectionState.NegotiatedProtocol
+	}
+	var path string
+	if h.matchPath {
+		cached, path = readRequestPath(conn.Conn, cached)
+	}
+	var matched *fallback
+	for i := range h.fallbacks {
+		if h.fallbacks[i].match(serverName, nextProto, path) {
+			matched = &h.fallbacks[i]
+			break
+		}
+	}
+	if matched == nil {
+		h.logger.DebugContext(ctx, "process connection from ", metadata.Source, ": no fallback matched (server name: ", serverName, ", ALPN: ", nextProto, ", path: ", path, ")")
+		N.CloseOnHandshakeFailure(conn.Conn, onClose, os.ErrInvalid)
+		return true
+	}
+	if matched.proxyProtocol != 0 {
+		header, err := proxyproto.Encode(matched.proxyProtocol, metadata.Source, M.SocksaddrFromNet(conn.LocalAddr()))
+		if err != nil {
+			N.CloseOnHandshakeFailure(conn.Conn, onClose, err)
+			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": fallback"))
+			return true
+		}
+		cached = append(header, cached...)
+	}
+	replayConn := bufio.NewCachedConn(conn.Conn, buf.As(cached))
+	if matched.inbound != "" {
+		//nolint:staticcheck
+		metadata.InboundDetour = matched.inbound
+		h.logger.InfoContext(ctx, "fallback connection to inbound ", matched.inbound)
+	} else {
+		metadata.Destination = matched.destination
+		h.logger.InfoContext(ctx, "fallback connection to ", matched.destination)
+	}
+	h.router.RouteConnectionEx(ctx, replayConn, metadata, onClose)
+	return true
+}
+
+func (f *fallback) match(serverName string, nextProto string, path string) bool {
+	if len(f.serverName) > 0 && !common.Any(f.serverName, func(it string) bool {
+		return strings.EqualFold(it, serverName)
+	}) {
+		return false
+	}
+	if len(f.alpn) > 0 && !common.Contains(f.alpn, nextProto) {
+		return false
+	}
+	if f.path != "" && f.path != path {
+		return false
+	}
+	return true
+}
+
+// readRequestPath reads until the first line of an HTTP/1 request is
+// available and returns the request path without its query string.
+func readRequestPath(conn net.Conn, cached []byte) ([]byte, string) {
+	if bytes.IndexByte(cached, '\n') == -1 {
+		_ = conn.SetReadDeadline(time.Now().Add(C.ReadPayloadTimeout))
+		buffer := make([]byte, 4096)
+		for len(cached) < maxRequestLineSize && bytes.IndexByte(cached, '\n') == -1 {
+			n, err := conn.Read(buffer)
+			cached = append(cached, buffer[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	requestLine := cached
+	if index := bytes.IndexByte(requestLine, '\n'); index != -1 {
+		requestLine = requestLine[:index]
+	} else {
+		return cached, ""
+	}
+	fields := strings.Fields(string(requestLine))
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "HTTP/1.") || !strings.HasPrefix(fields[1], "/") {
+		return cached, ""
+	}
+	path := fields[1]
+	if index := strings.IndexByte(path, '?'); index != -1 {
+		path = path[:index]
+	}
+	return cached, path
+}
@@ -0,0 +1,187 @@
+package fallback
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type routedConnection struct {
+	conn     net.Conn
+	metadata adapter.InboundContext
+}
+
+type captureRouter struct {
+	routed chan routedConnection
+}
+
+func (r *captureRouter) RouteConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	r.routed <- routedConnection{conn, metadata}
+	return nil
+}
+
+func (r *captureRouter) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return nil
+}
+
+func (r *captureRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	r.routed <- routedConnection{conn, metadata}
+}
+
+func (r *captureRouter) RoutePacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+}
+
+func newConnPair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	return clientConn, serverConn
+}
+
+func TestHandler_MatchPathWithProxyProtocol(t *testing.T) {
+	t.Parallel()
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	handler, err := NewHandler(router, log.StdLogger(), []option.V2RayFallbackOptions{
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 8443}, Path: "/ws", ProxyProtocol: 1},
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 8080}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := newConnPair(t)
+	request := "GET /ws?ed=2048 HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	_, err = clientConn.Write([]byte(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := handler.NewConn(serverConn)
+	_, err = io.ReadFull(conn, make([]byte, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := M.SocksaddrFromNet(serverConn.RemoteAddr())
+	if !handler.NewConnectionEx(context.Background(), conn, adapter.InboundContext{Source: source}, nil) {
+		t.Fatal("fallback not taken")
+	}
+	routed := <-router.routed
+	if routed.metadata.Destination.Port != 8443 {
+		t.Fatalf("unexpected destination: %s", routed.metadata.Destination)
+	}
+	expected := "PROXY TCP4 127.0.0.1 127.0.0.1 " + F.ToString(source.Port) + " " + F.ToString(M.SocksaddrFromNet(serverConn.LocalAddr()).Port) + "\r\n" + request
+	received := make([]byte, len(expected))
+	_, err = io.ReadFull(routed.conn, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != expected {
+		t.Fatalf("unexpected replay: %q", received)
+	}
+}
+
+func TestHandler_DefaultAndInbound(t *testing.T) {
+	t.Parallel()
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	handler, err := NewHandler(router, log.StdLogger(), []option.V2RayFallbackOptions{
+		{Inbound: "trojan-in"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := newConnPair(t)
+	_, err = clientConn.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := handler.NewConn(serverConn)
+	_, err = io.ReadFull(conn, make([]byte, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !handler.NewConnectionEx(context.Background(), conn, adapter.InboundContext{}, nil) {
+		t.Fatal("fallback not taken")
+	}
+	routed := <-router.routed
+	//nolint:staticcheck
+	if routed.metadata.InboundDetour != "trojan-in" {
+		t.Fatalf("unexpected inbound detour: %s", routed.metadata.InboundDetour)
+	}
+	received := make([]byte, 5)
+	_, err = io.ReadFull(routed.conn, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != "hello" {
+		t.Fatalf("unexpected replay: %q", received)
+	}
+}
+
+func TestHandler_Committed(t *testing.T) {
+	t.Parallel()
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	handler, err := NewHandler(router, log.StdLogger(), []option.V2RayFallbackOptions{
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 8080}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := newConnPair(t)
+	_, err = clientConn.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := handler.NewConn(serverConn)
+	ctx := ContextWithConn(context.Background(), conn)
+	_, err = io.ReadFull(conn, make([]byte, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.ReaderReplaceable() || conn.WriterReplaceable() {
+		t.Fatal("recording connection must not be replaced")
+	}
+	Commit(ctx)
+	if !conn.ReaderReplaceable() || !conn.WriterReplaceable() {
+		t.Fatal("committed connection should be replaceable")
+	}
+	if handler.NewConnectionEx(ctx, conn, adapter.InboundContext{}, nil) {
+		t.Fatal("committed connection must not fall back")
+	}
+}
+
+func TestNewHandler_InvalidOptions(t *testing.T) {
+	t.Parallel()
+	for _, options := range []option.V2RayFallbackOptions{
+		{},
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 80}, Inbound: "in"},
+		{Inbound: "in", ProxyProtocol: 1},
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 80}, ProxyProtocol: 3},
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 80}, ALPN: []string{"h2"}},
+		{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 80}, Path: "ws"},
+	} {
+		_, err := NewHandler(nil, log.StdLogger(), []option.V2RayFallbackOptions{options}, false)
+		if err == nil {
+			t.Errorf("expected error for %+v", options)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	TCPFin = 0x01
+	TCPSyn = 0x02
+	TCPPsh = 0x08
+	TCPAck = 0x10
+)
+
+const (
+	protocolTCP = 6
+	protocolUDP = 17
+
+	ipv4HeaderLength = 20
+	ipv6HeaderLength = 40
+	tcpHeaderLength  = 20
+	udpHeaderLength  = 8
+)
+
+// MaxPayload is the largest payload that fits in a single synthesized packet
+// of either IP version.
+const MaxPayload = 0xFFFF - ipv6HeaderLength - tcpHeaderLength
+
+// TCPPacket synthesizes an IP packet carrying a TCP segment. Addresses of
+// different families are both written as IPv6.
+func TCPPacket(source netip.AddrPort, destination netip.AddrPort, seq uint32, ack uint32, flags uint8, payload []byte) []byte {
+	segment := make([]byte, tcpHeaderLength, tcpHeaderLength+len(payload))
+	binary.BigEndian.PutUint16(segment[0:], source.Port())
+	binary.BigEndian.PutUint16(segment[2:], destination.Port())
+	binary.BigEndian.PutUint32(segment[4:], seq)
+	binary.BigEndian.PutUint32(segment[8:], ack)
+	segment[12] = tcpHeaderLength / 4 << 4
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:], 0xFFFF)
+	segment = append(segment, payload...)
+	return ipPacket(source.Addr(), destination.Addr(), protocolTCP, segment, 16)
+}
+
+// UDPPacket synthesizes an IP packet carrying a UDP datagram.
+func UDPPacket(source netip.AddrPort, destination netip.AddrPort, payload []byte) []byte {
+	datagram := make([]byte, udpHeaderLength, udpHeaderLength+len(payload))
+	binary.BigEndian.PutUint16(datagram[0:], source.Port())
+	binary.BigEndian.PutUint16(datagram[2:], destination.Port())
+	binary.BigEndian.PutUint16(datagram[4:], uint16(len(datagram)+len(payload)))
+	datagram = append(datagram, payload...)
+	return ipPacket(source.Addr(), destination.Addr(), protocolUDP, datagram, 6)
+}
+
+func ipPacket(source netip.Addr, destination netip.Addr, protocol uint8, transport []byte, checksumOffset int) []byte {
+	source, destination = addressPair(source, destination)
+	var packet []byte
+	if source.Is4() {
+		packet = make([]byte, ipv4HeaderLength, ipv4HeaderLength+len(transport))
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:], uint16(ipv4HeaderLength+len(transport)))
+		binary.BigEndian.PutUint16(packet[6:], 0x4000)
+		packet[8] = 64
+		packet[9] = protocol
+		copy(packet[12:], source.AsSlice())
+		copy(packet[16:], destination.AsSlice())
+		binary.BigEndian.PutUint16(packet[10:], ^fold(sumBytes(0, packet)))
+	} else {
+		packet = make([]byte, ipv6HeaderLength, ipv6HeaderLength+len(transport))
+		packet[0] = 0x60
+		binary.BigEndian.PutUint16(packet[4:], uint16(len(transport)))
+		packet[6] = protocol
+		packet[7] = 64
+		copy(packet[8:], source.AsSlice())
+		copy(packet[24:], destination.AsSlice())
+	}
+	sum := sumBytes(0, source.AsSlice())
+	sum = sumBytes(sum, destination.AsSlice())
+	sum += uint32(protocol) + uint32(len(transport))
+	transportChecksum := ^fold(sumBytes(sum, transport))
+	if transportChecksum == 0 && protocol == protocolUDP {
+		transportChecksum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(transport[checksumOffset:], transportChecksum)
+	return append(packet, transport...)
+}
+
+// addressPair unmaps both addresses, and maps them back to IPv6 if only one
+// of them is IPv4. Invalid addresses become unspecified ones.
+func addressPair(source netip.Addr, destination netip.Addr) (netip.Addr, netip.Addr) {
+	source = source.Unmap()
+	destination = destination.Unmap()
+	switch {
+	case !source.IsValid() && !destination.IsValid():
+		return netip.IPv4Unspecified(), netip.IPv4Unspecified()
+	case !source.IsValid():
+		source = unspecified(destination)
+	case !destination.IsValid():
+		destination = unspecified(source)
+	}
+	if source.Is4() != destination.Is4() {
+		source = netip.AddrFrom16(source.As16())
+		destination = netip.AddrFrom16(destination.As16())
+	}
+	return source, destination
+}
+
+func unspecified(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.IPv4Unspecified()
+	}
+	return netip.IPv6Unspecified()
+}
+
+func sumBytes(sum uint32, data []byte) uint32 {
+	for len(data) >= 2 {
+		sum += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		sum += uint32(data[0]) << 8
+	}
+	return sum
+}
+
+func fold(sum uint32) uint16 {
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return uint16(sum)
+}
@@ -0,0 +1,46 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPPacket(t *testing.T) {
+	t.Parallel()
+	source := netip.MustParseAddrPort("192.168.1.10:50000")
+	destination := netip.MustParseAddrPort("1.1.1.1:443")
+	packet := TCPPacket(source, destination, 1, 2, TCPPsh|TCPAck, []byte("hello"))
+	require.Len(t, packet, ipv4HeaderLength+tcpHeaderLength+5)
+	require.Equal(t, uint16(0xFFFF), fold(sumBytes(0, packet[:ipv4HeaderLength])))
+	sum := sumBytes(0, packet[12:20])
+	sum += protocolTCP + uint32(len(packet)-ipv4HeaderLength)
+	require.Equal(t, uint16(0xFFFF), fold(sumBytes(sum, packet[ipv4HeaderLength:])))
+	require.Equal(t, []byte("hello"), packet[ipv4HeaderLength+tcpHeaderLength:])
+
+	packet = UDPPacket(source, netip.MustParseAddrPort("[2001:db8::1]:53"), []byte("query"))
+	require.Equal(t, byte(0x60), packet[0])
+	require.Equal(t, netip.MustParseAddr("::ffff:192.168.1.10"), netip.AddrFrom16([16]byte(packet[8:24])))
+	require.Equal(t, uint16(udpHeaderLength+5), binary.BigEndian.Uint16(packet[ipv6HeaderLength+4:]))
+}
+
+func TestCopyBlocks(t *testing.T) {
+	t.Parallel()
+	var stream bytes.Buffer
+	require.NoError(t, WriteHeader(&stream, "test", "test"))
+	packet := UDPPacket(netip.MustParseAddrPort("10.0.0.1:1000"), netip.MustParseAddrPort("10.0.0.2:53"), []byte("abc"))
+	stream.Write(AppendPacket(nil, time.Now(), packet, "connection 1"))
+	stream.Write(AppendPacket(nil, time.Now(), packet, ""))
+	complete := stream.Len()
+	stream.Write(AppendPacket(nil, time.Now(), packet, "")[:20])
+
+	var output bytes.Buffer
+	packets, err := CopyBlocks(&output, bytes.NewReader(stream.Bytes()))
+	require.Error(t, err)
+	require.Equal(t, 2, packets)
+	require.Equal(t, stream.Bytes()[:complete], output.Bytes())
+}
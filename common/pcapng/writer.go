@@ -0,0 +1,131 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// LinkTypeRaw is the link type of packets beginning with an IPv4 or IPv6
+// header, without any link layer header.
+const LinkTypeRaw = 101
+
+const (
+	blockTypeSectionHeader    = 0x0A0D0D0A
+	blockTypeInterface        = 0x00000001
+	blockTypeEnhancedPacket   = 0x00000006
+	byteOrderMagic            = 0x1A2B3C4D
+	optionEndOfOptions        = 0
+	optionComment             = 1
+	optionInterfaceName       = 2
+	optionSectionApplication  = 4
+	optionTimestampResolution = 9
+)
+
+// WriteHeader writes the section header and the description of the only
+// interface, which carries raw IP packets with nanosecond timestamps.
+func WriteHeader(writer io.Writer, application string, interfaceName string) error {
+	var options []byte
+	options = appendOption(options, optionSectionApplication, []byte(application))
+	options = appendOption(options, optionEndOfOptions, nil)
+	body := make([]byte, 16, 16+len(options))
+	binary.LittleEndian.PutUint32(body[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:], 1)
+	binary.LittleEndian.PutUint16(body[6:], 0)
+	// Section length is unknown for a stream.
+	binary.LittleEndian.PutUint64(body[8:], 0xFFFFFFFFFFFFFFFF)
+	body = append(body, options...)
+	_, err := writer.Write(appendBlock(nil, blockTypeSectionHeader, body))
+	if err != nil {
+		return err
+	}
+	options = options[:0]
+	options = appendOption(options, optionInterfaceName, []byte(interfaceName))
+	options = appendOption(options, optionTimestampResolution, []byte{9})
+	options = appendOption(options, optionEndOfOptions, nil)
+	body = make([]byte, 8, 8+len(options))
+	binary.LittleEndian.PutUint16(body[0:], LinkTypeRaw)
+	binary.LittleEndian.PutUint32(body[4:], 0)
+	body = append(body, options...)
+	_, err = writer.Write(appendBlock(nil, blockTypeInterface, body))
+	return err
+}
+
+// AppendPacket appends an enhanced packet block of the interface written by
+// WriteHeader, with an optional comment.
+func AppendPacket(buffer []byte, timestamp time.Time, packet []byte, comment string) []byte {
+	var options []byte
+	if comment != "" {
+		options = appendOption(options, optionComment, []byte(comment))
+		options = appendOption(options, optionEndOfOptions, nil)
+	}
+	body := make([]byte, 20, 20+len(packet)+3+len(options))
+	nanoseconds := uint64(timestamp.UnixNano())
+	binary.LittleEndian.PutUint32(body[0:], 0)
+	binary.LittleEndian.PutUint32(body[4:], uint32(nanoseconds>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(nanoseconds))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(packet)))
+	body = append(body, packet...)
+	body = appendPadding(body)
+	body = append(body, options...)
+	return appendBlock(buffer, blockTypeEnhancedPacket, body)
+}
+
+func appendBlock(buffer []byte, blockType uint32, body []byte) []byte {
+	totalLength := uint32(12 + len(body))
+	buffer = binary.LittleEndian.AppendUint32(buffer, blockType)
+	buffer = binary.LittleEndian.AppendUint32(buffer, totalLength)
+	buffer = append(buffer, body...)
+	return binary.LittleEndian.AppendUint32(buffer, totalLength)
+}
+
+func appendOption(buffer []byte, code uint16, value []byte) []byte {
+	buffer = binary.LittleEndian.AppendUint16(buffer, code)
+	buffer = binary.LittleEndian.AppendUint16(buffer, uint16(len(value)))
+	buffer = append(buffer, value...)
+	return appendPadding(buffer)
+}
+
+func appendPadding(buffer []byte) []byte {
+	for len(buffer)%4 != 0 {
+		buffer = append(buffer, 0)
+	}
+	return buffer
+}
+
+// CopyBlocks copies whole blocks from reader to writer until reader ends, so
+// that an interrupted stream still leaves a valid file. It returns the number
+// of packets copied.
+func CopyBlocks(writer io.Writer, reader io.Reader) (packets int, err error) {
+	header := make([]byte, 8)
+	var block []byte
+	for {
+		_, err = io.ReadFull(reader, header)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		totalLength := binary.LittleEndian.Uint32(header[4:])
+		if totalLength < 12 || totalLength%4 != 0 {
+			return packets, E.New("invalid block length: ", totalLength)
+		}
+		block = append(block[:0], header...)
+		block = append(block, make([]byte, totalLength-8)...)
+		_, err = io.ReadFull(reader, block[8:])
+		if err != nil {
+			return
+		}
+		_, err = writer.Write(block)
+		if err != nil {
+			return
+		}
+		if binary.LittleEndian.Uint32(header) == blockTypeEnhancedPacket {
+			packets++
+		}
+	}
+}
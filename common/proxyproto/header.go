@@ -0,0 +1,108 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	v2CommandLocal = 0x20
+	v2CommandProxy = 0x21
+
+	v2FamilyUnspec = 0x00
+	v2FamilyTCP4   = 0x11
+	v2FamilyUDP4   = 0x12
+	v2FamilyTCP6   = 0x21
+	v2FamilyUDP6   = 0x22
+)
+
+// Encode builds a PROXY protocol header of the given version (1 or 2)
+// announcing a TCP connection from source to destination. Non-IP addresses
+// produce an UNKNOWN (v1) or LOCAL (v2) header.
+func Encode(version uint8, source M.Socksaddr, destination M.Socksaddr) ([]byte, error) {
+	switch version {
+	case 1:
+		return encodeV1(source, destination), nil
+	case 2:
+		return encodeV2(source, destination, false), nil
+	default:
+		return nil, E.New("unknown PROXY protocol version: ", version)
+	}
+}
+
+func encodeV1(source M.Socksaddr, destination M.Socksaddr) []byte {
+	sourceAddr, destinationAddr, loaded := addressPair(source, destination)
+	if !loaded {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	header := []byte("PROXY ")
+	if sourceAddr.Is4() {
+		header = append(header, "TCP4 "...)
+	} else {
+		header = append(header, "TCP6 "...)
+	}
+	header = sourceAddr.AppendTo(header)
+	header = append(header, ' ')
+	header = destinationAddr.AppendTo(header)
+	header = append(header, ' ')
+	header = strconv.AppendUint(header, uint64(source.Port), 10)
+	header = append(header, ' ')
+	header = strconv.AppendUint(header, uint64(destination.Port), 10)
+	return append(header, '\r', '\n')
+}
+
+// EncodePacket builds a version 2 header announcing a UDP datagram from
+// source to destination, to be prepended to every datagram.
+func EncodePacket(source M.Socksaddr, destination M.Socksaddr) []byte {
+	return encodeV2(source, destination, true)
+}
+
+func encodeV2(source M.Socksaddr, destination M.Socksaddr, isPacket bool) []byte {
+	header := append([]byte(nil), v2Signature...)
+	sourceAddr, destinationAddr, loaded := addressPair(source, destination)
+	if !loaded {
+		header = append(header, v2CommandLocal, v2FamilyUnspec)
+		return binary.BigEndian.AppendUint16(header, 0)
+	}
+	header = append(header, v2CommandProxy)
+	switch {
+	case sourceAddr.Is4() && isPacket:
+		header = append(header, v2FamilyUDP4)
+	case sourceAddr.Is4():
+		header = append(header, v2FamilyTCP4)
+	case isPacket:
+		header = append(header, v2FamilyUDP6)
+	default:
+		header = append(header, v2FamilyTCP6)
+	}
+	if sourceAddr.Is4() {
+		header = binary.BigEndian.AppendUint16(header, 4+4+2+2)
+	} else {
+		header = binary.BigEndian.AppendUint16(header, 16+16+2+2)
+	}
+	header = append(header, sourceAddr.AsSlice()...)
+	header = append(header, destinationAddr.AsSlice()...)
+	header = binary.BigEndian.AppendUint16(header, source.Port)
+	return binary.BigEndian.AppendUint16(header, destination.Port)
+}
+
+// addressPair returns both addresses in the same family, mapping IPv4 into
+// IPv6 when the two sides differ.
+func addressPair(source M.Socksaddr, destination M.Socksaddr) (netip.Addr, netip.Addr, bool) {
+	if !source.IsIP() || !destination.IsIP() {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+	sourceAddr := source.Addr.Unmap()
+	destinationAddr := destination.Addr.Unmap()
+	if sourceAddr.Is4() != destinationAddr.Is4() {
+		sourceAddr = netip.AddrFrom16(sourceAddr.As16())
+		destinationAddr = netip.AddrFrom16(destinationAddr.As16())
+	}
+	return sourceAddr, destinationAddr, true
+}
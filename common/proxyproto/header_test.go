@@ -0,0 +1,70 @@
+package proxyproto
+
+import (
+	"bytes"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func TestEncodeV1(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		source      string
+		destination string
+		expected    string
+	}{
+		{"192.0.2.1:50000", "198.51.100.1:443", "PROXY TCP4 192.0.2.1 198.51.100.1 50000 443\r\n"},
+		{"[2001:db8::1]:50000", "[2001:db8::2]:443", "PROXY TCP6 2001:db8::1 2001:db8::2 50000 443\r\n"},
+		{"192.0.2.1:50000", "[2001:db8::2]:443", "PROXY TCP6 ::ffff:192.0.2.1 2001:db8::2 50000 443\r\n"},
+		{"192.0.2.1:50000", "example.com:443", "PROXY UNKNOWN\r\n"},
+	} {
+		header, err := Encode(1, M.ParseSocksaddr(testCase.source), M.ParseSocksaddr(testCase.destination))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(header) != testCase.expected {
+			t.Errorf("unexpected header for %s -> %s: %q", testCase.source, testCase.destination, header)
+		}
+	}
+}
+
+func TestEncodeV2(t *testing.T) {
+	t.Parallel()
+	header, err := Encode(2, M.ParseSocksaddr("192.0.2.1:50000"), M.ParseSocksaddr("198.51.100.1:443"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append([]byte(nil), v2Signature...),
+		0x21, 0x11, 0x00, 0x0C,
+		192, 0, 2, 1,
+		198, 51, 100, 1,
+		0xC3, 0x50,
+		0x01, 0xBB,
+	)
+	if !bytes.Equal(header, expected) {
+		t.Fatalf("unexpected header: %x", header)
+	}
+	header, err = Encode(2, M.ParseSocksaddr("192.0.2.1:50000"), M.ParseSocksaddr("example.com:443"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(header[len(v2Signature):], []byte{0x20, 0x00, 0x00, 0x00}) {
+		t.Fatalf("unexpected local header: %x", header)
+	}
+	_, err = Encode(3, M.Socksaddr{}, M.Socksaddr{})
+	if err == nil {
+		t.Fatal("expected error for unknown version")
+	}
+}
+
+func TestEncodePacket(t *testing.T) {
+	t.Parallel()
+	header := EncodePacket(M.ParseSocksaddr("[2001:db8::1]:50000"), M.ParseSocksaddr("[2001:db8::2]:53"))
+	if header[len(v2Signature)] != 0x21 || header[len(v2Signature)+1] != 0x22 {
+		t.Fatalf("unexpected command or family: %x", header[len(v2Signature):len(v2Signature)+2])
+	}
+	if len(header) != len(v2Signature)+4+36 {
+		t.Fatalf("unexpected header length: %d", len(header))
+	}
+}
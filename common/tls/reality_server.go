@@ -111,7 +111,11 @@ func NewRealityServer(ctx context.Context, logger log.Logger, options option.Inb
 		}
 	}
 
-	handshakeDialer, err := dialer.New(ctx, options.Reality.Handshake.DialerOptions, options.Reality.Handshake.ServerIsDomain())
+	handshakeDialer, err := dialer.NewWithOptions(dialer.Options{
+		Context:        ctx,
+		Options:        options.Reality.Handshake.DialerOptions,
+		RemoteIsDomain: options.Reality.Handshake.ServerIsDomain(),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +23,7 @@ type HistoryStorage struct {
 	access       sync.RWMutex
 	delayHistory map[string]*adapter.URLTestHistory
 	updateHook   chan<- struct{}
+	observer     func(tag string, history *adapter.URLTestHistory)
 }
 
 func NewHistoryStorage() *HistoryStorage {
@@ -35,6 +36,14 @@ func (s *HistoryStorage) SetHook(hook chan<- struct{}) {
 	s.updateHook = hook
 }
 
+// SetObserver sets a function called after every change with the new
+// history of the tag, or nil if the history was deleted.
+func (s *HistoryStorage) SetObserver(observer func(tag string, history *adapter.URLTestHistory)) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.observer = observer
+}
+
 func (s *HistoryStorage) LoadURLTestHistory(tag string) *adapter.URLTestHistory {
 	if s == nil {
 		return nil
@@ -48,14 +57,22 @@ func (s *HistoryStorage) DeleteURLTestHistory(tag string) {
 	s.access.Lock()
 	delete(s.delayHistory, tag)
 	s.notifyUpdated()
+	observer := s.observer
 	s.access.Unlock()
+	if observer != nil {
+		observer(tag, nil)
+	}
 }
 
 func (s *HistoryStorage) StoreURLTestHistory(tag string, history *adapter.URLTestHistory) {
 	s.access.Lock()
 	s.delayHistory[tag] = history
 	s.notifyUpdated()
+	observer := s.observer
 	s.access.Unlock()
+	if observer != nil {
+		observer(tag, history)
+	}
 }
 
 func (s *HistoryStorage) notifyUpdated() {
@@ -72,6 +89,7 @@ func (s *HistoryStorage) Close() error {
 	s.access.Lock()
 	defer s.access.Unlock()
 	s.updateHook = nil
+	s.observer = nil
 	return nil
 }
 
@@ -131,3 +149,52 @@ func URLTest(ctx context.Context, link string, detour N.Dialer) (t uint16, err e
 	t = uint16(time.Since(start) / time.Millisecond)
 	return
 }
+
+// UDPTest sends request to destination through detour's packet connection
+// and measures the time until a response accepted by check arrives.
+func UDPTest(ctx context.Context, detour N.Dialer, destination M.Socksaddr, request []byte, check func(response []byte) error) (t uint16, err error) {
+	start := time.Now()
+	instance, err := detour.ListenPacket(ctx, destination)
+	if err != nil {
+		return
+	}
+	defer instance.Close()
+	deadline, loaded := ctx.Deadline()
+	if !loaded {
+		deadline = time.Now().Add(C.TCPTimeout)
+	}
+	err = instance.SetReadDeadline(deadline)
+	if err != nil {
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			instance.Close()
+		case <-done:
+		}
+	}()
+	_, err = instance.WriteTo(request, destination)
+	if err != nil {
+		return
+	}
+	response := make([]byte, 4096)
+	for {
+		var n int
+		n, _, err = instance.ReadFrom(response)
+		if err != nil {
+			return
+		}
+		err = check(response[:n])
+		if err == nil {
+			break
+		}
+	}
+	t = uint16(time.Since(start) / time.Millisecond)
+	if t == 0 {
+		t = 1
+	}
+	return
+}
@@ -28,6 +28,8 @@ const (
 	TypeDERP         = "derp"
 	TypeResolved     = "resolved"
 	TypeSSMAPI       = "ssm-api"
+	TypeReverse      = "reverse"
+	TypePortal       = "portal"
 )
 
 const (
@@ -35,6 +37,8 @@ const (
 	TypeURLTest     = "urltest"
 	TypeFallback    = "fallback"
 	TypeLoadBalance = "load-balance"
+	TypeRelay       = "relay"
+	TypeSmart       = "smart"
 )
 
 func ProxyDisplayName(proxyType string) string {
@@ -85,6 +89,10 @@ func ProxyDisplayName(proxyType string) string {
 		return "Hysteria2"
 	case TypeAnyTLS:
 		return "AnyTLS"
+	case TypeReverse:
+		return "Reverse"
+	case TypePortal:
+		return "Portal"
 	case TypeSelector:
 		return "Selector"
 	case TypeURLTest:
@@ -93,6 +101,10 @@ func ProxyDisplayName(proxyType string) string {
 		return "Fallback"
 	case TypeLoadBalance:
 		return "LoadBalance"
+	case TypeRelay:
+		return "Relay"
+	case TypeSmart:
+		return "Smart"
 	default:
 		return "Unknown"
 	}
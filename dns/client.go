@@ -46,6 +46,7 @@ type Client struct {
 	cacheLock               compatible.Map[dns.Question, chan struct{}]
 	transportCache          freelru.Cache[transportCacheKey, *dns.Msg]
 	transportCacheLock      compatible.Map[transportCacheKey, chan struct{}]
+	trackers                []adapter.DNSQueryTracker
 }
 
 type ClientOptions struct {
@@ -94,6 +95,10 @@ type transportCacheKey struct {
 	clientSubnet netip.Prefix
 }
 
+func (c *Client) AppendTracker(tracker adapter.DNSQueryTracker) {
+	c.trackers = append(c.trackers, tracker)
+}
+
 func (c *Client) Start() {
 	if c.initRDRCFunc != nil {
 		c.rdrc = c.initRDRCFunc()
@@ -247,6 +252,9 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 			}
 		}
 		response, ttl := c.loadResponse(question, transport, cacheClientSubnet)
+		for _, tracker := range c.trackers {
+			tracker.CacheLookup(transport, response != nil)
+		}
 		if response != nil {
 			logCachedResponse(c.logger, ctx, response, ttl)
 			response.Id = message.Id
@@ -270,6 +278,7 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
 		ctx, cancel = context.WithTimeout(ctx, c.timeout)
 	}
+	exchangeStart := time.Now()
 	response, err := transport.Exchange(ctx, message)
 	if cancel != nil {
 		cancel()
@@ -278,10 +287,15 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 		var rcodeError RcodeError
 		if errors.As(err, &rcodeError) {
 			response = FixedResponseStatus(message, int(rcodeError))
-		} else {
-			return nil, err
+			err = nil
 		}
 	}
+	for _, tracker := range c.trackers {
+		tracker.Exchanged(transport, response, time.Since(exchangeStart), err)
+	}
+	if err != nil {
+		return nil, err
+	}
 	/*if question.Qtype == dns.TypeA || question.Qtype == dns.TypeAAAA {
 		validResponse := response
 	loop:
@@ -442,6 +456,79 @@ func (c *Client) ClearCache() {
 	}
 }
 
+func (c *Client) CacheEntries() []adapter.DNSCacheEntry {
+	var entries []adapter.DNSCacheEntry
+	c.rangeCache(func(entry adapter.DNSCacheEntry, remove func() bool) {
+		entry.Response = entry.Response.Copy()
+		entries = append(entries, entry)
+	})
+	return entries
+}
+
+func (c *Client) RemoveCache(match func(entry adapter.DNSCacheEntry) bool) int {
+	var removed int
+	c.rangeCache(func(entry adapter.DNSCacheEntry, remove func() bool) {
+		if match(entry) && remove() {
+			removed++
+		}
+	})
+	return removed
+}
+
+func (c *Client) IndependentCache() bool {
+	return c.independentCache
+}
+
+// rangeCache calls block with every unexpired entry of the cache in use and
+// a function removing it.
+func (c *Client) rangeCache(block func(entry adapter.DNSCacheEntry, remove func() bool)) {
+	timeNow := time.Now()
+	newEntry := func(question dns.Question, response *dns.Msg, expireAt time.Time) (adapter.DNSCacheEntry, bool) {
+		entry := adapter.DNSCacheEntry{
+			Question: question,
+			Response: response,
+		}
+		if !c.disableExpire {
+			if timeNow.After(expireAt) {
+				return entry, false
+			}
+			entry.ExpireAt = expireAt
+		}
+		return entry, true
+	}
+	if c.cache != nil {
+		for _, question := range c.cache.Keys() {
+			response, expireAt, loaded := c.cache.PeekWithLifetime(question)
+			if !loaded {
+				continue
+			}
+			entry, valid := newEntry(question, response, expireAt)
+			if !valid {
+				continue
+			}
+			block(entry, func() bool {
+				return c.cache.Remove(question)
+			})
+		}
+	} else if c.transportCache != nil {
+		for _, key := range c.transportCache.Keys() {
+			response, expireAt, loaded := c.transportCache.PeekWithLifetime(key)
+			if !loaded {
+				continue
+			}
+			entry, valid := newEntry(key.Question, response, expireAt)
+			if !valid {
+				continue
+			}
+			entry.Transport = key.transportTag
+			entry.ClientSubnet = key.clientSubnet
+			block(entry, func() bool {
+				return c.transportCache.Remove(key)
+			})
+		}
+	}
+}
+
 func sortAddresses(response4 []netip.Addr, response6 []netip.Addr, strategy C.DomainStrategy) []netip.Addr {
 	if strategy == C.DomainStrategyPreferIPv6 {
 		return append(response6, response4...)
@@ -493,6 +580,9 @@ func (c *Client) lookupToExchange(ctx context.Context, transport adapter.DNSTran
 	if !disableCache {
 		cachedAddresses, err := c.questionCache(question, transport, cacheClientSubnet)
 		if err != ErrNotCached {
+			for _, tracker := range c.trackers {
+				tracker.CacheLookup(transport, true)
+			}
 			return cachedAddresses, err
 		}
 	}
@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTransport struct {
+	adapter.DNSTransport
+	tag string
+}
+
+func (t *stubTransport) Tag() string {
+	return t.tag
+}
+
+func (t *stubTransport) Exchange(ctx context.Context, message *dns.Msg) (*dns.Msg, error) {
+	response := new(dns.Msg)
+	response.SetReply(message)
+	question := message.Question[0]
+	header := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: 300}
+	switch question.Qtype {
+	case dns.TypeA:
+		response.Answer = append(response.Answer, &dns.A{Hdr: header, A: netip.MustParseAddr("192.0.2.1").AsSlice()})
+	case dns.TypeAAAA:
+		response.Answer = append(response.Answer, &dns.AAAA{Hdr: header, AAAA: netip.MustParseAddr("2001:db8::1").AsSlice()})
+	}
+	return response, nil
+}
+
+func exchangeTestQuery(t *testing.T, client *Client, transport adapter.DNSTransport, name string, qType uint16) {
+	message := new(dns.Msg)
+	message.SetQuestion(name, qType)
+	_, err := client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+}
+
+func TestClientCacheEntries(t *testing.T) {
+	t.Parallel()
+	client := NewClient(ClientOptions{})
+	require.False(t, client.IndependentCache())
+	transport := &stubTransport{tag: "remote"}
+	exchangeTestQuery(t, client, transport, "www.example.com.", dns.TypeA)
+	exchangeTestQuery(t, client, transport, "www.example.com.", dns.TypeAAAA)
+
+	entries := client.CacheEntries()
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		require.Equal(t, "www.example.com.", entry.Question.Name)
+		require.Empty(t, entry.Transport)
+		require.False(t, entry.ExpireAt.IsZero())
+		require.Len(t, entry.Response.Answer, 1)
+		entry.Response.Answer = nil
+	}
+	// Entries are copies and changing them leaves the cache alone.
+	for _, entry := range client.CacheEntries() {
+		require.Len(t, entry.Response.Answer, 1)
+	}
+}
+
+func TestClientCacheEntriesIndependent(t *testing.T) {
+	t.Parallel()
+	client := NewClient(ClientOptions{IndependentCache: true, DisableExpire: true})
+	require.True(t, client.IndependentCache())
+	exchangeTestQuery(t, client, &stubTransport{tag: "local"}, "www.example.com.", dns.TypeA)
+	exchangeTestQuery(t, client, &stubTransport{tag: "remote"}, "www.example.com.", dns.TypeA)
+
+	entries := client.CacheEntries()
+	require.Len(t, entries, 2)
+	require.ElementsMatch(t, []string{"local", "remote"}, []string{entries[0].Transport, entries[1].Transport})
+	for _, entry := range entries {
+		require.True(t, entry.ExpireAt.IsZero())
+	}
+}
+
+func TestClientRemoveCache(t *testing.T) {
+	t.Parallel()
+	client := NewClient(ClientOptions{IndependentCache: true})
+	local, remote := &stubTransport{tag: "local"}, &stubTransport{tag: "remote"}
+	exchangeTestQuery(t, client, local, "www.example.com.", dns.TypeA)
+	exchangeTestQuery(t, client, remote, "www.example.com.", dns.TypeA)
+	exchangeTestQuery(t, client, remote, "www.example.com.", dns.TypeAAAA)
+	exchangeTestQuery(t, client, remote, "www.example.org.", dns.TypeA)
+
+	removed := client.RemoveCache(func(entry adapter.DNSCacheEntry) bool {
+		return entry.Question.Name == "www.example.com." && entry.Transport == "remote"
+	})
+	require.Equal(t, 2, removed)
+	entries := client.CacheEntries()
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		require.False(t, entry.Question.Name == "www.example.com." && entry.Transport == "remote")
+	}
+
+	require.Zero(t, client.RemoveCache(func(entry adapter.DNSCacheEntry) bool {
+		return false
+	}))
+	require.Equal(t, 2, client.RemoveCache(func(entry adapter.DNSCacheEntry) bool {
+		return true
+	}))
+	require.Empty(t, client.CacheEntries())
+}
+
+func TestClientRemoveCacheDisabled(t *testing.T) {
+	t.Parallel()
+	client := NewClient(ClientOptions{DisableCache: true})
+	exchangeTestQuery(t, client, &stubTransport{tag: "remote"}, "www.example.com.", dns.TypeA)
+	require.Empty(t, client.CacheEntries())
+	require.Zero(t, client.RemoveCache(func(entry adapter.DNSCacheEntry) bool {
+		return true
+	}))
+}
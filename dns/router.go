@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -35,6 +36,7 @@ type Router struct {
 	transport             adapter.DNSTransportManager
 	outbound              adapter.OutboundManager
 	client                adapter.DNSClient
+	access                sync.RWMutex
 	rules                 []adapter.DNSRule
 	defaultDomainStrategy C.DomainStrategy
 	upstreamTimeout       time.Duration
@@ -77,27 +79,31 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.DNSOp
 		},
 		Logger: router.logger,
 	})
-	if len(options.Servers) > 0 {
-		for i, serverOptions := range options.Servers {
-			if serverOptions.ClientSubnetFromInbound == nil {
-				continue
-			}
-			tag := serverOptions.Tag
-			if tag == "" {
-				tag = F.ToString(i)
-			}
-			if router.serverClientSubnetFromInbound == nil {
-				router.serverClientSubnetFromInbound = make(map[string]*option.ClientSubnetFromInboundOptions)
-			}
-			router.serverClientSubnetFromInbound[tag] = serverOptions.ClientSubnetFromInbound
-		}
-	}
+	router.serverClientSubnetFromInbound = newServerClientSubnetFromInbound(options.Servers)
 	if options.ReverseMapping {
 		router.dnsReverseMapping = common.Must1(freelru.NewSharded[netip.Addr, string](1024, maphash.NewHasher[netip.Addr]().Hash32))
 	}
 	return router
 }
 
+func newServerClientSubnetFromInbound(servers []option.DNSServerOptions) map[string]*option.ClientSubnetFromInboundOptions {
+	var serverClientSubnetFromInbound map[string]*option.ClientSubnetFromInboundOptions
+	for i, serverOptions := range servers {
+		if serverOptions.ClientSubnetFromInbound == nil {
+			continue
+		}
+		tag := serverOptions.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		if serverClientSubnetFromInbound == nil {
+			serverClientSubnetFromInbound = make(map[string]*option.ClientSubnetFromInboundOptions)
+		}
+		serverClientSubnetFromInbound[tag] = serverOptions.ClientSubnetFromInbound
+	}
+	return serverClientSubnetFromInbound
+}
+
 func (r *Router) Initialize(rules []option.DNSRule) error {
 	for i, ruleOptions := range rules {
 		dnsRule, err := R.NewDNSRule(r.ctx, r.logger, ruleOptions, true)
@@ -109,6 +115,42 @@ func (r *Router) Initialize(rules []option.DNSRule) error {
 	return nil
 }
 
+// Reload replaces the rules and the per-server client subnet options of a
+// started router. The new rules are started before they are swapped in.
+func (r *Router) Reload(options option.DNSOptions) error {
+	newRules := make([]adapter.DNSRule, 0, len(options.Rules))
+	closeRules := func() {
+		for _, rule := range newRules {
+			rule.Close()
+		}
+	}
+	for i, ruleOptions := range options.Rules {
+		dnsRule, err := R.NewDNSRule(r.ctx, r.logger, ruleOptions, true)
+		if err != nil {
+			closeRules()
+			return E.Cause(err, "parse dns rule[", i, "]")
+		}
+		newRules = append(newRules, dnsRule)
+		err = dnsRule.Start()
+		if err != nil {
+			closeRules()
+			return E.Cause(err, "initialize DNS rule[", i, "]")
+		}
+	}
+	r.access.Lock()
+	previousRules := r.rules
+	r.rules = newRules
+	r.serverClientSubnetFromInbound = newServerClientSubnetFromInbound(options.Servers)
+	r.access.Unlock()
+	for i, rule := range previousRules {
+		err := rule.Close()
+		if err != nil {
+			r.logger.Warn(E.Cause(err, "close previous dns rule[", i, "]"))
+		}
+	}
+	return nil
+}
+
 func (r *Router) Start(stage adapter.StartStage) error {
 	monitor := taskmonitor.New(r.logger, C.StartTimeout)
 	switch stage {
@@ -151,8 +193,11 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 	if ruleIndex != -1 {
 		currentRuleIndex = ruleIndex + 1
 	}
-	for ; currentRuleIndex < len(r.rules); currentRuleIndex++ {
-		currentRule := r.rules[currentRuleIndex]
+	r.access.RLock()
+	rules := r.rules
+	r.access.RUnlock()
+	for ; currentRuleIndex < len(rules); currentRuleIndex++ {
+		currentRule := rules[currentRuleIndex]
 		if currentRule.WithAddressLimit() && !isAddressQuery {
 			continue
 		}
@@ -303,10 +348,13 @@ func (r *Router) applyServerQueryOptions(transport adapter.DNSTransport, options
 	if options.ClientSubnetFromInbound != nil {
 		return options
 	}
-	if r.serverClientSubnetFromInbound == nil {
+	r.access.RLock()
+	serverClientSubnetFromInbound := r.serverClientSubnetFromInbound
+	r.access.RUnlock()
+	if serverClientSubnetFromInbound == nil {
 		return options
 	}
-	fromInbound := r.serverClientSubnetFromInbound[transport.Tag()]
+	fromInbound := serverClientSubnetFromInbound[transport.Tag()]
 	if fromInbound != nil {
 		options.ClientSubnetFromInbound = fromInbound
 	}
@@ -343,8 +391,13 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg, options adapte
 		metadata.IPVersion = 6
 	}
 	metadata.Domain = FqdnToDomain(message.Question[0].Name)
+	trace := adapter.DNSQueryTraceFromContext(ctx)
 	if options.Transport != nil {
 		selectedTransport = options.Transport
+		if trace != nil {
+			trace.RuleIndex = -1
+			trace.Servers = []string{selectedTransport.Tag()}
+		}
 		if legacyTransport, isLegacy := selectedTransport.(adapter.LegacyDNSTransport); isLegacy {
 			if options.Strategy == C.DomainStrategyAsIS {
 				options.Strategy = legacyTransport.LegacyStrategy()
@@ -383,6 +436,11 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg, options adapte
 				fallbackGrace      time.Duration
 			)
 			transports, fallbackTransports, upstreamTimeout, fallbackTimeout, fallbackGrace, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex, isAddressQuery(message), &dnsOptions)
+			if trace != nil {
+				trace.Rule = rule
+				trace.RuleIndex = ruleIndex
+				trace.Servers = common.Map(transports, adapter.DNSTransport.Tag)
+			}
 			if rule != nil {
 				switch action := rule.Action().(type) {
 				case *R.RuleActionReject:
@@ -1092,6 +1150,10 @@ func isAddressQuery(message *mDNS.Msg) bool {
 	return false
 }
 
+func (r *Router) AppendTracker(tracker adapter.DNSQueryTracker) {
+	r.client.AppendTracker(tracker)
+}
+
 func (r *Router) ClearCache() {
 	r.client.ClearCache()
 	if r.platformInterface != nil {
@@ -1099,6 +1161,18 @@ func (r *Router) ClearCache() {
 	}
 }
 
+func (r *Router) CacheEntries() []adapter.DNSCacheEntry {
+	return r.client.CacheEntries()
+}
+
+func (r *Router) RemoveCache(match func(entry adapter.DNSCacheEntry) bool) int {
+	return r.client.RemoveCache(match)
+}
+
+func (r *Router) IndependentCache() bool {
+	return r.client.IndependentCache()
+}
+
 func (r *Router) LookupReverseMapping(ip netip.Addr) (string, bool) {
 	if r.dnsReverseMapping == nil {
 		return "", false
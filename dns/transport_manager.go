@@ -204,7 +204,7 @@ func (m *TransportManager) Remove(tag string) error {
 	if m.defaultTransport == transport {
 		if len(m.transports) > 0 {
 			nextTransport := m.transports[0]
-			if nextTransport.Type() != C.DNSTypeFakeIP {
+			if nextTransport.Type() == C.DNSTypeFakeIP {
 				return E.New("default server cannot be fakeip")
 			}
 			m.defaultTransport = nextTransport
@@ -251,6 +251,7 @@ func (m *TransportManager) Create(ctx context.Context, logger log.ContextLogger,
 			}
 		}
 	}
+	var replacedDefault bool
 	if existsTransport, loaded := m.transportByTag[tag]; loaded {
 		if m.started {
 			err = common.Close(existsTransport)
@@ -265,6 +266,12 @@ func (m *TransportManager) Create(ctx context.Context, logger log.ContextLogger,
 			panic("invalid inbound index")
 		}
 		m.transports = append(m.transports[:existsIndex], m.transports[existsIndex+1:]...)
+		for _, dependency := range existsTransport.Dependencies() {
+			m.dependByTag[dependency] = common.Filter(m.dependByTag[dependency], func(it string) bool {
+				return it != tag
+			})
+		}
+		replacedDefault = m.defaultTransport == existsTransport
 	}
 	m.transports = append(m.transports, transport)
 	m.transportByTag[tag] = transport
@@ -272,7 +279,7 @@ func (m *TransportManager) Create(ctx context.Context, logger log.ContextLogger,
 	for _, dependency := range dependencies {
 		m.dependByTag[dependency] = append(m.dependByTag[dependency], tag)
 	}
-	if tag == m.defaultTag || (m.defaultTag == "" && m.defaultTransport == nil) {
+	if tag == m.defaultTag || replacedDefault || (m.defaultTag == "" && m.defaultTransport == nil) {
 		if transport.Type() == C.DNSTypeFakeIP {
 			return E.New("default server cannot be fakeip")
 		}
@@ -0,0 +1,141 @@
+package accesslog
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ net.Conn     = (*trackedConn)(nil)
+	_ N.PacketConn = (*trackedPacketConn)(nil)
+)
+
+// connState counts the traffic of a connection and keeps the first error
+// seen on it as the close reason.
+type connState struct {
+	service   *Service
+	record    *Record
+	upload    atomic.Int64
+	download  atomic.Int64
+	access    sync.Mutex
+	err       error
+	errLoaded bool
+	closeOnce sync.Once
+}
+
+func (s *connState) setError(err error) {
+	if err == nil {
+		return
+	}
+	s.access.Lock()
+	if !s.errLoaded {
+		s.err = err
+		s.errLoaded = true
+	}
+	s.access.Unlock()
+}
+
+func (s *connState) finish() {
+	s.closeOnce.Do(func() {
+		s.access.Lock()
+		err := s.err
+		s.access.Unlock()
+		s.record.End = time.Now()
+		s.record.Upload = s.upload.Load()
+		s.record.Download = s.download.Load()
+		s.record.CloseReason = closeReason(err)
+		s.service.write(s.record)
+	})
+}
+
+type trackedConn struct {
+	N.ExtendedConn
+	state *connState
+}
+
+func (c *trackedConn) Read(p []byte) (n int, err error) {
+	n, err = c.ExtendedConn.Read(p)
+	c.state.upload.Add(int64(n))
+	c.state.setError(err)
+	return
+}
+
+func (c *trackedConn) ReadBuffer(buffer *buf.Buffer) error {
+	err := c.ExtendedConn.ReadBuffer(buffer)
+	if err != nil {
+		c.state.setError(err)
+		return err
+	}
+	c.state.upload.Add(int64(buffer.Len()))
+	return nil
+}
+
+func (c *trackedConn) Write(p []byte) (n int, err error) {
+	n, err = c.ExtendedConn.Write(p)
+	c.state.download.Add(int64(n))
+	c.state.setError(err)
+	return
+}
+
+func (c *trackedConn) WriteBuffer(buffer *buf.Buffer) error {
+	dataLen := int64(buffer.Len())
+	err := c.ExtendedConn.WriteBuffer(buffer)
+	if err != nil {
+		c.state.setError(err)
+		return err
+	}
+	c.state.download.Add(dataLen)
+	return nil
+}
+
+func (c *trackedConn) Close() error {
+	err := c.ExtendedConn.Close()
+	c.state.finish()
+	return err
+}
+
+func (c *trackedConn) Upstream() any {
+	return c.ExtendedConn
+}
+
+type trackedPacketConn struct {
+	N.PacketConn
+	state *connState
+}
+
+func (c *trackedPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err != nil {
+		c.state.setError(err)
+		return
+	}
+	c.state.upload.Add(int64(buffer.Len()))
+	return
+}
+
+func (c *trackedPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	dataLen := int64(buffer.Len())
+	err := c.PacketConn.WritePacket(buffer, destination)
+	if err != nil {
+		c.state.setError(err)
+		return err
+	}
+	c.state.download.Add(dataLen)
+	return nil
+}
+
+func (c *trackedPacketConn) Close() error {
+	err := c.PacketConn.Close()
+	c.state.finish()
+	return err
+}
+
+func (c *trackedPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,127 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+const (
+	ReasonEOF     = "eof"
+	ReasonClosed  = "closed"
+	ReasonTimeout = "timeout"
+)
+
+// Record describes a finished connection.
+type Record struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Network           string    `json:"network"`
+	InboundType       string    `json:"inbound_type"`
+	Inbound           string    `json:"inbound,omitempty"`
+	User              string    `json:"user,omitempty"`
+	Source            string    `json:"source"`
+	DestinationDomain string    `json:"destination_domain,omitempty"`
+	DestinationIP     string    `json:"destination_ip,omitempty"`
+	DestinationPort   uint16    `json:"destination_port"`
+	Protocol          string    `json:"protocol,omitempty"`
+	Rule              string    `json:"rule"`
+	Chain             []string  `json:"chain"`
+	Upload            int64     `json:"upload"`
+	Download          int64     `json:"download"`
+	CloseReason       string    `json:"close_reason"`
+}
+
+func newRecord(metadata adapter.InboundContext, matchedRule adapter.Rule, chain []string) *Record {
+	record := &Record{
+		Start:           time.Now(),
+		Network:         metadata.Network,
+		InboundType:     metadata.InboundType,
+		Inbound:         metadata.Inbound,
+		User:            metadata.User,
+		Source:          metadata.Source.String(),
+		DestinationPort: metadata.Destination.Port,
+		Protocol:        metadata.Protocol,
+		Chain:           chain,
+	}
+	if metadata.Domain != "" {
+		record.DestinationDomain = metadata.Domain
+	} else {
+		record.DestinationDomain = metadata.Destination.Fqdn
+	}
+	if metadata.Destination.IsIP() {
+		record.DestinationIP = metadata.Destination.Addr.String()
+	} else if len(metadata.DestinationAddresses) > 0 {
+		record.DestinationIP = metadata.DestinationAddresses[0].String()
+	}
+	if matchedRule != nil {
+		record.Rule = F.ToString(matchedRule, " => ", matchedRule.Action())
+	} else {
+		record.Rule = "final"
+	}
+	return record
+}
+
+func closeReason(err error) string {
+	switch {
+	case err == nil:
+		return ReasonClosed
+	case errors.Is(err, io.EOF):
+		return ReasonEOF
+	case E.IsTimeout(err):
+		return ReasonTimeout
+	case E.IsClosedOrCanceled(err):
+		return ReasonClosed
+	default:
+		return err.Error()
+	}
+}
+
+func (r *Record) encode(format string) ([]byte, error) {
+	if format == FormatCSV {
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		err := writer.Write([]string{
+			r.Start.Format(time.RFC3339Nano),
+			r.End.Format(time.RFC3339Nano),
+			r.Network,
+			r.InboundType,
+			r.Inbound,
+			r.User,
+			r.Source,
+			r.DestinationDomain,
+			r.DestinationIP,
+			strconv.Itoa(int(r.DestinationPort)),
+			r.Protocol,
+			r.Rule,
+			strings.Join(r.Chain, " > "),
+			strconv.FormatInt(r.Upload, 10),
+			strconv.FormatInt(r.Download, 10),
+			r.CloseReason,
+		})
+		if err != nil {
+			return nil, err
+		}
+		writer.Flush()
+		return buffer.Bytes(), writer.Error()
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
@@ -0,0 +1,165 @@
+package accesslog
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+var (
+	_ adapter.LifecycleService  = (*Service)(nil)
+	_ adapter.ConnectionTracker = (*Service)(nil)
+)
+
+// Service writes one record per closed connection to a file and/or syslog.
+type Service struct {
+	ctx      context.Context
+	logger   log.Logger
+	outbound adapter.OutboundManager
+	options  option.AccessLogOptions
+	format   string
+	rotate   *log.RotateOptions
+	access   sync.Mutex
+	writers  []io.Writer
+	closers  []io.Closer
+}
+
+func NewService(ctx context.Context, logger log.Logger, options option.AccessLogOptions) (*Service, error) {
+	if options.Output == "" && options.Syslog == nil {
+		return nil, E.New("missing output or syslog")
+	}
+	s := &Service{
+		ctx:      ctx,
+		logger:   logger,
+		outbound: service.FromContext[adapter.OutboundManager](ctx),
+		options:  options,
+	}
+	switch options.Format {
+	case "", FormatJSON:
+		s.format = FormatJSON
+	case FormatCSV:
+		s.format = FormatCSV
+	default:
+		return nil, E.New("unknown access log format: ", options.Format)
+	}
+	if options.Rotation != nil {
+		if options.Output == "" {
+			return nil, E.New("rotation requires output")
+		}
+		s.rotate = &log.RotateOptions{
+			Interval:   time.Duration(options.Rotation.Interval),
+			MaxBackups: options.Rotation.MaxBackups,
+			MaxAge:     time.Duration(options.Rotation.MaxAge),
+		}
+		if options.Rotation.MaxSize != nil {
+			s.rotate.MaxSize = int64(options.Rotation.MaxSize.Value())
+		}
+	}
+	return s, nil
+}
+
+func (s *Service) Name() string {
+	return "access log"
+}
+
+func (s *Service) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateInitialize {
+		return nil
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.options.Output != "" {
+		if s.rotate != nil {
+			file, err := log.OpenRotateFile(s.ctx, s.options.Output, *s.rotate)
+			if err != nil {
+				return E.Cause(err, "open access log")
+			}
+			s.writers = append(s.writers, file)
+			s.closers = append(s.closers, file)
+		} else {
+			file, err := filemanager.OpenFile(s.ctx, s.options.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				return E.Cause(err, "open access log")
+			}
+			s.writers = append(s.writers, file)
+			s.closers = append(s.closers, file)
+		}
+	}
+	if s.options.Syslog != nil {
+		writer, err := dialSyslog(*s.options.Syslog)
+		if err != nil {
+			return E.Cause(err, "dial syslog")
+		}
+		s.writers = append(s.writers, writer)
+		s.closers = append(s.closers, writer)
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	closers := s.closers
+	s.writers = nil
+	s.closers = nil
+	return common.Close(common.Map(closers, func(it io.Closer) any {
+		return it
+	})...)
+}
+
+func (s *Service) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	return &trackedConn{
+		ExtendedConn: bufio.NewExtendedConn(conn),
+		state:        s.newState(metadata, matchedRule, matchOutbound),
+	}
+}
+
+func (s *Service) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	return &trackedPacketConn{
+		PacketConn: conn,
+		state:      s.newState(metadata, matchedRule, matchOutbound),
+	}
+}
+
+func (s *Service) newState(metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) *connState {
+	var chain []string
+	if s.outbound != nil {
+		chain, _ = trafficontrol.OutboundChain(s.outbound, matchOutbound)
+	} else if matchOutbound != nil {
+		chain = []string{matchOutbound.Tag()}
+	}
+	return &connState{
+		service: s,
+		record:  newRecord(metadata, matchedRule, chain),
+	}
+}
+
+func (s *Service) write(record *Record) {
+	content, err := record.encode(s.format)
+	if err != nil {
+		s.logger.Error("encode access log: ", err)
+		return
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	for _, writer := range s.writers {
+		_, err = writer.Write(content)
+		if err != nil {
+			s.logger.Error("write access log: ", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubOutbound struct {
+	adapter.Outbound
+	tag string
+}
+
+func (o *stubOutbound) Tag() string {
+	return o.tag
+}
+
+func newTestService(t *testing.T, format string) (*Service, string) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	service, err := NewService(context.Background(), log.NewNOPFactory().Logger(), option.AccessLogOptions{
+		Output: path,
+		Format: format,
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.Start(adapter.StartStateInitialize))
+	t.Cleanup(func() {
+		service.Close()
+	})
+	return service, path
+}
+
+func routeTestConnection(t *testing.T, service *Service) {
+	client, server := net.Pipe()
+	conn := service.RoutedConnection(context.Background(), server, adapter.InboundContext{
+		Inbound:     "mixed-in",
+		InboundType: C.TypeMixed,
+		Network:     "tcp",
+		User:        "alice",
+		Source:      M.ParseSocksaddr("127.0.0.1:50000"),
+		Destination: M.ParseSocksaddr("example.com:443"),
+		Protocol:    C.ProtocolTLS,
+		DestinationAddresses: []netip.Addr{
+			netip.MustParseAddr("93.184.216.34"),
+		},
+	}, nil, &stubOutbound{tag: "proxy"})
+	go func() {
+		client.Write([]byte("hello"))
+		buffer := make([]byte, 3)
+		io.ReadFull(client, buffer)
+		client.Close()
+	}()
+	buffer := make([]byte, 5)
+	_, err := io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("bye"))
+	require.NoError(t, err)
+	_, err = conn.Read(buffer)
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, conn.Close())
+	conn.Close()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	t.Parallel()
+	service, path := newTestService(t, "")
+	routeTestConnection(t, service)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(content), "\n"))
+	var record Record
+	require.NoError(t, json.Unmarshal(content, &record))
+	require.Equal(t, "mixed-in", record.Inbound)
+	require.Equal(t, "alice", record.User)
+	require.Equal(t, "127.0.0.1:50000", record.Source)
+	require.Equal(t, "example.com", record.DestinationDomain)
+	require.Equal(t, "93.184.216.34", record.DestinationIP)
+	require.Equal(t, uint16(443), record.DestinationPort)
+	require.Equal(t, C.ProtocolTLS, record.Protocol)
+	require.Equal(t, "final", record.Rule)
+	require.Equal(t, []string{"proxy"}, record.Chain)
+	require.Equal(t, int64(5), record.Upload)
+	require.Equal(t, int64(3), record.Download)
+	require.Equal(t, ReasonEOF, record.CloseReason)
+	require.False(t, record.End.Before(record.Start))
+}
+
+func TestAccessLogCSV(t *testing.T) {
+	t.Parallel()
+	service, path := newTestService(t, FormatCSV)
+	routeTestConnection(t, service)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0], 16)
+	require.Equal(t, "example.com", rows[0][7])
+	require.Equal(t, "proxy", rows[0][12])
+	require.Equal(t, "5", rows[0][13])
+	require.Equal(t, ReasonEOF, rows[0][15])
+}
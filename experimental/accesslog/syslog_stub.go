@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package accesslog
+
+import (
+	"io"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func dialSyslog(options option.AccessLogSyslogOptions) (io.WriteCloser, error) {
+	return nil, E.New("syslog is not supported on this platform")
+}
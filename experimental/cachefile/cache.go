@@ -23,13 +23,16 @@ var (
 	bucketExpand   = []byte("group_expand")
 	bucketMode     = []byte("clash_mode")
 	bucketRuleSet  = []byte("rule_set")
+	bucketLearned  = []byte("learned_outbound")
 
 	bucketNameList = []string{
 		string(bucketSelected),
 		string(bucketExpand),
 		string(bucketMode),
 		string(bucketRuleSet),
+		string(bucketLearned),
 		string(bucketRDRC),
+		string(bucketTraffic),
 	}
 
 	cacheIDDefault = []byte("default")
@@ -316,3 +319,63 @@ func (c *CacheFile) SaveRuleSet(tag string, set *adapter.SavedBinary) error {
 		return bucket.Put([]byte(tag), setBinary)
 	})
 }
+
+func (c *CacheFile) LoadLearnedOutbounds(group string) map[string]*adapter.LearnedOutbound {
+	learnedMap := make(map[string]*adapter.LearnedOutbound)
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketLearned)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(group))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var learned adapter.LearnedOutbound
+			if learned.UnmarshalBinary(value) == nil {
+				learnedMap[string(key)] = &learned
+			}
+			return nil
+		})
+	})
+	return learnedMap
+}
+
+func (c *CacheFile) SaveLearnedOutbound(group string, key string, learned *adapter.LearnedOutbound) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketLearned)
+		if err != nil {
+			return err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(group))
+		if err != nil {
+			return err
+		}
+		learnedBinary, err := learned.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), learnedBinary)
+	})
+}
+
+func (c *CacheFile) DeleteLearnedOutbound(group string, key string) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketLearned)
+		if bucket == nil {
+			return nil
+		}
+		if key == "" {
+			if bucket.Bucket([]byte(group)) == nil {
+				return nil
+			}
+			return bucket.DeleteBucket([]byte(group))
+		}
+		bucket = bucket.Bucket([]byte(group))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
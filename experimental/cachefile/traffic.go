@@ -0,0 +1,135 @@
+package cachefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/sagernet/bbolt"
+	"github.com/sagernet/sing-box/adapter"
+)
+
+// Traffic history is stored as traffic/<period>/<group by>, keyed by the
+// big-endian unix time of the bucket start followed by the name, so that
+// each group is ordered by time.
+var bucketTraffic = []byte("traffic")
+
+func trafficKey(at time.Time, name string) []byte {
+	key := make([]byte, 8+len(name))
+	binary.BigEndian.PutUint64(key, uint64(at.Unix()))
+	copy(key[8:], name)
+	return key
+}
+
+func (c *CacheFile) AddTrafficHistory(entries []adapter.TrafficHistoryEntry) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketTraffic)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			periodBucket, err := bucket.CreateBucketIfNotExists([]byte(entry.Period))
+			if err != nil {
+				return err
+			}
+			groupBucket, err := periodBucket.CreateBucketIfNotExists([]byte(entry.GroupBy))
+			if err != nil {
+				return err
+			}
+			key := trafficKey(entry.Time, entry.Name)
+			var upload, download uint64
+			if content := groupBucket.Get(key); len(content) == 16 {
+				upload = binary.BigEndian.Uint64(content)
+				download = binary.BigEndian.Uint64(content[8:])
+			}
+			value := make([]byte, 16)
+			binary.BigEndian.PutUint64(value, upload+uint64(entry.Upload))
+			binary.BigEndian.PutUint64(value[8:], download+uint64(entry.Download))
+			err = groupBucket.Put(key, value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *CacheFile) LoadTrafficHistory(period string, groupBy string, since time.Time, until time.Time) []adapter.TrafficHistoryEntry {
+	var entries []adapter.TrafficHistoryEntry
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketTraffic)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(period))
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(groupBy))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		var start []byte
+		if !since.IsZero() {
+			start = trafficKey(since, "")
+		}
+		var end []byte
+		if !until.IsZero() {
+			end = trafficKey(until, "")
+		}
+		var key, value []byte
+		if start != nil {
+			key, value = cursor.Seek(start)
+		} else {
+			key, value = cursor.First()
+		}
+		for ; key != nil; key, value = cursor.Next() {
+			if end != nil && bytes.Compare(key, end) >= 0 {
+				break
+			}
+			if len(key) < 8 || len(value) != 16 {
+				continue
+			}
+			entries = append(entries, adapter.TrafficHistoryEntry{
+				Period:   period,
+				GroupBy:  groupBy,
+				Name:     string(key[8:]),
+				Time:     time.Unix(int64(binary.BigEndian.Uint64(key)), 0),
+				Upload:   int64(binary.BigEndian.Uint64(value)),
+				Download: int64(binary.BigEndian.Uint64(value[8:])),
+			})
+		}
+		return nil
+	})
+	return entries
+}
+
+func (c *CacheFile) PruneTrafficHistory(period string, before time.Time) error {
+	end := trafficKey(before, "")
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketTraffic)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(period))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEachBucket(func(groupBy []byte) error {
+			groupBucket := bucket.Bucket(groupBy)
+			var expired [][]byte
+			cursor := groupBucket.Cursor()
+			for key, _ := cursor.First(); key != nil && bytes.Compare(key, end) < 0; key, _ = cursor.Next() {
+				expired = append(expired, key)
+			}
+			for _, key := range expired {
+				err := groupBucket.Delete(key)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
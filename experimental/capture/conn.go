@@ -0,0 +1,244 @@
+package capture
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pcapng"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+var (
+	_ net.Conn     = (*capturedConn)(nil)
+	_ N.PacketConn = (*capturedPacketConn)(nil)
+)
+
+// connState synthesizes the packets of a captured connection. The client is
+// the inbound source, the server is the destination as far as it is known.
+type connState struct {
+	sessions    []*Session
+	id          string
+	description string
+	outbounds   []string
+	client      netip.AddrPort
+	server      netip.AddrPort
+	access      sync.Mutex
+	described   bool
+	clientSeq   uint32
+	serverSeq   uint32
+	clientFin   bool
+	serverFin   bool
+}
+
+// newConnState takes the connection ID, rule and outbound chain from the
+// Clash API tracker, which wraps every routed connection.
+func newConnState(conn any, metadata adapter.InboundContext, outbound N.Dialer, remoteConn net.Conn) *connState {
+	state := &connState{
+		client: metadata.Source.AddrPort(),
+		server: serverAddress(metadata, outbound, remoteConn),
+	}
+	var trackerMetadata trafficontrol.TrackerMetadata
+	if tracker, loaded := common.Cast[*trafficontrol.TCPConn](conn); loaded {
+		trackerMetadata = tracker.Metadata()
+	} else if tracker, loaded := common.Cast[*trafficontrol.UDPConn](conn); loaded {
+		trackerMetadata = tracker.Metadata()
+	} else {
+		trackerMetadata.ID, _ = uuid.NewV4()
+		trackerMetadata.Metadata = metadata
+		if outboundAdapter, isOutbound := outbound.(adapter.Outbound); isOutbound {
+			trackerMetadata.Outbound = outboundAdapter.Tag()
+			trackerMetadata.Chain = []string{outboundAdapter.Tag()}
+		}
+	}
+	state.id = trackerMetadata.ID.String()
+	state.outbounds = trackerMetadata.Chain
+	description := []string{
+		"connection " + state.id,
+		"inbound " + trackerMetadata.InboundName(),
+	}
+	if len(trackerMetadata.Chain) > 0 {
+		description = append(description, "outbound "+strings.Join(common.Reverse(append([]string(nil), trackerMetadata.Chain...)), " -> "))
+	}
+	description = append(description, "rule "+trackerMetadata.RuleName())
+	if host := trackerMetadata.Host(); host != "" {
+		description = append(description, "host "+host)
+	}
+	state.description = strings.Join(description, ", ")
+	return state
+}
+
+// serverAddress returns the destination IP, the first resolved address, or
+// the remote address of a direct connection, in that order. Proxied domain
+// destinations have no known address and are written as unspecified.
+func serverAddress(metadata adapter.InboundContext, outbound N.Dialer, remoteConn net.Conn) netip.AddrPort {
+	destination := metadata.Destination
+	switch {
+	case destination.IsIP():
+		return destination.AddrPort()
+	case len(metadata.DestinationAddresses) > 0:
+		return netip.AddrPortFrom(metadata.DestinationAddresses[0], destination.Port)
+	}
+	if outboundAdapter, isOutbound := outbound.(adapter.Outbound); isOutbound && outboundAdapter.Type() == C.TypeDirect && remoteConn != nil {
+		if remoteAddr := M.SocksaddrFromNet(remoteConn.RemoteAddr()); remoteAddr.IsIP() {
+			return remoteAddr.AddrPort()
+		}
+	}
+	return netip.AddrPortFrom(netip.Addr{}, destination.Port)
+}
+
+// comment describes the connection on its first packet and only identifies
+// it on the following ones.
+func (s *connState) comment() string {
+	if s.described {
+		return "connection " + s.id
+	}
+	s.described = true
+	return s.description
+}
+
+func (s *connState) push(packet []byte) {
+	block := pcapng.AppendPacket(nil, time.Now(), packet, s.comment())
+	for _, session := range s.sessions {
+		session.push(block)
+	}
+}
+
+func (s *connState) handshake() {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.push(pcapng.TCPPacket(s.client, s.server, 0, 0, pcapng.TCPSyn, nil))
+	s.push(pcapng.TCPPacket(s.server, s.client, 0, 1, pcapng.TCPSyn|pcapng.TCPAck, nil))
+	s.push(pcapng.TCPPacket(s.client, s.server, 1, 1, pcapng.TCPAck, nil))
+	s.clientSeq = 1
+	s.serverSeq = 1
+}
+
+func (s *connState) tcpPayload(fromClient bool, payload []byte) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	for len(payload) > 0 {
+		segment := payload
+		if len(segment) > pcapng.MaxPayload {
+			segment = segment[:pcapng.MaxPayload]
+		}
+		payload = payload[len(segment):]
+		if fromClient {
+			s.push(pcapng.TCPPacket(s.client, s.server, s.clientSeq, s.serverSeq, pcapng.TCPPsh|pcapng.TCPAck, segment))
+			s.clientSeq += uint32(len(segment))
+		} else {
+			s.push(pcapng.TCPPacket(s.server, s.client, s.serverSeq, s.clientSeq, pcapng.TCPPsh|pcapng.TCPAck, segment))
+			s.serverSeq += uint32(len(segment))
+		}
+	}
+}
+
+func (s *connState) tcpFin(fromClient bool) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if fromClient && !s.clientFin {
+		s.clientFin = true
+		s.push(pcapng.TCPPacket(s.client, s.server, s.clientSeq, s.serverSeq, pcapng.TCPFin|pcapng.TCPAck, nil))
+		s.clientSeq++
+	} else if !fromClient && !s.serverFin {
+		s.serverFin = true
+		s.push(pcapng.TCPPacket(s.server, s.client, s.serverSeq, s.clientSeq, pcapng.TCPFin|pcapng.TCPAck, nil))
+		s.serverSeq++
+	}
+}
+
+func (s *connState) udpPayload(fromClient bool, remote M.Socksaddr, payload []byte) {
+	server := s.server
+	if remote.IsIP() {
+		server = remote.AddrPort()
+	} else if remote.Port != 0 {
+		server = netip.AddrPortFrom(server.Addr(), remote.Port)
+	}
+	if len(payload) > pcapng.MaxPayload {
+		payload = payload[:pcapng.MaxPayload]
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if fromClient {
+		s.push(pcapng.UDPPacket(s.client, server, payload))
+	} else {
+		s.push(pcapng.UDPPacket(server, s.client, payload))
+	}
+}
+
+// capturedConn records what is read from the inbound connection as client
+// packets and what is written to it as server packets. It is deliberately
+// not replaceable, so that copying never bypasses it.
+type capturedConn struct {
+	net.Conn
+	state *connState
+}
+
+func (c *capturedConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		c.state.tcpPayload(true, p[:n])
+	}
+	// Timeouts of early reads are not the end of the stream.
+	if errors.Is(err, io.EOF) {
+		c.state.tcpFin(true)
+	}
+	return
+}
+
+func (c *capturedConn) Write(p []byte) (n int, err error) {
+	n, err = c.Conn.Write(p)
+	if n > 0 {
+		c.state.tcpPayload(false, p[:n])
+	}
+	return
+}
+
+func (c *capturedConn) CloseWrite() error {
+	c.state.tcpFin(false)
+	return N.CloseWrite(c.Conn)
+}
+
+func (c *capturedConn) Close() error {
+	c.state.tcpFin(true)
+	c.state.tcpFin(false)
+	return c.Conn.Close()
+}
+
+func (c *capturedConn) Upstream() any {
+	return c.Conn
+}
+
+type capturedPacketConn struct {
+	N.PacketConn
+	state *connState
+}
+
+func (c *capturedPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err == nil {
+		c.state.udpPayload(true, destination, buffer.Bytes())
+	}
+	return
+}
+
+func (c *capturedPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	c.state.udpPayload(false, destination, buffer.Bytes())
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *capturedPacketConn) Upstream() any {
+	return c.PacketConn
+}
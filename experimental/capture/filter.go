@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common"
+)
+
+// Filter selects the connections of a capture. Inbound and outbound match
+// tags, an outbound also matches the groups a connection passes through, and
+// the rule items match the same way as in route rules. An empty filter
+// selects every connection.
+type Filter struct {
+	Inbound  []string
+	Outbound []string
+	Rule     option.DefaultHeadlessRule
+}
+
+type matcher struct {
+	inbound  []string
+	outbound []string
+	rule     adapter.HeadlessRule
+}
+
+func newMatcher(ctx context.Context, filter Filter) (*matcher, error) {
+	m := &matcher{
+		inbound:  filter.Inbound,
+		outbound: filter.Outbound,
+	}
+	if filter.Rule.IsValid() {
+		headlessRule, err := rule.NewDefaultHeadlessRule(ctx, filter.Rule)
+		if err != nil {
+			return nil, err
+		}
+		m.rule = headlessRule
+	}
+	return m, nil
+}
+
+func (m *matcher) match(metadata adapter.InboundContext, outbounds []string) bool {
+	if len(m.inbound) > 0 && !common.Contains(m.inbound, metadata.Inbound) && !common.Contains(m.inbound, metadata.InboundType+"/"+metadata.Inbound) {
+		return false
+	}
+	if len(m.outbound) > 0 && !common.Any(outbounds, func(it string) bool {
+		return common.Contains(m.outbound, it)
+	}) {
+		return false
+	}
+	if m.rule != nil {
+		metadata.ResetRuleCache()
+		if !m.rule.Match(&metadata) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pcapng"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// sessionQueueSize is the number of packets buffered for a slow writer
+// before new packets are dropped. Connections never wait for a capture.
+const sessionQueueSize = 1024
+
+var (
+	_ adapter.LifecycleService  = (*Manager)(nil)
+	_ adapter.ConnectionCapture = (*Manager)(nil)
+)
+
+// Manager runs the active captures. Each connection is matched against the
+// captures running when it is established, and its payload is written to
+// the matching ones as synthesized packets.
+type Manager struct {
+	ctx      context.Context
+	logger   log.Logger
+	access   sync.Mutex
+	sessions []*Session
+	// active mirrors len(sessions), so that connections skip capture
+	// without taking the lock while nothing is being captured
+	active atomic.Int32
+}
+
+func NewManager(ctx context.Context, logger log.Logger) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		logger: logger,
+	}
+}
+
+func (m *Manager) Name() string {
+	return "capture"
+}
+
+func (m *Manager) Start(stage adapter.StartStage) error {
+	return nil
+}
+
+func (m *Manager) Close() error {
+	m.access.Lock()
+	sessions := m.sessions
+	m.sessions = nil
+	m.active.Store(0)
+	m.access.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+	return nil
+}
+
+// NewSession writes the pcapng header to writer and starts capturing the
+// connections selected by filter until the session is closed or a write
+// fails.
+func (m *Manager) NewSession(filter Filter, writer io.Writer) (*Session, error) {
+	matcher, err := newMatcher(m.ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	err = pcapng.WriteHeader(writer, "sing-box "+C.Version, "sing-box")
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		manager: m,
+		matcher: matcher,
+		writer:  writer,
+		queue:   make(chan []byte, sessionQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	m.access.Lock()
+	m.sessions = append(m.sessions, session)
+	m.active.Store(int32(len(m.sessions)))
+	m.access.Unlock()
+	go session.loopWrite()
+	return session, nil
+}
+
+func (m *Manager) remove(session *Session) {
+	m.access.Lock()
+	defer m.access.Unlock()
+	m.sessions = common.Filter(m.sessions, func(it *Session) bool {
+		return it != session
+	})
+	m.active.Store(int32(len(m.sessions)))
+}
+
+func (m *Manager) match(metadata adapter.InboundContext, outbounds []string) []*Session {
+	m.access.Lock()
+	defer m.access.Unlock()
+	if len(m.sessions) == 0 {
+		return nil
+	}
+	return common.Filter(m.sessions, func(it *Session) bool {
+		return it.matcher.match(metadata, outbounds)
+	})
+}
+
+func (m *Manager) CaptureConnection(ctx context.Context, conn net.Conn, remoteConn net.Conn, metadata adapter.InboundContext, outbound N.Dialer) net.Conn {
+	if m.active.Load() == 0 {
+		return conn
+	}
+	state := newConnState(conn, metadata, outbound, remoteConn)
+	sessions := m.match(metadata, state.outbounds)
+	if len(sessions) == 0 {
+		return conn
+	}
+	state.sessions = sessions
+	state.handshake()
+	return &capturedConn{Conn: conn, state: state}
+}
+
+func (m *Manager) CapturePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, outbound N.Dialer) N.PacketConn {
+	if m.active.Load() == 0 {
+		return conn
+	}
+	state := newConnState(conn, metadata, outbound, nil)
+	sessions := m.match(metadata, state.outbounds)
+	if len(sessions) == 0 {
+		return conn
+	}
+	state.sessions = sessions
+	return &capturedPacketConn{PacketConn: conn, state: state}
+}
+
+// Session is a running capture writing to a single pcapng stream.
+type Session struct {
+	manager   *Manager
+	matcher   *matcher
+	writer    io.Writer
+	queue     chan []byte
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	err       error
+	packets   atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Done is closed once the session has stopped writing.
+func (s *Session) Done() <-chan struct{} {
+	return s.stopped
+}
+
+// Packets returns the number of packets written and dropped so far.
+func (s *Session) Packets() (written uint64, dropped uint64) {
+	return s.packets.Load(), s.dropped.Load()
+}
+
+// Close stops the session after the queued packets are written, and returns
+// the write error that stopped it, if any.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		s.manager.remove(s)
+		close(s.done)
+	})
+	<-s.stopped
+	return s.err
+}
+
+func (s *Session) push(block []byte) {
+	select {
+	case <-s.done:
+	case s.queue <- block:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *Session) loopWrite() {
+	defer close(s.stopped)
+	for {
+		select {
+		case block := <-s.queue:
+			if !s.write(block) {
+				return
+			}
+		case <-s.done:
+			for {
+				select {
+				case block := <-s.queue:
+					if !s.write(block) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Session) write(block []byte) bool {
+	_, err := s.writer.Write(block)
+	if err != nil {
+		s.err = err
+		s.closeOnce.Do(func() {
+			s.manager.remove(s)
+			close(s.done)
+		})
+		return false
+	}
+	s.packets.Add(1)
+	return true
+}
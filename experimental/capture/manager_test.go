@@ -0,0 +1,144 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pcapng"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/json/badoption"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type lockedBuffer struct {
+	access sync.Mutex
+	bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.access.Lock()
+	defer b.access.Unlock()
+	return b.Buffer.Write(p)
+}
+
+// packetBlocks returns the packets and comments of the enhanced packet blocks
+// in a pcapng stream.
+func packetBlocks(stream []byte) (packets [][]byte, comments []string) {
+	for len(stream) > 0 {
+		blockType := binary.LittleEndian.Uint32(stream)
+		totalLength := binary.LittleEndian.Uint32(stream[4:])
+		block := stream[8 : totalLength-4]
+		stream = stream[totalLength:]
+		if blockType != 6 {
+			continue
+		}
+		capturedLength := binary.LittleEndian.Uint32(block[12:])
+		packets = append(packets, block[20:20+capturedLength])
+		options := block[20+(capturedLength+3)/4*4:]
+		var comment string
+		if len(options) > 0 {
+			comment = string(options[4 : 4+binary.LittleEndian.Uint16(options[2:])])
+		}
+		comments = append(comments, comment)
+	}
+	return
+}
+
+func newTestMetadata(domain string) adapter.InboundContext {
+	return adapter.InboundContext{
+		Inbound:     "mixed-in",
+		InboundType: "mixed",
+		Network:     N.NetworkTCP,
+		Source:      M.ParseSocksaddr("127.0.0.1:50000"),
+		Destination: M.ParseSocksaddrHostPort(domain, 80),
+		DestinationAddresses: []netip.Addr{
+			netip.MustParseAddr("93.184.215.14"),
+		},
+	}
+}
+
+func TestCaptureConnection(t *testing.T) {
+	t.Parallel()
+	manager := NewManager(context.Background(), log.NewNOPFactory().Logger())
+	var output lockedBuffer
+	session, err := manager.NewSession(Filter{
+		Inbound: []string{"mixed-in"},
+	}, &output)
+	require.NoError(t, err)
+	var other lockedBuffer
+	otherSession, err := manager.NewSession(Filter{Inbound: []string{"tun-in"}}, &other)
+	require.NoError(t, err)
+
+	client, server := net.Pipe()
+	conn := manager.CaptureConnection(context.Background(), server, nil, newTestMetadata("example.com"), nil)
+	require.NotEqual(t, server, conn)
+	go func() {
+		client.Write([]byte("request"))
+		buffer := make([]byte, 8)
+		io.ReadFull(client, buffer)
+		client.Close()
+	}()
+	buffer := make([]byte, 7)
+	_, err = io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("response"))
+	require.NoError(t, err)
+	_, err = conn.Read(buffer)
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, session.Close())
+	require.NoError(t, otherSession.Close())
+	written, dropped := session.Packets()
+	require.Equal(t, uint64(7), written)
+	require.Zero(t, dropped)
+
+	packets, comments := packetBlocks(output.Bytes())
+	require.Len(t, packets, 7)
+	require.Contains(t, comments[0], "inbound mixed/mixed-in")
+	require.Contains(t, comments[0], "host example.com")
+	require.Equal(t, netip.MustParseAddr("93.184.215.14").AsSlice(), packets[0][16:20])
+	require.Equal(t, pcapng.TCPSyn, int(packets[0][33]))
+	require.Equal(t, []byte("request"), packets[3][40:])
+	require.Equal(t, []byte("response"), packets[4][40:])
+	require.Equal(t, pcapng.TCPFin|pcapng.TCPAck, int(packets[5][33]))
+
+	otherPackets, _ := packetBlocks(other.Bytes())
+	require.Empty(t, otherPackets)
+
+	require.Zero(t, manager.active.Load())
+	_, server = net.Pipe()
+	require.Equal(t, server, manager.CaptureConnection(context.Background(), server, nil, newTestMetadata("example.com"), nil))
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	manager := NewManager(context.Background(), log.NewNOPFactory().Logger())
+	var output lockedBuffer
+	session, err := manager.NewSession(Filter{
+		Outbound: []string{"proxy"},
+	}, &output)
+	require.NoError(t, err)
+	require.Len(t, manager.match(newTestMetadata("example.com"), []string{"vps", "proxy"}), 1)
+	require.Empty(t, manager.match(newTestMetadata("example.com"), []string{"direct"}))
+	require.NoError(t, session.Close())
+	require.Empty(t, manager.match(newTestMetadata("example.com"), []string{"proxy"}))
+
+	var filter Filter
+	filter.Rule.DomainSuffix = badoption.Listable[string]{"example.org"}
+	filter.Rule.Port = badoption.Listable[uint16]{80}
+	matcher, err := newMatcher(context.Background(), filter)
+	require.NoError(t, err)
+	require.True(t, matcher.match(newTestMetadata("www.example.org"), nil))
+	require.False(t, matcher.match(newTestMetadata("www.example.com"), nil))
+}
@@ -26,6 +26,8 @@ func groupRouter(server *Server) http.Handler {
 		r.Use(parseProxyName, findProxyByName(server))
 		r.Get("/", getGroup(server))
 		r.Get("/delay", getGroupDelay(server))
+		r.Get("/learned", getGroupLearned)
+		r.Delete("/learned", clearGroupLearned)
 	})
 	return r
 }
@@ -134,3 +136,28 @@ func getGroupDelay(server *Server) func(w http.ResponseWriter, r *http.Request)
 		render.JSON(w, r, result)
 	}
 }
+
+func getGroupLearned(w http.ResponseWriter, r *http.Request) {
+	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
+	smart, isSmart := proxy.(*group.Smart)
+	if !isSmart {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError("Must be a Smart group"))
+		return
+	}
+	render.JSON(w, r, render.M{
+		"learned": smart.Learned(),
+	})
+}
+
+func clearGroupLearned(w http.ResponseWriter, r *http.Request) {
+	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
+	smart, isSmart := proxy.(*group.Smart)
+	if !isSmart {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError("Must be a Smart group"))
+		return
+	}
+	smart.ClearLearned(r.URL.Query().Get("key"))
+	render.NoContent(w, r)
+}
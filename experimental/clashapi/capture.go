@@ -0,0 +1,130 @@
+package clashapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/experimental/capture"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+
+	"github.com/go-chi/render"
+)
+
+// getCapture streams the payload of the connections selected by the filter
+// parameters as a pcapng file, until the client disconnects or the duration
+// passes. Only connections established after the request are captured.
+func getCapture(ctx context.Context, logger log.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		manager := service.PtrFromContext[capture.Manager](ctx)
+		if manager == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("Capture is not available"))
+			return
+		}
+		filter, err := parseCaptureFilter(r.URL.Query())
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		var duration time.Duration
+		if durationText := r.URL.Query().Get("duration"); durationText != "" {
+			duration, err = time.ParseDuration(durationText)
+			if err != nil || duration < 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("invalid duration"))
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-pcapng")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"capture.pcapng\"")
+		session, err := manager.NewSession(filter, &flushWriter{w})
+		if err != nil {
+			w.Header().Del("Content-Disposition")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		logger.Info("capture started from ", r.RemoteAddr)
+
+		var timeout <-chan time.Time
+		if duration > 0 {
+			timer := time.NewTimer(duration)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-r.Context().Done():
+		case <-session.Done():
+		case <-timeout:
+		}
+		err = session.Close()
+		written, dropped := session.Packets()
+		if err != nil {
+			logger.Info("capture stopped: ", err, ", ", written, " packets written, ", dropped, " dropped")
+		} else {
+			logger.Info("capture finished: ", written, " packets written, ", dropped, " dropped")
+		}
+	}
+}
+
+// parseCaptureFilter reads the filter from repeated or comma separated
+// parameters named after the route rule items.
+func parseCaptureFilter(query url.Values) (capture.Filter, error) {
+	var filter capture.Filter
+	filter.Inbound = queryList(query, "inbound")
+	filter.Outbound = queryList(query, "outbound")
+	filter.Rule.Network = queryList(query, "network")
+	filter.Rule.Domain = queryList(query, "domain")
+	filter.Rule.DomainSuffix = queryList(query, "domain_suffix")
+	filter.Rule.DomainKeyword = queryList(query, "domain_keyword")
+	filter.Rule.DomainRegex = queryList(query, "domain_regex")
+	filter.Rule.IPCIDR = queryList(query, "ip_cidr")
+	filter.Rule.SourceIPCIDR = queryList(query, "source_ip_cidr")
+	filter.Rule.PortRange = queryList(query, "port_range")
+	for _, key := range []string{"port", "source_port"} {
+		for _, portText := range queryList(query, key) {
+			port, err := strconv.ParseUint(portText, 10, 16)
+			if err != nil {
+				return capture.Filter{}, E.New("invalid ", key, ": ", portText)
+			}
+			if key == "port" {
+				filter.Rule.Port = append(filter.Rule.Port, uint16(port))
+			} else {
+				filter.Rule.SourcePort = append(filter.Rule.SourcePort, uint16(port))
+			}
+		}
+	}
+	return filter, nil
+}
+
+func queryList(query url.Values, key string) []string {
+	var values []string
+	for _, value := range query[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (w *flushWriter) Write(p []byte) (n int, err error) {
+	n, err = w.ResponseWriter.Write(p)
+	if err == nil {
+		w.ResponseWriter.(http.Flusher).Flush()
+	}
+	return
+}
@@ -1,9 +1,16 @@
 package clashapi
 
 import (
+	"io"
 	"net/http"
+	"os"
 
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/jsonc"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -12,7 +19,7 @@ import (
 func configRouter(server *Server, logFactory log.Factory) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getConfigs(server, logFactory))
-	r.Put("/", updateConfigs)
+	r.Put("/", updateConfigs(server))
 	r.Patch("/", patchConfigs(server))
 	return r
 }
@@ -66,6 +73,58 @@ func patchConfigs(server *Server) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func updateConfigs(w http.ResponseWriter, r *http.Request) {
-	render.NoContent(w, r)
+type updateConfigRequest struct {
+	Path    string `json:"path"`
+	Payload string `json:"payload"`
+}
+
+// updateConfigs reloads the configuration from payload, from path, or from
+// where it was originally loaded if both are empty.
+func updateConfigs(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reloader := service.FromContext[adapter.ConfigReloader](server.ctx)
+		if reloader == nil {
+			render.Status(r, http.StatusNotImplemented)
+			render.JSON(w, r, newError("reload not supported"))
+			return
+		}
+		var request updateConfigRequest
+		content, err := io.ReadAll(r.Body)
+		if err == nil && len(content) > 0 {
+			err = json.Unmarshal(content, &request)
+		}
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		if request.Payload == "" && request.Path == "" {
+			err = reloader.ReloadFromSource()
+		} else {
+			configContent := []byte(request.Payload)
+			if request.Path != "" {
+				configContent, err = os.ReadFile(request.Path)
+				if err != nil {
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, newError(err.Error()))
+					return
+				}
+			}
+			var options option.Options
+			options, err = jsonc.UnmarshalExtendedContext[option.Options](server.ctx, configContent)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError(err.Error()))
+				return
+			}
+			err = reloader.Reload(options)
+		}
+		if err != nil {
+			server.logger.Error("reload configuration: ", err)
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
 }
@@ -8,6 +8,8 @@ import (
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/ws"
 	"github.com/sagernet/ws/wsutil"
@@ -27,17 +29,19 @@ func connectionRouter(router adapter.Router, trafficManager *trafficontrol.Manag
 
 func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseConnectionQuery(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
 		if r.Header.Get("Upgrade") != "websocket" {
 			snapshot := trafficManager.Snapshot()
+			snapshot.Connections, snapshot.Total = query.Apply(snapshot.Connections)
 			render.JSON(w, r, snapshot)
 			return
 		}
 
-		conn, _, _, err := ws.UpgradeHTTP(r, w)
-		if err != nil {
-			return
-		}
-
 		intervalStr := r.URL.Query().Get("interval")
 		interval := 1000
 		if intervalStr != "" {
@@ -51,14 +55,46 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 			interval = t
 		}
 
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+
 		buf := &bytes.Buffer{}
-		sendSnapshot := func() error {
-			buf.Reset()
-			snapshot := trafficManager.Snapshot()
-			if err := json.NewEncoder(buf).Encode(snapshot); err != nil {
-				return err
+		var sendSnapshot func() error
+		if r.URL.Query().Get("diff") == "true" {
+			differ := trafficontrol.NewDiffer()
+			sendSnapshot = func() error {
+				buf.Reset()
+				snapshot := trafficManager.Snapshot()
+				connections := common.Filter(snapshot.Connections, func(it trafficontrol.Tracker) bool {
+					return query.Match(it.Metadata())
+				})
+				diff := differ.Next(connections)
+				err := json.NewEncoder(buf).Encode(render.M{
+					"downloadTotal": snapshot.Download,
+					"uploadTotal":   snapshot.Upload,
+					"memory":        snapshot.Memory,
+					"total":         len(connections),
+					"added":         diff.Added,
+					"updated":       diff.Updated,
+					"removed":       diff.Removed,
+				})
+				if err != nil {
+					return err
+				}
+				return wsutil.WriteServerText(conn, buf.Bytes())
+			}
+		} else {
+			sendSnapshot = func() error {
+				buf.Reset()
+				snapshot := trafficManager.Snapshot()
+				snapshot.Connections, snapshot.Total = query.Apply(snapshot.Connections)
+				if err := json.NewEncoder(buf).Encode(snapshot); err != nil {
+					return err
+				}
+				return wsutil.WriteServerText(conn, buf.Bytes())
 			}
-			return wsutil.WriteServerText(conn, buf.Bytes())
 		}
 
 		if err = sendSnapshot(); err != nil {
@@ -75,6 +111,44 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 	}
 }
 
+// parseConnectionQuery reads the filter, sort and page parameters shared by
+// listing and closing connections.
+func parseConnectionQuery(r *http.Request) (trafficontrol.Query, error) {
+	values := r.URL.Query()
+	query := trafficontrol.Query{
+		Network:  values.Get("network"),
+		Inbound:  values.Get("inbound"),
+		Outbound: values.Get("outbound"),
+		Chain:    values.Get("chain"),
+		Rule:     values.Get("rule"),
+		Host:     values.Get("host"),
+		User:     values.Get("user"),
+		Process:  values.Get("process"),
+		Sort:     values.Get("sort"),
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, E.New("unknown order: ", values.Get("order"))
+	}
+	var err error
+	if offset := values.Get("offset"); offset != "" {
+		query.Offset, err = strconv.Atoi(offset)
+		if err != nil {
+			return query, E.Cause(err, "parse offset")
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return query, E.Cause(err, "parse limit")
+		}
+	}
+	return query, query.Validate()
+}
+
 func closeConnection(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := uuid.FromStringOrNil(chi.URLParam(r, "id"))
@@ -91,7 +165,22 @@ func closeConnection(trafficManager *trafficontrol.Manager) func(w http.Response
 
 func closeAllConnections(router adapter.Router, trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseConnectionQuery(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
 		snapshot := trafficManager.Snapshot()
+		if query.Filtered() {
+			for _, c := range snapshot.Connections {
+				if query.Match(c.Metadata()) {
+					c.Close()
+				}
+			}
+			render.NoContent(w, r)
+			return
+		}
 		for _, c := range snapshot.Connections {
 			c.Close()
 		}
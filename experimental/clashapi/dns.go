@@ -3,23 +3,32 @@ package clashapi
 import (
 	"context"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/miekg/dns"
 )
 
-func dnsRouter(router adapter.DNSRouter) http.Handler {
+func dnsRouter(ctx context.Context, router adapter.DNSRouter) http.Handler {
 	r := chi.NewRouter()
-	r.Get("/query", queryDNS(router))
+	r.Get("/query", queryDNS(ctx, router))
+	r.Get("/cache", getDNSCache(router))
+	r.Delete("/cache", deleteDNSCache(router))
 	return r
 }
 
-func queryDNS(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+func queryDNS(ctx context.Context, router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := r.URL.Query().Get("name")
 		qTypeStr := r.URL.Query().Get("type")
@@ -34,37 +43,74 @@ func queryDNS(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), C.DNSTimeout)
+		var options adapter.DNSQueryOptions
+		if serverTag := r.URL.Query().Get("server"); serverTag != "" {
+			transport, loaded := service.FromContext[adapter.DNSTransportManager](ctx).Transport(serverTag)
+			if !loaded {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("DNS server not found: "+serverTag))
+				return
+			}
+			options.Transport = transport
+		}
+
+		queryCtx, cancel := context.WithTimeout(context.Background(), C.DNSTimeout)
 		defer cancel()
 
+		// Rules match the query as if it came from the given inbound and client.
+		var metadata adapter.InboundContext
+		if inboundTag := r.URL.Query().Get("inbound"); inboundTag != "" {
+			inbound, loaded := service.FromContext[adapter.InboundManager](ctx).Get(inboundTag)
+			if !loaded {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("inbound not found: "+inboundTag))
+				return
+			}
+			metadata.Inbound = inbound.Tag()
+			metadata.InboundType = inbound.Type()
+		}
+		if source := r.URL.Query().Get("source"); source != "" {
+			metadata.Source = M.ParseSocksaddr(source)
+			if !metadata.Source.IsIP() {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("invalid source address"))
+				return
+			}
+		}
+		if metadata.Inbound != "" || metadata.Source.IsValid() {
+			queryCtx = adapter.WithContext(queryCtx, &metadata)
+		}
+		trace := &adapter.DNSQueryTrace{RuleIndex: -1}
+		queryCtx = adapter.WithDNSQueryTrace(queryCtx, trace)
+
 		msg := dns.Msg{}
 		msg.SetQuestion(dns.Fqdn(name), qType)
-		resp, err := router.Exchange(ctx, &msg, adapter.DNSQueryOptions{})
+		resp, err := router.Exchange(queryCtx, &msg, options)
 		if err != nil {
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, newError(err.Error()))
 			return
 		}
 
+		server := "internal"
+		if len(trace.Servers) > 0 {
+			server = strings.Join(trace.Servers, ",")
+		}
 		responseData := render.M{
 			"Status":   resp.Rcode,
 			"Question": resp.Question,
-			"Server":   "internal",
+			"Server":   server,
 			"TC":       resp.Truncated,
 			"RD":       resp.RecursionDesired,
 			"RA":       resp.RecursionAvailable,
 			"AD":       resp.AuthenticatedData,
 			"CD":       resp.CheckingDisabled,
 		}
-
-		rr2Json := func(rr dns.RR) render.M {
-			header := rr.Header()
-			return render.M{
-				"name": header.Name,
-				"type": header.Rrtype,
-				"TTL":  header.Ttl,
-				"data": rr.String()[len(header.String()):],
-			}
+		if trace.Rule != nil {
+			responseData["Rule"] = F.ToString(trace.Rule, " => ", trace.Rule.Action())
+			responseData["RuleIndex"] = trace.RuleIndex
+		} else if options.Transport == nil {
+			responseData["Rule"] = "final"
 		}
 
 		if len(resp.Answer) > 0 {
@@ -80,3 +126,127 @@ func queryDNS(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Requ
 		render.JSON(w, r, responseData)
 	}
 }
+
+func rr2Json(rr dns.RR) render.M {
+	header := rr.Header()
+	return render.M{
+		"name": header.Name,
+		"type": header.Rrtype,
+		"TTL":  header.Ttl,
+		"data": rr.String()[len(header.String()):],
+	}
+}
+
+// dnsCacheFilter selects cache entries by domain, query type and server. The
+// domain matches as a case-insensitive substring, or exactly if exact is set.
+type dnsCacheFilter struct {
+	domain string
+	exact  bool
+	qType  uint16
+	server string
+}
+
+func parseDNSCacheFilter(r *http.Request, router adapter.DNSRouter, exact bool) (dnsCacheFilter, error) {
+	filter := dnsCacheFilter{
+		domain: strings.ToLower(strings.TrimSuffix(r.URL.Query().Get("domain"), ".")),
+		exact:  exact,
+		server: r.URL.Query().Get("server"),
+	}
+	if qTypeStr := r.URL.Query().Get("type"); qTypeStr != "" {
+		qType, exist := dns.StringToType[strings.ToUpper(qTypeStr)]
+		if !exist {
+			return filter, E.New("invalid query type")
+		}
+		filter.qType = qType
+	}
+	// Entries only record their server with independent_cache.
+	if filter.server != "" && !router.IndependentCache() {
+		return filter, E.New("server filter requires independent_cache")
+	}
+	return filter, nil
+}
+
+func (f dnsCacheFilter) isEmpty() bool {
+	return f.domain == "" && f.qType == 0 && f.server == ""
+}
+
+func (f dnsCacheFilter) match(entry adapter.DNSCacheEntry) bool {
+	if f.domain != "" {
+		domain := strings.ToLower(strings.TrimSuffix(entry.Question.Name, "."))
+		if f.exact && domain != f.domain || !f.exact && !strings.Contains(domain, f.domain) {
+			return false
+		}
+	}
+	if f.qType != 0 && entry.Question.Qtype != f.qType {
+		return false
+	}
+	if f.server != "" && entry.Transport != f.server {
+		return false
+	}
+	return true
+}
+
+func getDNSCache(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseDNSCacheFilter(r, router, false)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		entries := common.Filter(router.CacheEntries(), filter.match)
+		slices.SortFunc(entries, func(a, b adapter.DNSCacheEntry) int {
+			if result := strings.Compare(a.Question.Name, b.Question.Name); result != 0 {
+				return result
+			}
+			if a.Question.Qtype != b.Question.Qtype {
+				return int(a.Question.Qtype) - int(b.Question.Qtype)
+			}
+			return strings.Compare(a.Transport, b.Transport)
+		})
+		timeNow := time.Now()
+		render.JSON(w, r, render.M{
+			"entries": common.Map(entries, func(entry adapter.DNSCacheEntry) render.M {
+				item := render.M{
+					"domain": strings.TrimSuffix(entry.Question.Name, "."),
+					"type":   dns.TypeToString[entry.Question.Qtype],
+					"rcode":  dns.RcodeToString[entry.Response.Rcode],
+					"answer": common.Map(entry.Response.Answer, func(it dns.RR) string {
+						return it.String()[len(it.Header().String()):]
+					}),
+				}
+				if entry.Transport != "" {
+					item["server"] = entry.Transport
+				}
+				if entry.ClientSubnet.IsValid() {
+					item["client_subnet"] = entry.ClientSubnet.String()
+				}
+				if !entry.ExpireAt.IsZero() {
+					item["ttl"] = int(entry.ExpireAt.Sub(timeNow).Seconds())
+				}
+				return item
+			}),
+			"total": len(entries),
+		})
+	}
+}
+
+func deleteDNSCache(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseDNSCacheFilter(r, router, true)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		if filter.isEmpty() {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("missing domain, type or server"))
+			return
+		}
+		removed := router.RemoveCache(filter.match)
+		render.JSON(w, r, render.M{
+			"removed": removed,
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package clashapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/service"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type stubDNSRouter struct {
+	adapter.DNSRouter
+	independentCache bool
+	entries          []adapter.DNSCacheEntry
+	trace            adapter.DNSQueryTrace
+	options          adapter.DNSQueryOptions
+	metadata         *adapter.InboundContext
+}
+
+func (r *stubDNSRouter) IndependentCache() bool {
+	return r.independentCache
+}
+
+func (r *stubDNSRouter) CacheEntries() []adapter.DNSCacheEntry {
+	return r.entries
+}
+
+func (r *stubDNSRouter) RemoveCache(match func(entry adapter.DNSCacheEntry) bool) int {
+	var kept []adapter.DNSCacheEntry
+	for _, entry := range r.entries {
+		if !match(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(r.entries) - len(kept)
+	r.entries = kept
+	return removed
+}
+
+func (r *stubDNSRouter) Exchange(ctx context.Context, message *dns.Msg, options adapter.DNSQueryOptions) (*dns.Msg, error) {
+	r.options = options
+	r.metadata = adapter.ContextFrom(ctx)
+	if trace := adapter.DNSQueryTraceFromContext(ctx); trace != nil {
+		*trace = r.trace
+	}
+	response := new(dns.Msg)
+	response.SetReply(message)
+	return response, nil
+}
+
+type stubDNSTransport struct {
+	adapter.DNSTransport
+	tag string
+}
+
+func (t *stubDNSTransport) Tag() string {
+	return t.tag
+}
+
+type stubDNSTransportManager struct {
+	adapter.DNSTransportManager
+	transports []adapter.DNSTransport
+}
+
+func (m *stubDNSTransportManager) Transport(tag string) (adapter.DNSTransport, bool) {
+	for _, transport := range m.transports {
+		if transport.Tag() == tag {
+			return transport, true
+		}
+	}
+	return nil, false
+}
+
+type stubInbound struct {
+	adapter.Inbound
+	tag string
+}
+
+func (i *stubInbound) Type() string {
+	return "tun"
+}
+
+func (i *stubInbound) Tag() string {
+	return i.tag
+}
+
+type stubInboundManager struct {
+	adapter.InboundManager
+	inbounds []adapter.Inbound
+}
+
+func (m *stubInboundManager) Get(tag string) (adapter.Inbound, bool) {
+	for _, inbound := range m.inbounds {
+		if inbound.Tag() == tag {
+			return inbound, true
+		}
+	}
+	return nil, false
+}
+
+type stubDNSRule struct {
+	adapter.DNSRule
+}
+
+func (r *stubDNSRule) String() string {
+	return "inbound=tun-in"
+}
+
+func (r *stubDNSRule) Action() adapter.RuleAction {
+	return &stubRuleAction{}
+}
+
+type stubRuleAction struct{}
+
+func (a *stubRuleAction) Type() string {
+	return "route"
+}
+
+func (a *stubRuleAction) String() string {
+	return "route(remote)"
+}
+
+func newDNSCacheTestRouter(independentCache bool) *stubDNSRouter {
+	newEntry := func(name string, qType uint16, transport string) adapter.DNSCacheEntry {
+		response := new(dns.Msg)
+		response.SetQuestion(name, qType)
+		if qType == dns.TypeA {
+			response.Answer = append(response.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+				A:   netip.MustParseAddr("192.0.2.1").AsSlice(),
+			})
+		}
+		return adapter.DNSCacheEntry{
+			Question:  response.Question[0],
+			Transport: transport,
+			Response:  response,
+			ExpireAt:  time.Now().Add(time.Minute),
+		}
+	}
+	router := &stubDNSRouter{independentCache: independentCache}
+	for _, transport := range []string{"remote", "local"} {
+		if !independentCache {
+			transport = ""
+		}
+		router.entries = append(router.entries,
+			newEntry("www.example.org.", dns.TypeA, transport),
+			newEntry("WWW.Example.com.", dns.TypeAAAA, transport),
+			newEntry("www.example.com.", dns.TypeA, transport),
+		)
+		if !independentCache {
+			break
+		}
+	}
+	return router
+}
+
+func serveDNSTest(t *testing.T, handler http.Handler, method string, target string) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	return recorder.Code, body
+}
+
+func TestGetDNSCache(t *testing.T) {
+	t.Parallel()
+	handler := dnsRouter(context.Background(), newDNSCacheTestRouter(true))
+
+	status, body := serveDNSTest(t, handler, "GET", "/cache?domain=EXAMPLE.COM")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 4, body["total"])
+	entries := body["entries"].([]any)
+	first := entries[0].(map[string]any)
+	require.Equal(t, "WWW.Example.com", first["domain"])
+	require.Equal(t, "AAAA", first["type"])
+	require.Equal(t, "local", first["server"])
+	require.Equal(t, "NOERROR", first["rcode"])
+	require.Contains(t, first, "ttl")
+
+	status, body = serveDNSTest(t, handler, "GET", "/cache?type=a&server=remote")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 2, body["total"])
+	for _, entry := range body["entries"].([]any) {
+		require.Equal(t, []any{"192.0.2.1"}, entry.(map[string]any)["answer"])
+	}
+
+	status, body = serveDNSTest(t, handler, "GET", "/cache?type=BAD")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid query type", body["message"])
+}
+
+func TestGetDNSCacheServerWithoutIndependentCache(t *testing.T) {
+	t.Parallel()
+	handler := dnsRouter(context.Background(), newDNSCacheTestRouter(false))
+
+	status, body := serveDNSTest(t, handler, "GET", "/cache")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 3, body["total"])
+	require.NotContains(t, body["entries"].([]any)[0], "server")
+
+	status, _ = serveDNSTest(t, handler, "GET", "/cache?server=remote")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestDeleteDNSCache(t *testing.T) {
+	t.Parallel()
+	router := newDNSCacheTestRouter(true)
+	handler := dnsRouter(context.Background(), router)
+
+	// The domain matches exactly, unlike the listing.
+	status, body := serveDNSTest(t, handler, "DELETE", "/cache?domain=example.com")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 0, body["removed"])
+
+	status, body = serveDNSTest(t, handler, "DELETE", "/cache?domain=www.example.com.&server=remote")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 2, body["removed"])
+	require.Len(t, router.entries, 4)
+
+	status, body = serveDNSTest(t, handler, "DELETE", "/cache?type=A")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 3, body["removed"])
+	require.Len(t, router.entries, 1)
+
+	status, body = serveDNSTest(t, handler, "DELETE", "/cache")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "missing domain, type or server", body["message"])
+	require.Len(t, router.entries, 1)
+
+	status, _ = serveDNSTest(t, handler, "DELETE", "/cache?type=BAD")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Len(t, router.entries, 1)
+}
+
+func TestDeleteDNSCacheServerWithoutIndependentCache(t *testing.T) {
+	t.Parallel()
+	router := newDNSCacheTestRouter(false)
+	handler := dnsRouter(context.Background(), router)
+
+	status, body := serveDNSTest(t, handler, "DELETE", "/cache?server=remote")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "server filter requires independent_cache", body["message"])
+	require.Len(t, router.entries, 3)
+
+	status, body = serveDNSTest(t, handler, "DELETE", "/cache?domain=www.example.com")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 2, body["removed"])
+}
+
+func newDNSQueryTestContext() context.Context {
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	service.MustRegister[adapter.DNSTransportManager](ctx, &stubDNSTransportManager{
+		transports: []adapter.DNSTransport{&stubDNSTransport{tag: "remote"}},
+	})
+	service.MustRegister[adapter.InboundManager](ctx, &stubInboundManager{
+		inbounds: []adapter.Inbound{&stubInbound{tag: "tun-in"}},
+	})
+	return ctx
+}
+
+func TestQueryDNSServer(t *testing.T) {
+	t.Parallel()
+	router := &stubDNSRouter{trace: adapter.DNSQueryTrace{RuleIndex: -1, Servers: []string{"remote"}}}
+	handler := dnsRouter(newDNSQueryTestContext(), router)
+
+	status, body := serveDNSTest(t, handler, "GET", "/query?name=www.example.com&type=AAAA&server=remote")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "remote", router.options.Transport.Tag())
+	require.Nil(t, router.metadata)
+	require.Equal(t, "remote", body["Server"])
+	require.NotContains(t, body, "Rule")
+	require.NotContains(t, body, "RuleIndex")
+
+	status, body = serveDNSTest(t, handler, "GET", "/query?name=www.example.com&server=missing")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "DNS server not found: missing", body["message"])
+}
+
+func TestQueryDNSRuleTrace(t *testing.T) {
+	t.Parallel()
+	router := &stubDNSRouter{trace: adapter.DNSQueryTrace{
+		Rule:      &stubDNSRule{},
+		RuleIndex: 2,
+		Servers:   []string{"local", "remote"},
+	}}
+	handler := dnsRouter(newDNSQueryTestContext(), router)
+
+	status, body := serveDNSTest(t, handler, "GET", "/query?name=www.example.com&inbound=tun-in&source=172.19.0.2")
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, router.options.Transport)
+	require.Equal(t, "tun-in", router.metadata.Inbound)
+	require.Equal(t, "tun", router.metadata.InboundType)
+	require.Equal(t, netip.MustParseAddr("172.19.0.2"), router.metadata.Source.Addr)
+	require.Equal(t, "local,remote", body["Server"])
+	require.Equal(t, "inbound=tun-in => route(remote)", body["Rule"])
+	require.EqualValues(t, 2, body["RuleIndex"])
+
+	router.trace = adapter.DNSQueryTrace{RuleIndex: -1}
+	status, body = serveDNSTest(t, handler, "GET", "/query?name=www.example.com&source=172.19.0.2:53")
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, router.metadata.Inbound)
+	require.Equal(t, uint16(53), router.metadata.Source.Port)
+	require.Equal(t, "internal", body["Server"])
+	require.Equal(t, "final", body["Rule"])
+	require.NotContains(t, body, "RuleIndex")
+
+	status, body = serveDNSTest(t, handler, "GET", "/query?name=www.example.com&inbound=missing")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "inbound not found: missing", body["message"])
+
+	status, body = serveDNSTest(t, handler, "GET", "/query?name=www.example.com&source=example.com")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid source address", body["message"])
+}
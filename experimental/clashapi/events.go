@@ -0,0 +1,89 @@
+package clashapi
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsutil"
+
+	"github.com/go-chi/render"
+)
+
+// getEvents streams events from the event bus, optionally only the types
+// given in the comma separated type parameter and the types below them.
+func getEvents(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventBus := service.FromContext[adapter.EventBus](ctx)
+		if eventBus == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("Events are not enabled"))
+			return
+		}
+		var types []string
+		if typeText := r.URL.Query().Get("type"); typeText != "" {
+			types = strings.Split(typeText, ",")
+		}
+
+		subscription, done, err := eventBus.Subscribe()
+		if err != nil {
+			render.Status(r, http.StatusNoContent)
+			return
+		}
+		defer eventBus.UnSubscribe(subscription)
+
+		var conn net.Conn
+		if r.Header.Get("Upgrade") == "websocket" {
+			conn, _, _, err = ws.UpgradeHTTP(r, w)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+
+		if conn == nil {
+			w.Header().Set("Content-Type", "application/json")
+			render.Status(r, http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+
+		buf := &bytes.Buffer{}
+		var event adapter.Event
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.Context().Done():
+				return
+			case event = <-subscription:
+			}
+			if len(types) > 0 && !common.Any(types, func(it string) bool {
+				return it == event.Type || strings.HasPrefix(event.Type, it+".")
+			}) {
+				continue
+			}
+			buf.Reset()
+			err = json.NewEncoder(buf).Encode(event)
+			if err != nil {
+				break
+			}
+			if conn == nil {
+				_, err = w.Write(buf.Bytes())
+				w.(http.Flusher).Flush()
+			} else {
+				err = wsutil.WriteServerText(conn, buf.Bytes())
+			}
+
+			if err != nil {
+				break
+			}
+		}
+	}
+}
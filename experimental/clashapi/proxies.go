@@ -30,6 +30,7 @@ func proxyRouter(server *Server, router adapter.Router) http.Handler {
 		r.Get("/", getProxy(server))
 		r.Get("/delay", getProxyDelay(server))
 		r.Put("/", updateProxy)
+		r.Delete("/", resetProxy)
 	})
 	return r
 }
@@ -79,6 +80,14 @@ func proxyInfo(server *Server, detour adapter.Outbound) *badjson.JSONObject {
 	if group, isGroup := detour.(adapter.OutboundGroup); isGroup {
 		info.Put("now", group.Now())
 		info.Put("all", group.All())
+	} else if relay, isRelay := detour.(adapter.OutboundRelay); isRelay {
+		info.Put("all", relay.Hops())
+	}
+	switch proxy := detour.(type) {
+	case adapter.PinnableGroup:
+		info.Put("fixed", proxy.Pinned())
+	case adapter.ExcludableGroup:
+		info.Put("excluded", proxy.Excluded())
 	}
 	return &info
 }
@@ -156,7 +165,8 @@ func getProxy(server *Server) func(w http.ResponseWriter, r *http.Request) {
 }
 
 type UpdateProxyRequest struct {
-	Name string `json:"name"`
+	Name    string    `json:"name"`
+	Exclude *[]string `json:"exclude"`
 }
 
 func updateProxy(w http.ResponseWriter, r *http.Request) {
@@ -168,19 +178,51 @@ func updateProxy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
-	selector, ok := proxy.(*group.Selector)
-	if !ok {
+	switch outboundGroup := proxy.(type) {
+	case *group.Selector:
+		if !outboundGroup.SelectOutbound(req.Name) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("Selector update error: not found"))
+			return
+		}
+	case adapter.PinnableGroup:
+		if req.Name == "" || !outboundGroup.Pin(req.Name) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(C.ProxyDisplayName(outboundGroup.Type())+" update error: not found"))
+			return
+		}
+	case adapter.ExcludableGroup:
+		if req.Exclude == nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(C.ProxyDisplayName(outboundGroup.Type())+" update error: missing exclude"))
+			return
+		}
+		if err := outboundGroup.Exclude(*req.Exclude); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(C.ProxyDisplayName(outboundGroup.Type())+" update error: "+err.Error()))
+			return
+		}
+	default:
 		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, newError("Must be a Selector"))
+		render.JSON(w, r, newError("Must be a Selector, Fallback, Smart or LoadBalance"))
 		return
 	}
 
-	if !selector.SelectOutbound(req.Name) {
+	render.NoContent(w, r)
+}
+
+func resetProxy(w http.ResponseWriter, r *http.Request) {
+	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
+	switch outboundGroup := proxy.(type) {
+	case adapter.PinnableGroup:
+		outboundGroup.Pin("")
+	case adapter.ExcludableGroup:
+		_ = outboundGroup.Exclude(nil)
+	default:
 		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, newError("Selector update error: not found"))
+		render.JSON(w, r, newError("Must be a Fallback, Smart or LoadBalance"))
 		return
 	}
-
 	render.NoContent(w, r)
 }
 
@@ -116,6 +116,9 @@ func NewServer(ctx context.Context, logFactory log.ObservableFactory, options op
 		r.Get("/", hello(options.ExternalUI != ""))
 		r.Get("/logs", getLogs(logFactory))
 		r.Get("/traffic", traffic(trafficManager))
+		r.Get("/traffic/history", trafficHistory(ctx))
+		r.Get("/events", getEvents(ctx))
+		r.Get("/capture", getCapture(ctx, s.logger))
 		r.Get("/version", version)
 		r.Mount("/configs", configRouter(s, logFactory))
 		r.Mount("/proxies", proxyRouter(s, s.router))
@@ -126,7 +129,7 @@ func NewServer(ctx context.Context, logFactory log.ObservableFactory, options op
 		r.Mount("/script", scriptRouter())
 		r.Mount("/profile", profileRouter())
 		r.Mount("/cache", cacheRouter(ctx))
-		r.Mount("/dns", dnsRouter(s.dnsRouter))
+		r.Mount("/dns", dnsRouter(ctx, s.dnsRouter))
 
 		s.setupMetaAPI(r)
 	})
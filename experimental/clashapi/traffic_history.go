@@ -0,0 +1,81 @@
+package clashapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/service"
+
+	"github.com/go-chi/render"
+)
+
+type TrafficHistoryEntry struct {
+	Time     time.Time `json:"time"`
+	Name     string    `json:"name"`
+	Upload   int64     `json:"upload"`
+	Download int64     `json:"download"`
+}
+
+func trafficHistory(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		history := service.FromContext[adapter.TrafficHistory](ctx)
+		if history == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("Traffic history is not enabled"))
+			return
+		}
+		query := r.URL.Query()
+		groupBy := query.Get("group_by")
+		if groupBy == "" {
+			groupBy = adapter.TrafficGroupOutbound
+		}
+		period := query.Get("period")
+		if period == "" {
+			period = adapter.TrafficPeriodDay
+		}
+		var since, until time.Time
+		var err error
+		if sinceString := query.Get("since"); sinceString != "" {
+			since, err = time.Parse(time.RFC3339, sinceString)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("Invalid since: "+err.Error()))
+				return
+			}
+		}
+		if untilString := query.Get("until"); untilString != "" {
+			until, err = time.Parse(time.RFC3339, untilString)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("Invalid until: "+err.Error()))
+				return
+			}
+		}
+		entries, err := history.TrafficHistory(period, groupBy, since, until)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		name := query.Get("name")
+		result := make([]TrafficHistoryEntry, 0, len(entries))
+		for _, entry := range entries {
+			if name != "" && entry.Name != name {
+				continue
+			}
+			result = append(result, TrafficHistoryEntry{
+				Time:     entry.Time,
+				Name:     entry.Name,
+				Upload:   entry.Upload,
+				Download: entry.Download,
+			})
+		}
+		render.JSON(w, r, render.M{
+			"group_by": groupBy,
+			"period":   period,
+			"history":  result,
+		})
+	}
+}
@@ -104,6 +104,7 @@ func (m *Manager) Snapshot() *Snapshot {
 		Upload:      m.uploadTotal.Load(),
 		Download:    m.downloadTotal.Load(),
 		Connections: connections,
+		Total:       len(connections),
 		Memory:      m.memory,
 	}
 }
@@ -117,6 +118,7 @@ type Snapshot struct {
 	Download    int64
 	Upload      int64
 	Connections []Tracker
+	Total       int
 	Memory      uint64
 }
 
@@ -125,6 +127,7 @@ func (s *Snapshot) MarshalJSON() ([]byte, error) {
 		"downloadTotal": s.Download,
 		"uploadTotal":   s.Upload,
 		"connections":   common.Map(s.Connections, func(t Tracker) TrackerMetadata { return t.Metadata() }),
+		"total":         s.Total,
 		"memory":        s.Memory,
 	})
 }
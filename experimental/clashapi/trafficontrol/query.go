@@ -0,0 +1,217 @@
+package trafficontrol
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	SortStart    = "start"
+	SortUpload   = "upload"
+	SortDownload = "download"
+	SortHost     = "host"
+)
+
+// Query selects connections by their metadata, then sorts and pages them.
+// Inbound, outbound, chain, network and user match exactly, the others are
+// case-insensitive substrings. Host matches the domain and both addresses.
+type Query struct {
+	Network    string
+	Inbound    string
+	Outbound   string
+	Chain      string
+	Rule       string
+	Host       string
+	User       string
+	Process    string
+	Sort       string
+	Descending bool
+	Offset     int
+	Limit      int
+}
+
+func (q Query) Validate() error {
+	switch q.Sort {
+	case "", SortStart, SortUpload, SortDownload, SortHost:
+	default:
+		return E.New("unknown sort key: ", q.Sort)
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return E.New("negative offset or limit")
+	}
+	return nil
+}
+
+// Filtered returns whether the query selects a subset of connections.
+func (q Query) Filtered() bool {
+	return q.Network != "" || q.Inbound != "" || q.Outbound != "" || q.Chain != "" ||
+		q.Rule != "" || q.Host != "" || q.User != "" || q.Process != ""
+}
+
+func (q Query) Match(metadata TrackerMetadata) bool {
+	if q.Network != "" && metadata.Metadata.Network != q.Network {
+		return false
+	}
+	if q.Inbound != "" && metadata.Metadata.Inbound != q.Inbound && metadata.InboundName() != q.Inbound {
+		return false
+	}
+	if q.Outbound != "" && metadata.Outbound != q.Outbound {
+		return false
+	}
+	if q.Chain != "" && !common.Contains(metadata.Chain, q.Chain) {
+		return false
+	}
+	if q.Rule != "" && !containsFold(metadata.RuleName(), q.Rule) {
+		return false
+	}
+	if q.Host != "" && !containsFold(metadata.Host(), q.Host) &&
+		!containsFold(metadata.Metadata.Destination.Addr.String(), q.Host) &&
+		!containsFold(metadata.Metadata.Source.Addr.String(), q.Host) {
+		return false
+	}
+	if q.User != "" && metadata.Metadata.User != q.User {
+		return false
+	}
+	if q.Process != "" && !containsFold(metadata.ProcessPath(), q.Process) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the requested page of the matching connections, oldest first
+// unless sorted otherwise, and the number of matching connections.
+func (q Query) Apply(connections []Tracker) ([]Tracker, int) {
+	entries := make([]queryEntry, 0, len(connections))
+	for _, connection := range connections {
+		metadata := connection.Metadata()
+		if !q.Match(metadata) {
+			continue
+		}
+		entries = append(entries, queryEntry{
+			tracker:  connection,
+			metadata: metadata,
+			upload:   metadata.Upload.Load(),
+			download: metadata.Download.Load(),
+		})
+	}
+	slices.SortStableFunc(entries, func(a, b queryEntry) int {
+		result := q.compare(a, b)
+		if result == 0 {
+			result = a.metadata.CreatedAt.Compare(b.metadata.CreatedAt)
+		}
+		if q.Descending {
+			return -result
+		}
+		return result
+	})
+	total := len(entries)
+	if q.Offset >= len(entries) {
+		entries = nil
+	} else {
+		entries = entries[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(entries) {
+		entries = entries[:q.Limit]
+	}
+	return common.Map(entries, func(it queryEntry) Tracker {
+		return it.tracker
+	}), total
+}
+
+func (q Query) compare(a, b queryEntry) int {
+	switch q.Sort {
+	case SortUpload:
+		return cmp.Compare(a.upload, b.upload)
+	case SortDownload:
+		return cmp.Compare(a.download, b.download)
+	case SortHost:
+		return strings.Compare(a.metadata.Host(), b.metadata.Host())
+	default:
+		return a.metadata.CreatedAt.Compare(b.metadata.CreatedAt)
+	}
+}
+
+type queryEntry struct {
+	tracker  Tracker
+	metadata TrackerMetadata
+	upload   int64
+	download int64
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// ConnectionDelta is the traffic of a connection since the previous diff.
+type ConnectionDelta struct {
+	ID       uuid.UUID `json:"id"`
+	Upload   int64     `json:"upload"`
+	Download int64     `json:"download"`
+}
+
+type ConnectionDiff struct {
+	Added   []TrackerMetadata
+	Updated []ConnectionDelta
+	Removed []uuid.UUID
+}
+
+// Differ remembers the connections sent to a client, so that only the
+// changes since the previous call are sent next time.
+type Differ struct {
+	sent map[uuid.UUID]ConnectionDelta
+}
+
+func NewDiffer() *Differ {
+	return &Differ{
+		sent: make(map[uuid.UUID]ConnectionDelta),
+	}
+}
+
+func (d *Differ) Next(connections []Tracker) ConnectionDiff {
+	diff := ConnectionDiff{
+		Added:   []TrackerMetadata{},
+		Updated: []ConnectionDelta{},
+		Removed: []uuid.UUID{},
+	}
+	current := make(map[uuid.UUID]ConnectionDelta, len(connections))
+	for _, connection := range connections {
+		metadata := connection.Metadata()
+		counter := ConnectionDelta{
+			ID:       metadata.ID,
+			Upload:   metadata.Upload.Load(),
+			Download: metadata.Download.Load(),
+		}
+		current[metadata.ID] = counter
+		previous, loaded := d.sent[metadata.ID]
+		if !loaded {
+			// Counted from the values recorded here, not the ones at encoding.
+			metadata.Upload = new(atomic.Int64)
+			metadata.Upload.Store(counter.Upload)
+			metadata.Download = new(atomic.Int64)
+			metadata.Download.Store(counter.Download)
+			diff.Added = append(diff.Added, metadata)
+			continue
+		}
+		if counter.Upload != previous.Upload || counter.Download != previous.Download {
+			diff.Updated = append(diff.Updated, ConnectionDelta{
+				ID:       metadata.ID,
+				Upload:   counter.Upload - previous.Upload,
+				Download: counter.Download - previous.Download,
+			})
+		}
+	}
+	for id := range d.sent {
+		if _, loaded := current[id]; !loaded {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	d.sent = current
+	return diff
+}
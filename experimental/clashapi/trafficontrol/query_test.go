@@ -0,0 +1,107 @@
+package trafficontrol
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTracker struct {
+	metadata TrackerMetadata
+}
+
+func (t *stubTracker) Metadata() TrackerMetadata {
+	return t.metadata
+}
+
+func (t *stubTracker) Close() error {
+	return nil
+}
+
+func newStubTracker(createdAt time.Time, outbound string, host string, upload int64) *stubTracker {
+	id, _ := uuid.NewV4()
+	tracker := &stubTracker{metadata: TrackerMetadata{
+		ID: id,
+		Metadata: adapter.InboundContext{
+			Network:     N.NetworkTCP,
+			Inbound:     "mixed-in",
+			InboundType: "mixed",
+			Domain:      host,
+			Source:      M.SocksaddrFrom(netip.MustParseAddr("192.168.1.10"), 50000),
+		},
+		CreatedAt: createdAt,
+		Upload:    new(atomic.Int64),
+		Download:  new(atomic.Int64),
+		Chain:     []string{outbound, "proxy"},
+		Outbound:  outbound,
+	}}
+	tracker.metadata.Upload.Store(upload)
+	return tracker
+}
+
+func TestQueryApply(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	a := newStubTracker(now, "vps-a", "www.example.com", 300)
+	b := newStubTracker(now.Add(time.Second), "vps-b", "api.example.org", 100)
+	c := newStubTracker(now.Add(2*time.Second), "vps-a", "cdn.example.net", 200)
+	connections := []Tracker{c, a, b}
+
+	result, total := Query{}.Apply(connections)
+	require.Equal(t, []Tracker{a, b, c}, result)
+	require.Equal(t, 3, total)
+
+	result, total = Query{Outbound: "vps-a"}.Apply(connections)
+	require.Equal(t, []Tracker{a, c}, result)
+	require.Equal(t, 2, total)
+
+	result, _ = Query{Chain: "proxy", Host: "EXAMPLE.ORG"}.Apply(connections)
+	require.Equal(t, []Tracker{b}, result)
+
+	result, _ = Query{Host: "192.168.1"}.Apply(connections)
+	require.Len(t, result, 3)
+
+	result, _ = Query{Inbound: "mixed/mixed-in", Rule: "final"}.Apply(connections)
+	require.Len(t, result, 3)
+
+	result, total = Query{Sort: SortUpload, Descending: true, Offset: 1, Limit: 1}.Apply(connections)
+	require.Equal(t, []Tracker{c}, result)
+	require.Equal(t, 3, total)
+
+	result, _ = Query{Offset: 5}.Apply(connections)
+	require.Empty(t, result)
+
+	require.Error(t, Query{Sort: "unknown"}.Validate())
+}
+
+func TestDiffer(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	a := newStubTracker(now, "vps-a", "a.example.com", 10)
+	b := newStubTracker(now, "vps-b", "b.example.com", 20)
+	differ := NewDiffer()
+
+	diff := differ.Next([]Tracker{a, b})
+	require.Len(t, diff.Added, 2)
+	require.Empty(t, diff.Updated)
+	require.Empty(t, diff.Removed)
+
+	a.metadata.Upload.Add(5)
+	diff = differ.Next([]Tracker{a, b})
+	require.Empty(t, diff.Added)
+	require.Equal(t, []ConnectionDelta{{ID: a.metadata.ID, Upload: 5}}, diff.Updated)
+
+	c := newStubTracker(now, "vps-a", "c.example.com", 0)
+	diff = differ.Next([]Tracker{a, c})
+	require.Equal(t, []uuid.UUID{c.metadata.ID}, common.Map(diff.Added, func(it TrackerMetadata) uuid.UUID { return it.ID }))
+	require.Equal(t, []uuid.UUID{b.metadata.ID}, diff.Removed)
+}
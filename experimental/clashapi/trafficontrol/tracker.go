@@ -29,63 +29,73 @@ type TrackerMetadata struct {
 }
 
 func (t TrackerMetadata) MarshalJSON() ([]byte, error) {
-	var inbound string
-	if t.Metadata.Inbound != "" {
-		inbound = t.Metadata.InboundType + "/" + t.Metadata.Inbound
-	} else {
-		inbound = t.Metadata.InboundType
-	}
-	var domain string
-	if t.Metadata.Domain != "" {
-		domain = t.Metadata.Domain
-	} else {
-		domain = t.Metadata.Destination.Fqdn
-	}
-	var processPath string
-	if t.Metadata.ProcessInfo != nil {
-		if t.Metadata.ProcessInfo.ProcessPath != "" {
-			processPath = t.Metadata.ProcessInfo.ProcessPath
-		} else if t.Metadata.ProcessInfo.PackageName != "" {
-			processPath = t.Metadata.ProcessInfo.PackageName
-		}
-		if processPath == "" {
-			if t.Metadata.ProcessInfo.UserId != -1 {
-				processPath = F.ToString(t.Metadata.ProcessInfo.UserId)
-			}
-		} else if t.Metadata.ProcessInfo.User != "" {
-			processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.User, ")")
-		} else if t.Metadata.ProcessInfo.UserId != -1 {
-			processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.UserId, ")")
-		}
-	}
-	var rule string
-	if t.Rule != nil {
-		rule = F.ToString(t.Rule, " => ", t.Rule.Action())
-	} else {
-		rule = "final"
-	}
 	return json.Marshal(map[string]any{
 		"id": t.ID,
 		"metadata": map[string]any{
 			"network":         t.Metadata.Network,
-			"type":            inbound,
+			"type":            t.InboundName(),
 			"sourceIP":        t.Metadata.Source.Addr,
 			"destinationIP":   t.Metadata.Destination.Addr,
 			"sourcePort":      F.ToString(t.Metadata.Source.Port),
 			"destinationPort": F.ToString(t.Metadata.Destination.Port),
-			"host":            domain,
+			"host":            t.Host(),
 			"dnsMode":         "normal",
-			"processPath":     processPath,
+			"processPath":     t.ProcessPath(),
 		},
 		"upload":      t.Upload.Load(),
 		"download":    t.Download.Load(),
 		"start":       t.CreatedAt,
 		"chains":      t.Chain,
-		"rule":        rule,
+		"rule":        t.RuleName(),
 		"rulePayload": "",
 	})
 }
 
+// InboundName returns the inbound as type/tag, or only the type if the
+// inbound has no tag.
+func (t TrackerMetadata) InboundName() string {
+	if t.Metadata.Inbound != "" {
+		return t.Metadata.InboundType + "/" + t.Metadata.Inbound
+	}
+	return t.Metadata.InboundType
+}
+
+func (t TrackerMetadata) Host() string {
+	if t.Metadata.Domain != "" {
+		return t.Metadata.Domain
+	}
+	return t.Metadata.Destination.Fqdn
+}
+
+func (t TrackerMetadata) ProcessPath() string {
+	if t.Metadata.ProcessInfo == nil {
+		return ""
+	}
+	var processPath string
+	if t.Metadata.ProcessInfo.ProcessPath != "" {
+		processPath = t.Metadata.ProcessInfo.ProcessPath
+	} else if t.Metadata.ProcessInfo.PackageName != "" {
+		processPath = t.Metadata.ProcessInfo.PackageName
+	}
+	if processPath == "" {
+		if t.Metadata.ProcessInfo.UserId != -1 {
+			processPath = F.ToString(t.Metadata.ProcessInfo.UserId)
+		}
+	} else if t.Metadata.ProcessInfo.User != "" {
+		processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.User, ")")
+	} else if t.Metadata.ProcessInfo.UserId != -1 {
+		processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.UserId, ")")
+	}
+	return processPath
+}
+
+func (t TrackerMetadata) RuleName() string {
+	if t.Rule != nil {
+		return F.ToString(t.Rule, " => ", t.Rule.Action())
+	}
+	return "final"
+}
+
 type Tracker interface {
 	Metadata() TrackerMetadata
 	Close() error
@@ -118,33 +128,59 @@ func (tt *TCPConn) WriterReplaceable() bool {
 	return true
 }
 
-func NewTCPTracker(conn net.Conn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *TCPConn {
-	id, _ := uuid.NewV4()
-	var (
-		chain        []string
-		next         string
-		outbound     string
-		outboundType string
-	)
-	if matchOutbound != nil {
-		next = matchOutbound.Tag()
-	} else {
-		next = outboundManager.Default().Tag()
-	}
+// appendChain follows groups from next to the outbound actually used,
+// expanding every hop of relays.
+func appendChain(chain []string, outboundManager adapter.OutboundManager, next string) ([]string, adapter.Outbound) {
+	var lastOutbound adapter.Outbound
 	for {
 		detour, loaded := outboundManager.Outbound(next)
 		if !loaded {
 			break
 		}
 		chain = append(chain, next)
-		outbound = detour.Tag()
-		outboundType = detour.Type()
+		lastOutbound = detour
+		if relay, isRelay := detour.(adapter.OutboundRelay); isRelay {
+			for _, hop := range relay.Hops() {
+				var hopOutbound adapter.Outbound
+				chain, hopOutbound = appendChain(chain, outboundManager, hop)
+				if hopOutbound != nil {
+					lastOutbound = hopOutbound
+				}
+			}
+			break
+		}
 		group, isGroup := detour.(adapter.OutboundGroup)
 		if !isGroup {
 			break
 		}
 		next = group.Now()
 	}
+	return chain, lastOutbound
+}
+
+// OutboundChain returns the outbounds a connection routed to matchOutbound
+// passes through, in routing order, and the outbound that finally dials.
+func OutboundChain(outboundManager adapter.OutboundManager, matchOutbound adapter.Outbound) ([]string, adapter.Outbound) {
+	var next string
+	if matchOutbound != nil {
+		next = matchOutbound.Tag()
+	} else {
+		next = outboundManager.Default().Tag()
+	}
+	return appendChain(nil, outboundManager, next)
+}
+
+func NewTCPTracker(conn net.Conn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *TCPConn {
+	id, _ := uuid.NewV4()
+	var (
+		outbound     string
+		outboundType string
+	)
+	chain, lastOutbound := OutboundChain(outboundManager, matchOutbound)
+	if lastOutbound != nil {
+		outbound = lastOutbound.Tag()
+		outboundType = lastOutbound.Type()
+	}
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
 	tracker := &TCPConn{
@@ -202,29 +238,13 @@ func (ut *UDPConn) WriterReplaceable() bool {
 func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *UDPConn {
 	id, _ := uuid.NewV4()
 	var (
-		chain        []string
-		next         string
 		outbound     string
 		outboundType string
 	)
-	if matchOutbound != nil {
-		next = matchOutbound.Tag()
-	} else {
-		next = outboundManager.Default().Tag()
-	}
-	for {
-		detour, loaded := outboundManager.Outbound(next)
-		if !loaded {
-			break
-		}
-		chain = append(chain, next)
-		outbound = detour.Tag()
-		outboundType = detour.Type()
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
+	chain, lastOutbound := OutboundChain(outboundManager, matchOutbound)
+	if lastOutbound != nil {
+		outbound = lastOutbound.Tag()
+		outboundType = lastOutbound.Type()
 	}
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
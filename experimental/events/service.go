@@ -0,0 +1,294 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/conntrack"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/byteformats"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/observable"
+	"github.com/sagernet/sing/common/x/list"
+	"github.com/sagernet/sing/service"
+
+	"github.com/miekg/dns"
+)
+
+// dnsFailureThreshold is the number of consecutive failed exchanges after
+// which a DNS server is reported as failing.
+const dnsFailureThreshold = 3
+
+var (
+	_ adapter.LifecycleService = (*Service)(nil)
+	_ adapter.EventBus         = (*Service)(nil)
+	_ adapter.DNSQueryTracker  = (*Service)(nil)
+)
+
+// Service is the event bus. Besides the events emitted by other components,
+// it derives health events from the shared URL test history, DNS events from
+// query results and memory events from the connection killer, and delivers
+// all of them to the subscribers and the configured sinks.
+type Service struct {
+	ctx            context.Context
+	logger         log.Logger
+	outbound       adapter.OutboundManager
+	direct         N.Dialer
+	subscriber     *observable.Subscriber[adapter.Event]
+	observer       *observable.Observer[adapter.Event]
+	sinks          []*sink
+	history        *urltest.HistoryStorage
+	killerCallback *list.Element[conntrack.KillerCallback]
+	access         sync.Mutex
+	health         map[string]bool
+	groupDown      map[string]bool
+	dnsFailures    map[string]int
+	done           chan struct{}
+	closeOnce      sync.Once
+	wg             sync.WaitGroup
+}
+
+func NewService(ctx context.Context, logger log.Logger, options option.EventsOptions) (*Service, error) {
+	subscriber := observable.NewSubscriber[adapter.Event](128)
+	s := &Service{
+		ctx:         ctx,
+		logger:      logger,
+		outbound:    service.FromContext[adapter.OutboundManager](ctx),
+		subscriber:  subscriber,
+		observer:    observable.NewObserver[adapter.Event](subscriber, 64),
+		health:      make(map[string]bool),
+		groupDown:   make(map[string]bool),
+		dnsFailures: make(map[string]int),
+		done:        make(chan struct{}),
+	}
+	if len(options.Webhooks) > 0 {
+		defaultDialer, err := dialer.NewDefault(ctx, option.DialerOptions{})
+		if err != nil {
+			return nil, E.Cause(err, "create webhook dialer")
+		}
+		// resolved by the DNS router on each dial, so DNS servers replaced
+		// by a reload are picked up
+		s.direct = dialer.NewResolveDialer(ctx, defaultDialer, true, "", adapter.DNSQueryOptions{}, 0)
+	}
+	for i, webhookOptions := range options.Webhooks {
+		sink, err := newWebhookSink(s, webhookOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse webhooks[", i, "]")
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+	for i, execOptions := range options.Exec {
+		sink, err := newExecSink(execOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse exec[", i, "]")
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+	return s, nil
+}
+
+func (s *Service) Name() string {
+	return "events"
+}
+
+func (s *Service) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateInitialize {
+		return nil
+	}
+	for _, it := range s.sinks {
+		s.wg.Add(1)
+		go s.loopDeliver(it)
+	}
+	if history := service.PtrFromContext[urltest.HistoryStorage](s.ctx); history != nil {
+		s.history = history
+	} else if clashServer := service.FromContext[adapter.ClashServer](s.ctx); clashServer != nil {
+		s.history, _ = clashServer.HistoryStorage().(*urltest.HistoryStorage)
+	}
+	if s.history != nil {
+		s.history.SetObserver(s.updateHealth)
+	}
+	s.killerCallback = conntrack.RegisterKillerCallback(s.memoryKilled)
+	return nil
+}
+
+func (s *Service) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.history != nil {
+			s.history.SetObserver(nil)
+		}
+		if s.killerCallback != nil {
+			conntrack.UnregisterKillerCallback(s.killerCallback)
+		}
+		close(s.done)
+		s.wg.Wait()
+		err = s.observer.Close()
+	})
+	return err
+}
+
+func (s *Service) Emit(event adapter.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.subscriber.Emit(event)
+	for _, it := range s.sinks {
+		if !it.match(event.Type) {
+			continue
+		}
+		select {
+		case it.queue <- event:
+		default:
+			s.logger.Warn("event queue of ", it.name, " is full, dropped ", event.Type)
+		}
+	}
+}
+
+func (s *Service) Subscribe() (subscription observable.Subscription[adapter.Event], done <-chan struct{}, err error) {
+	return s.observer.Subscribe()
+}
+
+func (s *Service) UnSubscribe(subscription observable.Subscription[adapter.Event]) {
+	s.observer.UnSubscribe(subscription)
+}
+
+func (s *Service) loopDeliver(it *sink) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-it.queue:
+			ctx, cancel := context.WithTimeout(s.ctx, it.timeout)
+			err := it.deliver(ctx, event)
+			cancel()
+			if err != nil {
+				s.logger.Error("deliver ", event.Type, " to ", it.name, ": ", err)
+			}
+		}
+	}
+}
+
+// updateHealth is called on every change of the URL test history. The first
+// result of an outbound is only reported if the check failed, so that a
+// restart does not report every outbound as healthy.
+func (s *Service) updateHealth(tag string, history *adapter.URLTestHistory) {
+	healthy := history != nil
+	s.access.Lock()
+	previous, loaded := s.health[tag]
+	if loaded && previous == healthy {
+		s.access.Unlock()
+		return
+	}
+	s.health[tag] = healthy
+	var events []adapter.Event
+	if loaded || !healthy {
+		event := adapter.Event{Tag: tag}
+		if healthy {
+			event.Type = adapter.EventOutboundHealthy
+			event.Message = F.ToString(history.Delay, "ms")
+		} else {
+			event.Type = adapter.EventOutboundUnhealthy
+		}
+		events = append(events, event)
+	}
+	events = append(events, s.updateGroups()...)
+	s.access.Unlock()
+	for _, event := range events {
+		s.Emit(event)
+	}
+}
+
+// updateGroups reports the groups whose members all failed their last check,
+// and the ones that have a healthy member again. Members that were never
+// checked are not counted as down.
+func (s *Service) updateGroups() []adapter.Event {
+	if s.outbound == nil {
+		return nil
+	}
+	var events []adapter.Event
+	for _, outbound := range s.outbound.Outbounds() {
+		group, isGroup := outbound.(adapter.OutboundGroup)
+		if !isGroup {
+			continue
+		}
+		members := group.All()
+		down := len(members) > 0 && common.All(members, func(it string) bool {
+			healthy, loaded := s.health[s.realTag(it)]
+			return loaded && !healthy
+		})
+		if down == s.groupDown[group.Tag()] {
+			continue
+		}
+		if down {
+			s.groupDown[group.Tag()] = true
+			events = append(events, adapter.Event{Type: adapter.EventGroupDown, Tag: group.Tag()})
+		} else {
+			delete(s.groupDown, group.Tag())
+			events = append(events, adapter.Event{Type: adapter.EventGroupUp, Tag: group.Tag(), Outbound: group.Now()})
+		}
+	}
+	return events
+}
+
+// realTag returns the tag the URL test history of a member is stored by,
+// which is the selected outbound for a nested group.
+func (s *Service) realTag(tag string) string {
+	outbound, loaded := s.outbound.Outbound(tag)
+	if !loaded {
+		return tag
+	}
+	if group, isGroup := outbound.(adapter.OutboundGroup); isGroup {
+		if now := group.Now(); now != "" {
+			return now
+		}
+	}
+	return tag
+}
+
+func (s *Service) memoryKilled(usage uint64, closed int) {
+	s.Emit(adapter.Event{
+		Type:    adapter.EventMemoryKilled,
+		Message: F.ToString("memory usage ", byteformats.FormatMemoryBytes(usage), ", closed ", closed, " connections"),
+	})
+}
+
+func (s *Service) CacheLookup(transport adapter.DNSTransport, hit bool) {
+}
+
+func (s *Service) Exchanged(transport adapter.DNSTransport, response *dns.Msg, elapsed time.Duration, err error) {
+	if err != nil && E.IsClosedOrCanceled(err) {
+		return
+	}
+	failed := err != nil || response.Rcode == dns.RcodeServerFailure
+	tag := transport.Tag()
+	s.access.Lock()
+	failures := s.dnsFailures[tag]
+	if failed {
+		failures++
+		s.dnsFailures[tag] = failures
+	} else {
+		delete(s.dnsFailures, tag)
+	}
+	s.access.Unlock()
+	switch {
+	case failed && failures == dnsFailureThreshold:
+		var message string
+		if err != nil {
+			message = err.Error()
+		} else {
+			message = dns.RcodeToString[response.Rcode]
+		}
+		s.Emit(adapter.Event{Type: adapter.EventDNSFailing, Tag: tag, Message: message})
+	case !failed && failures >= dnsFailureThreshold:
+		s.Emit(adapter.Event{Type: adapter.EventDNSRecovered, Tag: tag})
+	}
+}
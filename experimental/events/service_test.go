@@ -0,0 +1,176 @@
+package events
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/observable"
+	"github.com/sagernet/sing/service"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type stubOutboundManager struct {
+	adapter.OutboundManager
+	outbounds []adapter.Outbound
+}
+
+func (m *stubOutboundManager) Outbounds() []adapter.Outbound {
+	return m.outbounds
+}
+
+func (m *stubOutboundManager) Outbound(tag string) (adapter.Outbound, bool) {
+	for _, outbound := range m.outbounds {
+		if outbound.Tag() == tag {
+			return outbound, true
+		}
+	}
+	return nil, false
+}
+
+type stubGroup struct {
+	adapter.OutboundGroup
+	tag     string
+	members []string
+}
+
+func (g *stubGroup) Tag() string {
+	return g.tag
+}
+
+func (g *stubGroup) Now() string {
+	return g.members[0]
+}
+
+func (g *stubGroup) All() []string {
+	return g.members
+}
+
+type stubTransport struct {
+	adapter.DNSTransport
+	tag string
+}
+
+func (t *stubTransport) Tag() string {
+	return t.tag
+}
+
+func nextEvent(t *testing.T, subscription observable.Subscription[adapter.Event]) adapter.Event {
+	select {
+	case event := <-subscription:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("missing event")
+		return adapter.Event{}
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *urltest.HistoryStorage, observable.Subscription[adapter.Event]) {
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	history := urltest.NewHistoryStorage()
+	service.MustRegisterPtr(ctx, history)
+	service.MustRegister[adapter.OutboundManager](ctx, &stubOutboundManager{
+		outbounds: []adapter.Outbound{&stubGroup{tag: "group", members: []string{"a", "b"}}},
+	})
+	eventService, err := NewService(ctx, log.NewNOPFactory().Logger(), option.EventsOptions{})
+	require.NoError(t, err)
+	require.NoError(t, eventService.Start(adapter.StartStateInitialize))
+	t.Cleanup(func() {
+		eventService.Close()
+	})
+	subscription, _, err := eventService.Subscribe()
+	require.NoError(t, err)
+	return eventService, history, subscription
+}
+
+func TestHealthEvents(t *testing.T) {
+	t.Parallel()
+	_, history, subscription := newTestService(t)
+
+	history.StoreURLTestHistory("a", &adapter.URLTestHistory{Delay: 100})
+	history.DeleteURLTestHistory("b")
+	require.Equal(t, adapter.EventOutboundUnhealthy, nextEvent(t, subscription).Type)
+
+	history.DeleteURLTestHistory("a")
+	event := nextEvent(t, subscription)
+	require.Equal(t, adapter.EventOutboundUnhealthy, event.Type)
+	require.Equal(t, "a", event.Tag)
+	event = nextEvent(t, subscription)
+	require.Equal(t, adapter.EventGroupDown, event.Type)
+	require.Equal(t, "group", event.Tag)
+
+	history.StoreURLTestHistory("b", &adapter.URLTestHistory{Delay: 50})
+	event = nextEvent(t, subscription)
+	require.Equal(t, adapter.EventOutboundHealthy, event.Type)
+	require.Equal(t, "b", event.Tag)
+	require.Equal(t, "50ms", event.Message)
+	require.Equal(t, adapter.EventGroupUp, nextEvent(t, subscription).Type)
+}
+
+func TestDNSEvents(t *testing.T) {
+	t.Parallel()
+	eventService, _, subscription := newTestService(t)
+	transport := &stubTransport{tag: "remote"}
+	failure := &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}
+	for i := 0; i < dnsFailureThreshold+1; i++ {
+		eventService.Exchanged(transport, failure, time.Millisecond, nil)
+	}
+	event := nextEvent(t, subscription)
+	require.Equal(t, adapter.EventDNSFailing, event.Type)
+	require.Equal(t, "remote", event.Tag)
+	require.Equal(t, "SERVFAIL", event.Message)
+
+	eventService.Exchanged(transport, &dns.Msg{}, time.Millisecond, nil)
+	require.Equal(t, adapter.EventDNSRecovered, nextEvent(t, subscription).Type)
+	select {
+	case event = <-subscription:
+		t.Fatal("unexpected event ", event.Type)
+	default:
+	}
+}
+
+func TestSinkMatch(t *testing.T) {
+	t.Parallel()
+	it := newSink("test", []string{"group", adapter.EventRuleSetFailed}, 0, nil)
+	require.True(t, it.match(adapter.EventGroupSelected))
+	require.True(t, it.match(adapter.EventGroupDown))
+	require.True(t, it.match(adapter.EventRuleSetFailed))
+	require.False(t, it.match(adapter.EventRuleSetUpdated))
+	require.True(t, newSink("test", nil, 0, nil).match(adapter.EventConfigReloaded))
+}
+
+func TestWebhookDirect(t *testing.T) {
+	t.Parallel()
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		content, _ := io.ReadAll(request.Body)
+		received <- string(content)
+	}))
+	defer server.Close()
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	// the stub has no default outbound, webhooks without detour must not
+	// need one
+	service.MustRegister[adapter.OutboundManager](ctx, &stubOutboundManager{})
+	eventService, err := NewService(ctx, log.NewNOPFactory().Logger(), option.EventsOptions{
+		Webhooks: []option.EventWebhookOptions{{URL: server.URL}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, eventService.Start(adapter.StartStateInitialize))
+	defer eventService.Close()
+	eventService.Emit(adapter.Event{Type: adapter.EventConfigReloaded})
+	select {
+	case content := <-received:
+		require.Contains(t, content, adapter.EventConfigReloaded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing webhook request")
+	}
+}
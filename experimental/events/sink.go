@@ -0,0 +1,218 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/ntp"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultQueueSize = 64
+)
+
+// sink delivers the events matching its filter one at a time. An entry of
+// the filter matches the event type itself and, like group, every type
+// below it.
+type sink struct {
+	name    string
+	events  []string
+	timeout time.Duration
+	queue   chan adapter.Event
+	deliver func(ctx context.Context, event adapter.Event) error
+}
+
+func newSink(name string, events []string, timeout time.Duration, deliver func(ctx context.Context, event adapter.Event) error) *sink {
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &sink{
+		name:    name,
+		events:  events,
+		timeout: timeout,
+		queue:   make(chan adapter.Event, defaultQueueSize),
+		deliver: deliver,
+	}
+}
+
+func (s *sink) match(eventType string) bool {
+	return len(s.events) == 0 || common.Any(s.events, func(it string) bool {
+		return it == eventType || strings.HasPrefix(eventType, it+".")
+	})
+}
+
+func newTemplate(text string) (*template.Template, error) {
+	return template.New("event").Funcs(template.FuncMap{
+		"json": func(value any) (string, error) {
+			content, err := json.Marshal(value)
+			return string(content), err
+		},
+	}).Parse(text)
+}
+
+func executeTemplate(tmpl *template.Template, event adapter.Event) (string, error) {
+	var buffer strings.Builder
+	err := tmpl.Execute(&buffer, event)
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// newWebhookSink sends each event as a request to the URL. The body is the
+// event in JSON unless a template is set.
+func newWebhookSink(service *Service, options option.EventWebhookOptions) (*sink, error) {
+	if options.URL == "" {
+		return nil, E.New("missing url")
+	}
+	requestURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, E.Cause(err, "parse url")
+	}
+	if requestURL.Scheme != "http" && requestURL.Scheme != "https" {
+		return nil, E.New("unsupported url scheme: ", requestURL.Scheme)
+	}
+	method := options.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var bodyTemplate *template.Template
+	if options.Template != "" {
+		bodyTemplate, err = newTemplate(options.Template)
+		if err != nil {
+			return nil, E.Cause(err, "parse template")
+		}
+	}
+	headers := options.Headers.Build()
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json")
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialer, err := service.dialer(options.Detour)
+				if err != nil {
+					return nil, err
+				}
+				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+			TLSClientConfig: &tls.Config{
+				Time:    ntp.TimeFuncFromContext(service.ctx),
+				RootCAs: adapter.RootPoolFromContext(service.ctx),
+			},
+		},
+	}
+	return newSink("webhook "+requestURL.Host, options.Events, time.Duration(options.Timeout), func(ctx context.Context, event adapter.Event) error {
+		var (
+			body []byte
+			err  error
+		)
+		if bodyTemplate != nil {
+			content, err := executeTemplate(bodyTemplate, event)
+			if err != nil {
+				return E.Cause(err, "execute template")
+			}
+			body = []byte(content)
+		} else {
+			body, err = json.Marshal(event)
+			if err != nil {
+				return err
+			}
+		}
+		request, err := http.NewRequestWithContext(ctx, method, options.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header = headers.Clone()
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return E.New("unexpected status: ", response.Status)
+		}
+		return nil
+	}), nil
+}
+
+func (s *Service) dialer(detour string) (N.Dialer, error) {
+	if detour == "" {
+		return s.direct, nil
+	}
+	outbound, loaded := s.outbound.Outbound(detour)
+	if !loaded {
+		return nil, E.New("detour not found: ", detour)
+	}
+	return outbound, nil
+}
+
+// newExecSink runs the command for each event, with the arguments expanded
+// as templates, the event in JSON on stdin and its fields in EVENT_*
+// environment variables.
+func newExecSink(options option.EventExecOptions) (*sink, error) {
+	if options.Command == "" {
+		return nil, E.New("missing command")
+	}
+	argTemplates := make([]*template.Template, 0, len(options.Args))
+	for i, arg := range options.Args {
+		argTemplate, err := newTemplate(arg)
+		if err != nil {
+			return nil, E.Cause(err, "parse args[", i, "]")
+		}
+		argTemplates = append(argTemplates, argTemplate)
+	}
+	return newSink("exec "+options.Command, options.Events, time.Duration(options.Timeout), func(ctx context.Context, event adapter.Event) error {
+		args := make([]string, 0, len(argTemplates))
+		for _, argTemplate := range argTemplates {
+			arg, err := executeTemplate(argTemplate, event)
+			if err != nil {
+				return E.Cause(err, "execute template")
+			}
+			args = append(args, arg)
+		}
+		input, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		command := exec.CommandContext(ctx, options.Command, args...)
+		command.Stdin = bytes.NewReader(input)
+		command.Env = append(os.Environ(),
+			"EVENT_TYPE="+event.Type,
+			"EVENT_TIME="+event.Time.Format(time.RFC3339),
+			"EVENT_TAG="+event.Tag,
+			"EVENT_NETWORK="+event.Network,
+			"EVENT_OUTBOUND="+event.Outbound,
+			"EVENT_PREVIOUS="+event.Previous,
+			"EVENT_MESSAGE="+event.Message,
+		)
+		output, err := command.CombinedOutput()
+		if err != nil {
+			if len(output) > 0 {
+				return E.Cause(err, strings.TrimSpace(string(output)))
+			}
+			return err
+		}
+		return nil
+	}), nil
+}
@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricWriter renders the Prometheus text exposition format (version 0.0.4),
+// which OpenMetrics scrapers accept as well.
+type metricWriter struct {
+	bytes.Buffer
+}
+
+func (w *metricWriter) header(name string, metricType string, help string) {
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(help)
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(metricType)
+	w.WriteByte('\n')
+}
+
+func (w *metricWriter) sample(name string, labelNames []string, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labelName)
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(labelValues[i]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(value))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+type vecEntry[T any] struct {
+	labelValues []string
+	value       *T
+}
+
+// vec holds one value per label combination.
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+	newValue   func() *T
+	access     sync.Mutex
+	entries    map[string]*vecEntry[T]
+}
+
+func newVec[T any](name string, help string, newValue func() *T, labelNames ...string) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		newValue:   newValue,
+		entries:    make(map[string]*vecEntry[T]),
+	}
+}
+
+func (v *vec[T]) with(labelValues ...string) *T {
+	key := strings.Join(labelValues, "\x00")
+	v.access.Lock()
+	defer v.access.Unlock()
+	entry, loaded := v.entries[key]
+	if !loaded {
+		entry = &vecEntry[T]{
+			labelValues: labelValues,
+			value:       v.newValue(),
+		}
+		v.entries[key] = entry
+	}
+	return entry.value
+}
+
+func (v *vec[T]) sorted() []*vecEntry[T] {
+	v.access.Lock()
+	keys := make([]string, 0, len(v.entries))
+	for key := range v.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]*vecEntry[T], 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, v.entries[key])
+	}
+	v.access.Unlock()
+	return entries
+}
+
+type counterVec struct {
+	*vec[atomic.Int64]
+}
+
+func newCounterVec(name string, help string, labelNames ...string) counterVec {
+	return counterVec{newVec(name, help, func() *atomic.Int64 {
+		return new(atomic.Int64)
+	}, labelNames...)}
+}
+
+func (v counterVec) write(w *metricWriter) {
+	entries := v.sorted()
+	if len(entries) == 0 {
+		return
+	}
+	w.header(v.name, "counter", v.help)
+	for _, entry := range entries {
+		w.sample(v.name, v.labelNames, entry.labelValues, float64(entry.value.Load()))
+	}
+}
+
+var durationBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+type histogram struct {
+	buckets [10]atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64
+}
+
+func (h *histogram) observe(duration time.Duration) {
+	for i, bound := range durationBuckets {
+		if duration <= bound {
+			h.buckets[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	h.sum.Add(int64(duration))
+}
+
+type histogramVec struct {
+	*vec[histogram]
+}
+
+func newHistogramVec(name string, help string, labelNames ...string) histogramVec {
+	return histogramVec{newVec(name, help, func() *histogram {
+		return new(histogram)
+	}, labelNames...)}
+}
+
+func (v histogramVec) write(w *metricWriter) {
+	entries := v.sorted()
+	if len(entries) == 0 {
+		return
+	}
+	w.header(v.name, "histogram", v.help)
+	bucketLabelNames := append(append([]string(nil), v.labelNames...), "le")
+	for _, entry := range entries {
+		bucketLabelValues := append(append([]string(nil), entry.labelValues...), "")
+		var cumulative int64
+		for i, bound := range durationBuckets {
+			cumulative += entry.value.buckets[i].Load()
+			bucketLabelValues[len(bucketLabelValues)-1] = formatValue(bound.Seconds())
+			w.sample(v.name+"_bucket", bucketLabelNames, bucketLabelValues, float64(cumulative))
+		}
+		count := entry.value.count.Load()
+		bucketLabelValues[len(bucketLabelValues)-1] = "+Inf"
+		w.sample(v.name+"_bucket", bucketLabelNames, bucketLabelValues, float64(count))
+		w.sample(v.name+"_sum", v.labelNames, entry.labelValues, time.Duration(entry.value.sum.Load()).Seconds())
+		w.sample(v.name+"_count", v.labelNames, entry.labelValues, float64(count))
+	}
+}
+
+// gauge writes a gauge family computed at scrape time.
+type gauge struct {
+	name       string
+	help       string
+	labelNames []string
+	samples    []gaugeSample
+}
+
+type gaugeSample struct {
+	labelValues []string
+	value       float64
+}
+
+func newGauge(name string, help string, labelNames ...string) *gauge {
+	return &gauge{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+	}
+}
+
+func (g *gauge) add(value float64, labelValues ...string) {
+	g.samples = append(g.samples, gaugeSample{labelValues, value})
+}
+
+func (g *gauge) write(w *metricWriter) {
+	if len(g.samples) == 0 {
+		return
+	}
+	sort.SliceStable(g.samples, func(i, j int) bool {
+		return strings.Join(g.samples[i].labelValues, "\x00") < strings.Join(g.samples[j].labelValues, "\x00")
+	})
+	w.header(g.name, "gauge", g.help)
+	for _, sample := range g.samples {
+		w.sample(g.name, g.labelNames, sample.labelValues, sample.value)
+	}
+}
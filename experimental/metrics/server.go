@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"github.com/miekg/dns"
+)
+
+const namespace = "radio_box_"
+
+var (
+	_ adapter.LifecycleService  = (*Server)(nil)
+	_ adapter.ConnectionTracker = (*Server)(nil)
+	_ adapter.DNSQueryTracker   = (*Server)(nil)
+)
+
+// Server serves Prometheus metrics at /metrics. Traffic, rule and DNS
+// counters are collected as a connection and DNS tracker; connection
+// gauges, groups and runtime stats are read at scrape time.
+type Server struct {
+	ctx            context.Context
+	logger         log.Logger
+	listen         string
+	httpServer     *http.Server
+	outbound       adapter.OutboundManager
+	history        adapter.URLTestHistoryStorage
+	trafficManager *trafficontrol.Manager
+
+	inboundBytes       counterVec
+	inboundConnections counterVec
+	outboundBytes      counterVec
+	outboundConns      counterVec
+	userBytes          counterVec
+	userConnections    counterVec
+	ruleHits           counterVec
+	dnsQueries         counterVec
+	dnsDuration        histogramVec
+	dnsCacheHits       atomic.Int64
+	dnsCacheMisses     atomic.Int64
+}
+
+func NewServer(ctx context.Context, logger log.Logger, options option.MetricsOptions) *Server {
+	s := &Server{
+		ctx:                ctx,
+		logger:             logger,
+		listen:             options.Listen,
+		outbound:           service.FromContext[adapter.OutboundManager](ctx),
+		inboundBytes:       newCounterVec(namespace+"inbound_bytes_total", "Bytes transferred by inbound.", "inbound", "direction"),
+		inboundConnections: newCounterVec(namespace+"inbound_connections_total", "Connections accepted by inbound.", "inbound", "network"),
+		outboundBytes:      newCounterVec(namespace+"outbound_bytes_total", "Bytes transferred by outbound.", "outbound", "direction"),
+		outboundConns:      newCounterVec(namespace+"outbound_connections_total", "Connections routed to outbound.", "outbound", "network"),
+		userBytes:          newCounterVec(namespace+"user_bytes_total", "Bytes transferred by user.", "user", "direction"),
+		userConnections:    newCounterVec(namespace+"user_connections_total", "Connections accepted by user.", "user", "network"),
+		ruleHits:           newCounterVec(namespace+"rule_hits_total", "Connections routed by rule.", "rule", "action"),
+		dnsQueries:         newCounterVec(namespace+"dns_queries_total", "DNS queries sent to transport by response code.", "transport", "rcode"),
+		dnsDuration:        newHistogramVec(namespace+"dns_query_duration_seconds", "DNS exchange latency by transport.", "transport"),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	s.httpServer = &http.Server{
+		Handler: mux,
+	}
+	return s
+}
+
+func (s *Server) Name() string {
+	return "metrics server"
+}
+
+func (s *Server) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	if history := service.PtrFromContext[urltest.HistoryStorage](s.ctx); history != nil {
+		s.history = history
+	}
+	if clashServer := service.FromContext[adapter.ClashServer](s.ctx); clashServer != nil {
+		if s.history == nil {
+			s.history = clashServer.HistoryStorage()
+		}
+		if trafficServer, isTrafficServer := clashServer.(interface {
+			TrafficManager() *trafficontrol.Manager
+		}); isTrafficServer {
+			s.trafficManager = trafficServer.TrafficManager()
+		}
+	}
+	listener, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("metrics server started at ", listener.Addr())
+	go func() {
+		err = s.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("serve error: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.httpServer),
+	)
+}
+
+func (s *Server) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	readCounter, writeCounter := s.routed(N.NetworkTCP, metadata, matchedRule, matchOutbound)
+	return bufio.NewInt64CounterConn(conn, readCounter, writeCounter)
+}
+
+func (s *Server) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	readCounter, writeCounter := s.routed(N.NetworkUDP, metadata, matchedRule, matchOutbound)
+	return bufio.NewInt64CounterPacketConn(conn, readCounter, nil, writeCounter, nil)
+}
+
+func (s *Server) routed(network string, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) (readCounter []*atomic.Int64, writeCounter []*atomic.Int64) {
+	if metadata.Inbound != "" {
+		s.inboundConnections.with(metadata.Inbound, network).Add(1)
+		readCounter = append(readCounter, s.inboundBytes.with(metadata.Inbound, "uplink"))
+		writeCounter = append(writeCounter, s.inboundBytes.with(metadata.Inbound, "downlink"))
+	}
+	outbound := matchOutbound.Tag()
+	s.outboundConns.with(outbound, network).Add(1)
+	readCounter = append(readCounter, s.outboundBytes.with(outbound, "uplink"))
+	writeCounter = append(writeCounter, s.outboundBytes.with(outbound, "downlink"))
+	if metadata.User != "" {
+		s.userConnections.with(metadata.User, network).Add(1)
+		readCounter = append(readCounter, s.userBytes.with(metadata.User, "uplink"))
+		writeCounter = append(writeCounter, s.userBytes.with(metadata.User, "downlink"))
+	}
+	if matchedRule != nil {
+		s.ruleHits.with(matchedRule.String(), matchedRule.Action().String()).Add(1)
+	} else {
+		s.ruleHits.with("final", "route("+outbound+")").Add(1)
+	}
+	return
+}
+
+func (s *Server) CacheLookup(transport adapter.DNSTransport, hit bool) {
+	if hit {
+		s.dnsCacheHits.Add(1)
+	} else {
+		s.dnsCacheMisses.Add(1)
+	}
+}
+
+func (s *Server) Exchanged(transport adapter.DNSTransport, response *dns.Msg, elapsed time.Duration, err error) {
+	var rcode string
+	if err != nil {
+		rcode = "error"
+	} else {
+		rcode = dns.RcodeToString[response.Rcode]
+	}
+	s.dnsQueries.with(transport.Tag(), rcode).Add(1)
+	s.dnsDuration.with(transport.Tag()).observe(elapsed)
+}
+
+func (s *Server) serveMetrics(writer http.ResponseWriter, request *http.Request) {
+	var w metricWriter
+	s.inboundConnections.write(&w)
+	s.inboundBytes.write(&w)
+	s.outboundConns.write(&w)
+	s.outboundBytes.write(&w)
+	s.userConnections.write(&w)
+	s.userBytes.write(&w)
+	s.ruleHits.write(&w)
+	s.writeActiveConnections(&w)
+	s.writeGroups(&w)
+	s.dnsQueries.write(&w)
+	s.dnsDuration.write(&w)
+	s.writeDNSCache(&w)
+	writeRuntime(&w)
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writer.Write(w.Bytes())
+}
+
+func (s *Server) writeActiveConnections(w *metricWriter) {
+	if s.trafficManager == nil {
+		return
+	}
+	connections := s.trafficManager.Connections()
+	active := newGauge(namespace+"active_connections", "Open connections by inbound, outbound and network.", "inbound", "outbound", "network")
+	counts := make(map[[3]string]int)
+	for _, connection := range connections {
+		counts[[3]string{connection.Metadata.Inbound, connection.Outbound, connection.Metadata.Network}]++
+	}
+	for labels, count := range counts {
+		active.add(float64(count), labels[:]...)
+	}
+	active.write(w)
+	uploadTotal, downloadTotal := s.trafficManager.Total()
+	traffic := newGauge(namespace+"traffic_bytes", "Bytes transferred by all connections since start or last reset.", "direction")
+	traffic.add(float64(uploadTotal), "uplink")
+	traffic.add(float64(downloadTotal), "downlink")
+	traffic.write(w)
+}
+
+func (s *Server) writeGroups(w *metricWriter) {
+	if s.outbound == nil {
+		return
+	}
+	selected := newGauge(namespace+"outbound_group_selected", "Whether the member is the selected outbound of the group.", "group", "outbound")
+	delay := newGauge(namespace+"outbound_delay_seconds", "Latency of the last health check.", "outbound")
+	checkTime := newGauge(namespace+"outbound_last_check_timestamp_seconds", "Time of the last health check.", "outbound")
+	checked := make(map[string]bool)
+	for _, outbound := range s.outbound.Outbounds() {
+		group, isGroup := outbound.(adapter.OutboundGroup)
+		if !isGroup {
+			continue
+		}
+		now := group.Now()
+		for _, member := range group.All() {
+			if member == now {
+				selected.add(1, group.Tag(), member)
+			} else {
+				selected.add(0, group.Tag(), member)
+			}
+			if s.history == nil || checked[member] {
+				continue
+			}
+			checked[member] = true
+			history := s.history.LoadURLTestHistory(member)
+			if history == nil {
+				continue
+			}
+			delay.add((time.Duration(history.Delay) * time.Millisecond).Seconds(), member)
+			checkTime.add(float64(history.Time.Unix()), member)
+		}
+	}
+	selected.write(w)
+	delay.write(w)
+	checkTime.write(w)
+}
+
+func (s *Server) writeDNSCache(w *metricWriter) {
+	hits := s.dnsCacheHits.Load()
+	misses := s.dnsCacheMisses.Load()
+	if hits+misses == 0 {
+		return
+	}
+	lookups := newGauge(namespace+"dns_cache_lookups", "DNS cache lookups by result.", "result")
+	lookups.add(float64(hits), "hit")
+	lookups.add(float64(misses), "miss")
+	lookups.write(w)
+	ratio := newGauge(namespace+"dns_cache_hit_ratio", "Share of cacheable DNS queries answered from cache.")
+	ratio.add(float64(hits) / float64(hits+misses))
+	ratio.write(w)
+}
+
+func writeRuntime(w *metricWriter) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	for _, metric := range []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(memStats.Alloc)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(memStats.HeapInuse)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", float64(memStats.HeapObjects)},
+		{"go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(memStats.StackInuse)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(memStats.Sys)},
+		{"go_memstats_next_gc_bytes", "Number of heap bytes when next garbage collection will take place.", float64(memStats.NextGC)},
+	} {
+		w.header(metric.name, "gauge", metric.help)
+		w.sample(metric.name, nil, nil, metric.value)
+	}
+	w.header("go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed.")
+	w.sample("go_memstats_alloc_bytes_total", nil, nil, float64(memStats.TotalAlloc))
+	w.header("go_gc_cycles_total", "counter", "Number of completed GC cycles.")
+	w.sample("go_gc_cycles_total", nil, nil, float64(memStats.NumGC))
+	w.header("go_gc_pause_seconds_total", "counter", "Total GC pause time.")
+	w.sample("go_gc_pause_seconds_total", nil, nil, time.Duration(memStats.PauseTotalNs).Seconds())
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTransport struct {
+	adapter.DNSTransport
+	tag string
+}
+
+func (t *stubTransport) Tag() string {
+	return t.tag
+}
+
+func TestServeMetrics(t *testing.T) {
+	server := NewServer(context.Background(), log.NewNOPFactory().Logger(), option.MetricsOptions{})
+	transport := &stubTransport{tag: `remote"dns`}
+	server.CacheLookup(transport, true)
+	server.CacheLookup(transport, false)
+	server.Exchanged(transport, &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}}, 20*time.Millisecond, nil)
+	server.Exchanged(transport, nil, time.Minute, context.DeadlineExceeded)
+
+	recorder := httptest.NewRecorder()
+	server.serveMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	require.Contains(t, body, "# TYPE radio_box_dns_queries_total counter\n")
+	require.Contains(t, body, `radio_box_dns_queries_total{transport="remote\"dns",rcode="NXDOMAIN"} 1`)
+	require.Contains(t, body, `radio_box_dns_queries_total{transport="remote\"dns",rcode="error"} 1`)
+	require.Contains(t, body, `radio_box_dns_query_duration_seconds_bucket{transport="remote\"dns",le="0.01"} 0`)
+	require.Contains(t, body, `radio_box_dns_query_duration_seconds_bucket{transport="remote\"dns",le="0.025"} 1`)
+	require.Contains(t, body, `radio_box_dns_query_duration_seconds_bucket{transport="remote\"dns",le="+Inf"} 2`)
+	require.Contains(t, body, `radio_box_dns_query_duration_seconds_count{transport="remote\"dns"} 2`)
+	require.Contains(t, body, "radio_box_dns_cache_hit_ratio 0.5\n")
+	require.Contains(t, body, "# TYPE go_goroutines gauge\n")
+}
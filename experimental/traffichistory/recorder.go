@@ -0,0 +1,352 @@
+package traffichistory
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/bufio"
+	"github.com/sagernet/sing/common/byteformats"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	defaultFlushInterval    = time.Minute
+	defaultHourlyRetention  = 48
+	defaultDailyRetention   = 62
+	defaultMonthlyRetention = 24
+)
+
+var periods = []string{adapter.TrafficPeriodHour, adapter.TrafficPeriodDay, adapter.TrafficPeriodMonth}
+
+var (
+	_ adapter.LifecycleService  = (*Recorder)(nil)
+	_ adapter.ConnectionTracker = (*Recorder)(nil)
+	_ adapter.TrafficHistory    = (*Recorder)(nil)
+)
+
+// Recorder counts traffic by outbound, inbound, user and rule-set in memory
+// and periodically adds it to the hourly, daily and monthly buckets stored
+// in the cache file.
+type Recorder struct {
+	ctx           context.Context
+	logger        log.Logger
+	outbound      adapter.OutboundManager
+	cacheFile     adapter.CacheFile
+	flushInterval time.Duration
+	retention     map[string]int
+	budgets       []*budget
+	access        sync.Mutex
+	counters      map[counterKey]*counter
+	flushAccess   sync.Mutex
+	done          chan struct{}
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+}
+
+type counterKey struct {
+	groupBy string
+	name    string
+}
+
+type counter struct {
+	upload   atomic.Int64
+	download atomic.Int64
+}
+
+type budget struct {
+	groupBy   string
+	name      string
+	limit     int64
+	alertedAt time.Time
+}
+
+func NewRecorder(ctx context.Context, logger log.Logger, options option.TrafficHistoryOptions) (*Recorder, error) {
+	r := &Recorder{
+		ctx:           ctx,
+		logger:        logger,
+		outbound:      service.FromContext[adapter.OutboundManager](ctx),
+		flushInterval: time.Duration(options.FlushInterval),
+		retention: map[string]int{
+			adapter.TrafficPeriodHour:  options.Retention.Hourly,
+			adapter.TrafficPeriodDay:   options.Retention.Daily,
+			adapter.TrafficPeriodMonth: options.Retention.Monthly,
+		},
+		counters: make(map[counterKey]*counter),
+		done:     make(chan struct{}),
+	}
+	if r.flushInterval <= 0 {
+		r.flushInterval = defaultFlushInterval
+	}
+	if r.retention[adapter.TrafficPeriodHour] <= 0 {
+		r.retention[adapter.TrafficPeriodHour] = defaultHourlyRetention
+	}
+	if r.retention[adapter.TrafficPeriodDay] <= 0 {
+		r.retention[adapter.TrafficPeriodDay] = defaultDailyRetention
+	}
+	if r.retention[adapter.TrafficPeriodMonth] <= 0 {
+		r.retention[adapter.TrafficPeriodMonth] = defaultMonthlyRetention
+	}
+	for i, budgetOptions := range options.Budgets {
+		if !isGroupBy(budgetOptions.Type) {
+			return nil, E.New("parse budget[", i, "]: unknown type: ", budgetOptions.Type)
+		}
+		if budgetOptions.Name == "" {
+			return nil, E.New("parse budget[", i, "]: missing name")
+		}
+		if budgetOptions.Monthly == nil || budgetOptions.Monthly.Value() == 0 {
+			return nil, E.New("parse budget[", i, "]: missing monthly")
+		}
+		r.budgets = append(r.budgets, &budget{
+			groupBy: budgetOptions.Type,
+			name:    budgetOptions.Name,
+			limit:   int64(budgetOptions.Monthly.Value()),
+		})
+	}
+	return r, nil
+}
+
+func isPeriod(period string) bool {
+	switch period {
+	case adapter.TrafficPeriodHour, adapter.TrafficPeriodDay, adapter.TrafficPeriodMonth:
+		return true
+	default:
+		return false
+	}
+}
+
+func isGroupBy(groupBy string) bool {
+	switch groupBy {
+	case adapter.TrafficGroupOutbound, adapter.TrafficGroupInbound, adapter.TrafficGroupUser, adapter.TrafficGroupRuleSet:
+		return true
+	default:
+		return false
+	}
+}
+
+// periodStart returns the start of the local-time period containing t.
+func periodStart(period string, t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch period {
+	case adapter.TrafficPeriodHour:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case adapter.TrafficPeriodDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+func addPeriods(period string, t time.Time, n int) time.Time {
+	switch period {
+	case adapter.TrafficPeriodHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case adapter.TrafficPeriodDay:
+		return t.AddDate(0, 0, n)
+	default:
+		return t.AddDate(0, n, 0)
+	}
+}
+
+func (r *Recorder) Name() string {
+	return "traffic history"
+}
+
+func (r *Recorder) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	r.cacheFile = service.FromContext[adapter.CacheFile](r.ctx)
+	if r.cacheFile == nil {
+		return E.New("missing cache file")
+	}
+	r.wg.Add(1)
+	go r.loopFlush()
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+		if r.cacheFile != nil {
+			err = r.flush(time.Now())
+		}
+	})
+	return err
+}
+
+func (r *Recorder) loopFlush() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-ticker.C:
+			err := r.flush(now)
+			if err != nil {
+				r.logger.Error("flush traffic history: ", err)
+			}
+			r.prune(now)
+		}
+	}
+}
+
+func (r *Recorder) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	readCounter, writeCounter := r.routed(metadata, matchedRule, matchOutbound)
+	return bufio.NewInt64CounterConn(conn, readCounter, writeCounter)
+}
+
+func (r *Recorder) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	readCounter, writeCounter := r.routed(metadata, matchedRule, matchOutbound)
+	return bufio.NewInt64CounterPacketConn(conn, readCounter, nil, writeCounter, nil)
+}
+
+func (r *Recorder) routed(metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) (readCounter []*atomic.Int64, writeCounter []*atomic.Int64) {
+	var keys []counterKey
+	var outbound string
+	if r.outbound != nil {
+		_, lastOutbound := trafficontrol.OutboundChain(r.outbound, matchOutbound)
+		if lastOutbound != nil {
+			outbound = lastOutbound.Tag()
+		}
+	} else if matchOutbound != nil {
+		outbound = matchOutbound.Tag()
+	}
+	if outbound != "" {
+		keys = append(keys, counterKey{adapter.TrafficGroupOutbound, outbound})
+	}
+	if metadata.Inbound != "" {
+		keys = append(keys, counterKey{adapter.TrafficGroupInbound, metadata.Inbound})
+	}
+	if metadata.User != "" {
+		keys = append(keys, counterKey{adapter.TrafficGroupUser, metadata.User})
+	}
+	if referrer, isReferrer := matchedRule.(adapter.RuleSetReferrer); isReferrer {
+		for _, tag := range referrer.RuleSets() {
+			keys = append(keys, counterKey{adapter.TrafficGroupRuleSet, tag})
+		}
+	}
+	r.access.Lock()
+	defer r.access.Unlock()
+	for _, key := range keys {
+		trafficCounter, loaded := r.counters[key]
+		if !loaded {
+			trafficCounter = new(counter)
+			r.counters[key] = trafficCounter
+		}
+		readCounter = append(readCounter, &trafficCounter.upload)
+		writeCounter = append(writeCounter, &trafficCounter.download)
+	}
+	return
+}
+
+func (r *Recorder) flush(now time.Time) error {
+	r.flushAccess.Lock()
+	defer r.flushAccess.Unlock()
+	var entries []adapter.TrafficHistoryEntry
+	r.access.Lock()
+	for key, trafficCounter := range r.counters {
+		upload := trafficCounter.upload.Swap(0)
+		download := trafficCounter.download.Swap(0)
+		if upload == 0 && download == 0 {
+			continue
+		}
+		for _, period := range periods {
+			entries = append(entries, adapter.TrafficHistoryEntry{
+				Period:   period,
+				GroupBy:  key.groupBy,
+				Name:     key.name,
+				Time:     periodStart(period, now),
+				Upload:   upload,
+				Download: download,
+			})
+		}
+	}
+	r.access.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+	err := r.cacheFile.AddTrafficHistory(entries)
+	if err != nil {
+		// keep the traffic for the next flush
+		r.access.Lock()
+		for _, entry := range entries {
+			if entry.Period != adapter.TrafficPeriodHour {
+				continue
+			}
+			trafficCounter := r.counters[counterKey{entry.GroupBy, entry.Name}]
+			trafficCounter.upload.Add(entry.Upload)
+			trafficCounter.download.Add(entry.Download)
+		}
+		r.access.Unlock()
+		return err
+	}
+	r.checkBudgets(now)
+	return nil
+}
+
+func (r *Recorder) prune(now time.Time) {
+	for _, period := range periods {
+		before := addPeriods(period, periodStart(period, now), 1-r.retention[period])
+		err := r.cacheFile.PruneTrafficHistory(period, before)
+		if err != nil {
+			r.logger.Error("prune traffic history: ", err)
+		}
+	}
+}
+
+func (r *Recorder) checkBudgets(now time.Time) {
+	if len(r.budgets) == 0 {
+		return
+	}
+	monthStart := periodStart(adapter.TrafficPeriodMonth, now)
+	monthEnd := addPeriods(adapter.TrafficPeriodMonth, monthStart, 1)
+	for _, trafficBudget := range r.budgets {
+		if trafficBudget.alertedAt.Equal(monthStart) {
+			continue
+		}
+		var total int64
+		for _, entry := range r.cacheFile.LoadTrafficHistory(adapter.TrafficPeriodMonth, trafficBudget.groupBy, monthStart, monthEnd) {
+			if entry.Name == trafficBudget.name {
+				total += entry.Upload + entry.Download
+			}
+		}
+		if total < trafficBudget.limit {
+			continue
+		}
+		trafficBudget.alertedAt = monthStart
+		r.logger.Warn("monthly traffic budget exceeded for ", trafficBudget.groupBy, " ", trafficBudget.name, ": ",
+			byteformats.FormatMemoryBytes(uint64(total)), " / ", byteformats.FormatMemoryBytes(uint64(trafficBudget.limit)))
+	}
+}
+
+// TrafficHistory flushes pending traffic and returns the stored buckets of
+// the period that start in [since, until). Zero times are unbounded.
+func (r *Recorder) TrafficHistory(period string, groupBy string, since time.Time, until time.Time) ([]adapter.TrafficHistoryEntry, error) {
+	if !isPeriod(period) {
+		return nil, E.New("unknown period: ", period)
+	}
+	if !isGroupBy(groupBy) {
+		return nil, E.New("unknown group: ", groupBy)
+	}
+	if r.cacheFile == nil {
+		return nil, E.New("traffic history is not started")
+	}
+	err := r.flush(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return r.cacheFile.LoadTrafficHistory(period, groupBy, since, until), nil
+}
@@ -0,0 +1,90 @@
+package traffichistory
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/experimental/cachefile"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubOutbound struct {
+	adapter.Outbound
+	tag string
+}
+
+func (o *stubOutbound) Tag() string {
+	return o.tag
+}
+
+type stubRule struct {
+	adapter.Rule
+	ruleSets []string
+}
+
+func (r *stubRule) RuleSets() []string {
+	return r.ruleSets
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	cacheFile := cachefile.New(ctx, option.CacheFileOptions{
+		Path: filepath.Join(t.TempDir(), "cache.db"),
+	})
+	require.NoError(t, cacheFile.Start(adapter.StartStateInitialize))
+	defer cacheFile.Close()
+	service.MustRegister[adapter.CacheFile](ctx, cacheFile)
+
+	recorder, err := NewRecorder(ctx, log.NewNOPFactory().Logger(), option.TrafficHistoryOptions{})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Start(adapter.StartStateStart))
+
+	client, server := net.Pipe()
+	conn := recorder.RoutedConnection(ctx, server, adapter.InboundContext{
+		Inbound: "mixed-in",
+		User:    "alice",
+	}, &stubRule{ruleSets: []string{"geosite-cn"}}, &stubOutbound{tag: "proxy"})
+	go func() {
+		client.Write([]byte("hello"))
+		io.ReadFull(client, make([]byte, 2))
+		client.Close()
+	}()
+	_, err = io.ReadFull(conn, make([]byte, 5))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ok"))
+	require.NoError(t, err)
+	conn.Close()
+
+	now := time.Now()
+	for _, groupBy := range []string{adapter.TrafficGroupOutbound, adapter.TrafficGroupInbound, adapter.TrafficGroupUser, adapter.TrafficGroupRuleSet} {
+		for _, period := range periods {
+			entries, err := recorder.TrafficHistory(period, groupBy, time.Time{}, time.Time{})
+			require.NoError(t, err)
+			require.Len(t, entries, 1, groupBy+"/"+period)
+			require.Equal(t, int64(5), entries[0].Upload)
+			require.Equal(t, int64(2), entries[0].Download)
+			require.Equal(t, periodStart(period, now), entries[0].Time)
+		}
+	}
+	entries, err := recorder.TrafficHistory(adapter.TrafficPeriodDay, adapter.TrafficGroupOutbound, time.Time{}, periodStart(adapter.TrafficPeriodDay, now))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	_, err = recorder.TrafficHistory("week", adapter.TrafficGroupOutbound, time.Time{}, time.Time{})
+	require.Error(t, err)
+
+	require.NoError(t, recorder.Close())
+	recorder.prune(now.AddDate(3, 0, 0))
+	for _, period := range periods {
+		require.Empty(t, cacheFile.LoadTrafficHistory(period, adapter.TrafficGroupOutbound, time.Time{}, time.Time{}))
+	}
+}
@@ -1,6 +1,7 @@
 package experimental
 
 import (
+	"context"
 	"os"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -8,7 +9,7 @@ import (
 	"github.com/sagernet/sing-box/option"
 )
 
-type V2RayServerConstructor = func(logger log.Logger, options option.V2RayAPIOptions) (adapter.V2RayServer, error)
+type V2RayServerConstructor = func(ctx context.Context, logFactory log.Factory, options option.V2RayAPIOptions) (adapter.V2RayServer, error)
 
 var v2rayServerConstructor V2RayServerConstructor
 
@@ -16,9 +17,9 @@ func RegisterV2RayServerConstructor(constructor V2RayServerConstructor) {
 	v2rayServerConstructor = constructor
 }
 
-func NewV2RayServer(logger log.Logger, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
+func NewV2RayServer(ctx context.Context, logFactory log.Factory, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
 	if v2rayServerConstructor == nil {
 		return nil, os.ErrInvalid
 	}
-	return v2rayServerConstructor(logger, options)
+	return v2rayServerConstructor(ctx, logFactory, options)
 }
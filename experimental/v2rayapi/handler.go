@@ -0,0 +1,242 @@
+package v2rayapi
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
+)
+
+var _ HandlerServiceServer = (*HandlerService)(nil)
+
+// HandlerService manages inbounds, outbounds and inbound users at runtime.
+// Inbounds and outbounds are given in sing-box JSON format, so unlike
+// StatsService it keeps its own service name instead of the V2Ray one.
+type HandlerService struct {
+	ctx             context.Context
+	logFactory      log.Factory
+	router          adapter.Router
+	inboundManager  adapter.InboundManager
+	outboundManager adapter.OutboundManager
+	serviceManager  adapter.ServiceManager
+	statsService    *StatsService
+	access          sync.Mutex
+}
+
+func NewHandlerService(ctx context.Context, logFactory log.Factory, statsService *StatsService) *HandlerService {
+	return &HandlerService{
+		ctx:             ctx,
+		logFactory:      logFactory,
+		router:          service.FromContext[adapter.Router](ctx),
+		inboundManager:  service.FromContext[adapter.InboundManager](ctx),
+		outboundManager: service.FromContext[adapter.OutboundManager](ctx),
+		serviceManager:  service.FromContext[adapter.ServiceManager](ctx),
+		statsService:    statsService,
+	}
+}
+
+func (s *HandlerService) AddInbound(ctx context.Context, request *AddInboundRequest) (*AddInboundResponse, error) {
+	options, err := json.UnmarshalExtendedContext[option.Inbound](s.ctx, []byte(request.Inbound))
+	if err != nil {
+		return nil, E.Cause(err, "parse inbound")
+	}
+	if options.Tag == "" {
+		return nil, E.New("missing inbound tag")
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if _, loaded := s.inboundManager.Get(options.Tag); loaded {
+		return nil, E.New("inbound already exists: ", options.Tag)
+	}
+	err = s.inboundManager.Create(
+		s.ctx,
+		s.router,
+		s.logFactory.NewLogger(F.ToString("inbound/", options.Type, "[", options.Tag, "]")),
+		options.Tag,
+		options.Type,
+		options.Options,
+	)
+	if err != nil {
+		return nil, err
+	}
+	inbound, _ := s.inboundManager.Get(options.Tag)
+	if managedServer, isManaged := inbound.(adapter.ManagedUserServer); isManaged {
+		for _, user := range managedServer.ManagedUsers() {
+			s.addStatsUser(user.Name)
+		}
+	}
+	return &AddInboundResponse{}, nil
+}
+
+func (s *HandlerService) RemoveInbound(ctx context.Context, request *RemoveInboundRequest) (*RemoveInboundResponse, error) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if _, loaded := s.inboundManager.Get(request.Tag); !loaded {
+		return nil, E.New("inbound not found: ", request.Tag)
+	}
+	err := s.inboundManager.Remove(request.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveInboundResponse{}, nil
+}
+
+func (s *HandlerService) AlterInbound(ctx context.Context, request *AlterInboundRequest) (*AlterInboundResponse, error) {
+	if request.AddUser != nil && request.RemoveUser != nil {
+		return nil, E.New("multiple operations in one request")
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	inbound, loaded := s.inboundManager.Get(request.Tag)
+	if !loaded {
+		return nil, E.New("inbound not found: ", request.Tag)
+	}
+	managedServer, isManaged := inbound.(adapter.ManagedUserServer)
+	if !isManaged {
+		return nil, E.New("inbound/", inbound.Type(), "[", inbound.Tag(), "] does not support managed users")
+	}
+	if editor, isOwned := s.userEditor(request.Tag); isOwned {
+		return s.alterOwnedInbound(editor, request)
+	}
+	users := managedServer.ManagedUsers()
+	switch {
+	case request.AddUser != nil:
+		operation := request.AddUser
+		if operation.Name == "" {
+			return nil, E.New("missing user name")
+		}
+		if common.Any(users, func(it adapter.ManagedUser) bool {
+			return it.Name == operation.Name
+		}) {
+			return nil, E.New("user already exists: ", operation.Name)
+		}
+		err := managedServer.UpdateUsers(append(slices.Clip(users), adapter.ManagedUser{
+			Name:     operation.Name,
+			Password: operation.Password,
+			UUID:     operation.Uuid,
+			Flow:     operation.Flow,
+		}))
+		if err != nil {
+			return nil, err
+		}
+		s.addStatsUser(operation.Name)
+	case request.RemoveUser != nil:
+		name := request.RemoveUser.Name
+		index := slices.IndexFunc(users, func(it adapter.ManagedUser) bool {
+			return it.Name == name
+		})
+		if index == -1 {
+			return nil, E.New("user not found: ", name)
+		}
+		err := managedServer.UpdateUsers(slices.Delete(slices.Clone(users), index, index+1))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, E.New("missing operation")
+	}
+	return &AlterInboundResponse{}, nil
+}
+
+// alterOwnedInbound edits the users of an inbound through the service
+// owning them.
+func (s *HandlerService) alterOwnedInbound(editor adapter.ManagedUserEditor, request *AlterInboundRequest) (*AlterInboundResponse, error) {
+	switch {
+	case request.AddUser != nil:
+		operation := request.AddUser
+		if operation.Name == "" {
+			return nil, E.New("missing user name")
+		}
+		err := editor.AddUser(adapter.ManagedUser{
+			Name:     operation.Name,
+			Password: operation.Password,
+			UUID:     operation.Uuid,
+			Flow:     operation.Flow,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.addStatsUser(operation.Name)
+	case request.RemoveUser != nil:
+		err := editor.RemoveUser(request.RemoveUser.Name)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, E.New("missing operation")
+	}
+	return &AlterInboundResponse{}, nil
+}
+
+func (s *HandlerService) userEditor(inboundTag string) (adapter.ManagedUserEditor, bool) {
+	if s.serviceManager == nil {
+		return nil, false
+	}
+	for _, it := range s.serviceManager.Services() {
+		if owner, isOwner := it.(adapter.ManagedUserOwner); isOwner {
+			if editor, loaded := owner.ManagedUserEditor(inboundTag); loaded {
+				return editor, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (s *HandlerService) AddOutbound(ctx context.Context, request *AddOutboundRequest) (*AddOutboundResponse, error) {
+	options, err := json.UnmarshalExtendedContext[option.Outbound](s.ctx, []byte(request.Outbound))
+	if err != nil {
+		return nil, E.Cause(err, "parse outbound")
+	}
+	if options.Tag == "" {
+		return nil, E.New("missing outbound tag")
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if _, loaded := s.outboundManager.Outbound(options.Tag); loaded {
+		return nil, E.New("outbound already exists: ", options.Tag)
+	}
+	err = s.outboundManager.Create(
+		s.ctx,
+		s.router,
+		s.logFactory.NewLogger(F.ToString("outbound/", options.Type, "[", options.Tag, "]")),
+		options.Tag,
+		options.Type,
+		options.Options,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &AddOutboundResponse{}, nil
+}
+
+func (s *HandlerService) RemoveOutbound(ctx context.Context, request *RemoveOutboundRequest) (*RemoveOutboundResponse, error) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if _, loaded := s.outboundManager.Outbound(request.Tag); !loaded {
+		return nil, E.New("outbound not found: ", request.Tag)
+	}
+	err := s.outboundManager.Remove(request.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveOutboundResponse{}, nil
+}
+
+func (s *HandlerService) mustEmbedUnimplementedHandlerServiceServer() {
+}
+
+// addStatsUser enables statistics for users managed through the API, since
+// the stats service only counts the users listed in its options.
+func (s *HandlerService) addStatsUser(user string) {
+	if s.statsService != nil && user != "" {
+		s.statsService.AddUser(user)
+	}
+}
@@ -0,0 +1,659 @@
+package v2rayapi
+
+import (
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AddInboundRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inbound in sing-box JSON format.
+	Inbound       string `protobuf:"bytes,1,opt,name=inbound,proto3" json:"inbound,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddInboundRequest) Reset() {
+	*x = AddInboundRequest{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddInboundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddInboundRequest) ProtoMessage() {}
+
+func (x *AddInboundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddInboundRequest.ProtoReflect.Descriptor instead.
+func (*AddInboundRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AddInboundRequest) GetInbound() string {
+	if x != nil {
+		return x.Inbound
+	}
+	return ""
+}
+
+type AddInboundResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddInboundResponse) Reset() {
+	*x = AddInboundResponse{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddInboundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddInboundResponse) ProtoMessage() {}
+
+func (x *AddInboundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddInboundResponse.ProtoReflect.Descriptor instead.
+func (*AddInboundResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{1}
+}
+
+type RemoveInboundRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveInboundRequest) Reset() {
+	*x = RemoveInboundRequest{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveInboundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveInboundRequest) ProtoMessage() {}
+
+func (x *RemoveInboundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveInboundRequest.ProtoReflect.Descriptor instead.
+func (*RemoveInboundRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RemoveInboundRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type RemoveInboundResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveInboundResponse) Reset() {
+	*x = RemoveInboundResponse{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveInboundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveInboundResponse) ProtoMessage() {}
+
+func (x *RemoveInboundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveInboundResponse.ProtoReflect.Descriptor instead.
+func (*RemoveInboundResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{3}
+}
+
+type AddUserOperation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Uuid          string                 `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Flow          string                 `protobuf:"bytes,4,opt,name=flow,proto3" json:"flow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddUserOperation) Reset() {
+	*x = AddUserOperation{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddUserOperation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddUserOperation) ProtoMessage() {}
+
+func (x *AddUserOperation) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddUserOperation.ProtoReflect.Descriptor instead.
+func (*AddUserOperation) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AddUserOperation) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AddUserOperation) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *AddUserOperation) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *AddUserOperation) GetFlow() string {
+	if x != nil {
+		return x.Flow
+	}
+	return ""
+}
+
+type RemoveUserOperation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveUserOperation) Reset() {
+	*x = RemoveUserOperation{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveUserOperation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveUserOperation) ProtoMessage() {}
+
+func (x *RemoveUserOperation) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveUserOperation.ProtoReflect.Descriptor instead.
+func (*RemoveUserOperation) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RemoveUserOperation) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type AlterInboundRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	AddUser       *AddUserOperation      `protobuf:"bytes,2,opt,name=add_user,json=addUser,proto3" json:"add_user,omitempty"`
+	RemoveUser    *RemoveUserOperation   `protobuf:"bytes,3,opt,name=remove_user,json=removeUser,proto3" json:"remove_user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlterInboundRequest) Reset() {
+	*x = AlterInboundRequest{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlterInboundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlterInboundRequest) ProtoMessage() {}
+
+func (x *AlterInboundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlterInboundRequest.ProtoReflect.Descriptor instead.
+func (*AlterInboundRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AlterInboundRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *AlterInboundRequest) GetAddUser() *AddUserOperation {
+	if x != nil {
+		return x.AddUser
+	}
+	return nil
+}
+
+func (x *AlterInboundRequest) GetRemoveUser() *RemoveUserOperation {
+	if x != nil {
+		return x.RemoveUser
+	}
+	return nil
+}
+
+type AlterInboundResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlterInboundResponse) Reset() {
+	*x = AlterInboundResponse{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlterInboundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlterInboundResponse) ProtoMessage() {}
+
+func (x *AlterInboundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlterInboundResponse.ProtoReflect.Descriptor instead.
+func (*AlterInboundResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{7}
+}
+
+type AddOutboundRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Outbound in sing-box JSON format.
+	Outbound      string `protobuf:"bytes,1,opt,name=outbound,proto3" json:"outbound,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddOutboundRequest) Reset() {
+	*x = AddOutboundRequest{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddOutboundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddOutboundRequest) ProtoMessage() {}
+
+func (x *AddOutboundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddOutboundRequest.ProtoReflect.Descriptor instead.
+func (*AddOutboundRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AddOutboundRequest) GetOutbound() string {
+	if x != nil {
+		return x.Outbound
+	}
+	return ""
+}
+
+type AddOutboundResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddOutboundResponse) Reset() {
+	*x = AddOutboundResponse{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddOutboundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddOutboundResponse) ProtoMessage() {}
+
+func (x *AddOutboundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddOutboundResponse.ProtoReflect.Descriptor instead.
+func (*AddOutboundResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{9}
+}
+
+type RemoveOutboundRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveOutboundRequest) Reset() {
+	*x = RemoveOutboundRequest{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveOutboundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveOutboundRequest) ProtoMessage() {}
+
+func (x *RemoveOutboundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveOutboundRequest.ProtoReflect.Descriptor instead.
+func (*RemoveOutboundRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RemoveOutboundRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type RemoveOutboundResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveOutboundResponse) Reset() {
+	*x = RemoveOutboundResponse{}
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveOutboundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveOutboundResponse) ProtoMessage() {}
+
+func (x *RemoveOutboundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_handler_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveOutboundResponse.ProtoReflect.Descriptor instead.
+func (*RemoveOutboundResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_handler_proto_rawDescGZIP(), []int{11}
+}
+
+var File_experimental_v2rayapi_handler_proto protoreflect.FileDescriptor
+
+const file_experimental_v2rayapi_handler_proto_rawDesc = "" +
+	"\n" +
+	"#experimental/v2rayapi/handler.proto\x12\x15experimental.v2rayapi\"-\n" +
+	"\x11AddInboundRequest\x12\x18\n" +
+	"\ainbound\x18\x01 \x01(\tR\ainbound\"\x14\n" +
+	"\x12AddInboundResponse\"(\n" +
+	"\x14RemoveInboundRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\"\x17\n" +
+	"\x15RemoveInboundResponse\"j\n" +
+	"\x10AddUserOperation\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\x12\x12\n" +
+	"\x04uuid\x18\x03 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04flow\x18\x04 \x01(\tR\x04flow\")\n" +
+	"\x13RemoveUserOperation\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xb8\x01\n" +
+	"\x13AlterInboundRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12B\n" +
+	"\badd_user\x18\x02 \x01(\v2'.experimental.v2rayapi.AddUserOperationR\aaddUser\x12K\n" +
+	"\vremove_user\x18\x03 \x01(\v2*.experimental.v2rayapi.RemoveUserOperationR\n" +
+	"removeUser\"\x16\n" +
+	"\x14AlterInboundResponse\"0\n" +
+	"\x12AddOutboundRequest\x12\x1a\n" +
+	"\boutbound\x18\x01 \x01(\tR\boutbound\"\x15\n" +
+	"\x13AddOutboundResponse\")\n" +
+	"\x15RemoveOutboundRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\"\x18\n" +
+	"\x16RemoveOutboundResponse2\xa7\x04\n" +
+	"\x0eHandlerService\x12c\n" +
+	"\n" +
+	"AddInbound\x12(.experimental.v2rayapi.AddInboundRequest\x1a).experimental.v2rayapi.AddInboundResponse\"\x00\x12l\n" +
+	"\rRemoveInbound\x12+.experimental.v2rayapi.RemoveInboundRequest\x1a,.experimental.v2rayapi.RemoveInboundResponse\"\x00\x12i\n" +
+	"\fAlterInbound\x12*.experimental.v2rayapi.AlterInboundRequest\x1a+.experimental.v2rayapi.AlterInboundResponse\"\x00\x12f\n" +
+	"\vAddOutbound\x12).experimental.v2rayapi.AddOutboundRequest\x1a*.experimental.v2rayapi.AddOutboundResponse\"\x00\x12o\n" +
+	"\x0eRemoveOutbound\x12,.experimental.v2rayapi.RemoveOutboundRequest\x1a-.experimental.v2rayapi.RemoveOutboundResponse\"\x00B4Z2github.com/sagernet/sing-box/experimental/v2rayapib\x06proto3"
+
+var (
+	file_experimental_v2rayapi_handler_proto_rawDescOnce sync.Once
+	file_experimental_v2rayapi_handler_proto_rawDescData []byte
+)
+
+func file_experimental_v2rayapi_handler_proto_rawDescGZIP() []byte {
+	file_experimental_v2rayapi_handler_proto_rawDescOnce.Do(func() {
+		file_experimental_v2rayapi_handler_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_experimental_v2rayapi_handler_proto_rawDesc), len(file_experimental_v2rayapi_handler_proto_rawDesc)))
+	})
+	return file_experimental_v2rayapi_handler_proto_rawDescData
+}
+
+var (
+	file_experimental_v2rayapi_handler_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+	file_experimental_v2rayapi_handler_proto_goTypes  = []any{
+		(*AddInboundRequest)(nil),      // 0: experimental.v2rayapi.AddInboundRequest
+		(*AddInboundResponse)(nil),     // 1: experimental.v2rayapi.AddInboundResponse
+		(*RemoveInboundRequest)(nil),   // 2: experimental.v2rayapi.RemoveInboundRequest
+		(*RemoveInboundResponse)(nil),  // 3: experimental.v2rayapi.RemoveInboundResponse
+		(*AddUserOperation)(nil),       // 4: experimental.v2rayapi.AddUserOperation
+		(*RemoveUserOperation)(nil),    // 5: experimental.v2rayapi.RemoveUserOperation
+		(*AlterInboundRequest)(nil),    // 6: experimental.v2rayapi.AlterInboundRequest
+		(*AlterInboundResponse)(nil),   // 7: experimental.v2rayapi.AlterInboundResponse
+		(*AddOutboundRequest)(nil),     // 8: experimental.v2rayapi.AddOutboundRequest
+		(*AddOutboundResponse)(nil),    // 9: experimental.v2rayapi.AddOutboundResponse
+		(*RemoveOutboundRequest)(nil),  // 10: experimental.v2rayapi.RemoveOutboundRequest
+		(*RemoveOutboundResponse)(nil), // 11: experimental.v2rayapi.RemoveOutboundResponse
+	}
+)
+
+var file_experimental_v2rayapi_handler_proto_depIdxs = []int32{
+	4,  // 0: experimental.v2rayapi.AlterInboundRequest.add_user:type_name -> experimental.v2rayapi.AddUserOperation
+	5,  // 1: experimental.v2rayapi.AlterInboundRequest.remove_user:type_name -> experimental.v2rayapi.RemoveUserOperation
+	0,  // 2: experimental.v2rayapi.HandlerService.AddInbound:input_type -> experimental.v2rayapi.AddInboundRequest
+	2,  // 3: experimental.v2rayapi.HandlerService.RemoveInbound:input_type -> experimental.v2rayapi.RemoveInboundRequest
+	6,  // 4: experimental.v2rayapi.HandlerService.AlterInbound:input_type -> experimental.v2rayapi.AlterInboundRequest
+	8,  // 5: experimental.v2rayapi.HandlerService.AddOutbound:input_type -> experimental.v2rayapi.AddOutboundRequest
+	10, // 6: experimental.v2rayapi.HandlerService.RemoveOutbound:input_type -> experimental.v2rayapi.RemoveOutboundRequest
+	1,  // 7: experimental.v2rayapi.HandlerService.AddInbound:output_type -> experimental.v2rayapi.AddInboundResponse
+	3,  // 8: experimental.v2rayapi.HandlerService.RemoveInbound:output_type -> experimental.v2rayapi.RemoveInboundResponse
+	7,  // 9: experimental.v2rayapi.HandlerService.AlterInbound:output_type -> experimental.v2rayapi.AlterInboundResponse
+	9,  // 10: experimental.v2rayapi.HandlerService.AddOutbound:output_type -> experimental.v2rayapi.AddOutboundResponse
+	11, // 11: experimental.v2rayapi.HandlerService.RemoveOutbound:output_type -> experimental.v2rayapi.RemoveOutboundResponse
+	7,  // [7:12] is the sub-list for method output_type
+	2,  // [2:7] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_experimental_v2rayapi_handler_proto_init() }
+func file_experimental_v2rayapi_handler_proto_init() {
+	if File_experimental_v2rayapi_handler_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_experimental_v2rayapi_handler_proto_rawDesc), len(file_experimental_v2rayapi_handler_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_experimental_v2rayapi_handler_proto_goTypes,
+		DependencyIndexes: file_experimental_v2rayapi_handler_proto_depIdxs,
+		MessageInfos:      file_experimental_v2rayapi_handler_proto_msgTypes,
+	}.Build()
+	File_experimental_v2rayapi_handler_proto = out.File
+	file_experimental_v2rayapi_handler_proto_goTypes = nil
+	file_experimental_v2rayapi_handler_proto_depIdxs = nil
+}
@@ -0,0 +1,125 @@
+package v2rayapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/service/ssmapi"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubInboundManager struct {
+	adapter.InboundManager
+	inbounds map[string]adapter.Inbound
+}
+
+func (m *stubInboundManager) Get(tag string) (adapter.Inbound, bool) {
+	inbound, loaded := m.inbounds[tag]
+	return inbound, loaded
+}
+
+type stubUserServer struct {
+	adapter.Inbound
+	users []adapter.ManagedUser
+}
+
+func (s *stubUserServer) SetTracker(tracker adapter.SSMTracker) {
+}
+
+func (s *stubUserServer) UpdateUsers(users []adapter.ManagedUser) error {
+	s.users = users
+	return nil
+}
+
+func (s *stubUserServer) ManagedUsers() []adapter.ManagedUser {
+	return s.users
+}
+
+func TestHandlerAlterInbound(t *testing.T) {
+	t.Parallel()
+	inbound := &stubUserServer{users: []adapter.ManagedUser{{Name: "alice", Password: "a"}}}
+	statsService := NewStatsService(option.V2RayStatsServiceOptions{Enabled: true})
+	handler := &HandlerService{
+		inboundManager: &stubInboundManager{inbounds: map[string]adapter.Inbound{"in": inbound}},
+		statsService:   statsService,
+	}
+	ctx := context.Background()
+	_, err := handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", AddUser: &AddUserOperation{Name: "bob", Password: "b"}})
+	require.NoError(t, err)
+	require.Equal(t, []adapter.ManagedUser{{Name: "alice", Password: "a"}, {Name: "bob", Password: "b"}}, inbound.users)
+	require.True(t, statsService.users["bob"])
+
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", AddUser: &AddUserOperation{Name: "bob", Password: "c"}})
+	require.Error(t, err)
+
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", RemoveUser: &RemoveUserOperation{Name: "alice"}})
+	require.NoError(t, err)
+	require.Equal(t, []adapter.ManagedUser{{Name: "bob", Password: "b"}}, inbound.users)
+
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", RemoveUser: &RemoveUserOperation{Name: "alice"}})
+	require.Error(t, err)
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "missing", RemoveUser: &RemoveUserOperation{Name: "bob"}})
+	require.Error(t, err)
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in"})
+	require.Error(t, err)
+}
+
+type stubServiceManager struct {
+	adapter.ServiceManager
+	services []adapter.Service
+}
+
+func (m *stubServiceManager) Services() []adapter.Service {
+	return m.services
+}
+
+type stubUserOwner struct {
+	adapter.Service
+	inboundTag string
+	editor     adapter.ManagedUserEditor
+}
+
+func (o *stubUserOwner) ManagedUserEditor(inboundTag string) (adapter.ManagedUserEditor, bool) {
+	return o.editor, inboundTag == o.inboundTag
+}
+
+func TestHandlerAlterOwnedInbound(t *testing.T) {
+	t.Parallel()
+	inbound := &stubUserServer{}
+	userManager := ssmapi.NewUserManager(inbound, ssmapi.NewTrafficManager())
+	require.NoError(t, userManager.Add("alice", ssmapi.UserSettings{Password: "a"}))
+	statsService := NewStatsService(option.V2RayStatsServiceOptions{Enabled: true})
+	handler := &HandlerService{
+		inboundManager: &stubInboundManager{inbounds: map[string]adapter.Inbound{"in": inbound}},
+		serviceManager: &stubServiceManager{services: []adapter.Service{&stubUserOwner{inboundTag: "in", editor: userManager}}},
+		statsService:   statsService,
+	}
+	ctx := context.Background()
+	_, err := handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", AddUser: &AddUserOperation{Name: "bob", Password: "b"}})
+	require.NoError(t, err)
+	require.True(t, statsService.users["bob"])
+	_, loaded := userManager.Get("bob")
+	require.True(t, loaded)
+
+	// a later change through the SSM API keeps the user
+	require.NoError(t, userManager.Add("carol", ssmapi.UserSettings{Password: "c"}))
+	require.Equal(t, []adapter.ManagedUser{{Name: "alice", Password: "a"}, {Name: "bob", Password: "b"}, {Name: "carol", Password: "c"}}, inbound.users)
+
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", AddUser: &AddUserOperation{Name: "bob", Password: "b"}})
+	require.Error(t, err)
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", RemoveUser: &RemoveUserOperation{Name: "alice"}})
+	require.NoError(t, err)
+	_, loaded = userManager.Get("alice")
+	require.False(t, loaded)
+	require.Equal(t, []adapter.ManagedUser{{Name: "bob", Password: "b"}, {Name: "carol", Password: "c"}}, inbound.users)
+	_, err = handler.AlterInbound(ctx, &AlterInboundRequest{Tag: "in", RemoveUser: &RemoveUserOperation{Name: "alice"}})
+	require.Error(t, err)
+}
+
+func TestHandlerServiceName(t *testing.T) {
+	require.Equal(t, "experimental.v2rayapi.HandlerService", HandlerService_ServiceDesc.ServiceName)
+	require.Equal(t, "v2ray.core.app.stats.command.StatsService", StatsService_ServiceDesc.ServiceName)
+}
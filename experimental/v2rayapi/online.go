@@ -0,0 +1,58 @@
+package v2rayapi
+
+import (
+	"net"
+
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ net.Conn     = (*onlineConn)(nil)
+	_ N.PacketConn = (*onlinePacketConn)(nil)
+)
+
+type onlineConn struct {
+	N.ExtendedConn
+	onClose N.CloseHandlerFunc
+}
+
+func (c *onlineConn) Close() error {
+	err := c.ExtendedConn.Close()
+	c.onClose(nil)
+	return err
+}
+
+func (c *onlineConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *onlineConn) WriterReplaceable() bool {
+	return true
+}
+
+func (c *onlineConn) Upstream() any {
+	return c.ExtendedConn
+}
+
+type onlinePacketConn struct {
+	N.PacketConn
+	onClose N.CloseHandlerFunc
+}
+
+func (c *onlinePacketConn) Close() error {
+	err := c.PacketConn.Close()
+	c.onClose(nil)
+	return err
+}
+
+func (c *onlinePacketConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *onlinePacketConn) WriterReplaceable() bool {
+	return true
+}
+
+func (c *onlinePacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -1,6 +1,7 @@
 package v2rayapi
 
 import (
+	"context"
 	"errors"
 	"net"
 	"net/http"
@@ -22,24 +23,31 @@ func init() {
 var _ adapter.V2RayServer = (*Server)(nil)
 
 type Server struct {
-	logger       log.Logger
-	listen       string
-	tcpListener  net.Listener
-	grpcServer   *grpc.Server
-	statsService *StatsService
+	logger         log.Logger
+	listen         string
+	tcpListener    net.Listener
+	grpcServer     *grpc.Server
+	statsService   *StatsService
+	handlerService *HandlerService
 }
 
-func NewServer(logger log.Logger, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
+func NewServer(ctx context.Context, logFactory log.Factory, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
 	grpcServer := grpc.NewServer(grpc.Creds(insecure.NewCredentials()))
 	statsService := NewStatsService(common.PtrValueOrDefault(options.Stats))
 	if statsService != nil {
 		RegisterStatsServiceServer(grpcServer, statsService)
 	}
+	var handlerService *HandlerService
+	if options.Handler != nil && options.Handler.Enabled {
+		handlerService = NewHandlerService(ctx, logFactory, statsService)
+		RegisterHandlerServiceServer(grpcServer, handlerService)
+	}
 	server := &Server{
-		logger:       logger,
-		listen:       options.Listen,
-		grpcServer:   grpcServer,
-		statsService: statsService,
+		logger:         logFactory.NewLogger("v2ray-api"),
+		listen:         options.Listen,
+		grpcServer:     grpcServer,
+		statsService:   statsService,
+		handlerService: handlerService,
 	}
 	return server, nil
 }
@@ -78,5 +86,8 @@ func (s *Server) Close() error {
 }
 
 func (s *Server) StatsService() adapter.ConnectionTracker {
+	if s.statsService == nil {
+		return nil
+	}
 	return s.statsService
 }
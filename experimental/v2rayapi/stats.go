@@ -3,6 +3,7 @@ package v2rayapi
 import (
 	"context"
 	"net"
+	"net/netip"
 	"regexp"
 	"runtime"
 	"strings"
@@ -33,6 +34,13 @@ type StatsService struct {
 	users     map[string]bool
 	access    sync.Mutex
 	counters  map[string]*atomic.Int64
+	online    map[string]map[netip.Addr]*onlineSource
+}
+
+// onlineSource is a source IP of a user's active connections.
+type onlineSource struct {
+	connections int
+	lastSeen    int64
 }
 
 func NewStatsService(options option.V2RayStatsServiceOptions) *StatsService {
@@ -57,9 +65,17 @@ func NewStatsService(options option.V2RayStatsServiceOptions) *StatsService {
 		outbounds: outbounds,
 		users:     users,
 		counters:  make(map[string]*atomic.Int64),
+		online:    make(map[string]map[netip.Addr]*onlineSource),
 	}
 }
 
+// AddUser enables statistics for a user added at runtime.
+func (s *StatsService) AddUser(user string) {
+	s.access.Lock()
+	s.users[user] = true
+	s.access.Unlock()
+}
+
 func (s *StatsService) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
 	inbound := metadata.Inbound
 	user := metadata.User
@@ -68,11 +84,12 @@ func (s *StatsService) RoutedConnection(ctx context.Context, conn net.Conn, meta
 	var writeCounter []*atomic.Int64
 	countInbound := inbound != "" && s.inbounds[inbound]
 	countOutbound := outbound != "" && s.outbounds[outbound]
+	s.access.Lock()
 	countUser := user != "" && s.users[user]
 	if !countInbound && !countOutbound && !countUser {
+		s.access.Unlock()
 		return conn
 	}
-	s.access.Lock()
 	if countInbound {
 		readCounter = append(readCounter, s.loadOrCreateCounter("inbound>>>"+inbound+">>>traffic>>>uplink"))
 		writeCounter = append(writeCounter, s.loadOrCreateCounter("inbound>>>"+inbound+">>>traffic>>>downlink"))
@@ -85,8 +102,16 @@ func (s *StatsService) RoutedConnection(ctx context.Context, conn net.Conn, meta
 		readCounter = append(readCounter, s.loadOrCreateCounter("user>>>"+user+">>>traffic>>>uplink"))
 		writeCounter = append(writeCounter, s.loadOrCreateCounter("user>>>"+user+">>>traffic>>>downlink"))
 	}
+	var onClose N.CloseHandlerFunc
+	if countUser {
+		onClose = s.acquireOnline(user, metadata.Source.Addr)
+	}
 	s.access.Unlock()
-	return bufio.NewInt64CounterConn(conn, readCounter, writeCounter)
+	conn = bufio.NewInt64CounterConn(conn, readCounter, writeCounter)
+	if onClose != nil {
+		conn = &onlineConn{bufio.NewExtendedConn(conn), onClose}
+	}
+	return conn
 }
 
 func (s *StatsService) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
@@ -97,11 +122,12 @@ func (s *StatsService) RoutedPacketConnection(ctx context.Context, conn N.Packet
 	var writeCounter []*atomic.Int64
 	countInbound := inbound != "" && s.inbounds[inbound]
 	countOutbound := outbound != "" && s.outbounds[outbound]
+	s.access.Lock()
 	countUser := user != "" && s.users[user]
 	if !countInbound && !countOutbound && !countUser {
+		s.access.Unlock()
 		return conn
 	}
-	s.access.Lock()
 	if countInbound {
 		readCounter = append(readCounter, s.loadOrCreateCounter("inbound>>>"+inbound+">>>traffic>>>uplink"))
 		writeCounter = append(writeCounter, s.loadOrCreateCounter("inbound>>>"+inbound+">>>traffic>>>downlink"))
@@ -114,8 +140,16 @@ func (s *StatsService) RoutedPacketConnection(ctx context.Context, conn N.Packet
 		readCounter = append(readCounter, s.loadOrCreateCounter("user>>>"+user+">>>traffic>>>uplink"))
 		writeCounter = append(writeCounter, s.loadOrCreateCounter("user>>>"+user+">>>traffic>>>downlink"))
 	}
+	var onClose N.CloseHandlerFunc
+	if countUser {
+		onClose = s.acquireOnline(user, metadata.Source.Addr)
+	}
 	s.access.Unlock()
-	return bufio.NewInt64CounterPacketConn(conn, readCounter, nil, writeCounter, nil)
+	conn = bufio.NewInt64CounterPacketConn(conn, readCounter, nil, writeCounter, nil)
+	if onClose != nil {
+		conn = &onlinePacketConn{conn, onClose}
+	}
+	return conn
 }
 
 func (s *StatsService) GetStats(ctx context.Context, request *GetStatsRequest) (*GetStatsResponse, error) {
@@ -207,9 +241,98 @@ func (s *StatsService) GetSysStats(ctx context.Context, request *SysStatsRequest
 	return response, nil
 }
 
+func (s *StatsService) GetStatsOnline(ctx context.Context, request *GetStatsRequest) (*GetStatsResponse, error) {
+	user, err := s.onlineUser(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	s.access.Lock()
+	value := int64(len(s.online[user]))
+	s.access.Unlock()
+	return &GetStatsResponse{Stat: &Stat{Name: request.Name, Value: value}}, nil
+}
+
+func (s *StatsService) GetStatsOnlineIpList(ctx context.Context, request *GetStatsRequest) (*GetStatsOnlineIpListResponse, error) {
+	user, err := s.onlineUser(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	response := &GetStatsOnlineIpListResponse{
+		Name: request.Name,
+		Ips:  make(map[string]int64),
+	}
+	s.access.Lock()
+	for address, source := range s.online[user] {
+		response.Ips[address.String()] = source.lastSeen
+	}
+	s.access.Unlock()
+	return response, nil
+}
+
+func (s *StatsService) GetAllOnlineUsers(ctx context.Context, request *GetAllOnlineUsersRequest) (*GetAllOnlineUsersResponse, error) {
+	var response GetAllOnlineUsersResponse
+	s.access.Lock()
+	for user := range s.online {
+		response.Users = append(response.Users, "user>>>"+user+">>>online")
+	}
+	s.access.Unlock()
+	return &response, nil
+}
+
 func (s *StatsService) mustEmbedUnimplementedStatsServiceServer() {
 }
 
+// onlineUser parses an online stat name in the form of user>>>name>>>online.
+func (s *StatsService) onlineUser(name string) (string, error) {
+	user, isUser := strings.CutPrefix(name, "user>>>")
+	if isUser {
+		user, isUser = strings.CutSuffix(user, ">>>online")
+	}
+	if !isUser {
+		return "", E.New("invalid online stat name: ", name)
+	}
+	s.access.Lock()
+	loaded := s.users[user]
+	s.access.Unlock()
+	if !loaded {
+		return "", E.New(name, " not found.")
+	}
+	return user, nil
+}
+
+// acquireOnline records a connection from source for user and returns the
+// handler that releases it. Must be called with access held.
+func (s *StatsService) acquireOnline(user string, source netip.Addr) N.CloseHandlerFunc {
+	source = source.Unmap()
+	if !source.IsValid() {
+		return nil
+	}
+	sources := s.online[user]
+	if sources == nil {
+		sources = make(map[netip.Addr]*onlineSource)
+		s.online[user] = sources
+	}
+	entry := sources[source]
+	if entry == nil {
+		entry = &onlineSource{}
+		sources[source] = entry
+	}
+	entry.connections++
+	entry.lastSeen = time.Now().Unix()
+	return N.OnceClose(func(error) {
+		s.access.Lock()
+		defer s.access.Unlock()
+		entry.connections--
+		if entry.connections > 0 {
+			return
+		}
+		delete(sources, source)
+		if len(sources) == 0 {
+			delete(s.online, user)
+		}
+	})
+}
+
 //nolint:staticcheck
 func (s *StatsService) loadOrCreateCounter(name string) *atomic.Int64 {
 	counter, loaded := s.counters[name]
@@ -431,6 +431,140 @@ func (x *SysStatsResponse) GetUptime() uint32 {
 	return 0
 }
 
+type GetStatsOnlineIpListResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Source IPs of the user's active connections, mapped to the Unix time
+	// each was last seen.
+	Ips           map[string]int64 `protobuf:"bytes,2,rep,name=ips,proto3" json:"ips,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsOnlineIpListResponse) Reset() {
+	*x = GetStatsOnlineIpListResponse{}
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsOnlineIpListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsOnlineIpListResponse) ProtoMessage() {}
+
+func (x *GetStatsOnlineIpListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsOnlineIpListResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsOnlineIpListResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_stats_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetStatsOnlineIpListResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GetStatsOnlineIpListResponse) GetIps() map[string]int64 {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+type GetAllOnlineUsersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllOnlineUsersRequest) Reset() {
+	*x = GetAllOnlineUsersRequest{}
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllOnlineUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllOnlineUsersRequest) ProtoMessage() {}
+
+func (x *GetAllOnlineUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllOnlineUsersRequest.ProtoReflect.Descriptor instead.
+func (*GetAllOnlineUsersRequest) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_stats_proto_rawDescGZIP(), []int{8}
+}
+
+type GetAllOnlineUsersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Users         []string               `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllOnlineUsersResponse) Reset() {
+	*x = GetAllOnlineUsersResponse{}
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllOnlineUsersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllOnlineUsersResponse) ProtoMessage() {}
+
+func (x *GetAllOnlineUsersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_experimental_v2rayapi_stats_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllOnlineUsersResponse.ProtoReflect.Descriptor instead.
+func (*GetAllOnlineUsersResponse) Descriptor() ([]byte, []int) {
+	return file_experimental_v2rayapi_stats_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetAllOnlineUsersResponse) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
 var File_experimental_v2rayapi_stats_proto protoreflect.FileDescriptor
 
 const file_experimental_v2rayapi_stats_proto_rawDesc = "" +
@@ -465,12 +599,24 @@ const file_experimental_v2rayapi_stats_proto_rawDesc = "" +
 	"\vLiveObjects\x18\b \x01(\x04R\vLiveObjects\x12\"\n" +
 	"\fPauseTotalNs\x18\t \x01(\x04R\fPauseTotalNs\x12\x16\n" +
 	"\x06Uptime\x18\n" +
-	" \x01(\rR\x06Uptime2\xb4\x02\n" +
+	" \x01(\rR\x06Uptime\"\xba\x01\n" +
+	"\x1cGetStatsOnlineIpListResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12N\n" +
+	"\x03ips\x18\x02 \x03(\v2<.experimental.v2rayapi.GetStatsOnlineIpListResponse.IpsEntryR\x03ips\x1a6\n" +
+	"\bIpsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x1a\n" +
+	"\x18GetAllOnlineUsersRequest\"1\n" +
+	"\x19GetAllOnlineUsersResponse\x12\x14\n" +
+	"\x05users\x18\x01 \x03(\tR\x05users2\x8a\x05\n" +
 	"\fStatsService\x12]\n" +
 	"\bGetStats\x12&.experimental.v2rayapi.GetStatsRequest\x1a'.experimental.v2rayapi.GetStatsResponse\"\x00\x12c\n" +
+	"\x0eGetStatsOnline\x12&.experimental.v2rayapi.GetStatsRequest\x1a'.experimental.v2rayapi.GetStatsResponse\"\x00\x12c\n" +
 	"\n" +
 	"QueryStats\x12(.experimental.v2rayapi.QueryStatsRequest\x1a).experimental.v2rayapi.QueryStatsResponse\"\x00\x12`\n" +
-	"\vGetSysStats\x12&.experimental.v2rayapi.SysStatsRequest\x1a'.experimental.v2rayapi.SysStatsResponse\"\x00B4Z2github.com/sagernet/sing-box/experimental/v2rayapib\x06proto3"
+	"\vGetSysStats\x12&.experimental.v2rayapi.SysStatsRequest\x1a'.experimental.v2rayapi.SysStatsResponse\"\x00\x12u\n" +
+	"\x14GetStatsOnlineIpList\x12&.experimental.v2rayapi.GetStatsRequest\x1a3.experimental.v2rayapi.GetStatsOnlineIpListResponse\"\x00\x12x\n" +
+	"\x11GetAllOnlineUsers\x12/.experimental.v2rayapi.GetAllOnlineUsersRequest\x1a0.experimental.v2rayapi.GetAllOnlineUsersResponse\"\x00B4Z2github.com/sagernet/sing-box/experimental/v2rayapib\x06proto3"
 
 var (
 	file_experimental_v2rayapi_stats_proto_rawDescOnce sync.Once
@@ -485,32 +631,43 @@ func file_experimental_v2rayapi_stats_proto_rawDescGZIP() []byte {
 }
 
 var (
-	file_experimental_v2rayapi_stats_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+	file_experimental_v2rayapi_stats_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 	file_experimental_v2rayapi_stats_proto_goTypes  = []any{
-		(*GetStatsRequest)(nil),    // 0: experimental.v2rayapi.GetStatsRequest
-		(*Stat)(nil),               // 1: experimental.v2rayapi.Stat
-		(*GetStatsResponse)(nil),   // 2: experimental.v2rayapi.GetStatsResponse
-		(*QueryStatsRequest)(nil),  // 3: experimental.v2rayapi.QueryStatsRequest
-		(*QueryStatsResponse)(nil), // 4: experimental.v2rayapi.QueryStatsResponse
-		(*SysStatsRequest)(nil),    // 5: experimental.v2rayapi.SysStatsRequest
-		(*SysStatsResponse)(nil),   // 6: experimental.v2rayapi.SysStatsResponse
+		(*GetStatsRequest)(nil),              // 0: experimental.v2rayapi.GetStatsRequest
+		(*Stat)(nil),                         // 1: experimental.v2rayapi.Stat
+		(*GetStatsResponse)(nil),             // 2: experimental.v2rayapi.GetStatsResponse
+		(*QueryStatsRequest)(nil),            // 3: experimental.v2rayapi.QueryStatsRequest
+		(*QueryStatsResponse)(nil),           // 4: experimental.v2rayapi.QueryStatsResponse
+		(*SysStatsRequest)(nil),              // 5: experimental.v2rayapi.SysStatsRequest
+		(*SysStatsResponse)(nil),             // 6: experimental.v2rayapi.SysStatsResponse
+		(*GetStatsOnlineIpListResponse)(nil), // 7: experimental.v2rayapi.GetStatsOnlineIpListResponse
+		(*GetAllOnlineUsersRequest)(nil),     // 8: experimental.v2rayapi.GetAllOnlineUsersRequest
+		(*GetAllOnlineUsersResponse)(nil),    // 9: experimental.v2rayapi.GetAllOnlineUsersResponse
+		nil,                                  // 10: experimental.v2rayapi.GetStatsOnlineIpListResponse.IpsEntry
 	}
 )
 
 var file_experimental_v2rayapi_stats_proto_depIdxs = []int32{
-	1, // 0: experimental.v2rayapi.GetStatsResponse.stat:type_name -> experimental.v2rayapi.Stat
-	1, // 1: experimental.v2rayapi.QueryStatsResponse.stat:type_name -> experimental.v2rayapi.Stat
-	0, // 2: experimental.v2rayapi.StatsService.GetStats:input_type -> experimental.v2rayapi.GetStatsRequest
-	3, // 3: experimental.v2rayapi.StatsService.QueryStats:input_type -> experimental.v2rayapi.QueryStatsRequest
-	5, // 4: experimental.v2rayapi.StatsService.GetSysStats:input_type -> experimental.v2rayapi.SysStatsRequest
-	2, // 5: experimental.v2rayapi.StatsService.GetStats:output_type -> experimental.v2rayapi.GetStatsResponse
-	4, // 6: experimental.v2rayapi.StatsService.QueryStats:output_type -> experimental.v2rayapi.QueryStatsResponse
-	6, // 7: experimental.v2rayapi.StatsService.GetSysStats:output_type -> experimental.v2rayapi.SysStatsResponse
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	1,  // 0: experimental.v2rayapi.GetStatsResponse.stat:type_name -> experimental.v2rayapi.Stat
+	1,  // 1: experimental.v2rayapi.QueryStatsResponse.stat:type_name -> experimental.v2rayapi.Stat
+	10, // 2: experimental.v2rayapi.GetStatsOnlineIpListResponse.ips:type_name -> experimental.v2rayapi.GetStatsOnlineIpListResponse.IpsEntry
+	0,  // 3: experimental.v2rayapi.StatsService.GetStats:input_type -> experimental.v2rayapi.GetStatsRequest
+	0,  // 4: experimental.v2rayapi.StatsService.GetStatsOnline:input_type -> experimental.v2rayapi.GetStatsRequest
+	3,  // 5: experimental.v2rayapi.StatsService.QueryStats:input_type -> experimental.v2rayapi.QueryStatsRequest
+	5,  // 6: experimental.v2rayapi.StatsService.GetSysStats:input_type -> experimental.v2rayapi.SysStatsRequest
+	0,  // 7: experimental.v2rayapi.StatsService.GetStatsOnlineIpList:input_type -> experimental.v2rayapi.GetStatsRequest
+	8,  // 8: experimental.v2rayapi.StatsService.GetAllOnlineUsers:input_type -> experimental.v2rayapi.GetAllOnlineUsersRequest
+	2,  // 9: experimental.v2rayapi.StatsService.GetStats:output_type -> experimental.v2rayapi.GetStatsResponse
+	2,  // 10: experimental.v2rayapi.StatsService.GetStatsOnline:output_type -> experimental.v2rayapi.GetStatsResponse
+	4,  // 11: experimental.v2rayapi.StatsService.QueryStats:output_type -> experimental.v2rayapi.QueryStatsResponse
+	6,  // 12: experimental.v2rayapi.StatsService.GetSysStats:output_type -> experimental.v2rayapi.SysStatsResponse
+	7,  // 13: experimental.v2rayapi.StatsService.GetStatsOnlineIpList:output_type -> experimental.v2rayapi.GetStatsOnlineIpListResponse
+	9,  // 14: experimental.v2rayapi.StatsService.GetAllOnlineUsers:output_type -> experimental.v2rayapi.GetAllOnlineUsersResponse
+	9,  // [9:15] is the sub-list for method output_type
+	3,  // [3:9] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_experimental_v2rayapi_stats_proto_init() }
@@ -524,7 +681,7 @@ func file_experimental_v2rayapi_stats_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_experimental_v2rayapi_stats_proto_rawDesc), len(file_experimental_v2rayapi_stats_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   7,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
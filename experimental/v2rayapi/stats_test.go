@@ -0,0 +1,68 @@
+package v2rayapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubOutbound struct {
+	adapter.Outbound
+}
+
+func (o *stubOutbound) Tag() string {
+	return "direct"
+}
+
+func TestStatsOnline(t *testing.T) {
+	t.Parallel()
+	statsService := NewStatsService(option.V2RayStatsServiceOptions{
+		Enabled: true,
+		Users:   []string{"alice"},
+	})
+	ctx := context.Background()
+	open := func(user string, source string) net.Conn {
+		client, server := net.Pipe()
+		t.Cleanup(func() {
+			client.Close()
+		})
+		return statsService.RoutedConnection(ctx, server, adapter.InboundContext{
+			User:   user,
+			Source: M.ParseSocksaddrHostPort(source, 1000),
+		}, nil, &stubOutbound{})
+	}
+	first := open("alice", "10.0.0.1")
+	second := open("alice", "10.0.0.1")
+	third := open("alice", "::ffff:10.0.0.2")
+	open("bob", "10.0.0.3")
+
+	response, err := statsService.GetStatsOnline(ctx, &GetStatsRequest{Name: "user>>>alice>>>online"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), response.Stat.Value)
+	ipList, err := statsService.GetStatsOnlineIpList(ctx, &GetStatsRequest{Name: "user>>>alice>>>online"})
+	require.NoError(t, err)
+	require.Len(t, ipList.Ips, 2)
+	require.Contains(t, ipList.Ips, "10.0.0.2")
+	onlineUsers, err := statsService.GetAllOnlineUsers(ctx, &GetAllOnlineUsersRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"user>>>alice>>>online"}, onlineUsers.Users)
+	_, err = statsService.GetStatsOnline(ctx, &GetStatsRequest{Name: "user>>>bob>>>online"})
+	require.Error(t, err)
+
+	first.Close()
+	first.Close()
+	third.Close()
+	response, err = statsService.GetStatsOnline(ctx, &GetStatsRequest{Name: "user>>>alice>>>online"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), response.Stat.Value)
+	second.Close()
+	onlineUsers, err = statsService.GetAllOnlineUsers(ctx, &GetAllOnlineUsersRequest{})
+	require.NoError(t, err)
+	require.Empty(t, onlineUsers.Users)
+}
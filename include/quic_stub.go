@@ -5,7 +5,9 @@ package include
 import (
 	"context"
 	"io"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -16,8 +18,10 @@ import (
 	"github.com/sagernet/sing-box/dns"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	protocolHTTP "github.com/sagernet/sing-box/protocol/http"
 	"github.com/sagernet/sing-box/protocol/naive"
 	"github.com/sagernet/sing-box/transport/v2ray"
+	"github.com/sagernet/sing-box/transport/v2rayxhttp"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -32,6 +36,12 @@ func init() {
 			return nil, C.ErrQUICNotIncluded
 		},
 	)
+	v2rayxhttp.ServeHTTP3Func = func(packetConn net.PacketConn, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
+	v2rayxhttp.NewHTTP3TransportFunc = func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config, keepAlivePeriod time.Duration) (func() http.RoundTripper, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
 }
 
 func registerQUICInbounds(registry *inbound.Registry) {
@@ -47,9 +57,15 @@ func registerQUICInbounds(registry *inbound.Registry) {
 	naive.ConfigureHTTP3ListenerFunc = func(listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
 		return nil, C.ErrQUICNotIncluded
 	}
+	protocolHTTP.ConfigureHTTP3ListenerFunc = func(listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
 }
 
 func registerQUICOutbounds(registry *outbound.Registry) {
+	protocolHTTP.NewHTTP3ClientFunc = func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config) (protocolHTTP.HTTP3Client, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
 	outbound.Register[option.HysteriaOutboundOptions](registry, C.TypeHysteria, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HysteriaOutboundOptions) (adapter.Outbound, error) {
 		return nil, C.ErrQUICNotIncluded
 	})
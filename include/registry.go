@@ -26,7 +26,9 @@ import (
 	"github.com/sagernet/sing-box/protocol/mixed"
 	"github.com/sagernet/sing-box/protocol/naive"
 	"github.com/sagernet/sing-box/protocol/redirect"
+	"github.com/sagernet/sing-box/protocol/reverse"
 	"github.com/sagernet/sing-box/protocol/shadowsocks"
+	"github.com/sagernet/sing-box/protocol/shadowsocksr"
 	"github.com/sagernet/sing-box/protocol/shadowtls"
 	"github.com/sagernet/sing-box/protocol/socks"
 	"github.com/sagernet/sing-box/protocol/ssh"
@@ -58,6 +60,7 @@ func InboundRegistry() *inbound.Registry {
 
 	shadowsocks.RegisterInbound(registry)
 	vmess.RegisterInbound(registry)
+	ssh.RegisterInbound(registry)
 	trojan.RegisterInbound(registry)
 	naive.RegisterInbound(registry)
 	shadowtls.RegisterInbound(registry)
@@ -82,10 +85,13 @@ func OutboundRegistry() *outbound.Registry {
 	group.RegisterURLTest(registry)
 	group.RegisterFallback(registry)
 	group.RegisterLoadBalance(registry)
+	group.RegisterRelay(registry)
+	group.RegisterSmart(registry)
 
 	socks.RegisterOutbound(registry)
 	http.RegisterOutbound(registry)
 	shadowsocks.RegisterOutbound(registry)
+	shadowsocksr.RegisterOutbound(registry)
 	vmess.RegisterOutbound(registry)
 	trojan.RegisterOutbound(registry)
 	tor.RegisterOutbound(registry)
@@ -93,10 +99,10 @@ func OutboundRegistry() *outbound.Registry {
 	shadowtls.RegisterOutbound(registry)
 	vless.RegisterOutbound(registry)
 	anytls.RegisterOutbound(registry)
+	reverse.RegisterPortal(registry)
 
 	registerQUICOutbounds(registry)
 	registerWireGuardOutbound(registry)
-	registerStubForRemovedOutbounds(registry)
 
 	return registry
 }
@@ -104,6 +110,7 @@ func OutboundRegistry() *outbound.Registry {
 func EndpointRegistry() *endpoint.Registry {
 	registry := endpoint.NewRegistry()
 
+	reverse.RegisterEndpoint(registry)
 	registerWireGuardEndpoint(registry)
 	registerTailscaleEndpoint(registry)
 
@@ -145,9 +152,3 @@ func registerStubForRemovedInbounds(registry *inbound.Registry) {
 		return nil, E.New("ShadowsocksR is deprecated and removed in sing-box 1.6.0")
 	})
 }
-
-func registerStubForRemovedOutbounds(registry *outbound.Registry) {
-	outbound.Register[option.ShadowsocksROutboundOptions](registry, C.TypeShadowsocksR, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksROutboundOptions) (adapter.Outbound, error) {
-		return nil, E.New("ShadowsocksR is deprecated and removed in sing-box 1.6.0")
-	})
-}
@@ -3,6 +3,8 @@
 package include
 
 import (
+	"context"
+
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental"
 	"github.com/sagernet/sing-box/log"
@@ -11,7 +13,7 @@ import (
 )
 
 func init() {
-	experimental.RegisterV2RayServerConstructor(func(logger log.Logger, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
+	experimental.RegisterV2RayServerConstructor(func(ctx context.Context, logFactory log.Factory, options option.V2RayAPIOptions) (adapter.V2RayServer, error) {
 		return nil, E.New(`v2ray api is not included in this build, rebuild with -tags with_v2ray_api`)
 	})
 }
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing/common/json"
+)
+
+// ContextFieldsFunc extracts the inbound and outbound tags of the connection
+// a context belongs to. The log package cannot read connection metadata
+// itself, so the caller that owns it provides the function.
+type ContextFieldsFunc func(ctx context.Context) (inbound string, outbound string)
+
+// JSONFormatter formats entries as JSON lines.
+type JSONFormatter struct {
+	ContextFields ContextFieldsFunc
+}
+
+type jsonEntry struct {
+	Level    string `json:"level"`
+	Time     string `json:"time"`
+	Tag      string `json:"tag,omitempty"`
+	ID       uint32 `json:"id,omitempty"`
+	Inbound  string `json:"inbound,omitempty"`
+	Outbound string `json:"outbound,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (f JSONFormatter) Format(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string {
+	entry := jsonEntry{
+		Level:   FormatLevel(level),
+		Time:    timestamp.Format(time.RFC3339Nano),
+		Tag:     tag,
+		Message: strings.TrimSuffix(message, "\n"),
+	}
+	if ctx != nil {
+		if id, loaded := IDFromContext(ctx); loaded {
+			entry.ID = id.ID
+		}
+		if f.ContextFields != nil {
+			entry.Inbound, entry.Outbound = f.ContextFields(ctx)
+		}
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return entry.Message + "\n"
+	}
+	return string(content) + "\n"
+}
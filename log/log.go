@@ -17,6 +17,7 @@ type Options struct {
 	DefaultWriter  io.Writer
 	BaseTime       time.Time
 	PlatformWriter PlatformWriter
+	ContextFields  ContextFieldsFunc
 }
 
 func New(options Options) (Factory, error) {
@@ -26,34 +27,48 @@ func New(options Options) (Factory, error) {
 		return NewNOPFactory(), nil
 	}
 
-	var logWriter io.Writer
-	var logFilePath string
-
-	switch logOptions.Output {
-	case "":
-		logWriter = options.DefaultWriter
-		if logWriter == nil {
-			logWriter = os.Stderr
+	output, err := newSink(options, logOptions.Output, logOptions.Format, logOptions.Timestamp, logOptions.Rotation)
+	if err != nil {
+		return nil, err
+	}
+	if output.Writer == nil && output.FilePath == "" {
+		output.Writer = options.DefaultWriter
+		if output.Writer == nil {
+			output.Writer = os.Stderr
 		}
-	case "stderr":
-		logWriter = os.Stderr
-	case "stdout":
-		logWriter = os.Stdout
-	default:
-		logFilePath = logOptions.Output
 	}
-	logFormatter := Formatter{
-		BaseTime:         options.BaseTime,
-		DisableColors:    logOptions.DisableColor || logFilePath != "",
-		DisableTimestamp: !logOptions.Timestamp && logFilePath != "",
-		FullTimestamp:    logOptions.Timestamp,
-		TimestampFormat:  "-0700 2006-01-02 15:04:05",
+	output.Level = LevelTrace
+	var sinks []*Sink
+	for i, sinkOptions := range logOptions.Sinks {
+		if sinkOptions.Output == "" {
+			return nil, E.New("parse log sink[", i, "]: missing output")
+		}
+		sink, err := newSink(options, sinkOptions.Output, sinkOptions.Format, sinkOptions.Timestamp, sinkOptions.Rotation)
+		if err != nil {
+			return nil, E.Cause(err, "parse log sink[", i, "]")
+		}
+		if sinkOptions.Level != "" {
+			sink.Level, err = ParseLevel(sinkOptions.Level)
+			if err != nil {
+				return nil, E.Cause(err, "parse log sink[", i, "]: parse log level")
+			}
+		} else {
+			sink.Level = LevelTrace
+		}
+		sink.Tags = sinkOptions.Tag
+		sinks = append(sinks, sink)
 	}
-	factory := NewDefaultFactory(
+	factory := newDefaultFactory(
 		options.Context,
-		logFormatter,
-		logWriter,
-		logFilePath,
+		Formatter{
+			BaseTime:         options.BaseTime,
+			DisableColors:    logOptions.DisableColor || output.FilePath != "",
+			DisableTimestamp: !logOptions.Timestamp && output.FilePath != "",
+			FullTimestamp:    logOptions.Timestamp,
+			TimestampFormat:  timestampFormat,
+		},
+		output,
+		sinks,
 		options.PlatformWriter,
 		options.Observable,
 	)
@@ -68,3 +83,48 @@ func New(options Options) (Factory, error) {
 	}
 	return factory, nil
 }
+
+const timestampFormat = "-0700 2006-01-02 15:04:05"
+
+func newSink(options Options, output string, format string, timestamp bool, rotation *option.LogRotationOptions) (*Sink, error) {
+	sink := &Sink{}
+	switch output {
+	case "":
+	case "stderr":
+		sink.Writer = os.Stderr
+	case "stdout":
+		sink.Writer = os.Stdout
+	default:
+		sink.FilePath = output
+	}
+	if rotation != nil {
+		if sink.FilePath == "" {
+			return nil, E.New("rotation requires a file output")
+		}
+		sink.Rotate = &RotateOptions{
+			Interval:   time.Duration(rotation.Interval),
+			MaxBackups: rotation.MaxBackups,
+			MaxAge:     time.Duration(rotation.MaxAge),
+		}
+		if rotation.MaxSize != nil {
+			sink.Rotate.MaxSize = int64(rotation.MaxSize.Value())
+		}
+	}
+	switch format {
+	case "", "text":
+		sink.Format = Formatter{
+			BaseTime:         options.BaseTime,
+			DisableColors:    options.Options.DisableColor || sink.FilePath != "",
+			DisableTimestamp: !timestamp && sink.FilePath != "",
+			FullTimestamp:    timestamp,
+			TimestampFormat:  timestampFormat,
+		}.Format
+	case "json":
+		sink.Format = JSONFormatter{
+			ContextFields: options.ContextFields,
+		}.Format
+	default:
+		return nil, E.New("unknown log format: ", format)
+	}
+	return sink, nil
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/sagernet/sing/common"
 	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/observable"
-	"github.com/sagernet/sing/service/filemanager"
 )
 
 var _ Factory = (*defaultFactory)(nil)
@@ -18,9 +17,8 @@ type defaultFactory struct {
 	ctx               context.Context
 	formatter         Formatter
 	platformFormatter Formatter
-	writer            io.Writer
-	file              *os.File
-	filePath          string
+	output            *Sink
+	sinks             []*Sink
 	platformWriter    PlatformWriter
 	needObservable    bool
 	level             Level
@@ -36,6 +34,22 @@ func NewDefaultFactory(
 	platformWriter PlatformWriter,
 	needObservable bool,
 ) ObservableFactory {
+	return newDefaultFactory(ctx, formatter, &Sink{
+		Format:   formatter.Format,
+		Writer:   writer,
+		FilePath: filePath,
+		Level:    LevelTrace,
+	}, nil, platformWriter, needObservable)
+}
+
+func newDefaultFactory(
+	ctx context.Context,
+	formatter Formatter,
+	output *Sink,
+	sinks []*Sink,
+	platformWriter PlatformWriter,
+	needObservable bool,
+) *defaultFactory {
 	factory := &defaultFactory{
 		ctx:       ctx,
 		formatter: formatter,
@@ -43,8 +57,8 @@ func NewDefaultFactory(
 			BaseTime:         formatter.BaseTime,
 			DisableLineBreak: true,
 		},
-		writer:         writer,
-		filePath:       filePath,
+		output:         output,
+		sinks:          sinks,
 		platformWriter: platformWriter,
 		needObservable: needObservable,
 		level:          LevelTrace,
@@ -60,22 +74,26 @@ func NewDefaultFactory(
 }
 
 func (f *defaultFactory) Start() error {
-	if f.filePath != "" {
-		logFile, err := filemanager.OpenFile(f.ctx, f.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	err := f.output.start(f.ctx)
+	if err != nil {
+		return err
+	}
+	for _, sink := range f.sinks {
+		err = sink.start(f.ctx)
 		if err != nil {
 			return err
 		}
-		f.writer = logFile
-		f.file = logFile
 	}
 	return nil
 }
 
 func (f *defaultFactory) Close() error {
-	return common.Close(
-		common.PtrOrNil(f.file),
-		f.subscriber,
-	)
+	closers := []any{f.output}
+	for _, sink := range f.sinks {
+		closers = append(closers, sink)
+	}
+	closers = append(closers, f.subscriber)
+	return common.Close(closers...)
 }
 
 func (f *defaultFactory) Level() Level {
@@ -115,28 +133,26 @@ func (l *observableLogger) Log(ctx context.Context, level Level, args []any) {
 		return
 	}
 	nowTime := time.Now()
-	if l.needObservable {
-		message, messageSimple := l.formatter.FormatWithSimple(ctx, level, l.tag, F.ToString(args...), nowTime)
-		if level == LevelPanic {
-			panic(message)
-		}
-		l.writer.Write([]byte(message))
-		if level == LevelFatal {
-			os.Exit(1)
+	rawMessage := F.ToString(args...)
+	message := l.output.Format(ctx, level, l.tag, rawMessage, nowTime)
+	if level == LevelPanic {
+		panic(message)
+	}
+	l.output.Writer.Write([]byte(message))
+	for _, sink := range l.sinks {
+		if sink.match(level, l.tag) {
+			sink.Writer.Write([]byte(sink.Format(ctx, level, l.tag, rawMessage, nowTime)))
 		}
+	}
+	if level == LevelFatal {
+		os.Exit(1)
+	}
+	if l.needObservable {
+		_, messageSimple := l.formatter.FormatWithSimple(ctx, level, l.tag, rawMessage, nowTime)
 		l.subscriber.Emit(Entry{level, messageSimple})
-	} else {
-		message := l.formatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime)
-		if level == LevelPanic {
-			panic(message)
-		}
-		l.writer.Write([]byte(message))
-		if level == LevelFatal {
-			os.Exit(1)
-		}
 	}
 	if l.platformWriter != nil {
-		l.platformWriter.WriteMessage(level, l.platformFormatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime))
+		l.platformWriter.WriteMessage(level, l.platformFormatter.Format(ctx, level, l.tag, rawMessage, nowTime))
 	}
 }
 
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+const rotateTimeFormat = "2006-01-02T15-04-05.000"
+
+type RotateOptions struct {
+	MaxSize    int64
+	Interval   time.Duration
+	MaxBackups int
+	MaxAge     time.Duration
+}
+
+// RotateFile is a log file that is renamed to <name>.<time> and reopened
+// once it grows past MaxSize or Interval elapses. Backups beyond MaxBackups
+// or older than MaxAge are removed after each rotation.
+type RotateFile struct {
+	ctx          context.Context
+	path         string
+	options      RotateOptions
+	access       sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotateAt time.Time
+}
+
+func OpenRotateFile(ctx context.Context, path string, options RotateOptions) (*RotateFile, error) {
+	file := &RotateFile{
+		ctx:     ctx,
+		path:    filemanager.BasePath(ctx, path),
+		options: options,
+	}
+	err := file.open(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *RotateFile) open(now time.Time) error {
+	file, err := filemanager.OpenFile(f.ctx, f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = fileInfo.Size()
+	if f.options.Interval > 0 {
+		f.nextRotateAt = now.Truncate(f.options.Interval).Add(f.options.Interval)
+	}
+	return nil
+}
+
+func (f *RotateFile) Write(p []byte) (n int, err error) {
+	f.access.Lock()
+	defer f.access.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	now := time.Now()
+	if f.size > 0 && (f.options.MaxSize > 0 && f.size+int64(len(p)) > f.options.MaxSize ||
+		f.options.Interval > 0 && !now.Before(f.nextRotateAt)) {
+		err = f.rotate(now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return
+}
+
+func (f *RotateFile) rotate(now time.Time) error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+	renameErr := os.Rename(f.path, f.path+"."+now.Format(rotateTimeFormat))
+	err = f.open(now)
+	if err != nil {
+		return err
+	}
+	if renameErr != nil {
+		// keep writing to the current file instead of retrying on every write
+		f.size = 0
+	} else {
+		f.removeBackups(now)
+	}
+	return nil
+}
+
+func (f *RotateFile) removeBackups(now time.Time) {
+	if f.options.MaxBackups <= 0 && f.options.MaxAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, match := range matches {
+		backupTime, err := time.ParseInLocation(rotateTimeFormat, strings.TrimPrefix(match, f.path+"."), time.Local)
+		if err != nil {
+			continue
+		}
+		if f.options.MaxAge > 0 && now.Sub(backupTime) > f.options.MaxAge {
+			os.Remove(match)
+			continue
+		}
+		backups = append(backups, match)
+	}
+	if f.options.MaxBackups > 0 && len(backups) > f.options.MaxBackups {
+		sort.Strings(backups)
+		for _, backup := range backups[:len(backups)-f.options.MaxBackups] {
+			os.Remove(backup)
+		}
+	}
+}
+
+func (f *RotateFile) Close() error {
+	f.access.Lock()
+	defer f.access.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "box.log")
+	file, err := OpenRotateFile(context.Background(), path, RotateOptions{
+		MaxSize:    16,
+		MaxBackups: 1,
+	})
+	require.NoError(t, err)
+	for _, line := range []string{"0123456789\n", "abcdefghij\n", "ABCDEFGHIJ\n"} {
+		_, err = file.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "ABCDEFGHIJ\n", string(content))
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	content, err = os.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "abcdefghij\n", string(content))
+}
+
+func TestSinks(t *testing.T) {
+	t.Parallel()
+	directory := t.TempDir()
+	dnsPath := filepath.Join(directory, "dns.log")
+	mainPath := filepath.Join(directory, "box.log")
+	factory, err := New(Options{
+		Context: context.Background(),
+		Options: option.LogOptions{
+			Level:  "debug",
+			Output: mainPath,
+			Format: "json",
+			Rotation: &option.LogRotationOptions{
+				Interval:   badoption.Duration(time.Hour),
+				MaxBackups: 1,
+			},
+			Sinks: []option.LogSinkOptions{{
+				Output: dnsPath,
+				Level:  "info",
+				Tag:    []string{"dns"},
+			}},
+		},
+		ContextFields: func(ctx context.Context) (string, string) {
+			return "mixed-in", "direct"
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, factory.Start())
+	ctx := ContextWithNewID(context.Background())
+	factory.NewLogger("dns").InfoContext(ctx, "exchanged")
+	factory.NewLogger("dns").DebugContext(ctx, "cached")
+	factory.NewLogger("router").InfoContext(ctx, "matched")
+	factory.NewLogger("router").Trace("ignored")
+	require.NoError(t, factory.Close())
+
+	content, err := os.ReadFile(mainPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	require.Len(t, lines, 3)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "info", entry["level"])
+	require.Equal(t, "dns", entry["tag"])
+	require.Equal(t, "mixed-in", entry["inbound"])
+	require.Equal(t, "direct", entry["outbound"])
+	require.Equal(t, "exchanged", entry["message"])
+	require.NotZero(t, entry["id"])
+	require.NotEmpty(t, entry["time"])
+
+	content, err = os.ReadFile(dnsPath)
+	require.NoError(t, err)
+	require.Equal(t, "INFO dns: exchanged\n", stripID(string(content)))
+}
+
+func stripID(message string) string {
+	start := strings.Index(message, "[")
+	end := strings.Index(message, "] ")
+	if start < 0 || end < start {
+		return message
+	}
+	return message[:start] + message[end+2:]
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+type FormatFunc func(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string
+
+// Sink is a log destination. The factory level applies to every sink; a sink
+// may further restrict entries by level and by tag prefix.
+type Sink struct {
+	Format   FormatFunc
+	Writer   io.Writer
+	FilePath string
+	Rotate   *RotateOptions
+	Level    Level
+	Tags     []string
+	closer   io.Closer
+}
+
+func (s *Sink) start(ctx context.Context) error {
+	if s.FilePath == "" {
+		return nil
+	}
+	if s.Rotate != nil {
+		rotateFile, err := OpenRotateFile(ctx, s.FilePath, *s.Rotate)
+		if err != nil {
+			return err
+		}
+		s.Writer = rotateFile
+		s.closer = rotateFile
+	} else {
+		logFile, err := filemanager.OpenFile(ctx, s.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		s.Writer = logFile
+		s.closer = logFile
+	}
+	return nil
+}
+
+func (s *Sink) match(level Level, tag string) bool {
+	if level > s.Level {
+		return false
+	}
+	if len(s.Tags) == 0 {
+		return true
+	}
+	for _, prefix := range s.Tags {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -1,12 +1,19 @@
 package option
 
-import "github.com/sagernet/sing/common/json/badoption"
+import (
+	"github.com/sagernet/sing/common/byteformats"
+	"github.com/sagernet/sing/common/json/badoption"
+)
 
 type ExperimentalOptions struct {
-	CacheFile *CacheFileOptions `json:"cache_file,omitempty"`
-	ClashAPI  *ClashAPIOptions  `json:"clash_api,omitempty"`
-	V2RayAPI  *V2RayAPIOptions  `json:"v2ray_api,omitempty"`
-	Debug     *DebugOptions     `json:"debug,omitempty"`
+	CacheFile      *CacheFileOptions      `json:"cache_file,omitempty"`
+	ClashAPI       *ClashAPIOptions       `json:"clash_api,omitempty"`
+	V2RayAPI       *V2RayAPIOptions       `json:"v2ray_api,omitempty"`
+	Metrics        *MetricsOptions        `json:"metrics,omitempty"`
+	AccessLog      *AccessLogOptions      `json:"access_log,omitempty"`
+	TrafficHistory *TrafficHistoryOptions `json:"traffic_history,omitempty"`
+	Events         *EventsOptions         `json:"events,omitempty"`
+	Debug          *DebugOptions          `json:"debug,omitempty"`
 }
 
 type CacheFileOptions struct {
@@ -42,8 +49,67 @@ type ClashAPIOptions struct {
 }
 
 type V2RayAPIOptions struct {
-	Listen string                    `json:"listen,omitempty"`
-	Stats  *V2RayStatsServiceOptions `json:"stats,omitempty"`
+	Listen  string                      `json:"listen,omitempty"`
+	Stats   *V2RayStatsServiceOptions   `json:"stats,omitempty"`
+	Handler *V2RayHandlerServiceOptions `json:"handler,omitempty"`
+}
+
+type MetricsOptions struct {
+	Listen string `json:"listen,omitempty"`
+}
+
+type AccessLogOptions struct {
+	Output   string                  `json:"output,omitempty"`
+	Format   string                  `json:"format,omitempty"`
+	Rotation *LogRotationOptions     `json:"rotation,omitempty"`
+	Syslog   *AccessLogSyslogOptions `json:"syslog,omitempty"`
+}
+
+type AccessLogSyslogOptions struct {
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+type TrafficHistoryOptions struct {
+	Enabled       bool                    `json:"enabled,omitempty"`
+	FlushInterval badoption.Duration      `json:"flush_interval,omitempty"`
+	Retention     TrafficRetentionOptions `json:"retention,omitempty"`
+	Budgets       []TrafficBudgetOptions  `json:"budgets,omitempty"`
+}
+
+type TrafficRetentionOptions struct {
+	Hourly  int `json:"hourly,omitempty"`
+	Daily   int `json:"daily,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+}
+
+type TrafficBudgetOptions struct {
+	Type    string                   `json:"type,omitempty"`
+	Name    string                   `json:"name,omitempty"`
+	Monthly *byteformats.MemoryBytes `json:"monthly,omitempty"`
+}
+
+type EventsOptions struct {
+	Webhooks []EventWebhookOptions `json:"webhooks,omitempty"`
+	Exec     []EventExecOptions    `json:"exec,omitempty"`
+}
+
+type EventWebhookOptions struct {
+	URL      string                     `json:"url"`
+	Method   string                     `json:"method,omitempty"`
+	Headers  badoption.HTTPHeader       `json:"headers,omitempty"`
+	Template string                     `json:"template,omitempty"`
+	Events   badoption.Listable[string] `json:"events,omitempty"`
+	Detour   string                     `json:"detour,omitempty"`
+	Timeout  badoption.Duration         `json:"timeout,omitempty"`
+}
+
+type EventExecOptions struct {
+	Command string                     `json:"command"`
+	Args    badoption.Listable[string] `json:"args,omitempty"`
+	Events  badoption.Listable[string] `json:"events,omitempty"`
+	Timeout badoption.Duration         `json:"timeout,omitempty"`
 }
 
 type V2RayStatsServiceOptions struct {
@@ -52,3 +118,7 @@ type V2RayStatsServiceOptions struct {
 	Outbounds []string `json:"outbounds,omitempty"`
 	Users     []string `json:"users,omitempty"`
 }
+
+type V2RayHandlerServiceOptions struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
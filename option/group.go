@@ -14,18 +14,34 @@ type URLTestOutboundOptions struct {
 	Interval                  badoption.Duration `json:"interval,omitempty"`
 	Tolerance                 uint16             `json:"tolerance,omitempty"`
 	IdleTimeout               badoption.Duration `json:"idle_timeout,omitempty"`
+	UDPCheck                  *UDPCheckOptions   `json:"udp_check,omitempty"`
 	InterruptExistConnections bool               `json:"interrupt_exist_connections,omitempty"`
 }
 
+type UDPCheckOptions struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Server   string `json:"server,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
 type FallbackOutboundOptions struct {
 	Outbounds                 []string           `json:"outbounds"`
 	URL                       string             `json:"url,omitempty"`
 	Interval                  badoption.Duration `json:"interval,omitempty"`
 	IdleTimeout               badoption.Duration `json:"idle_timeout,omitempty"`
 	Timeout                   badoption.Duration `json:"timeout,omitempty"`
+	FirstByteTimeout          badoption.Duration `json:"first_byte_timeout,omitempty"`
+	UDPCheck                  *UDPCheckOptions   `json:"udp_check,omitempty"`
 	InterruptExistConnections bool               `json:"interrupt_exist_connections,omitempty"`
 }
 
+type SmartOutboundOptions struct {
+	FallbackOutboundOptions
+	Expiry badoption.Duration `json:"expiry,omitempty"`
+}
+
 type LoadBalanceStrategy string
 
 const (
@@ -41,5 +57,10 @@ type LoadBalanceOutboundOptions struct {
 	IdleTimeout               badoption.Duration  `json:"idle_timeout,omitempty"`
 	Timeout                   badoption.Duration  `json:"timeout,omitempty"`
 	Strategy                  LoadBalanceStrategy `json:"strategy,omitempty"`
+	UDPCheck                  *UDPCheckOptions    `json:"udp_check,omitempty"`
 	InterruptExistConnections bool                `json:"interrupt_exist_connections,omitempty"`
 }
+
+type RelayOutboundOptions struct {
+	Outbounds []string `json:"outbounds"`
+}
@@ -0,0 +1,22 @@
+package option
+
+import (
+	"github.com/sagernet/sing/common/byteformats"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+type LogRotationOptions struct {
+	MaxSize    *byteformats.MemoryBytes `json:"max_size,omitempty"`
+	Interval   badoption.Duration       `json:"interval,omitempty"`
+	MaxBackups int                      `json:"max_backups,omitempty"`
+	MaxAge     badoption.Duration       `json:"max_age,omitempty"`
+}
+
+type LogSinkOptions struct {
+	Output    string                     `json:"output,omitempty"`
+	Level     string                     `json:"level,omitempty"`
+	Tag       badoption.Listable[string] `json:"tag,omitempty"`
+	Format    string                     `json:"format,omitempty"`
+	Timestamp bool                       `json:"timestamp,omitempty"`
+	Rotation  *LogRotationOptions        `json:"rotation,omitempty"`
+}
@@ -38,11 +38,14 @@ func (o *Options) UnmarshalJSONContext(ctx context.Context, content []byte) erro
 }
 
 type LogOptions struct {
-	Disabled     bool   `json:"disabled,omitempty"`
-	Level        string `json:"level,omitempty"`
-	Output       string `json:"output,omitempty"`
-	Timestamp    bool   `json:"timestamp,omitempty"`
-	DisableColor bool   `json:"-"`
+	Disabled     bool                `json:"disabled,omitempty"`
+	Level        string              `json:"level,omitempty"`
+	Output       string              `json:"output,omitempty"`
+	Timestamp    bool                `json:"timestamp,omitempty"`
+	Format       string              `json:"format,omitempty"`
+	Rotation     *LogRotationOptions `json:"rotation,omitempty"`
+	Sinks        []LogSinkOptions    `json:"sinks,omitempty"`
+	DisableColor bool                `json:"-"`
 }
 
 type StubOptions struct{}
@@ -0,0 +1,18 @@
+package option
+
+import "github.com/sagernet/sing/common/auth"
+
+type ReverseEndpointOptions struct {
+	DialerOptions
+	ServerOptions
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Connections int    `json:"connections,omitempty"`
+}
+
+type PortalOutboundOptions struct {
+	Domain   string      `json:"domain,omitempty"`
+	Users    []auth.User `json:"users,omitempty"`
+	Protocol string      `json:"protocol,omitempty"`
+	Padding  bool        `json:"padding,omitempty"`
+}
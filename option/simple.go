@@ -16,6 +16,7 @@ type HTTPMixedInboundOptions struct {
 	Users          []auth.User           `json:"users,omitempty"`
 	DomainResolver *DomainResolveOptions `json:"domain_resolver,omitempty"`
 	SetSystemProxy bool                  `json:"set_system_proxy,omitempty"`
+	Network        NetworkList           `json:"network,omitempty"`
 	InboundTLSOptionsContainer
 }
 
@@ -34,6 +35,7 @@ type HTTPOutboundOptions struct {
 	ServerOptions
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	Version  string `json:"version,omitempty"`
 	OutboundTLSOptionsContainer
 	Path    string               `json:"path,omitempty"`
 	Headers badoption.HTTPHeader `json:"headers,omitempty"`
@@ -1,6 +1,23 @@
 package option
 
-import "github.com/sagernet/sing/common/json/badoption"
+import (
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+type SSHInboundOptions struct {
+	ListenOptions
+	Users         []SSHUser                  `json:"users,omitempty"`
+	NoAuth        bool                       `json:"no_auth,omitempty"`
+	HostKey       badoption.Listable[string] `json:"host_key,omitempty"`
+	HostKeyPath   badoption.Listable[string] `json:"host_key_path,omitempty"`
+	ServerVersion string                     `json:"server_version,omitempty"`
+}
+
+type SSHUser struct {
+	auth.User
+	AuthorizedKeys badoption.Listable[string] `json:"authorized_keys,omitempty"`
+}
 
 type SSHOutboundOptions struct {
 	DialerOptions
@@ -1 +1,12 @@
 package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type V2RayFallbackOptions struct {
+	ServerOptions
+	Inbound       string                     `json:"inbound,omitempty"`
+	ServerName    badoption.Listable[string] `json:"server_name,omitempty"`
+	ALPN          badoption.Listable[string] `json:"alpn,omitempty"`
+	Path          string                     `json:"path,omitempty"`
+	ProxyProtocol uint8                      `json:"proxy_protocol,omitempty"`
+}
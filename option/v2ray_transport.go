@@ -1,6 +1,9 @@
 package option
 
 import (
+	"strconv"
+	"strings"
+
 	C "github.com/sagernet/sing-box/constant"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -15,6 +18,8 @@ type _V2RayTransportOptions struct {
 	QUICOptions        V2RayQUICOptions        `json:"-"`
 	GRPCOptions        V2RayGRPCOptions        `json:"-"`
 	HTTPUpgradeOptions V2RayHTTPUpgradeOptions `json:"-"`
+	XHTTPOptions       V2RayXHTTPOptions       `json:"-"`
+	KCPOptions         V2RayKCPOptions         `json:"-"`
 }
 
 type V2RayTransportOptions _V2RayTransportOptions
@@ -32,6 +37,10 @@ func (o V2RayTransportOptions) MarshalJSON() ([]byte, error) {
 		v = o.GRPCOptions
 	case C.V2RayTransportTypeHTTPUpgrade:
 		v = o.HTTPUpgradeOptions
+	case C.V2RayTransportTypeXHTTP:
+		v = o.XHTTPOptions
+	case C.V2RayTransportTypeKCP:
+		v = o.KCPOptions
 	case "":
 		return nil, E.New("missing transport type")
 	default:
@@ -57,6 +66,10 @@ func (o *V2RayTransportOptions) UnmarshalJSON(bytes []byte) error {
 		v = &o.GRPCOptions
 	case C.V2RayTransportTypeHTTPUpgrade:
 		v = &o.HTTPUpgradeOptions
+	case C.V2RayTransportTypeXHTTP:
+		v = &o.XHTTPOptions
+	case C.V2RayTransportTypeKCP:
+		v = &o.KCPOptions
 	default:
 		return E.New("unknown transport type: " + o.Type)
 	}
@@ -98,3 +111,85 @@ type V2RayHTTPUpgradeOptions struct {
 	Path    string               `json:"path,omitempty"`
 	Headers badoption.HTTPHeader `json:"headers,omitempty"`
 }
+
+type V2RayKCPOptions struct {
+	MTU              uint32 `json:"mtu,omitempty"`
+	TTI              uint32 `json:"tti,omitempty"`
+	UplinkCapacity   uint32 `json:"uplink_capacity,omitempty"`
+	DownlinkCapacity uint32 `json:"downlink_capacity,omitempty"`
+	Congestion       bool   `json:"congestion,omitempty"`
+	WriteBufferSize  uint32 `json:"write_buffer_size,omitempty"`
+	HeaderType       string `json:"header_type,omitempty"`
+	Seed             string `json:"seed,omitempty"`
+	MaxSessions      int    `json:"max_sessions,omitempty"`
+}
+
+type V2RayXHTTPOptions struct {
+	Host                 string                 `json:"host,omitempty"`
+	Path                 string                 `json:"path,omitempty"`
+	Mode                 string                 `json:"mode,omitempty"`
+	Headers              badoption.HTTPHeader   `json:"headers,omitempty"`
+	XPaddingBytes        *V2RayXHTTPRange       `json:"x_padding_bytes,omitempty"`
+	NoGRPCHeader         bool                   `json:"no_grpc_header,omitempty"`
+	NoSSEHeader          bool                   `json:"no_sse_header,omitempty"`
+	ScMaxEachPostBytes   *V2RayXHTTPRange       `json:"sc_max_each_post_bytes,omitempty"`
+	ScMinPostsIntervalMs *V2RayXHTTPRange       `json:"sc_min_posts_interval_ms,omitempty"`
+	ScMaxBufferedPosts   int                    `json:"sc_max_buffered_posts,omitempty"`
+	ScMaxBufferedBytes   int64                  `json:"sc_max_buffered_bytes,omitempty"`
+	ScMaxSessions        int                    `json:"sc_max_sessions,omitempty"`
+	ScStreamUpServerSecs *V2RayXHTTPRange       `json:"sc_stream_up_server_secs,omitempty"`
+	Xmux                 *V2RayXHTTPXmuxOptions `json:"xmux,omitempty"`
+}
+
+type V2RayXHTTPXmuxOptions struct {
+	MaxConcurrency   *V2RayXHTTPRange   `json:"max_concurrency,omitempty"`
+	MaxConnections   *V2RayXHTTPRange   `json:"max_connections,omitempty"`
+	CMaxReuseTimes   *V2RayXHTTPRange   `json:"c_max_reuse_times,omitempty"`
+	HMaxRequestTimes *V2RayXHTTPRange   `json:"h_max_request_times,omitempty"`
+	HMaxReusableSecs *V2RayXHTTPRange   `json:"h_max_reusable_secs,omitempty"`
+	HKeepAlivePeriod badoption.Duration `json:"h_keep_alive_period,omitempty"`
+}
+
+// V2RayXHTTPRange is a number or a "from-to" range, a random value of which
+// is picked on each use.
+type V2RayXHTTPRange struct {
+	From int32
+	To   int32
+}
+
+func (r V2RayXHTTPRange) MarshalJSON() ([]byte, error) {
+	if r.From == r.To {
+		return json.Marshal(r.From)
+	}
+	return json.Marshal(strconv.Itoa(int(r.From)) + "-" + strconv.Itoa(int(r.To)))
+}
+
+func (r *V2RayXHTTPRange) UnmarshalJSON(content []byte) error {
+	var value int32
+	if json.Unmarshal(content, &value) == nil {
+		r.From, r.To = value, value
+		return nil
+	}
+	var stringValue string
+	err := json.Unmarshal(content, &stringValue)
+	if err != nil {
+		return err
+	}
+	fromString, toString, isRange := strings.Cut(stringValue, "-")
+	if !isRange {
+		toString = fromString
+	}
+	from, err := strconv.ParseInt(strings.TrimSpace(fromString), 10, 32)
+	if err != nil {
+		return E.Cause(err, "parse range: ", stringValue)
+	}
+	to, err := strconv.ParseInt(strings.TrimSpace(toString), 10, 32)
+	if err != nil {
+		return E.Cause(err, "parse range: ", stringValue)
+	}
+	if from > to {
+		return E.New("invalid range: ", stringValue)
+	}
+	r.From, r.To = int32(from), int32(to)
+	return nil
+}
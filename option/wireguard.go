@@ -16,6 +16,7 @@ type WireGuardEndpointOptions struct {
 	Peers      []WireGuardPeer                  `json:"peers,omitempty"`
 	UDPTimeout badoption.Duration               `json:"udp_timeout,omitempty"`
 	Workers    int                              `json:"workers,omitempty"`
+	Amnezia    *WireGuardAmneziaOptions         `json:"amnezia,omitempty"`
 	DialerOptions
 }
 
@@ -27,6 +28,19 @@ type WireGuardPeer struct {
 	AllowedIPs                  badoption.Listable[netip.Prefix] `json:"allowed_ips,omitempty"`
 	PersistentKeepaliveInterval uint16                           `json:"persistent_keepalive_interval,omitempty"`
 	Reserved                    []uint8                          `json:"reserved,omitempty"`
+	Amnezia                     *WireGuardAmneziaOptions         `json:"amnezia,omitempty"`
+}
+
+type WireGuardAmneziaOptions struct {
+	JunkPacketCount            int    `json:"jc,omitempty"`
+	JunkPacketMinSize          int    `json:"jmin,omitempty"`
+	JunkPacketMaxSize          int    `json:"jmax,omitempty"`
+	InitPacketJunkSize         int    `json:"s1,omitempty"`
+	ResponsePacketJunkSize     int    `json:"s2,omitempty"`
+	InitPacketMagicHeader      uint32 `json:"h1,omitempty"`
+	ResponsePacketMagicHeader  uint32 `json:"h2,omitempty"`
+	UnderloadPacketMagicHeader uint32 `json:"h3,omitempty"`
+	TransportPacketMagicHeader uint32 `json:"h4,omitempty"`
 }
 
 type LegacyWireGuardOutboundOptions struct {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -28,13 +29,16 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.AnyTLSInboundOptions](registry, C.TypeAnyTLS, NewInbound)
 }
 
+var _ adapter.ManagedUserServer = (*Inbound)(nil)
+
 type Inbound struct {
 	inbound.Adapter
-	tlsConfig tls.ServerConfig
-	router    adapter.ConnectionRouterEx
-	logger    logger.ContextLogger
-	listener  *listener.Listener
-	service   *anytls.Service
+	tlsConfig     tls.ServerConfig
+	router        adapter.ConnectionRouterEx
+	logger        logger.ContextLogger
+	listener      *listener.Listener
+	paddingScheme []byte
+	users         atomic.Pointer[inboundUsers]
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.AnyTLSInboundOptions) (adapter.Inbound, error) {
@@ -52,23 +56,16 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		inbound.tlsConfig = tlsConfig
 	}
 
-	paddingScheme := padding.DefaultPaddingScheme
+	inbound.paddingScheme = padding.DefaultPaddingScheme
 	if len(options.PaddingScheme) > 0 {
-		paddingScheme = []byte(strings.Join(options.PaddingScheme, "\n"))
+		inbound.paddingScheme = []byte(strings.Join(options.PaddingScheme, "\n"))
 	}
 
-	service, err := anytls.NewService(anytls.ServiceConfig{
-		Users: common.Map(options.Users, func(it option.AnyTLSUser) anytls.User {
-			return (anytls.User)(it)
-		}),
-		PaddingScheme: paddingScheme,
-		Handler:       (*inboundHandler)(inbound),
-		Logger:        logger,
-	})
+	users, err := inbound.newUsers(options.Users)
 	if err != nil {
 		return nil, err
 	}
-	inbound.service = service
+	inbound.users.Store(users)
 	inbound.listener = listener.New(listener.Options{
 		Context:           ctx,
 		Logger:            logger,
@@ -96,6 +93,38 @@ func (h *Inbound) Close() error {
 	return common.Close(h.listener, h.tlsConfig)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.Password == "" {
+			return E.New("missing password for user ", user.Name)
+		}
+	}
+	newUsers, err := h.newUsers(common.Map(users, func(it adapter.ManagedUser) option.AnyTLSUser {
+		return option.AnyTLSUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	}))
+	if err != nil {
+		return err
+	}
+	h.users.Store(newUsers)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return common.Map(h.users.Load().users, func(it option.AnyTLSUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	})
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil {
 		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
@@ -106,13 +135,36 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	err := h.users.Load().service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
 	if err != nil {
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
 	}
 }
 
+// inboundUsers is the user list together with the service authenticating
+// it. UpdateUsers replaces both at once instead of updating the service in
+// place, which would race with connections being authenticated.
+type inboundUsers struct {
+	users   []option.AnyTLSUser
+	service *anytls.Service
+}
+
+func (h *Inbound) newUsers(users []option.AnyTLSUser) (*inboundUsers, error) {
+	service, err := anytls.NewService(anytls.ServiceConfig{
+		Users: common.Map(users, func(it option.AnyTLSUser) anytls.User {
+			return (anytls.User)(it)
+		}),
+		PaddingScheme: h.paddingScheme,
+		Handler:       (*inboundHandler)(h),
+		Logger:        h.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &inboundUsers{users, service}, nil
+}
+
 type inboundHandler Inbound
 
 func (h *inboundHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
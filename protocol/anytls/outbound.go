@@ -62,6 +62,7 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		Context:        ctx,
 		Options:        options.DialerOptions,
 		RemoteIsDomain: options.ServerIsDomain(),
+		Outbound:       true,
 	})
 	if err != nil {
 		return nil, err
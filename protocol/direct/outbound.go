@@ -47,11 +47,19 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if options.Detour != "" {
 		return nil, E.New("`detour` is not supported in direct context")
 	}
+	if options.ProxyProtocol != 0 {
+		//nolint:staticcheck
+		if options.OverrideAddress == "" {
+			return nil, E.New("`proxy_protocol` in direct outbound is only supported with `override_address`")
+		}
+		logger.Warn("`proxy_protocol` is enabled, a PROXY protocol header is sent to ", options.OverrideAddress, " on every connection")
+	}
 	outboundDialer, err := dialer.NewWithOptions(dialer.Options{
 		Context:        ctx,
 		Options:        options.DialerOptions,
 		RemoteIsDomain: true,
 		DirectOutbound: true,
+		Outbound:       true,
 	})
 	if err != nil {
 		return nil, err
@@ -68,10 +76,6 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		// loopBack:       newLoopBackDetector(router),
 	}
 	//nolint:staticcheck
-	if options.ProxyProtocol != 0 {
-		return nil, E.New("Proxy Protocol is deprecated and removed in sing-box 1.6.0")
-	}
-	//nolint:staticcheck
 	if options.OverrideAddress != "" && options.OverridePort != 0 {
 		outbound.overrideOption = 1
 		outbound.overrideDestination = M.ParseSocksaddrHostPort(options.OverrideAddress, options.OverridePort)
@@ -24,6 +24,8 @@ func RegisterFallback(registry *outbound.Registry) {
 
 var (
 	_ adapter.OutboundGroup             = (*Fallback)(nil)
+	_ adapter.URLTestGroup              = (*Fallback)(nil)
+	_ adapter.PinnableGroup             = (*Fallback)(nil)
 	_ adapter.ConnectionHandlerEx       = (*Fallback)(nil)
 	_ adapter.PacketConnectionHandlerEx = (*Fallback)(nil)
 )
@@ -39,13 +41,19 @@ type Fallback struct {
 	interval                     time.Duration
 	idleTimeout                  time.Duration
 	timeout                      time.Duration
+	firstByteTimeout             time.Duration
+	udpCheck                     *option.UDPCheckOptions
 	group                        *FallbackGroup
 	interruptExternalConnections bool
 }
 
 func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (adapter.Outbound, error) {
+	return newFallback(ctx, logger, C.TypeFallback, tag, options)
+}
+
+func newFallback(ctx context.Context, logger log.ContextLogger, outboundType string, tag string, options option.FallbackOutboundOptions) (*Fallback, error) {
 	outbound := &Fallback{
-		Adapter:                      outbound.NewAdapter(C.TypeFallback, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		Adapter:                      outbound.NewAdapter(outboundType, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
 		ctx:                          ctx,
 		outbound:                     service.FromContext[adapter.OutboundManager](ctx),
 		connection:                   service.FromContext[adapter.ConnectionManager](ctx),
@@ -55,6 +63,8 @@ func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextL
 		interval:                     time.Duration(options.Interval),
 		idleTimeout:                  time.Duration(options.IdleTimeout),
 		timeout:                      time.Duration(options.Timeout),
+		firstByteTimeout:             time.Duration(options.FirstByteTimeout),
+		udpCheck:                     options.UDPCheck,
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
 	if len(outbound.tags) == 0 {
@@ -72,10 +82,26 @@ func (s *Fallback) Start() error {
 		}
 		outbounds = append(outbounds, detour)
 	}
-	group, err := NewFallbackGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.idleTimeout, s.timeout, s.interruptExternalConnections)
+	group, err := NewFallbackGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.idleTimeout, s.timeout, s.firstByteTimeout, s.udpCheck, s.interruptExternalConnections)
 	if err != nil {
 		return err
 	}
+	if s.Tag() != "" {
+		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+		if cacheFile != nil {
+			pinned := cacheFile.LoadSelected(s.Tag())
+			if pinned != "" {
+				for _, detour := range outbounds {
+					if detour.Tag() == pinned {
+						group.pinned.Store(detour)
+						break
+					}
+				}
+			}
+		}
+	}
+	group.onPinnedChanged = s.storePinned
+	group.onSelectedChanged = s.emitSelected
 	s.group = group
 	return nil
 }
@@ -99,6 +125,51 @@ func (s *Fallback) All() []string {
 	return s.tags
 }
 
+func (s *Fallback) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return s.group.checker.urlTest(ctx, true)
+}
+
+// Pin makes the group stick to the given member until it fails or the pin
+// is cleared with an empty tag.
+func (s *Fallback) Pin(tag string) bool {
+	if tag == "" {
+		s.group.Pin(nil)
+		return true
+	}
+	for _, detour := range s.group.outbounds {
+		if detour.Tag() == tag {
+			s.group.Pin(detour)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Fallback) Pinned() string {
+	if pinned := s.group.pinned.Load(); pinned != nil {
+		return pinned.Tag()
+	}
+	return ""
+}
+
+func (s *Fallback) storePinned(tag string) {
+	if s.Tag() == "" {
+		return
+	}
+	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+	if cacheFile == nil {
+		return
+	}
+	err := cacheFile.StoreSelected(s.Tag(), tag)
+	if err != nil {
+		s.logger.Error("store pinned: ", err)
+	}
+}
+
+func (s *Fallback) emitSelected(network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	emitSelected(s.ctx, s.Tag(), network, previous, outbound)
+}
+
 func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	s.group.Touch()
 	return s.group.DialContext(ctx, network, destination)
@@ -119,24 +190,37 @@ func (s *Fallback) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	s.connection.NewPacketConnection(ctx, s, conn, metadata, onClose)
 }
 
+// fallbackLearner orders members per destination from the outcome of
+// previous connections.
+type fallbackLearner interface {
+	reorder(destination M.Socksaddr, tryList []adapter.Outbound) []adapter.Outbound
+	record(destination M.Socksaddr, detour adapter.Outbound, success bool)
+}
+
 type FallbackGroup struct {
 	checker                      *healthChecker
 	logger                       log.ContextLogger
 	outbounds                    []adapter.Outbound
 	selectedOutboundTCP          common.TypedValue[adapter.Outbound]
 	selectedOutboundUDP          common.TypedValue[adapter.Outbound]
+	pinned                       common.TypedValue[adapter.Outbound]
+	onPinnedChanged              func(tag string)
+	onSelectedChanged            func(network string, previous adapter.Outbound, outbound adapter.Outbound)
+	learner                      fallbackLearner
 	interruptGroup               *interrupt.Group
 	interruptExternalConnections bool
+	firstByteTimeout             time.Duration
 }
 
-func NewFallbackGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.ContextLogger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, interruptExternalConnections bool) (*FallbackGroup, error) {
+func NewFallbackGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.ContextLogger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, firstByteTimeout time.Duration, udpCheck *option.UDPCheckOptions, interruptExternalConnections bool) (*FallbackGroup, error) {
 	group := &FallbackGroup{
 		logger:                       logger,
 		outbounds:                    outbounds,
 		interruptGroup:               interrupt.NewGroup(),
+		firstByteTimeout:             firstByteTimeout,
 		interruptExternalConnections: interruptExternalConnections,
 	}
-	checker, err := newHealthChecker(ctx, outboundManager, logger, outbounds, link, interval, idleTimeout, timeout, group.performUpdateCheck)
+	checker, err := newHealthChecker(ctx, outboundManager, logger, outbounds, link, interval, idleTimeout, timeout, udpCheck, group.performUpdateCheck)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +241,9 @@ func (g *FallbackGroup) Close() error {
 }
 
 func (g *FallbackGroup) Now() string {
+	if pinned := g.pinned.Load(); pinned != nil {
+		return pinned.Tag()
+	}
 	if outboundTCP := g.selectedOutboundTCP.Load(); outboundTCP != nil {
 		return outboundTCP.Tag()
 	}
@@ -169,12 +256,39 @@ func (g *FallbackGroup) Now() string {
 	return ""
 }
 
+func (g *FallbackGroup) Pin(detour adapter.Outbound) {
+	if g.pinned.Swap(detour) == detour {
+		return
+	}
+	if g.onPinnedChanged != nil {
+		var tag string
+		if detour != nil {
+			tag = detour.Tag()
+		}
+		g.onPinnedChanged(tag)
+	}
+	g.updateSelected()
+}
+
+func (g *FallbackGroup) unpin(detour adapter.Outbound) {
+	if detour == nil || !g.pinned.CompareAndSwap(detour, nil) {
+		return
+	}
+	g.logger.Info("unpin ", detour.Tag(), ": outbound failed")
+	if g.onPinnedChanged != nil {
+		g.onPinnedChanged("")
+	}
+}
+
 func (g *FallbackGroup) Select(network string) (adapter.Outbound, bool) {
+	if pinned := g.pinned.Load(); pinned != nil && common.Contains(pinned.Network(), network) {
+		return pinned, true
+	}
 	for _, detour := range g.outbounds {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
-		if g.checker.history.LoadURLTestHistory(RealTag(detour)) != nil {
+		if g.checker.LoadHistory(network, RealTag(detour)) != nil {
 			return detour, true
 		}
 	}
@@ -188,99 +302,146 @@ func (g *FallbackGroup) Select(network string) (adapter.Outbound, bool) {
 }
 
 func (g *FallbackGroup) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	candidates := make([]adapter.Outbound, 0, len(g.outbounds))
-	for _, detour := range g.outbounds {
-		if !common.Contains(detour.Network(), network) {
-			continue
-		}
-		candidates = append(candidates, detour)
-	}
-	if len(candidates) == 0 {
+	tryList := g.tryList(destination, network)
+	if len(tryList) == 0 {
 		return nil, E.New("missing supported outbound")
 	}
-	preferred := make([]adapter.Outbound, 0, len(candidates))
-	others := make([]adapter.Outbound, 0, len(candidates))
-	for _, detour := range candidates {
-		if g.checker.history.LoadURLTestHistory(RealTag(detour)) != nil {
-			preferred = append(preferred, detour)
-		} else {
-			others = append(others, detour)
-		}
+	conn, index, err := g.dialNext(ctx, network, destination, tryList)
+	if err != nil {
+		return nil, err
+	}
+	if g.firstByteTimeout > 0 && N.NetworkName(network) == N.NetworkTCP {
+		return newFallbackConn(ctx, g, destination, tryList[index], tryList[index+1:], conn), nil
+	}
+	g.markSucceeded(destination, tryList[index])
+	return conn, nil
+}
+
+func (g *FallbackGroup) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	tryList := g.tryList(destination, N.NetworkUDP)
+	if len(tryList) == 0 {
+		return nil, E.New("missing supported outbound")
 	}
-	tryList := append(preferred, others...)
 	var lastErr error
 	for _, detour := range tryList {
-		conn, err := detour.DialContext(ctx, network, destination)
+		conn, err := detour.ListenPacket(ctx, destination)
 		if err == nil {
-			g.storeSelected(network, detour)
-			return g.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+			g.storeSelected(N.NetworkUDP, detour)
+			g.markSucceeded(destination, detour)
+			return g.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
 		}
 		lastErr = err
 		g.logger.ErrorContext(ctx, err)
-		g.checker.history.DeleteURLTestHistory(RealTag(detour))
-		go g.checker.CheckOutbounds(true)
+		g.markFailed(N.NetworkUDP, destination, detour)
 	}
 	return nil, lastErr
 }
 
-func (g *FallbackGroup) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	candidates := make([]adapter.Outbound, 0, len(g.outbounds))
+func (g *FallbackGroup) tryList(destination M.Socksaddr, network string) []adapter.Outbound {
+	preferred := make([]adapter.Outbound, 0, len(g.outbounds))
+	var others []adapter.Outbound
+	pinned := g.pinned.Load()
+	if pinned != nil && common.Contains(pinned.Network(), network) {
+		preferred = append(preferred, pinned)
+	}
 	for _, detour := range g.outbounds {
-		if !common.Contains(detour.Network(), N.NetworkUDP) {
+		if detour == pinned || !common.Contains(detour.Network(), network) {
 			continue
 		}
-		candidates = append(candidates, detour)
-	}
-	if len(candidates) == 0 {
-		return nil, E.New("missing supported outbound")
-	}
-	preferred := make([]adapter.Outbound, 0, len(candidates))
-	others := make([]adapter.Outbound, 0, len(candidates))
-	for _, detour := range candidates {
-		if g.checker.history.LoadURLTestHistory(RealTag(detour)) != nil {
+		if g.checker.LoadHistory(network, RealTag(detour)) != nil {
 			preferred = append(preferred, detour)
 		} else {
 			others = append(others, detour)
 		}
 	}
 	tryList := append(preferred, others...)
+	if g.learner != nil && pinned == nil {
+		tryList = g.learner.reorder(destination, tryList)
+	}
+	return tryList
+}
+
+func (g *FallbackGroup) dialNext(ctx context.Context, network string, destination M.Socksaddr, tryList []adapter.Outbound) (net.Conn, int, error) {
 	var lastErr error
-	for _, detour := range tryList {
-		conn, err := detour.ListenPacket(ctx, destination)
+	for index, detour := range tryList {
+		conn, err := detour.DialContext(ctx, network, destination)
 		if err == nil {
-			g.storeSelected(N.NetworkUDP, detour)
-			return g.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+			g.storeSelected(network, detour)
+			return g.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), index, nil
 		}
 		lastErr = err
 		g.logger.ErrorContext(ctx, err)
-		g.checker.history.DeleteURLTestHistory(RealTag(detour))
-		go g.checker.CheckOutbounds(true)
+		g.markFailed(network, destination, detour)
+	}
+	return nil, -1, lastErr
+}
+
+func (g *FallbackGroup) markFailed(network string, destination M.Socksaddr, detour adapter.Outbound) {
+	g.unpin(detour)
+	if g.learner != nil {
+		// Failures are attributed to the destination only, the health check
+		// decides whether the member is down as a whole.
+		g.learner.record(destination, detour, false)
+	} else {
+		g.checker.DeleteHistory(network, RealTag(detour))
+	}
+	go g.checker.CheckOutbounds(true)
+}
+
+func (g *FallbackGroup) markSucceeded(destination M.Socksaddr, detour adapter.Outbound) {
+	if g.learner != nil {
+		g.learner.record(destination, detour, true)
 	}
-	return nil, lastErr
 }
 
 func (g *FallbackGroup) storeSelected(network string, outbound adapter.Outbound) {
+	if g.learner != nil {
+		// Members are chosen per destination, a different choice is not a switch.
+		switch N.NetworkName(network) {
+		case N.NetworkTCP:
+			g.selectedOutboundTCP.Store(outbound)
+		case N.NetworkUDP:
+			g.selectedOutboundUDP.Store(outbound)
+		}
+		return
+	}
 	switch N.NetworkName(network) {
 	case N.NetworkTCP:
 		previous := g.selectedOutboundTCP.Swap(outbound)
 		if previous != nil && previous != outbound {
+			g.selectedChanged(N.NetworkTCP, previous, outbound)
 			g.interruptGroup.Interrupt(g.interruptExternalConnections)
 		}
 	case N.NetworkUDP:
 		previous := g.selectedOutboundUDP.Swap(outbound)
 		if previous != nil && previous != outbound {
+			g.selectedChanged(N.NetworkUDP, previous, outbound)
 			g.interruptGroup.Interrupt(g.interruptExternalConnections)
 		}
 	}
 }
 
+func (g *FallbackGroup) selectedChanged(network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	if g.onSelectedChanged != nil {
+		g.onSelectedChanged(network, previous, outbound)
+	}
+}
+
 func (g *FallbackGroup) performUpdateCheck() {
+	if pinned := g.pinned.Load(); pinned != nil && g.checker.history.LoadURLTestHistory(RealTag(pinned)) == nil {
+		g.unpin(pinned)
+	}
+	g.updateSelected()
+}
+
+func (g *FallbackGroup) updateSelected() {
 	var updated bool
 	if outbound, exists := g.Select(N.NetworkTCP); outbound != nil {
 		previous := g.selectedOutboundTCP.Load()
 		if previous == nil || (exists && outbound != previous) {
 			if previous != nil && outbound != previous {
 				updated = true
+				g.selectedChanged(N.NetworkTCP, previous, outbound)
 			}
 			g.selectedOutboundTCP.Store(outbound)
 		}
@@ -290,6 +451,7 @@ func (g *FallbackGroup) performUpdateCheck() {
 		if previous == nil || (exists && outbound != previous) {
 			if previous != nil && outbound != previous {
 				updated = true
+				g.selectedChanged(N.NetworkUDP, previous, outbound)
 			}
 			g.selectedOutboundUDP.Store(outbound)
 		}
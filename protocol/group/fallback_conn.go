@@ -0,0 +1,299 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// fallbackReplayBufferSize limits how much client data is kept for replay
+// while waiting for the first byte from the upstream.
+const fallbackReplayBufferSize = 64 * 1024
+
+var _ net.Conn = (*fallbackConn)(nil)
+
+// fallbackConn detects members that accept a connection but fail before
+// delivering any data (early close or no first byte within firstByteTimeout)
+// and transparently moves the flow to the next member, replaying the client
+// data written so far.
+type fallbackConn struct {
+	ctx         context.Context
+	group       *FallbackGroup
+	destination M.Socksaddr
+	established atomic.Bool
+	closed      atomic.Bool
+
+	access        sync.Mutex
+	conn          net.Conn
+	detour        adapter.Outbound
+	pending       []adapter.Outbound
+	buffer        []byte
+	overflow      bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+	firstByte     time.Time
+	switching     bool
+}
+
+func newFallbackConn(ctx context.Context, group *FallbackGroup, destination M.Socksaddr, detour adapter.Outbound, pending []adapter.Outbound, conn net.Conn) *fallbackConn {
+	return &fallbackConn{
+		ctx:         ctx,
+		group:       group,
+		destination: destination,
+		conn:        conn,
+		detour:      detour,
+		pending:     pending,
+	}
+}
+
+func (c *fallbackConn) Read(b []byte) (int, error) {
+	if c.established.Load() {
+		return c.conn.Read(b)
+	}
+	for {
+		c.access.Lock()
+		conn := c.conn
+		c.access.Unlock()
+		n, err := conn.Read(b)
+		if n > 0 {
+			c.establish()
+			return n, err
+		}
+		if err == nil {
+			continue
+		}
+		if c.closed.Load() || c.ctx.Err() != nil {
+			return 0, err
+		}
+		c.access.Lock()
+		readDeadline, firstByte := c.readDeadline, c.firstByte
+		c.access.Unlock()
+		if !readDeadline.IsZero() && !time.Now().Before(readDeadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) && (firstByte.IsZero() || time.Now().Before(firstByte)) {
+			// the deadline was moved while reading
+			continue
+		}
+		err = c.failover(conn, err)
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *fallbackConn) Write(b []byte) (int, error) {
+	if c.established.Load() {
+		return c.conn.Write(b)
+	}
+	c.access.Lock()
+	if !c.overflow {
+		if len(c.buffer)+len(b) > fallbackReplayBufferSize {
+			c.overflow = true
+			c.buffer = nil
+			c.firstByte = time.Time{}
+			c.updateReadDeadline()
+		} else {
+			c.buffer = append(c.buffer, b...)
+			if c.firstByte.IsZero() {
+				c.armFirstByte()
+			}
+		}
+	}
+	replayable := !c.overflow
+	conn := c.conn
+	c.access.Unlock()
+	n, err := conn.Write(b)
+	if err != nil && replayable && !c.closed.Load() && c.replayPending(conn) {
+		// The data is buffered and will be replayed on the next member once
+		// the read side notices the failure.
+		return len(b), nil
+	}
+	return n, err
+}
+
+// armFirstByte starts the first byte timer once the client has sent data,
+// as long as a member is left to move to. Idle connections and servers that
+// speak first are never failed by the timer.
+func (c *fallbackConn) armFirstByte() {
+	if len(c.buffer) == 0 || c.overflow || len(c.pending) == 0 {
+		return
+	}
+	c.firstByte = time.Now().Add(c.group.firstByteTimeout)
+	c.updateReadDeadline()
+}
+
+func (c *fallbackConn) updateReadDeadline() {
+	deadline := c.readDeadline
+	if !c.firstByte.IsZero() && (deadline.IsZero() || c.firstByte.Before(deadline)) {
+		deadline = c.firstByte
+	}
+	_ = c.conn.SetReadDeadline(deadline)
+}
+
+// replayPending returns whether data that failed to be written to conn will
+// still be replayed, which is only possible before the first byte committed
+// the flow to a member, and while a member is left to move to.
+func (c *fallbackConn) replayPending(conn net.Conn) bool {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.established.Load() {
+		return false
+	}
+	return c.conn != conn || c.switching || len(c.pending) > 0
+}
+
+func (c *fallbackConn) establish() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.firstByte = time.Time{}
+	_ = c.conn.SetReadDeadline(c.readDeadline)
+	c.buffer = nil
+	c.pending = nil
+	c.established.Store(true)
+	c.group.markSucceeded(c.destination, c.detour)
+}
+
+// failover moves the flow from the failed connection to the next member.
+// Members are dialed without holding the lock, so writes and Close are not
+// blocked; data written meanwhile is buffered and replayed too.
+func (c *fallbackConn) failover(failed net.Conn, cause error) error {
+	c.access.Lock()
+	if c.conn != failed {
+		c.access.Unlock()
+		return nil
+	}
+	c.group.logger.ErrorContext(c.ctx, E.Cause(cause, "outbound/", c.detour.Type(), "[", c.detour.Tag(), "] failed before first byte"))
+	c.group.markFailed(N.NetworkTCP, c.destination, c.detour)
+	_ = failed.Close()
+	c.switching = true
+	defer func() {
+		c.access.Lock()
+		c.switching = false
+		c.access.Unlock()
+	}()
+	for !c.overflow && len(c.pending) > 0 {
+		detour := c.pending[0]
+		c.pending = c.pending[1:]
+		writeDeadline := c.writeDeadline
+		c.access.Unlock()
+		conn, _, err := c.group.dialNext(c.ctx, N.NetworkTCP, c.destination, []adapter.Outbound{detour})
+		if err == nil {
+			_ = conn.SetWriteDeadline(writeDeadline)
+			err = c.replay(conn, detour)
+		}
+		if err != nil {
+			if c.closed.Load() {
+				return net.ErrClosed
+			}
+			c.access.Lock()
+			continue
+		}
+		c.conn = conn
+		c.detour = detour
+		_ = conn.SetWriteDeadline(c.writeDeadline)
+		c.firstByte = time.Time{}
+		c.armFirstByte()
+		c.updateReadDeadline()
+		c.access.Unlock()
+		return nil
+	}
+	c.access.Unlock()
+	return cause
+}
+
+// replay writes the buffered client data to conn until it has caught up
+// with the writes made while dialing. On success it returns with c.access
+// held, so that no write slips in before conn is swapped in.
+func (c *fallbackConn) replay(conn net.Conn, detour adapter.Outbound) error {
+	var written int
+	for {
+		c.access.Lock()
+		if c.overflow || c.closed.Load() {
+			c.access.Unlock()
+			_ = conn.Close()
+			return net.ErrClosed
+		}
+		chunk := c.buffer[written:]
+		if len(chunk) == 0 {
+			return nil
+		}
+		c.access.Unlock()
+		_, err := conn.Write(chunk)
+		if err != nil {
+			_ = conn.Close()
+			c.group.logger.ErrorContext(c.ctx, E.Cause(err, "outbound/", detour.Type(), "[", detour.Tag(), "] replay"))
+			c.group.markFailed(N.NetworkTCP, c.destination, detour)
+			return err
+		}
+		written += len(chunk)
+	}
+}
+
+func (c *fallbackConn) Close() error {
+	c.closed.Store(true)
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.conn.Close()
+}
+
+func (c *fallbackConn) LocalAddr() net.Addr {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.conn.LocalAddr()
+}
+
+func (c *fallbackConn) RemoteAddr() net.Addr {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.conn.RemoteAddr()
+}
+
+func (c *fallbackConn) SetDeadline(t time.Time) error {
+	err := c.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *fallbackConn) SetReadDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.readDeadline = t
+	if c.established.Load() {
+		return c.conn.SetReadDeadline(t)
+	}
+	c.updateReadDeadline()
+	return nil
+}
+
+func (c *fallbackConn) SetWriteDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.writeDeadline = t
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *fallbackConn) ReaderReplaceable() bool {
+	return c.established.Load()
+}
+
+func (c *fallbackConn) WriterReplaceable() bool {
+	return c.established.Load()
+}
+
+func (c *fallbackConn) Upstream() any {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.conn
+}
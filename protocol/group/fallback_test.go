@@ -3,6 +3,8 @@ package group
 import (
 	"context"
 	"errors"
+	"io"
+	"net"
 	"testing"
 	"time"
 
@@ -16,7 +18,7 @@ func TestFallbackDial_FailoverAndRecover(t *testing.T) {
 	backup := newFakeOutbound("backup", "tcp")
 	primary.SetDialError(errors.New("dial failed"))
 
-	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, false)
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, 0, nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,3 +49,238 @@ func TestFallbackDial_FailoverAndRecover(t *testing.T) {
 		t.Fatalf("expected fallback to recover to primary, got %s", group.Now())
 	}
 }
+
+func TestFallbackDial_FirstByteFailover(t *testing.T) {
+	silent := newFakeOutbound("silent", "tcp")
+	closing := newFakeOutbound("closing", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+	silent.SetHandler(func(conn net.Conn) {
+		_, _ = io.Copy(io.Discard, conn)
+	})
+	backup.SetHandler(func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buffer); err != nil {
+			return
+		}
+		_, _ = conn.Write(buffer)
+	})
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{silent, closing, backup}, "", 0, 0, 0, 50*time.Millisecond, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	for _, detour := range []adapter.Outbound{silent, closing, backup} {
+		group.checker.history.StoreURLTestHistory(detour.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	}
+
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, 5)
+	if _, err = io.ReadFull(conn, response); err != nil {
+		t.Fatal(err)
+	}
+	if string(response) != "hello" {
+		t.Fatalf("unexpected response: %q", response)
+	}
+	if silent.DialCalls() != 1 || closing.DialCalls() != 1 || backup.DialCalls() != 1 {
+		t.Fatalf("unexpected dial calls: silent=%d closing=%d backup=%d", silent.DialCalls(), closing.DialCalls(), backup.DialCalls())
+	}
+	if group.checker.history.LoadURLTestHistory(silent.Tag()) != nil || group.checker.history.LoadURLTestHistory(closing.Tag()) != nil {
+		t.Fatalf("failed members should be marked unavailable")
+	}
+	if group.Now() != backup.Tag() {
+		t.Fatalf("unexpected now: %s", group.Now())
+	}
+}
+
+func TestFallbackDial_FirstByteExhausted(t *testing.T) {
+	primary := newFakeOutbound("primary", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, 50*time.Millisecond, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if primary.DialCalls() != 1 || backup.DialCalls() != 1 {
+		t.Fatalf("unexpected dial calls: primary=%d backup=%d", primary.DialCalls(), backup.DialCalls())
+	}
+}
+
+func TestFallbackDial_FirstByteIdle(t *testing.T) {
+	silent := newFakeOutbound("silent", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+	silent.SetHandler(func(conn net.Conn) {
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{silent, backup}, "", 0, 0, 0, 50*time.Millisecond, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	group.checker.history.StoreURLTestHistory(silent.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		readDone <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if backup.DialCalls() != 0 || group.checker.history.LoadURLTestHistory(silent.Tag()) == nil {
+		t.Fatalf("idle connection should not fail over")
+	}
+	_ = conn.Close()
+	<-readDone
+}
+
+func TestFallbackDial_FirstByteLastMember(t *testing.T) {
+	closing := newFakeOutbound("closing", "tcp")
+	silent := newFakeOutbound("silent", "tcp")
+	silent.SetHandler(func(conn net.Conn) {
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{closing, silent}, "", 0, 0, 0, 50*time.Millisecond, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	group.checker.history.StoreURLTestHistory(closing.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	group.checker.history.StoreURLTestHistory(silent.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 20})
+
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	readDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		readDone <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if silent.DialCalls() != 1 || group.checker.history.LoadURLTestHistory(silent.Tag()) == nil {
+		t.Fatalf("the last member should not be failed by the first byte timer")
+	}
+	_ = conn.Close()
+	<-readDone
+}
+
+func TestFallbackDial_WriteWhileDialing(t *testing.T) {
+	closing := newFakeOutbound("closing", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+	backup.SetDialDelay(200 * time.Millisecond)
+	backup.SetHandler(func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, 10)
+		if _, err := io.ReadFull(conn, buffer); err != nil {
+			return
+		}
+		_, _ = conn.Write(buffer)
+	})
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{closing, backup}, "", 0, 0, 0, 50*time.Millisecond, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	response := make(chan string, 1)
+	go func() {
+		buffer := make([]byte, 10)
+		_, _ = io.ReadFull(conn, buffer)
+		response <- string(buffer)
+	}()
+	for backup.DialCalls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	writeAt := time.Now()
+	if _, err = conn.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(writeAt) > 100*time.Millisecond {
+		t.Fatalf("write blocked by dial")
+	}
+	if result := <-response; result != "helloworld" {
+		t.Fatalf("unexpected response: %q", result)
+	}
+}
+
+func TestFallbackPin_StickUntilFailure(t *testing.T) {
+	primary := newFakeOutbound("primary", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	var stored []string
+	group.onPinnedChanged = func(tag string) {
+		stored = append(stored, tag)
+	}
+	group.checker.history.StoreURLTestHistory(primary.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	group.checker.history.StoreURLTestHistory(backup.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 20})
+
+	group.Pin(backup)
+	if group.Now() != backup.Tag() {
+		t.Fatalf("unexpected now: %s", group.Now())
+	}
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if primary.DialCalls() != 0 || backup.DialCalls() != 1 {
+		t.Fatalf("unexpected dial calls: primary=%d backup=%d", primary.DialCalls(), backup.DialCalls())
+	}
+
+	backup.SetDialError(errors.New("dial failed"))
+	conn, err = group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.com", 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if group.pinned.Load() != nil {
+		t.Fatalf("pin should be cleared after failure")
+	}
+	if group.Now() != primary.Tag() {
+		t.Fatalf("unexpected now: %s", group.Now())
+	}
+	if len(stored) != 2 || stored[0] != backup.Tag() || stored[1] != "" {
+		t.Fatalf("unexpected stored pins: %v", stored)
+	}
+}
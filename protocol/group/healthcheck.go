@@ -10,9 +10,11 @@ import (
 	"github.com/sagernet/sing-box/common/urltest"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/batch"
 	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/x/list"
 	"github.com/sagernet/sing/service"
 	"github.com/sagernet/sing/service/pause"
@@ -34,6 +36,11 @@ type healthChecker struct {
 
 	history adapter.URLTestHistoryStorage
 
+	// udpCheck probes members over UDP, its results are kept apart from the
+	// shared TCP history and used for UDP selection only.
+	udpCheck   *udpChecker
+	udpHistory *urltest.HistoryStorage
+
 	checking atomic.Bool
 
 	access     sync.Mutex
@@ -45,7 +52,7 @@ type healthChecker struct {
 	onUpdate func()
 }
 
-func newHealthChecker(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, onUpdate func()) (*healthChecker, error) {
+func newHealthChecker(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, udpCheckOptions *option.UDPCheckOptions, onUpdate func()) (*healthChecker, error) {
 	if interval == 0 {
 		interval = C.DefaultURLTestInterval
 	}
@@ -58,6 +65,14 @@ func newHealthChecker(ctx context.Context, outboundManager adapter.OutboundManag
 	if interval > idleTimeout {
 		return nil, E.New("interval must be less or equal than idle_timeout")
 	}
+	udpCheck, err := newUDPChecker(udpCheckOptions)
+	if err != nil {
+		return nil, err
+	}
+	var udpHistory *urltest.HistoryStorage
+	if udpCheck != nil {
+		udpHistory = urltest.NewHistoryStorage()
+	}
 	var history adapter.URLTestHistoryStorage
 	if historyFromCtx := service.PtrFromContext[urltest.HistoryStorage](ctx); historyFromCtx != nil {
 		history = historyFromCtx
@@ -77,6 +92,8 @@ func newHealthChecker(ctx context.Context, outboundManager adapter.OutboundManag
 		idleTimeout: idleTimeout,
 		timeout:     timeout,
 		history:     history,
+		udpCheck:    udpCheck,
+		udpHistory:  udpHistory,
 		close:       make(chan struct{}),
 		onUpdate:    onUpdate,
 	}, nil
@@ -141,6 +158,16 @@ func (h *healthChecker) loopCheck() {
 	}
 }
 
+// LoadHistory returns the health of realTag for network, using UDP check
+// results for UDP when enabled.
+func (h *healthChecker) LoadHistory(network string, realTag string) *adapter.URLTestHistory {
+	return networkHistory(network, h.history, h.udpHistory).LoadURLTestHistory(realTag)
+}
+
+func (h *healthChecker) DeleteHistory(network string, realTag string) {
+	networkHistory(network, h.history, h.udpHistory).DeleteURLTestHistory(realTag)
+}
+
 func (h *healthChecker) CheckOutbounds(force bool) {
 	_, _ = h.urlTest(h.ctx, force)
 }
@@ -160,16 +187,32 @@ func (h *healthChecker) urlTest(ctx context.Context, force bool) (map[string]uin
 		if realTag == "" || checked[realTag] {
 			continue
 		}
-		history := h.history.LoadURLTestHistory(realTag)
-		if !force && history != nil && time.Since(history.Time) < h.interval {
-			continue
-		}
-		checked[realTag] = true
 		p, loaded := h.outbound.Outbound(realTag)
 		if !loaded {
 			continue
 		}
+		// TCP and UDP results age separately, so that a member failing only
+		// one of them is checked again on the next round.
+		checkTCP := force || historyExpired(h.history, realTag, h.interval)
+		checkUDP := h.udpCheck != nil && common.Contains(p.Network(), N.NetworkUDP) &&
+			(force || historyExpired(h.udpHistory, realTag, h.interval))
+		if !checkTCP && !checkUDP {
+			continue
+		}
+		checked[realTag] = true
 		b.Go(realTag, func() (any, error) {
+			if checkUDP {
+				var udpWait sync.WaitGroup
+				udpWait.Add(1)
+				go func() {
+					defer udpWait.Done()
+					h.udpCheck.checkAndStore(ctx, h.timeout, h.logger, h.udpHistory, tag, realTag, p)
+				}()
+				defer udpWait.Wait()
+			}
+			if !checkTCP {
+				return nil, nil
+			}
 			testCtx, cancel := context.WithTimeout(ctx, h.timeout)
 			defer cancel()
 			t, err := urltest.URLTest(testCtx, h.link, p)
@@ -2,6 +2,7 @@ package group
 
 import (
 	"context"
+	"encoding/json"
 	"net"
 	"strconv"
 	"sync"
@@ -28,6 +29,8 @@ func RegisterLoadBalance(registry *outbound.Registry) {
 
 var (
 	_ adapter.OutboundGroup             = (*LoadBalance)(nil)
+	_ adapter.URLTestGroup              = (*LoadBalance)(nil)
+	_ adapter.ExcludableGroup           = (*LoadBalance)(nil)
 	_ adapter.ConnectionHandlerEx       = (*LoadBalance)(nil)
 	_ adapter.PacketConnectionHandlerEx = (*LoadBalance)(nil)
 )
@@ -45,6 +48,7 @@ type LoadBalance struct {
 	idleTimeout time.Duration
 	timeout     time.Duration
 	strategy    option.LoadBalanceStrategy
+	udpCheck    *option.UDPCheckOptions
 
 	group *LoadBalanceGroup
 }
@@ -66,6 +70,7 @@ func NewLoadBalance(ctx context.Context, router adapter.Router, logger log.Conte
 		idleTimeout: time.Duration(options.IdleTimeout),
 		timeout:     time.Duration(options.Timeout),
 		strategy:    strategy,
+		udpCheck:    options.UDPCheck,
 	}
 	if len(outbound.tags) == 0 {
 		return nil, E.New("missing tags")
@@ -87,10 +92,27 @@ func (s *LoadBalance) Start() error {
 		}
 		outbounds = append(outbounds, detour)
 	}
-	group, err := NewLoadBalanceGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.idleTimeout, s.timeout, s.strategy)
+	group, err := NewLoadBalanceGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.idleTimeout, s.timeout, s.strategy, s.udpCheck)
 	if err != nil {
 		return err
 	}
+	if s.Tag() != "" {
+		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+		if cacheFile != nil {
+			excluded := cacheFile.LoadSelected(s.Tag())
+			if excluded != "" {
+				var tags []string
+				err = json.Unmarshal([]byte(excluded), &tags)
+				if err != nil {
+					s.logger.Warn("load excluded: ", err)
+				} else {
+					_ = group.Exclude(common.Filter(tags, func(it string) bool {
+						return group.outboundMap[it] != nil
+					}))
+				}
+			}
+		}
+	}
 	s.group = group
 	return nil
 }
@@ -114,6 +136,48 @@ func (s *LoadBalance) All() []string {
 	return s.tags
 }
 
+func (s *LoadBalance) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return s.group.checker.urlTest(ctx, true)
+}
+
+// Exclude temporarily removes the given members from selection, replacing
+// any previous exclusion. An empty list restores all members.
+func (s *LoadBalance) Exclude(tags []string) error {
+	for _, tag := range tags {
+		if s.group.outboundMap[tag] == nil {
+			return E.New("outbound not found: ", tag)
+		}
+	}
+	err := s.group.Exclude(tags)
+	if err != nil {
+		return err
+	}
+	if s.Tag() == "" {
+		return nil
+	}
+	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+	if cacheFile == nil {
+		return nil
+	}
+	var content string
+	if len(tags) > 0 {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		content = string(data)
+	}
+	err = cacheFile.StoreSelected(s.Tag(), content)
+	if err != nil {
+		s.logger.Error("store excluded: ", err)
+	}
+	return nil
+}
+
+func (s *LoadBalance) Excluded() []string {
+	return s.group.Excluded()
+}
+
 func (s *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	s.group.Touch()
 	return s.group.DialContext(ctx, network, destination)
@@ -151,10 +215,12 @@ type LoadBalanceGroup struct {
 	stickyAccess sync.Mutex
 	stickyCache  map[string]stickyEntry
 
+	excluded common.TypedValue[map[adapter.Outbound]bool]
+
 	lastSelected common.TypedValue[adapter.Outbound]
 }
 
-func NewLoadBalanceGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.ContextLogger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, strategy option.LoadBalanceStrategy) (*LoadBalanceGroup, error) {
+func NewLoadBalanceGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.ContextLogger, outbounds []adapter.Outbound, link string, interval time.Duration, idleTimeout time.Duration, timeout time.Duration, strategy option.LoadBalanceStrategy, udpCheck *option.UDPCheckOptions) (*LoadBalanceGroup, error) {
 	group := &LoadBalanceGroup{
 		logger:      logger,
 		outbounds:   outbounds,
@@ -165,7 +231,7 @@ func NewLoadBalanceGroup(ctx context.Context, outboundManager adapter.OutboundMa
 	for _, detour := range outbounds {
 		group.outboundMap[detour.Tag()] = detour
 	}
-	checker, err := newHealthChecker(ctx, outboundManager, logger, outbounds, link, interval, idleTimeout, timeout, nil)
+	checker, err := newHealthChecker(ctx, outboundManager, logger, outbounds, link, interval, idleTimeout, timeout, udpCheck, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -195,6 +261,32 @@ func (g *LoadBalanceGroup) Now() string {
 	return ""
 }
 
+func (g *LoadBalanceGroup) Exclude(tags []string) error {
+	excluded := make(map[adapter.Outbound]bool)
+	for _, tag := range tags {
+		detour := g.outboundMap[tag]
+		if detour != nil {
+			excluded[detour] = true
+		}
+	}
+	if len(excluded) > 0 && len(excluded) == len(g.outboundMap) {
+		return E.New("can not exclude all outbounds")
+	}
+	g.excluded.Store(excluded)
+	return nil
+}
+
+func (g *LoadBalanceGroup) Excluded() []string {
+	excluded := g.excluded.Load()
+	tags := make([]string, 0, len(excluded))
+	for _, detour := range g.outbounds {
+		if excluded[detour] {
+			tags = append(tags, detour.Tag())
+		}
+	}
+	return tags
+}
+
 func (g *LoadBalanceGroup) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	excluded := make(map[adapter.Outbound]bool)
 	var lastErr error
@@ -222,7 +314,7 @@ func (g *LoadBalanceGroup) DialContext(ctx context.Context, network string, dest
 		}
 		lastErr = err
 		g.logger.ErrorContext(ctx, err)
-		g.checker.history.DeleteURLTestHistory(RealTag(detour))
+		g.checker.DeleteHistory(network, RealTag(detour))
 		if g.strategy == option.LoadBalanceStrategyStickySessions {
 			g.deleteSticky(ctx, network, destination)
 		}
@@ -261,7 +353,7 @@ func (g *LoadBalanceGroup) ListenPacket(ctx context.Context, destination M.Socks
 		}
 		lastErr = err
 		g.logger.ErrorContext(ctx, err)
-		g.checker.history.DeleteURLTestHistory(RealTag(detour))
+		g.checker.DeleteHistory(N.NetworkUDP, RealTag(detour))
 		if g.strategy == option.LoadBalanceStrategyStickySessions {
 			g.deleteSticky(ctx, N.NetworkUDP, destination)
 		}
@@ -276,12 +368,13 @@ func (g *LoadBalanceGroup) ListenPacket(ctx context.Context, destination M.Socks
 func (g *LoadBalanceGroup) candidates(network string) []adapter.Outbound {
 	networkCandidates := make([]adapter.Outbound, 0, len(g.outbounds))
 	available := make([]adapter.Outbound, 0, len(g.outbounds))
+	excluded := g.excluded.Load()
 	for _, detour := range g.outbounds {
-		if !common.Contains(detour.Network(), network) {
+		if excluded[detour] || !common.Contains(detour.Network(), network) {
 			continue
 		}
 		networkCandidates = append(networkCandidates, detour)
-		if g.checker.history.LoadURLTestHistory(RealTag(detour)) != nil {
+		if g.checker.LoadHistory(network, RealTag(detour)) != nil {
 			available = append(available, detour)
 		}
 	}
@@ -377,15 +470,18 @@ func stickyKey(ctx context.Context, network string, destination M.Socksaddr) str
 }
 
 func destinationKey(destination M.Socksaddr) string {
-	host := destination.AddrString()
+	return destinationDomain(destination) + ":" + strconv.Itoa(int(destination.Port))
+}
+
+// destinationDomain normalizes domains to eTLD+1 and keeps addresses as is.
+func destinationDomain(destination M.Socksaddr) string {
 	if destination.IsFqdn() {
 		if base, err := publicsuffix.EffectiveTLDPlusOne(destination.Fqdn); err == nil {
-			host = base
-		} else {
-			host = destination.Fqdn
+			return base
 		}
+		return destination.Fqdn
 	}
-	return host + ":" + strconv.Itoa(int(destination.Port))
+	return destination.AddrString()
 }
 
 func rendezvousPick(key string, candidates []adapter.Outbound) adapter.Outbound {
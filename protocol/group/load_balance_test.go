@@ -14,7 +14,7 @@ func TestLoadBalance_RoundRobin(t *testing.T) {
 	a := newFakeOutbound("a", "tcp")
 	b := newFakeOutbound("b", "tcp")
 
-	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyRoundRobin)
+	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyRoundRobin, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,7 +41,7 @@ func TestLoadBalance_ConsistentHashing_TopLevelDomain(t *testing.T) {
 	a := newFakeOutbound("a", "tcp")
 	b := newFakeOutbound("b", "tcp")
 
-	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyConsistentHashing)
+	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyConsistentHashing, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,7 +69,7 @@ func TestLoadBalance_StickySessions(t *testing.T) {
 	a := newFakeOutbound("a", "tcp")
 	b := newFakeOutbound("b", "tcp")
 
-	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyStickySessions)
+	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyStickySessions, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,3 +94,39 @@ func TestLoadBalance_StickySessions(t *testing.T) {
 		t.Fatalf("expected sticky mapping, got a=%d b=%d", a.DialCalls(), b.DialCalls())
 	}
 }
+
+func TestLoadBalance_Exclude(t *testing.T) {
+	a := newFakeOutbound("a", "tcp")
+	b := newFakeOutbound("b", "tcp")
+
+	group, err := NewLoadBalanceGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{a, b}, "", 0, 0, 0, option.LoadBalanceStrategyRoundRobin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+
+	if err = group.Exclude([]string{"a", "b"}); err == nil {
+		t.Fatal("excluding all outbounds should fail")
+	}
+	if err = group.Exclude([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	dest := M.ParseSocksaddrHostPort("example.com", 80)
+	for i := 0; i < 4; i++ {
+		conn, err := group.DialContext(context.Background(), "tcp", dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+	if a.DialCalls() != 0 || b.DialCalls() != 4 {
+		t.Fatalf("excluded outbound should not be selected, got a=%d b=%d", a.DialCalls(), b.DialCalls())
+	}
+
+	if err = group.Exclude(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Excluded()) != 0 {
+		t.Fatalf("unexpected excluded: %v", group.Excluded())
+	}
+}
@@ -0,0 +1,299 @@
+package group
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+func RegisterRelay(registry *outbound.Registry) {
+	outbound.Register[option.RelayOutboundOptions](registry, C.TypeRelay, NewRelay)
+}
+
+var (
+	_ adapter.OutboundRelay             = (*Relay)(nil)
+	_ adapter.ConnectionHandlerEx       = (*Relay)(nil)
+	_ adapter.PacketConnectionHandlerEx = (*Relay)(nil)
+)
+
+// Relay dials the destination through every outbound in order, each hop
+// connecting to the next one through the previous hop.
+//
+// Every hop after the first is a separate instance of the configured outbound
+// owned by the relay, created with its detour pointing at the previous hop,
+// so connections pooled or multiplexed by a hop never leave the chain and the
+// shared outbound keeps dialing directly.
+type Relay struct {
+	outbound.Adapter
+	ctx        context.Context
+	router     adapter.Router
+	registry   adapter.OutboundRegistry
+	outbound   adapter.OutboundManager
+	connection adapter.ConnectionManager
+	logger     log.ContextLogger
+	tags       []string
+	outbounds  []adapter.Outbound
+	hops       []*relayHop
+}
+
+func NewRelay(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.RelayOutboundOptions) (adapter.Outbound, error) {
+	outbound := &Relay{
+		Adapter:    outbound.NewAdapter(C.TypeRelay, tag, nil, options.Outbounds),
+		ctx:        ctx,
+		router:     router,
+		registry:   service.FromContext[adapter.OutboundRegistry](ctx),
+		outbound:   service.FromContext[adapter.OutboundManager](ctx),
+		connection: service.FromContext[adapter.ConnectionManager](ctx),
+		logger:     logger,
+		tags:       options.Outbounds,
+	}
+	if len(outbound.tags) < 2 {
+		return nil, E.New("relay requires at least two outbounds")
+	}
+	return outbound, nil
+}
+
+func (s *Relay) Start() error {
+	outbounds := make([]adapter.Outbound, 0, len(s.tags))
+	for i, tag := range s.tags {
+		detour, loaded := s.outbound.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	// Nested relays are expanded, so that each of their hops gets an
+	// instance chained to this relay.
+	tags := s.expandHops(s.tags)
+	hops := make([]*relayHop, 0, len(tags))
+	for i, tag := range tags {
+		hop := &relayHop{
+			relay:     s,
+			index:     i,
+			tag:       tag,
+			instances: make(map[string]adapter.Outbound),
+		}
+		if i > 0 {
+			hop.upstream = hops[i-1]
+		}
+		hops = append(hops, hop)
+	}
+	s.outbounds = outbounds
+	s.hops = hops
+	for _, hop := range hops[1:] {
+		detour, _ := s.outbound.Outbound(hop.tag)
+		if _, isGroup := detour.(adapter.OutboundGroup); isGroup {
+			// Members of groups are instantiated when the group selects them.
+			continue
+		}
+		_, err := hop.instance(detour)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Relay) expandHops(tags []string) []string {
+	var expanded []string
+	for _, tag := range tags {
+		_, options, _ := s.outbound.Options(tag)
+		if relayOptions, isRelay := options.(*option.RelayOutboundOptions); isRelay {
+			expanded = append(expanded, s.expandHops(relayOptions.Outbounds)...)
+		} else {
+			expanded = append(expanded, tag)
+		}
+	}
+	return expanded
+}
+
+func (s *Relay) Close() error {
+	var instances []adapter.Outbound
+	for _, hop := range s.hops {
+		hop.access.Lock()
+		for _, instance := range hop.instances {
+			instances = append(instances, instance)
+		}
+		hop.instances = nil
+		hop.access.Unlock()
+	}
+	return common.Close(common.Map(instances, func(it adapter.Outbound) any {
+		return it
+	})...)
+}
+
+func (s *Relay) Network() []string {
+	networks := []string{N.NetworkTCP, N.NetworkUDP}
+	for _, detour := range s.outbounds {
+		networks = common.Filter(networks, func(it string) bool {
+			return common.Contains(detour.Network(), it)
+		})
+	}
+	return networks
+}
+
+func (s *Relay) Hops() []string {
+	return s.tags
+}
+
+func (s *Relay) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if !common.Contains(s.Network(), N.NetworkName(network)) {
+		return nil, E.New(network, " is not supported by every hop of relay")
+	}
+	return s.hops[len(s.hops)-1].DialContext(ctx, network, destination)
+}
+
+func (s *Relay) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	if !common.Contains(s.Network(), N.NetworkUDP) {
+		return nil, E.New("UDP is not supported by every hop of relay")
+	}
+	return s.hops[len(s.hops)-1].ListenPacket(ctx, destination)
+}
+
+func (s *Relay) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewConnection(ctx, s, conn, metadata, onClose)
+}
+
+func (s *Relay) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewPacketConnection(ctx, s, conn, metadata, onClose)
+}
+
+// relayHop is the outbound at one position of a relay. The first hop is the
+// shared outbound itself. Later hops dial through the relay's instance of the
+// outbound, or of the member currently selected when the hop is a group.
+type relayHop struct {
+	relay     *Relay
+	index     int
+	tag       string
+	upstream  *relayHop
+	access    sync.Mutex
+	instances map[string]adapter.Outbound
+}
+
+func (h *relayHop) Type() string {
+	return C.TypeRelay
+}
+
+// Tag is the detour tag the next hop's instance is created with.
+func (h *relayHop) Tag() string {
+	return F.ToString(h.relay.Tag(), "/", h.index, "/", h.tag)
+}
+
+func (h *relayHop) Network() []string {
+	return []string{N.NetworkTCP, N.NetworkUDP}
+}
+
+func (h *relayHop) Dependencies() []string {
+	return nil
+}
+
+func (h *relayHop) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	detour, err := h.outbound()
+	if err != nil {
+		return nil, err
+	}
+	return detour.DialContext(ctx, network, destination)
+}
+
+func (h *relayHop) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	detour, err := h.outbound()
+	if err != nil {
+		return nil, err
+	}
+	return detour.ListenPacket(ctx, destination)
+}
+
+func (h *relayHop) outbound() (adapter.Outbound, error) {
+	detour, loaded := h.relay.outbound.Outbound(h.tag)
+	if !loaded {
+		return nil, E.New("outbound not found: ", h.tag)
+	}
+	if h.upstream == nil {
+		return detour, nil
+	}
+	for {
+		group, isGroup := detour.(adapter.OutboundGroup)
+		if !isGroup {
+			break
+		}
+		detour, loaded = h.relay.outbound.Outbound(group.Now())
+		if !loaded {
+			return nil, E.New("outbound not found: ", group.Now())
+		}
+	}
+	return h.instance(detour)
+}
+
+// instance returns the relay's instance of detour, creating it with the
+// previous hop as its detour.
+func (h *relayHop) instance(detour adapter.Outbound) (adapter.Outbound, error) {
+	h.access.Lock()
+	defer h.access.Unlock()
+	if h.instances == nil {
+		return nil, E.New("relay closed")
+	}
+	tag := detour.Tag()
+	if instance, loaded := h.instances[tag]; loaded {
+		return instance, nil
+	}
+	outboundType, options, loaded := h.relay.outbound.Options(tag)
+	if !loaded {
+		return nil, E.New("outbound ", tag, " can not be a relay hop other than the first")
+	}
+	optionsValue := reflect.ValueOf(options)
+	if optionsValue.Kind() != reflect.Pointer {
+		return nil, E.New("outbound ", tag, " can not be a relay hop other than the first")
+	}
+	// The configured options are shared with the outbound, so the detour is
+	// set on a copy.
+	copied := reflect.New(optionsValue.Elem().Type())
+	copied.Elem().Set(optionsValue.Elem())
+	wrapper, isWrapper := copied.Interface().(option.DialerOptionsWrapper)
+	if !isWrapper {
+		return nil, E.New("outbound ", tag, " can not be a relay hop other than the first")
+	}
+	dialerOptions := wrapper.TakeDialerOptions()
+	dialerOptions.Detour = h.upstream.Tag()
+	wrapper.ReplaceDialerOptions(dialerOptions)
+	ctx := service.ContextWith[adapter.OutboundManager](h.relay.ctx, &relayOutboundManager{h.relay.outbound, h.upstream})
+	instance, err := h.relay.registry.CreateOutbound(ctx, h.relay.router, h.relay.logger, tag, outboundType, copied.Interface())
+	if err != nil {
+		return nil, E.Cause(err, "create relay hop ", tag)
+	}
+	for _, stage := range adapter.ListStartStages {
+		err = adapter.LegacyStart(instance, stage)
+		if err != nil {
+			common.Close(instance)
+			return nil, E.Cause(err, stage, " relay hop ", tag)
+		}
+	}
+	h.instances[tag] = instance
+	return instance, nil
+}
+
+// relayOutboundManager resolves the detour of a hop instance to the previous
+// hop of the relay.
+type relayOutboundManager struct {
+	adapter.OutboundManager
+	upstream *relayHop
+}
+
+func (m *relayOutboundManager) Outbound(tag string) (adapter.Outbound, bool) {
+	if tag == m.upstream.Tag() {
+		return m.upstream, true
+	}
+	return m.OutboundManager.Outbound(tag)
+}
@@ -0,0 +1,205 @@
+package group
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
+)
+
+// fakeHop behaves like a proxy outbound: it connects to its server through
+// its detour if one is configured.
+type fakeHop struct {
+	*fakeOutbound
+	server  M.Socksaddr
+	manager adapter.OutboundManager
+	detour  string
+	access  sync.Mutex
+	dialed  []M.Socksaddr
+}
+
+func (h *fakeHop) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	h.access.Lock()
+	h.dialed = append(h.dialed, destination)
+	h.access.Unlock()
+	if h.detour != "" {
+		upstream, loaded := h.manager.Outbound(h.detour)
+		if !loaded {
+			return nil, net.ErrClosed
+		}
+		conn, err := upstream.DialContext(ctx, network, h.server)
+		if err != nil {
+			return nil, err
+		}
+		_ = conn.Close()
+	}
+	return h.fakeOutbound.DialContext(ctx, network, destination)
+}
+
+func (h *fakeHop) Dialed() []M.Socksaddr {
+	h.access.Lock()
+	defer h.access.Unlock()
+	return append([]M.Socksaddr(nil), h.dialed...)
+}
+
+type fakeRelayManager struct {
+	adapter.OutboundManager
+	outbounds map[string]adapter.Outbound
+	options   map[string]any
+}
+
+func (m *fakeRelayManager) Outbound(tag string) (adapter.Outbound, bool) {
+	outbound, loaded := m.outbounds[tag]
+	return outbound, loaded
+}
+
+func (m *fakeRelayManager) Options(tag string) (string, any, bool) {
+	options, loaded := m.options[tag]
+	return "fake", options, loaded
+}
+
+// fakeRelayRegistry creates hops from socks options, recording every
+// instance created for relays.
+type fakeRelayRegistry struct {
+	adapter.OutboundRegistry
+	created map[string]*fakeHop
+}
+
+func (r *fakeRelayRegistry) CreateOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, outboundType string, options any) (adapter.Outbound, error) {
+	socksOptions := options.(*option.SOCKSOutboundOptions)
+	hop := &fakeHop{
+		fakeOutbound: newFakeOutbound(tag, "tcp", "udp"),
+		server:       socksOptions.ServerOptions.Build(),
+		manager:      service.FromContext[adapter.OutboundManager](ctx),
+		detour:       socksOptions.Detour,
+	}
+	r.created[tag] = hop
+	return hop, nil
+}
+
+func newTestRelay(t *testing.T, tags []string, servers map[string]M.Socksaddr, groups ...adapter.Outbound) (*Relay, map[string]*fakeHop, *fakeRelayRegistry) {
+	manager := &fakeRelayManager{
+		outbounds: make(map[string]adapter.Outbound),
+		options:   make(map[string]any),
+	}
+	shared := make(map[string]*fakeHop)
+	for tag, server := range servers {
+		shared[tag] = &fakeHop{fakeOutbound: newFakeOutbound(tag, "tcp", "udp"), server: server}
+		manager.outbounds[tag] = shared[tag]
+		manager.options[tag] = &option.SOCKSOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     server.AddrString(),
+				ServerPort: server.Port,
+			},
+		}
+	}
+	for _, group := range groups {
+		manager.outbounds[group.Tag()] = group
+	}
+	registry := &fakeRelayRegistry{created: make(map[string]*fakeHop)}
+	relay := &Relay{
+		ctx:      context.Background(),
+		registry: registry,
+		outbound: manager,
+		tags:     tags,
+	}
+	if err := relay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = relay.Close()
+	})
+	return relay, shared, registry
+}
+
+func TestRelayDial_Chain(t *testing.T) {
+	servers := map[string]M.Socksaddr{
+		"entry":  M.ParseSocksaddrHostPort("entry.example.com", 1),
+		"middle": M.ParseSocksaddrHostPort("middle.example.com", 2),
+		"exit":   M.ParseSocksaddrHostPort("exit.example.com", 3),
+	}
+	relay, shared, created := newTestRelay(t, []string{"entry", "middle", "exit"}, servers)
+	if len(created.created) != 2 {
+		t.Fatalf("expected instances of middle and exit, got %d", len(created.created))
+	}
+	shared["entry"].networks = []string{"tcp"}
+	if networks := relay.Network(); len(networks) != 1 || networks[0] != "tcp" {
+		t.Fatalf("unexpected networks: %v", networks)
+	}
+	if _, err := relay.ListenPacket(context.Background(), M.ParseSocksaddrHostPort("example.com", 53)); err == nil {
+		t.Fatal("UDP should be rejected when a hop does not support it")
+	}
+
+	destination := M.ParseSocksaddrHostPort("example.com", 80)
+	conn, err := relay.DialContext(context.Background(), "tcp", destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	expected := map[*fakeHop]M.Socksaddr{
+		created.created["exit"]:   destination,
+		created.created["middle"]: servers["exit"],
+		shared["entry"]:           servers["middle"],
+	}
+	for hop, address := range expected {
+		if dialed := hop.Dialed(); len(dialed) != 1 || dialed[0] != address {
+			t.Fatalf("hop %s dialed %v, expected %s", hop.Tag(), dialed, address)
+		}
+	}
+	// The shared outbounds of later hops are left alone, so connections they
+	// pool are never reused through the relay or the other way around.
+	for _, tag := range []string{"middle", "exit"} {
+		if dialed := shared[tag].Dialed(); len(dialed) != 0 {
+			t.Fatalf("shared %s dialed %v", tag, dialed)
+		}
+	}
+}
+
+func TestRelayDial_GroupHop(t *testing.T) {
+	servers := map[string]M.Socksaddr{
+		"entry":  M.ParseSocksaddrHostPort("entry.example.com", 1),
+		"exit-a": M.ParseSocksaddrHostPort("exit-a.example.com", 2),
+		"exit-b": M.ParseSocksaddrHostPort("exit-b.example.com", 3),
+	}
+	group := &fakeGroup{fakeOutbound: newFakeOutbound("exit", "tcp", "udp"), now: "exit-b"}
+	relay, shared, created := newTestRelay(t, []string{"entry", "exit"}, servers, group)
+	if len(created.created) != 0 {
+		t.Fatalf("group members should be created on demand, got %d", len(created.created))
+	}
+
+	destination := M.ParseSocksaddrHostPort("example.com", 80)
+	conn, err := relay.DialContext(context.Background(), "tcp", destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if dialed := created.created["exit-b"].Dialed(); len(dialed) != 1 || dialed[0] != destination {
+		t.Fatalf("selected member dialed %v", dialed)
+	}
+	if dialed := shared["entry"].Dialed(); len(dialed) != 1 || dialed[0] != servers["exit-b"] {
+		t.Fatalf("entry dialed %v", dialed)
+	}
+	if _, loaded := created.created["exit-a"]; loaded {
+		t.Fatal("unselected member should not be created")
+	}
+}
+
+type fakeGroup struct {
+	*fakeOutbound
+	now string
+}
+
+func (g *fakeGroup) Now() string {
+	return g.now
+}
+
+func (g *fakeGroup) All() []string {
+	return []string{g.now}
+}
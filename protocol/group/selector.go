@@ -122,7 +122,8 @@ func (s *Selector) SelectOutbound(tag string) bool {
 	if !loaded {
 		return false
 	}
-	if s.selected.Swap(detour) == detour {
+	previous := s.selected.Swap(detour)
+	if previous == detour {
 		return true
 	}
 	if s.Tag() != "" {
@@ -134,6 +135,9 @@ func (s *Selector) SelectOutbound(tag string) bool {
 			}
 		}
 	}
+	if previous != nil {
+		emitSelected(s.ctx, s.Tag(), "", previous, detour)
+	}
 	s.interruptGroup.Interrupt(s.interruptExternalConnections)
 	return true
 }
@@ -174,6 +178,18 @@ func (s *Selector) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	}
 }
 
+// emitSelected reports that the group switched from previous to outbound,
+// network is empty if the group does not select per network.
+func emitSelected(ctx context.Context, group string, network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	adapter.EmitEvent(ctx, adapter.Event{
+		Type:     adapter.EventGroupSelected,
+		Tag:      group,
+		Network:  network,
+		Outbound: outbound.Tag(),
+		Previous: previous.Tag(),
+	})
+}
+
 func RealTag(detour adapter.Outbound) string {
 	if group, isGroup := detour.(adapter.OutboundGroup); isGroup {
 		selectedTag := group.Now()
@@ -0,0 +1,269 @@
+package group
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
+)
+
+const defaultSmartExpiry = 7 * 24 * time.Hour
+
+func RegisterSmart(registry *outbound.Registry) {
+	outbound.Register[option.SmartOutboundOptions](registry, C.TypeSmart, NewSmart)
+}
+
+var (
+	_ adapter.OutboundGroup             = (*Smart)(nil)
+	_ adapter.URLTestGroup              = (*Smart)(nil)
+	_ adapter.PinnableGroup             = (*Smart)(nil)
+	_ adapter.ConnectionHandlerEx       = (*Smart)(nil)
+	_ adapter.PacketConnectionHandlerEx = (*Smart)(nil)
+)
+
+// Smart is a fallback group that remembers, per eTLD+1, which member last
+// succeeded or failed and prefers the working one next time.
+type Smart struct {
+	*Fallback
+	expiry  time.Duration
+	learner *smartLearner
+}
+
+func NewSmart(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SmartOutboundOptions) (adapter.Outbound, error) {
+	fallback, err := newFallback(ctx, logger, C.TypeSmart, tag, options.FallbackOutboundOptions)
+	if err != nil {
+		return nil, err
+	}
+	expiry := time.Duration(options.Expiry)
+	if expiry == 0 {
+		expiry = defaultSmartExpiry
+	}
+	return &Smart{
+		Fallback: fallback,
+		expiry:   expiry,
+	}, nil
+}
+
+func (s *Smart) Start() error {
+	err := s.Fallback.Start()
+	if err != nil {
+		return err
+	}
+	var cacheFile adapter.CacheFile
+	if s.Tag() != "" {
+		cacheFile = service.FromContext[adapter.CacheFile](s.ctx)
+	}
+	s.learner = newSmartLearner(s.Tag(), s.logger, cacheFile, s.expiry)
+	s.group.learner = s.learner
+	return nil
+}
+
+func (s *Smart) Learned() map[string]adapter.LearnedOutbound {
+	return s.learner.Entries()
+}
+
+// ClearLearned forgets the entry of key, or every entry if key is empty.
+func (s *Smart) ClearLearned(key string) {
+	s.learner.Clear(key)
+}
+
+type smartLearner struct {
+	tag       string
+	logger    log.ContextLogger
+	cacheFile adapter.CacheFile
+	expiry    time.Duration
+
+	access sync.Mutex
+	table  map[string]*adapter.LearnedOutbound
+
+	// Cache file writes are queued under access and applied in order by a
+	// single writer, so a late save can not bring back a cleared entry.
+	writeAccess sync.Mutex
+	writes      []smartWrite
+	writing     bool
+}
+
+// smartWrite saves learned for key, or deletes key when learned is nil.
+type smartWrite struct {
+	key     string
+	learned *adapter.LearnedOutbound
+}
+
+func newSmartLearner(tag string, logger log.ContextLogger, cacheFile adapter.CacheFile, expiry time.Duration) *smartLearner {
+	learner := &smartLearner{
+		tag:       tag,
+		logger:    logger,
+		cacheFile: cacheFile,
+		expiry:    expiry,
+		table:     make(map[string]*adapter.LearnedOutbound),
+	}
+	if cacheFile != nil {
+		for key, learned := range cacheFile.LoadLearnedOutbounds(tag) {
+			if time.Since(learned.LastUpdated) > expiry {
+				continue
+			}
+			learner.table[key] = learned
+		}
+	}
+	return learner
+}
+
+func (l *smartLearner) load(key string) *adapter.LearnedOutbound {
+	learned := l.table[key]
+	if learned != nil && time.Since(learned.LastUpdated) > l.expiry {
+		delete(l.table, key)
+		l.delete(key)
+		return nil
+	}
+	return learned
+}
+
+func (l *smartLearner) reorder(destination M.Socksaddr, tryList []adapter.Outbound) []adapter.Outbound {
+	l.access.Lock()
+	learned := l.load(destinationDomain(destination))
+	var succeeded, failed string
+	if learned != nil {
+		succeeded, failed = learned.Succeeded, learned.Failed
+	}
+	l.access.Unlock()
+	if succeeded == "" && failed == "" {
+		return tryList
+	}
+	ordered := make([]adapter.Outbound, 0, len(tryList))
+	var preferred, deferred adapter.Outbound
+	for _, detour := range tryList {
+		switch detour.Tag() {
+		case succeeded:
+			preferred = detour
+		case failed:
+			deferred = detour
+		default:
+			ordered = append(ordered, detour)
+		}
+	}
+	if preferred != nil {
+		ordered = append([]adapter.Outbound{preferred}, ordered...)
+	}
+	if deferred != nil {
+		ordered = append(ordered, deferred)
+	}
+	return ordered
+}
+
+func (l *smartLearner) record(destination M.Socksaddr, detour adapter.Outbound, success bool) {
+	key := destinationDomain(destination)
+	tag := detour.Tag()
+	l.access.Lock()
+	learned := l.load(key)
+	previous := adapter.LearnedOutbound{}
+	if learned == nil {
+		learned = &adapter.LearnedOutbound{}
+		l.table[key] = learned
+	} else {
+		previous = *learned
+	}
+	if success {
+		learned.Succeeded = tag
+		if learned.Failed == tag {
+			learned.Failed = ""
+		}
+	} else {
+		learned.Failed = tag
+		if learned.Succeeded == tag {
+			learned.Succeeded = ""
+		}
+	}
+	now := time.Now()
+	// Avoid rewriting the cache file for every connection, only refresh
+	// unchanged entries once a quarter of the expiry has passed.
+	changed := learned.Succeeded != previous.Succeeded || learned.Failed != previous.Failed
+	if !changed && now.Sub(learned.LastUpdated) < l.expiry/4 {
+		l.access.Unlock()
+		return
+	}
+	learned.LastUpdated = now
+	saved := *learned
+	l.enqueue(key, &saved)
+	l.access.Unlock()
+	if changed {
+		if success {
+			l.logger.Debug("learned ", key, " succeeded via ", tag)
+		} else {
+			l.logger.Debug("learned ", key, " failed via ", tag)
+		}
+	}
+}
+
+func (l *smartLearner) delete(key string) {
+	l.enqueue(key, nil)
+}
+
+func (l *smartLearner) enqueue(key string, learned *adapter.LearnedOutbound) {
+	if l.cacheFile == nil {
+		return
+	}
+	l.writeAccess.Lock()
+	l.writes = append(l.writes, smartWrite{key, learned})
+	if !l.writing {
+		l.writing = true
+		go l.flush()
+	}
+	l.writeAccess.Unlock()
+}
+
+func (l *smartLearner) flush() {
+	for {
+		l.writeAccess.Lock()
+		writes := l.writes
+		l.writes = nil
+		if len(writes) == 0 {
+			l.writing = false
+			l.writeAccess.Unlock()
+			return
+		}
+		l.writeAccess.Unlock()
+		for _, write := range writes {
+			if write.learned != nil {
+				err := l.cacheFile.SaveLearnedOutbound(l.tag, write.key, write.learned)
+				if err != nil {
+					l.logger.Warn("save learned outbound: ", err)
+				}
+			} else {
+				err := l.cacheFile.DeleteLearnedOutbound(l.tag, write.key)
+				if err != nil {
+					l.logger.Warn("delete learned outbound: ", err)
+				}
+			}
+		}
+	}
+}
+
+func (l *smartLearner) Entries() map[string]adapter.LearnedOutbound {
+	l.access.Lock()
+	defer l.access.Unlock()
+	entries := make(map[string]adapter.LearnedOutbound, len(l.table))
+	for key := range l.table {
+		if learned := l.load(key); learned != nil {
+			entries[key] = *learned
+		}
+	}
+	return entries
+}
+
+func (l *smartLearner) Clear(key string) {
+	l.access.Lock()
+	if key == "" {
+		l.table = make(map[string]*adapter.LearnedOutbound)
+	} else {
+		delete(l.table, key)
+	}
+	l.delete(key)
+	l.access.Unlock()
+}
@@ -0,0 +1,121 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func TestSmart_LearnPerDomain(t *testing.T) {
+	primary := newFakeOutbound("primary", "tcp")
+	backup := newFakeOutbound("backup", "tcp")
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	learner := newSmartLearner("", log.StdLogger(), nil, time.Hour)
+	group.learner = learner
+	group.checker.history.StoreURLTestHistory(primary.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	group.checker.history.StoreURLTestHistory(backup.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 20})
+
+	primary.SetDialError(errors.New("blocked"))
+	conn, err := group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("www.example.com", 443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	learned := learner.Entries()["example.com"]
+	if learned.Succeeded != backup.Tag() || learned.Failed != primary.Tag() {
+		t.Fatalf("unexpected learned entry: %+v", learned)
+	}
+	if group.checker.history.LoadURLTestHistory(primary.Tag()) == nil {
+		t.Fatalf("smart failures should not mark the member unavailable")
+	}
+
+	primary.SetDialError(nil)
+	conn, err = group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("api.example.com", 443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if primary.DialCalls() != 1 || backup.DialCalls() != 2 {
+		t.Fatalf("learned member should be preferred: primary=%d backup=%d", primary.DialCalls(), backup.DialCalls())
+	}
+
+	conn, err = group.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("example.org", 443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if primary.DialCalls() != 2 || backup.DialCalls() != 2 {
+		t.Fatalf("other domains should keep the default order: primary=%d backup=%d", primary.DialCalls(), backup.DialCalls())
+	}
+
+	learner.Clear("example.com")
+	if _, loaded := learner.Entries()["example.com"]; loaded {
+		t.Fatalf("entry should be cleared")
+	}
+}
+
+type slowLearnedCache struct {
+	adapter.CacheFile
+	access  sync.Mutex
+	learned map[string]adapter.LearnedOutbound
+}
+
+func (c *slowLearnedCache) LoadLearnedOutbounds(group string) map[string]*adapter.LearnedOutbound {
+	return nil
+}
+
+func (c *slowLearnedCache) SaveLearnedOutbound(group string, key string, learned *adapter.LearnedOutbound) error {
+	time.Sleep(50 * time.Millisecond)
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.learned[key] = *learned
+	return nil
+}
+
+func (c *slowLearnedCache) DeleteLearnedOutbound(group string, key string) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if key == "" {
+		c.learned = make(map[string]adapter.LearnedOutbound)
+	} else {
+		delete(c.learned, key)
+	}
+	return nil
+}
+
+func TestSmart_ClearAfterRecord(t *testing.T) {
+	cache := &slowLearnedCache{learned: make(map[string]adapter.LearnedOutbound)}
+	learner := newSmartLearner("smart", log.StdLogger(), cache, time.Hour)
+	learner.record(M.ParseSocksaddrHostPort("www.example.com", 443), newFakeOutbound("primary", "tcp"), true)
+	learner.Clear("")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		learner.writeAccess.Lock()
+		writing := learner.writing
+		learner.writeAccess.Unlock()
+		if !writing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache writes not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cache.access.Lock()
+	defer cache.access.Unlock()
+	if len(cache.learned) != 0 {
+		t.Fatalf("cleared entry was saved again: %+v", cache.learned)
+	}
+}
@@ -16,8 +16,11 @@ type fakeOutbound struct {
 	tag      string
 	networks []string
 
-	dialErr   atomic.Value // *errBox
-	listenErr atomic.Value // *errBox
+	dialErr     atomic.Value // *errBox
+	listenErr   atomic.Value // *errBox
+	handler     atomic.Value // func(net.Conn)
+	dialDelay   atomic.Int64
+	listenDelay atomic.Int64
 
 	dialCalls   atomic.Int32
 	listenCalls atomic.Int32
@@ -44,22 +47,43 @@ func (o *fakeOutbound) SetDialError(err error) {
 	o.dialErr.Store(&errBox{err: err})
 }
 
+// SetHandler serves the remote end of dialed connections with handler
+// instead of closing it immediately.
+func (o *fakeOutbound) SetHandler(handler func(conn net.Conn)) {
+	o.handler.Store(handler)
+}
+
+// SetDialDelay makes dials wait for delay before connecting.
+func (o *fakeOutbound) SetDialDelay(delay time.Duration) {
+	o.dialDelay.Store(int64(delay))
+}
+
+func (o *fakeOutbound) SetListenDelay(delay time.Duration) {
+	o.listenDelay.Store(int64(delay))
+}
+
 func (o *fakeOutbound) SetListenError(err error) {
 	o.listenErr.Store(&errBox{err: err})
 }
 
 func (o *fakeOutbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	o.dialCalls.Add(1)
+	time.Sleep(time.Duration(o.dialDelay.Load()))
 	if err := o.dialErr.Load().(*errBox).err; err != nil {
 		return nil, err
 	}
 	c1, c2 := net.Pipe()
-	_ = c2.Close()
+	if handler, loaded := o.handler.Load().(func(net.Conn)); loaded {
+		go handler(c2)
+	} else {
+		_ = c2.Close()
+	}
 	return c1, nil
 }
 
 func (o *fakeOutbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	o.listenCalls.Add(1)
+	time.Sleep(time.Duration(o.listenDelay.Load()))
 	if err := o.listenErr.Load().(*errBox).err; err != nil {
 		return nil, err
 	}
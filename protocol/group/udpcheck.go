@@ -0,0 +1,131 @@
+package group
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	defaultUDPCheckServer = "1.1.1.1:53"
+	defaultUDPCheckDomain = "www.gstatic.com"
+)
+
+// udpChecker probes the UDP path of members with a DNS query, or with a
+// custom request/response pair.
+type udpChecker struct {
+	server   M.Socksaddr
+	domain   string
+	request  []byte
+	response []byte
+}
+
+func newUDPChecker(options *option.UDPCheckOptions) (*udpChecker, error) {
+	if options == nil || !options.Enabled {
+		return nil, nil
+	}
+	server := options.Server
+	if server == "" {
+		server = defaultUDPCheckServer
+	}
+	checker := &udpChecker{
+		server: M.ParseSocksaddr(server),
+		domain: options.Domain,
+	}
+	if !checker.server.IsValid() || checker.server.Port == 0 {
+		return nil, E.New("invalid udp_check server: ", server)
+	}
+	if checker.domain == "" {
+		checker.domain = defaultUDPCheckDomain
+	}
+	if options.Request != "" {
+		request, err := hex.DecodeString(options.Request)
+		if err != nil {
+			return nil, E.Cause(err, "decode udp_check request")
+		}
+		checker.request = request
+	} else if options.Response != "" {
+		return nil, E.New("udp_check response requires request")
+	}
+	if options.Response != "" {
+		response, err := hex.DecodeString(options.Response)
+		if err != nil {
+			return nil, E.Cause(err, "decode udp_check response")
+		}
+		checker.response = response
+	}
+	return checker, nil
+}
+
+func (c *udpChecker) Check(ctx context.Context, detour N.Dialer) (uint16, error) {
+	if c.request != nil {
+		return urltest.UDPTest(ctx, detour, c.server, c.request, func(response []byte) error {
+			if !bytes.HasPrefix(response, c.response) {
+				return E.New("unexpected response")
+			}
+			return nil
+		})
+	}
+	message := new(mDNS.Msg)
+	message.SetQuestion(mDNS.Fqdn(c.domain), mDNS.TypeA)
+	request, err := message.Pack()
+	if err != nil {
+		return 0, err
+	}
+	return urltest.UDPTest(ctx, detour, c.server, request, func(response []byte) error {
+		var responseMessage mDNS.Msg
+		err := responseMessage.Unpack(response)
+		if err != nil {
+			return err
+		}
+		if !responseMessage.Response || responseMessage.Id != message.Id {
+			return E.New("unexpected DNS response")
+		}
+		return nil
+	})
+}
+
+// checkAndStore probes the UDP path of detour and records the result in the
+// UDP history of the group.
+func (c *udpChecker) checkAndStore(ctx context.Context, timeout time.Duration, logger log.Logger, history adapter.URLTestHistoryStorage, tag string, realTag string, detour N.Dialer) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	t, err := c.Check(ctx, detour)
+	if err != nil {
+		logger.Debug("outbound ", tag, " UDP unavailable: ", err)
+		history.DeleteURLTestHistory(realTag)
+	} else {
+		logger.Debug("outbound ", tag, " UDP available: ", t, "ms")
+		history.StoreURLTestHistory(realTag, &adapter.URLTestHistory{
+			Time:  time.Now(),
+			Delay: t,
+		})
+	}
+}
+
+// networkHistory returns the storage holding the results used for network,
+// UDP selection reads udpHistory when the UDP check is enabled.
+func networkHistory(network string, history adapter.URLTestHistoryStorage, udpHistory *urltest.HistoryStorage) adapter.URLTestHistoryStorage {
+	if udpHistory != nil && N.NetworkName(network) == N.NetworkUDP {
+		return udpHistory
+	}
+	return history
+}
+
+// historyExpired returns whether a member has no result younger than
+// interval in storage.
+func historyExpired(storage adapter.URLTestHistoryStorage, realTag string, interval time.Duration) bool {
+	history := storage.LoadURLTestHistory(realTag)
+	return history == nil || time.Since(history.Time) >= interval
+}
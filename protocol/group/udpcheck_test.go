@@ -0,0 +1,151 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+type echoDialer struct {
+	reply []byte
+}
+
+func (d *echoDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return nil, net.ErrClosed
+}
+
+func (d *echoDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return &echoPacketConn{reply: d.reply, packets: make(chan []byte, 1)}, nil
+}
+
+type echoPacketConn struct {
+	fakePacketConn
+	reply   []byte
+	packets chan []byte
+}
+
+func (c *echoPacketConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	if c.reply != nil {
+		c.packets <- c.reply
+	} else {
+		c.packets <- append([]byte(nil), b...)
+	}
+	return len(b), nil
+}
+
+func (c *echoPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case packet := <-c.packets:
+		return copy(b, packet), &net.UDPAddr{}, nil
+	case <-time.After(time.Second):
+		return 0, nil, context.DeadlineExceeded
+	}
+}
+
+func TestUDPChecker_RequestResponse(t *testing.T) {
+	checker, err := newUDPChecker(&option.UDPCheckOptions{
+		Enabled:  true,
+		Server:   "127.0.0.1:9",
+		Request:  "01020304",
+		Response: "0102",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delay, err := checker.Check(context.Background(), &echoDialer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay == 0 {
+		t.Fatal("expected non-zero delay")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = checker.Check(ctx, &echoDialer{reply: []byte{0xff}})
+	if err == nil {
+		t.Fatal("expected mismatched response to fail")
+	}
+	_, err = newUDPChecker(&option.UDPCheckOptions{Enabled: true, Response: "01"})
+	if err == nil {
+		t.Fatal("expected response without request to fail")
+	}
+}
+
+func TestFallbackUDPCheck_SeparateHistory(t *testing.T) {
+	primary := newFakeOutbound("primary", "tcp", "udp")
+	backup := newFakeOutbound("backup", "tcp", "udp")
+
+	group, err := NewFallbackGroup(context.Background(), nil, log.StdLogger(), []adapter.Outbound{primary, backup}, "", 0, 0, 0, 0, &option.UDPCheckOptions{Enabled: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.checker.outbounds = nil
+	group.checker.history.StoreURLTestHistory(primary.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	group.checker.history.StoreURLTestHistory(backup.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 20})
+	group.checker.udpHistory.StoreURLTestHistory(backup.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 20})
+
+	if detour, _ := group.Select("tcp"); detour != primary {
+		t.Fatalf("unexpected TCP selection: %s", detour.Tag())
+	}
+	if detour, _ := group.Select("udp"); detour != backup {
+		t.Fatalf("unexpected UDP selection: %s", detour.Tag())
+	}
+
+	conn, err := group.ListenPacket(context.Background(), M.ParseSocksaddrHostPort("example.com", 443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if primary.ListenCalls() != 0 || backup.ListenCalls() != 1 {
+		t.Fatalf("unexpected listen calls: primary=%d backup=%d", primary.ListenCalls(), backup.ListenCalls())
+	}
+}
+
+func TestHealthCheckUDP_OwnExpiry(t *testing.T) {
+	member := newFakeOutbound("member", "tcp", "udp")
+	manager := &fakeRelayManager{outbounds: map[string]adapter.Outbound{member.Tag(): member}}
+	checker, err := newHealthChecker(context.Background(), manager, log.StdLogger(), []adapter.Outbound{member}, "", time.Minute, 0, 100*time.Millisecond, &option.UDPCheckOptions{Enabled: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.history.StoreURLTestHistory(member.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+
+	checker.CheckOutbounds(false)
+	if member.DialCalls() != 0 || member.ListenCalls() != 1 {
+		t.Fatalf("expected a UDP check only: dial=%d listen=%d", member.DialCalls(), member.ListenCalls())
+	}
+
+	checker.udpHistory.StoreURLTestHistory(member.Tag(), &adapter.URLTestHistory{Time: time.Now(), Delay: 10})
+	checker.CheckOutbounds(false)
+	if member.DialCalls() != 0 || member.ListenCalls() != 1 {
+		t.Fatalf("expected no check while both results are fresh: dial=%d listen=%d", member.DialCalls(), member.ListenCalls())
+	}
+}
+
+func TestHealthCheckUDP_Concurrent(t *testing.T) {
+	member := newFakeOutbound("member", "tcp", "udp")
+	member.SetDialDelay(300 * time.Millisecond)
+	member.SetDialError(errors.New("blocked"))
+	member.SetListenDelay(300 * time.Millisecond)
+	manager := &fakeRelayManager{outbounds: map[string]adapter.Outbound{member.Tag(): member}}
+	checker, err := newHealthChecker(context.Background(), manager, log.StdLogger(), []adapter.Outbound{member}, "", time.Minute, 0, time.Second, &option.UDPCheckOptions{Enabled: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	checker.CheckOutbounds(false)
+	if member.DialCalls() != 1 || member.ListenCalls() != 1 {
+		t.Fatalf("expected both checks: dial=%d listen=%d", member.DialCalls(), member.ListenCalls())
+	}
+	if elapsed := time.Since(start); elapsed >= 550*time.Millisecond {
+		t.Fatalf("UDP and TCP checks should run concurrently, took %s", elapsed)
+	}
+}
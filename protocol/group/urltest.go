@@ -42,6 +42,7 @@ type URLTest struct {
 	interval                     time.Duration
 	tolerance                    uint16
 	idleTimeout                  time.Duration
+	udpCheck                     *option.UDPCheckOptions
 	group                        *URLTestGroup
 	interruptExternalConnections bool
 }
@@ -59,6 +60,7 @@ func NewURLTest(ctx context.Context, router adapter.Router, logger log.ContextLo
 		interval:                     time.Duration(options.Interval),
 		tolerance:                    options.Tolerance,
 		idleTimeout:                  time.Duration(options.IdleTimeout),
+		udpCheck:                     options.UDPCheck,
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
 	if len(outbound.tags) == 0 {
@@ -76,14 +78,19 @@ func (s *URLTest) Start() error {
 		}
 		outbounds = append(outbounds, detour)
 	}
-	group, err := NewURLTestGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.tolerance, s.idleTimeout, s.interruptExternalConnections)
+	group, err := NewURLTestGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.tolerance, s.idleTimeout, s.udpCheck, s.interruptExternalConnections)
 	if err != nil {
 		return err
 	}
+	group.onSelectedChanged = s.emitSelected
 	s.group = group
 	return nil
 }
 
+func (s *URLTest) emitSelected(network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	emitSelected(s.ctx, s.Tag(), network, previous, outbound)
+}
+
 func (s *URLTest) PostStart() error {
 	s.group.PostStart()
 	return nil
@@ -138,7 +145,7 @@ func (s *URLTest) DialContext(ctx context.Context, network string, destination M
 		return s.group.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
 	}
 	s.logger.ErrorContext(ctx, err)
-	s.group.history.DeleteURLTestHistory(outbound.Tag())
+	s.group.deleteHistory(network, outbound.Tag())
 	return nil, err
 }
 
@@ -156,7 +163,7 @@ func (s *URLTest) ListenPacket(ctx context.Context, destination M.Socksaddr) (ne
 		return s.group.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
 	}
 	s.logger.ErrorContext(ctx, err)
-	s.group.history.DeleteURLTestHistory(outbound.Tag())
+	s.group.deleteHistory(N.NetworkUDP, outbound.Tag())
 	return nil, err
 }
 
@@ -183,6 +190,8 @@ type URLTestGroup struct {
 	tolerance                    uint16
 	idleTimeout                  time.Duration
 	history                      adapter.URLTestHistoryStorage
+	udpCheck                     *udpChecker
+	udpHistory                   *urltest.HistoryStorage
 	checking                     atomic.Bool
 	selectedOutboundTCP          adapter.Outbound
 	selectedOutboundUDP          adapter.Outbound
@@ -193,9 +202,10 @@ type URLTestGroup struct {
 	close                        chan struct{}
 	started                      bool
 	lastActive                   common.TypedValue[time.Time]
+	onSelectedChanged            func(network string, previous adapter.Outbound, outbound adapter.Outbound)
 }
 
-func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16, idleTimeout time.Duration, interruptExternalConnections bool) (*URLTestGroup, error) {
+func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16, idleTimeout time.Duration, udpCheckOptions *option.UDPCheckOptions, interruptExternalConnections bool) (*URLTestGroup, error) {
 	if interval == 0 {
 		interval = C.DefaultURLTestInterval
 	}
@@ -208,6 +218,14 @@ func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManage
 	if interval > idleTimeout {
 		return nil, E.New("interval must be less or equal than idle_timeout")
 	}
+	udpCheck, err := newUDPChecker(udpCheckOptions)
+	if err != nil {
+		return nil, err
+	}
+	var udpHistory *urltest.HistoryStorage
+	if udpCheck != nil {
+		udpHistory = urltest.NewHistoryStorage()
+	}
 	var history adapter.URLTestHistoryStorage
 	if historyFromCtx := service.PtrFromContext[urltest.HistoryStorage](ctx); historyFromCtx != nil {
 		history = historyFromCtx
@@ -226,6 +244,8 @@ func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManage
 		tolerance:                    tolerance,
 		idleTimeout:                  idleTimeout,
 		history:                      history,
+		udpCheck:                     udpCheck,
+		udpHistory:                   udpHistory,
 		close:                        make(chan struct{}),
 		pause:                        service.FromContext[pause.Manager](ctx),
 		interruptGroup:               interrupt.NewGroup(),
@@ -274,14 +294,14 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 	switch network {
 	case N.NetworkTCP:
 		if g.selectedOutboundTCP != nil {
-			if history := g.history.LoadURLTestHistory(RealTag(g.selectedOutboundTCP)); history != nil {
+			if history := g.loadHistory(network, RealTag(g.selectedOutboundTCP)); history != nil {
 				minOutbound = g.selectedOutboundTCP
 				minDelay = history.Delay
 			}
 		}
 	case N.NetworkUDP:
 		if g.selectedOutboundUDP != nil {
-			if history := g.history.LoadURLTestHistory(RealTag(g.selectedOutboundUDP)); history != nil {
+			if history := g.loadHistory(network, RealTag(g.selectedOutboundUDP)); history != nil {
 				minOutbound = g.selectedOutboundUDP
 				minDelay = history.Delay
 			}
@@ -291,7 +311,7 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
-		history := g.history.LoadURLTestHistory(RealTag(detour))
+		history := g.loadHistory(network, RealTag(detour))
 		if history == nil {
 			continue
 		}
@@ -312,6 +332,14 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 	return minOutbound, true
 }
 
+func (g *URLTestGroup) loadHistory(network string, realTag string) *adapter.URLTestHistory {
+	return networkHistory(network, g.history, g.udpHistory).LoadURLTestHistory(realTag)
+}
+
+func (g *URLTestGroup) deleteHistory(network string, realTag string) {
+	networkHistory(network, g.history, g.udpHistory).DeleteURLTestHistory(realTag)
+}
+
 func (g *URLTestGroup) loopCheck() {
 	if time.Since(g.lastActive.Load()) > g.interval {
 		g.lastActive.Store(time.Now())
@@ -359,16 +387,32 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 		if checked[realTag] {
 			continue
 		}
-		history := g.history.LoadURLTestHistory(realTag)
-		if !force && history != nil && time.Since(history.Time) < g.interval {
-			continue
-		}
-		checked[realTag] = true
 		p, loaded := g.outbound.Outbound(realTag)
 		if !loaded {
 			continue
 		}
+		// TCP and UDP results age separately, so that a member failing only
+		// one of them is checked again on the next round.
+		checkTCP := force || historyExpired(g.history, realTag, g.interval)
+		checkUDP := g.udpCheck != nil && common.Contains(p.Network(), N.NetworkUDP) &&
+			(force || historyExpired(g.udpHistory, realTag, g.interval))
+		if !checkTCP && !checkUDP {
+			continue
+		}
+		checked[realTag] = true
 		b.Go(realTag, func() (any, error) {
+			if checkUDP {
+				var udpWait sync.WaitGroup
+				udpWait.Add(1)
+				go func() {
+					defer udpWait.Done()
+					g.udpCheck.checkAndStore(g.ctx, C.TCPTimeout, g.logger, g.udpHistory, tag, realTag, p)
+				}()
+				defer udpWait.Wait()
+			}
+			if !checkTCP {
+				return nil, nil
+			}
 			testCtx, cancel := context.WithTimeout(g.ctx, C.TCPTimeout)
 			defer cancel()
 			t, err := urltest.URLTest(testCtx, g.link, p)
@@ -398,12 +442,14 @@ func (g *URLTestGroup) performUpdateCheck() {
 	if outbound, exists := g.Select(N.NetworkTCP); outbound != nil && (g.selectedOutboundTCP == nil || (exists && outbound != g.selectedOutboundTCP)) {
 		if g.selectedOutboundTCP != nil {
 			updated = true
+			g.selectedChanged(N.NetworkTCP, g.selectedOutboundTCP, outbound)
 		}
 		g.selectedOutboundTCP = outbound
 	}
 	if outbound, exists := g.Select(N.NetworkUDP); outbound != nil && (g.selectedOutboundUDP == nil || (exists && outbound != g.selectedOutboundUDP)) {
 		if g.selectedOutboundUDP != nil {
 			updated = true
+			g.selectedChanged(N.NetworkUDP, g.selectedOutboundUDP, outbound)
 		}
 		g.selectedOutboundUDP = outbound
 	}
@@ -411,3 +457,9 @@ func (g *URLTestGroup) performUpdateCheck() {
 		g.interruptGroup.Interrupt(g.interruptExternalConnections)
 	}
 }
+
+func (g *URLTestGroup) selectedChanged(network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	if g.onSelectedChanged != nil {
+		g.onSelectedChanged(network, previous, outbound)
+	}
+}
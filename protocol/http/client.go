@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/net/http2"
+)
+
+var NewHTTP3ClientFunc func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config) (HTTP3Client, error)
+
+// HTTP3Client opens request streams on a shared HTTP/3 connection. The
+// returned stream is ready for data once the response has been read.
+type HTTP3Client interface {
+	OpenStream(ctx context.Context, request *http.Request) (DatagramStream, *http.Response, error)
+	Close() error
+}
+
+type connectOptions struct {
+	server   M.Socksaddr
+	username string
+	password string
+	headers  http.Header
+}
+
+func (o *connectOptions) newRequest(ctx context.Context, destination M.Socksaddr) *http.Request {
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: destination.String()},
+		Host:   destination.String(),
+		Header: o.headers.Clone(),
+	}
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	request.Header.Del("Host")
+	if o.username != "" {
+		request.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(o.username+":"+o.password)))
+	}
+	return request.WithContext(ctx)
+}
+
+func (o *connectOptions) newConnectUDPRequest(ctx context.Context, destination M.Socksaddr) *http.Request {
+	request := o.newRequest(ctx, destination)
+	request.Proto = protocolConnectUDP
+	request.URL = connectUDPURL(o.server.String(), destination)
+	request.Host = request.URL.Host
+	request.Header.Set("Capsule-Protocol", "?1")
+	return request
+}
+
+func checkResponse(response *http.Response) error {
+	switch {
+	case response.StatusCode/100 == 2:
+		return nil
+	case response.StatusCode == http.StatusProxyAuthRequired:
+		return E.New("authentication required")
+	case response.StatusCode == http.StatusMethodNotAllowed:
+		return E.New("method not allowed")
+	default:
+		return E.New("unexpected status: ", response.Status)
+	}
+}
+
+var _ N.Dialer = (*http2Client)(nil)
+
+type http2Client struct {
+	connectOptions
+	ctx        context.Context
+	dialer     N.Dialer
+	tlsConfig  tls.Config
+	transport  *http2.Transport
+	access     sync.Mutex
+	clientConn *http2.ClientConn
+}
+
+func newHTTP2Client(ctx context.Context, options connectOptions, dialer N.Dialer, tlsConfig tls.Config) *http2Client {
+	if len(tlsConfig.NextProtos()) == 0 {
+		tlsConfig.SetNextProtos([]string{http2.NextProtoTLS})
+	}
+	return &http2Client{
+		connectOptions: options,
+		ctx:            ctx,
+		dialer:         dialer,
+		tlsConfig:      tlsConfig,
+		transport:      &http2.Transport{},
+	}
+}
+
+func (c *http2Client) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if N.NetworkName(network) != N.NetworkTCP {
+		return nil, os.ErrInvalid
+	}
+	clientConn, err := c.getClientConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipeInReader, pipeInWriter := io.Pipe()
+	request := c.newRequest(c.ctx, destination)
+	request.Body = pipeInReader
+	response, err := clientConn.RoundTrip(request)
+	if err != nil {
+		pipeInWriter.Close()
+		return nil, err
+	}
+	err = checkResponse(response)
+	if err != nil {
+		response.Body.Close()
+		pipeInWriter.Close()
+		return nil, err
+	}
+	conn := v2rayhttp.NewHTTPConn(response.Body, pipeInWriter)
+	return &conn, nil
+}
+
+func (c *http2Client) getClientConn(ctx context.Context) (*http2.ClientConn, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.clientConn != nil && c.clientConn.CanTakeNewRequest() {
+		return c.clientConn, nil
+	}
+	conn, err := c.dialer.DialContext(ctx, N.NetworkTCP, c.server)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn, err := tls.ClientHandshake(ctx, conn, c.tlsConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		tlsConn.Close()
+		return nil, E.New("server does not support HTTP/2")
+	}
+	clientConn, err := c.transport.NewClientConn(tlsConn)
+	if err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	c.clientConn = clientConn
+	return clientConn, nil
+}
+
+func (c *http2Client) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
+
+func (c *http2Client) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.clientConn == nil {
+		return nil
+	}
+	return c.clientConn.Close()
+}
+
+var _ N.Dialer = (*http3Client)(nil)
+
+type http3Client struct {
+	connectOptions
+	ctx    context.Context
+	client HTTP3Client
+}
+
+func (c *http3Client) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if N.NetworkName(network) != N.NetworkTCP {
+		return nil, os.ErrInvalid
+	}
+	stream, err := c.openStream(ctx, c.newRequest(c.ctx, destination))
+	if err != nil {
+		return nil, err
+	}
+	return &streamConn{stream}, nil
+}
+
+func (c *http3Client) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	stream, err := c.openStream(ctx, c.newConnectUDPRequest(c.ctx, destination))
+	if err != nil {
+		return nil, err
+	}
+	return newDatagramPacketConn(stream, destination), nil
+}
+
+func (c *http3Client) openStream(ctx context.Context, request *http.Request) (DatagramStream, error) {
+	stream, response, err := c.client.OpenStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(response)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (c *http3Client) Close() error {
+	return common.Close(c.client)
+}
+
+type streamConn struct {
+	DatagramStream
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *streamConn) NeedAdditionalReadDeadline() bool {
+	return true
+}
+
+func (c *streamConn) Upstream() any {
+	return c.DatagramStream
+}
@@ -3,7 +3,10 @@ package http
 import (
 	std_bufio "bufio"
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -16,31 +19,59 @@ import (
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
 	N "github.com/sagernet/sing/common/network"
-	"github.com/sagernet/sing/protocol/http"
+	sHTTP "github.com/sagernet/sing/protocol/http"
+
+	"golang.org/x/net/http2"
 )
 
+var ConfigureHTTP3ListenerFunc func(listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error)
+
 func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.HTTPMixedInboundOptions](registry, C.TypeHTTP, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
-	router        adapter.ConnectionRouterEx
-	logger        log.ContextLogger
-	listener      *listener.Listener
-	authenticator *auth.Authenticator
-	tlsConfig     tls.ServerConfig
+	router    adapter.ConnectionRouterEx
+	logger    log.ContextLogger
+	listener  *listener.Listener
+	network   []string
+	users     atomic.Pointer[adapter.ManagedAuthenticator]
+	tlsConfig tls.ServerConfig
+	h2Server  *http2.Server
+	h3Server  io.Closer
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) (adapter.Inbound, error) {
 	inbound := &Inbound{
-		Adapter:       inbound.NewAdapter(C.TypeHTTP, tag),
-		router:        uot.NewRouter(router, logger),
-		logger:        logger,
-		authenticator: auth.NewAuthenticator(options.Users),
+		Adapter:  inbound.NewAdapter(C.TypeHTTP, tag),
+		router:   uot.NewRouter(router, logger),
+		logger:   logger,
+		h2Server: &http2.Server{},
+	}
+	inbound.users.Store(&adapter.ManagedAuthenticator{
+		Authenticator: auth.NewAuthenticator(options.Users),
+		Users:         options.Users,
+	})
+	if options.Network == "" {
+		inbound.network = []string{N.NetworkTCP}
+	} else {
+		inbound.network = options.Network.Build()
+	}
+	if options.SetSystemProxy && !common.Contains(inbound.network, N.NetworkTCP) {
+		return nil, E.New("set_system_proxy requires TCP")
+	}
+	if common.Contains(inbound.network, N.NetworkUDP) {
+		if options.TLS == nil || !options.TLS.Enabled {
+			return nil, E.New("TLS is required for HTTP/3 server")
+		}
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -52,7 +83,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	inbound.listener = listener.New(listener.Options{
 		Context:           ctx,
 		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
+		Network:           common.Filter(inbound.network, func(it string) bool { return it == N.NetworkTCP }),
 		Listen:            options.ListenOptions,
 		ConnectionHandler: inbound,
 		SetSystemProxy:    options.SetSystemProxy,
@@ -70,17 +101,56 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 		if err != nil {
 			return E.Cause(err, "create TLS config")
 		}
+		if common.Contains(h.network, N.NetworkTCP) {
+			if len(h.tlsConfig.NextProtos()) == 0 {
+				h.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+			} else if !common.Contains(h.tlsConfig.NextProtos(), http2.NextProtoTLS) {
+				h.tlsConfig.SetNextProtos(append([]string{http2.NextProtoTLS}, h.tlsConfig.NextProtos()...))
+			}
+		}
 	}
-	return h.listener.Start()
+	err := h.listener.Start()
+	if err != nil {
+		return err
+	}
+	if common.Contains(h.network, N.NetworkUDP) {
+		h3Server, err := ConfigureHTTP3ListenerFunc(h.listener, h, h.tlsConfig, h.logger)
+		if err == nil {
+			h.h3Server = h3Server
+		} else if len(h.network) > 1 {
+			h.logger.Warn(E.Cause(err, "http3 disabled"))
+		} else {
+			return err
+		}
+	}
+	return nil
 }
 
 func (h *Inbound) Close() error {
 	return common.Close(
 		h.listener,
+		h.h3Server,
 		h.tlsConfig,
 	)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	authenticator, err := adapter.NewManagedAuthenticator(users)
+	if err != nil {
+		return err
+	}
+	h.users.Store(authenticator)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return h.users.Load().ManagedUsers()
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil {
 		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
@@ -90,8 +160,12 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 			return
 		}
 		conn = tlsConn
+		if tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+			h.serveHTTP2(ctx, conn, metadata, onClose)
+			return
+		}
 	}
-	err := http.HandleConnectionEx(ctx, conn, std_bufio.NewReader(conn), h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
+	err := sHTTP.HandleConnectionEx(ctx, conn, std_bufio.NewReader(conn), h.users.Load().Authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
 	if err != nil {
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
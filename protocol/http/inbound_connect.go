@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/pipe"
+	sHTTP "github.com/sagernet/sing/protocol/http"
+
+	"golang.org/x/net/http2"
+)
+
+func (h *Inbound) serveHTTP2(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	h.h2Server.ServeConn(conn, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			h.serveConnect(log.ContextWithNewID(request.Context()), writer, request, metadata)
+		}),
+	})
+	conn.Close()
+	if onClose != nil {
+		onClose(nil)
+	}
+}
+
+// ServeHTTP handles requests of the HTTP/3 server.
+func (h *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var metadata adapter.InboundContext
+	//nolint:staticcheck
+	metadata.InboundDetour = h.listener.ListenOptions().Detour
+	//nolint:staticcheck
+	metadata.InboundOptions = h.listener.ListenOptions().InboundOptions
+	metadata.Source = sHTTP.SourceAddress(request)
+	metadata.OriginDestination = h.listener.UDPAddr()
+	ctx := log.ContextWithNewID(request.Context())
+	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+	h.serveConnect(ctx, writer, request, metadata)
+}
+
+func (h *Inbound) serveConnect(ctx context.Context, writer http.ResponseWriter, request *http.Request, metadata adapter.InboundContext) {
+	if authenticator := h.users.Load().Authenticator; authenticator != nil {
+		userName, password, authOk := sHTTP.ParseBasicAuth(request.Header.Get("Proxy-Authorization"))
+		if !authOk || !authenticator.Verify(userName, password) {
+			writer.Header().Set("Proxy-Authenticate", `Basic realm="sing-box" charset="UTF-8"`)
+			writer.WriteHeader(http.StatusProxyAuthRequired)
+			h.logger.ErrorContext(ctx, E.New("process connection from ", metadata.Source, ": authentication failed"))
+			return
+		}
+		ctx = auth.ContextWithUser(ctx, userName)
+	}
+	if request.Method != http.MethodConnect {
+		h.serveForward(ctx, writer, request, metadata)
+		return
+	}
+	if request.Proto == protocolConnectUDP {
+		h.serveConnectUDP(ctx, writer, request, metadata)
+		return
+	}
+	metadata.Destination = M.ParseSocksaddr(request.Host).Unwrap()
+	if !metadata.Destination.IsValid() || metadata.Destination.Port == 0 {
+		writer.WriteHeader(http.StatusBadRequest)
+		h.logger.ErrorContext(ctx, E.New("process connection from ", metadata.Source, ": invalid authority: ", request.Host))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+	done := make(chan struct{})
+	conn := v2rayhttp.NewHTTP2Wrapper(&v2rayhttp.ServerHTTPConn{
+		HTTP2Conn: v2rayhttp.NewHTTPConn(request.Body, writer),
+		Flusher:   writer.(http.Flusher),
+	})
+	h.newUserConnection(ctx, conn, metadata, N.OnceClose(func(it error) {
+		close(done)
+	}))
+	<-done
+	conn.CloseWrapper()
+}
+
+// serveForward proxies a plain request to the authority it is sent to, like
+// absolute-form requests over HTTP/1.1. Such requests are always http, since
+// clients tunnel https through CONNECT.
+func (h *Inbound) serveForward(ctx context.Context, writer http.ResponseWriter, request *http.Request, metadata adapter.InboundContext) {
+	authority := request.URL.Host
+	if authority == "" {
+		authority = request.Host
+	}
+	metadata.Destination = M.ParseSocksaddr(authority).Unwrap()
+	if !metadata.Destination.IsValid() {
+		writer.WriteHeader(http.StatusBadRequest)
+		h.logger.ErrorContext(ctx, E.New("process connection from ", metadata.Source, ": invalid authority: ", authority))
+		return
+	}
+	if metadata.Destination.Port == 0 {
+		metadata.Destination.Port = 80
+	}
+	var innerErr common.TypedValue[error]
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DisableCompression: true,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				input, output := pipe.Pipe()
+				go h.newUserConnection(ctx, output, metadata, func(it error) {
+					innerErr.Store(it)
+					common.Close(input, output)
+				})
+				return input, nil
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	forwardRequest := request.Clone(ctx)
+	forwardRequest.RequestURI = ""
+	forwardRequest.URL.Scheme = "http"
+	forwardRequest.URL.Host = authority
+	removeHopByHopHeaders(forwardRequest.Header)
+	response, err := httpClient.Do(forwardRequest)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadGateway)
+		h.logger.ErrorContext(ctx, E.Cause(E.Errors(innerErr.Load(), err), "process connection from ", metadata.Source))
+		return
+	}
+	defer response.Body.Close()
+	removeHopByHopHeaders(response.Header)
+	for key, values := range response.Header {
+		writer.Header()[key] = values
+	}
+	writer.WriteHeader(response.StatusCode)
+	_, err = io.Copy(writer, response.Body)
+	if err != nil {
+		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
+	}
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	header.Del("Proxy-Connection")
+	header.Del("Proxy-Authenticate")
+	header.Del("Proxy-Authorization")
+	header.Del("TE")
+	header.Del("Trailers")
+	header.Del("Transfer-Encoding")
+	header.Del("Upgrade")
+	connections := header.Get("Connection")
+	header.Del("Connection")
+	for _, name := range strings.Split(connections, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			header.Del(name)
+		}
+	}
+}
+
+func (h *Inbound) serveConnectUDP(ctx context.Context, writer http.ResponseWriter, request *http.Request, metadata adapter.InboundContext) {
+	streamer, isStreamer := writer.(DatagramStreamer)
+	if !isStreamer {
+		writer.WriteHeader(http.StatusNotImplemented)
+		h.logger.ErrorContext(ctx, E.New("process connection from ", metadata.Source, ": connect-udp requires HTTP/3"))
+		return
+	}
+	destination, err := parseConnectUDPPath(request.URL.Path)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
+		return
+	}
+	metadata.Destination = destination
+	writer.Header().Set("Capsule-Protocol", "?1")
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+	done := make(chan struct{})
+	h.streamUserPacketConnection(ctx, newDatagramPacketConn(streamer.DatagramStream(), destination), metadata, N.OnceClose(func(it error) {
+		close(done)
+	}))
+	<-done
+}
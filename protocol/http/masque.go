@@ -0,0 +1,289 @@
+package http
+
+import (
+	std_bufio "bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	protocolConnectUDP   = "connect-udp"
+	connectUDPPathPrefix = "/.well-known/masque/udp/"
+	capsuleTypeDatagram  = 0x00
+	maxCapsuleLength     = 65535 + 8
+)
+
+// ErrDatagramTooLarge is returned by DatagramStream.SendDatagram when the
+// payload does not fit in a QUIC datagram.
+var ErrDatagramTooLarge = E.New("datagram too large")
+
+// DatagramStream is an HTTP/3 request stream with RFC 9297 datagram support.
+type DatagramStream interface {
+	io.ReadWriteCloser
+	SendDatagram(b []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// DatagramStreamer is implemented by HTTP/3 response writers to take over
+// the request stream of an extended CONNECT.
+type DatagramStreamer interface {
+	DatagramStream() DatagramStream
+}
+
+func connectUDPURL(authority string, destination M.Socksaddr) *url.URL {
+	host := destination.AddrString()
+	port := F.ToString(destination.Port)
+	return &url.URL{
+		Scheme:  "https",
+		Host:    authority,
+		Path:    connectUDPPathPrefix + host + "/" + port + "/",
+		RawPath: connectUDPPathPrefix + strings.ReplaceAll(url.PathEscape(host), ":", "%3A") + "/" + port + "/",
+	}
+}
+
+func parseConnectUDPPath(path string) (M.Socksaddr, error) {
+	target, loaded := strings.CutPrefix(path, connectUDPPathPrefix)
+	if !loaded {
+		return M.Socksaddr{}, E.New("unexpected connect-udp path: ", path)
+	}
+	host, port, loaded := strings.Cut(strings.TrimSuffix(target, "/"), "/")
+	if !loaded || host == "" {
+		return M.Socksaddr{}, E.New("unexpected connect-udp path: ", path)
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || portNumber == 0 {
+		return M.Socksaddr{}, E.New("invalid connect-udp target port: ", port)
+	}
+	return M.ParseSocksaddrHostPort(host, uint16(portNumber)).Unwrap(), nil
+}
+
+var _ N.NetPacketConn = (*datagramPacketConn)(nil)
+
+// datagramPacketConn carries UDP payloads of a connect-udp association in
+// HTTP datagrams with context ID 0, the only context RFC 9298 defines.
+// Payloads that do not fit in a QUIC datagram are sent as DATAGRAM capsules
+// on the request stream instead.
+type datagramPacketConn struct {
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+	stream      DatagramStream
+	destination M.Socksaddr
+	datagrams   chan []byte
+	writeAccess sync.Mutex
+}
+
+func newDatagramPacketConn(stream DatagramStream, destination M.Socksaddr) *datagramPacketConn {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	conn := &datagramPacketConn{
+		ctx:         ctx,
+		cancel:      cancel,
+		stream:      stream,
+		destination: destination,
+		datagrams:   make(chan []byte, 64),
+	}
+	go conn.loopDatagrams()
+	go conn.loopCapsules()
+	return conn
+}
+
+func (c *datagramPacketConn) loopDatagrams() {
+	for {
+		datagram, err := c.stream.ReceiveDatagram(c.ctx)
+		if err != nil {
+			c.cancel(err)
+			return
+		}
+		c.deliver(datagram)
+	}
+}
+
+func (c *datagramPacketConn) loopCapsules() {
+	reader := std_bufio.NewReader(c.stream)
+	for {
+		capsuleType, err := readVarint(reader)
+		if err != nil {
+			c.cancel(err)
+			return
+		}
+		capsuleLength, err := readVarint(reader)
+		if err != nil {
+			c.cancel(err)
+			return
+		}
+		if capsuleType != capsuleTypeDatagram {
+			_, err = reader.Discard(int(capsuleLength))
+			if err != nil {
+				c.cancel(err)
+				return
+			}
+			continue
+		}
+		if capsuleLength > maxCapsuleLength {
+			c.cancel(E.New("connect-udp: capsule too large: ", capsuleLength))
+			return
+		}
+		datagram := make([]byte, capsuleLength)
+		_, err = io.ReadFull(reader, datagram)
+		if err != nil {
+			c.cancel(err)
+			return
+		}
+		c.deliver(datagram)
+	}
+}
+
+func (c *datagramPacketConn) deliver(datagram []byte) {
+	select {
+	case c.datagrams <- datagram:
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *datagramPacketConn) receive() ([]byte, error) {
+	for {
+		select {
+		case datagram := <-c.datagrams:
+			contextID, n := parseVarint(datagram)
+			if n == 0 || contextID != 0 {
+				continue
+			}
+			return datagram[n:], nil
+		case <-c.ctx.Done():
+			return nil, context.Cause(c.ctx)
+		}
+	}
+}
+
+func (c *datagramPacketConn) ReadPacket(buffer *buf.Buffer) (M.Socksaddr, error) {
+	payload, err := c.receive()
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	_, err = buffer.Write(payload)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	return c.destination, nil
+}
+
+func (c *datagramPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	return c.send(buffer.Bytes())
+}
+
+func (c *datagramPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	payload, err := c.receive()
+	if err != nil {
+		return
+	}
+	n = copy(p, payload)
+	addr = c.destination.UDPAddr()
+	return
+}
+
+func (c *datagramPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	err = c.send(p)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *datagramPacketConn) send(payload []byte) error {
+	datagram := make([]byte, 1+len(payload))
+	copy(datagram[1:], payload)
+	err := c.stream.SendDatagram(datagram)
+	if !errors.Is(err, ErrDatagramTooLarge) {
+		return err
+	}
+	capsule := appendVarint(make([]byte, 0, 16+len(datagram)), capsuleTypeDatagram)
+	capsule = appendVarint(capsule, uint64(len(datagram)))
+	capsule = append(capsule, datagram...)
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+	_, err = c.stream.Write(capsule)
+	return err
+}
+
+func (c *datagramPacketConn) Close() error {
+	c.cancel(net.ErrClosed)
+	return c.stream.Close()
+}
+
+func (c *datagramPacketConn) LocalAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *datagramPacketConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramPacketConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramPacketConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramPacketConn) Upstream() any {
+	return c.stream
+}
+
+func readVarint(reader io.ByteReader) (uint64, error) {
+	first, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	value := uint64(first & 0x3f)
+	for range 1<<(first>>6) - 1 {
+		next, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<8 | uint64(next)
+	}
+	return value, nil
+}
+
+func parseVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	length := 1 << (b[0] >> 6)
+	if len(b) < length {
+		return 0, 0
+	}
+	value := uint64(b[0] & 0x3f)
+	for _, next := range b[1:length] {
+		value = value<<8 | uint64(next)
+	}
+	return value, length
+}
+
+func appendVarint(b []byte, value uint64) []byte {
+	switch {
+	case value <= 0x3f:
+		return append(b, byte(value))
+	case value <= 0x3fff:
+		return append(b, byte(value>>8)|0x40, byte(value))
+	case value <= 0x3fffffff:
+		return append(b, byte(value>>24)|0x80, byte(value>>16), byte(value>>8), byte(value))
+	default:
+		return append(b, byte(value>>56)|0xc0, byte(value>>48), byte(value>>40), byte(value>>32), byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+}
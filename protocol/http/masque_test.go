@@ -0,0 +1,49 @@
+package http
+
+import (
+	"bytes"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectUDPPath(t *testing.T) {
+	t.Parallel()
+	for _, destination := range []M.Socksaddr{
+		M.ParseSocksaddr("192.0.2.1:53"),
+		M.ParseSocksaddr("[2001:db8::1]:443"),
+		M.ParseSocksaddr("example.com:8443"),
+	} {
+		requestURL := connectUDPURL("proxy.example.com:443", destination)
+		require.NotContains(t, requestURL.EscapedPath(), ":")
+		parsed, err := parseConnectUDPPath(requestURL.Path)
+		require.NoError(t, err)
+		require.Equal(t, destination, parsed)
+	}
+	for _, path := range []string{
+		"/",
+		"/.well-known/masque/udp/192.0.2.1/",
+		"/.well-known/masque/udp/192.0.2.1/0/",
+		"/.well-known/masque/udp/192.0.2.1/65536/",
+	} {
+		_, err := parseConnectUDPPath(path)
+		require.Error(t, err, path)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	t.Parallel()
+	for _, value := range []uint64{0, 63, 64, 16383, 16384, 1<<30 - 1, 1 << 30, 1<<62 - 1} {
+		encoded := appendVarint(nil, value)
+		decoded, n := parseVarint(encoded)
+		require.Equal(t, len(encoded), n)
+		require.Equal(t, value, decoded)
+		decoded, err := readVarint(bytes.NewReader(encoded))
+		require.NoError(t, err)
+		require.Equal(t, value, decoded)
+	}
+	_, n := parseVarint([]byte{0x40})
+	require.Zero(t, n)
+}
@@ -3,7 +3,6 @@ package http
 import (
 	"context"
 	"net"
-	"os"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
@@ -13,6 +12,7 @@ import (
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -26,7 +26,7 @@ func RegisterOutbound(registry *outbound.Registry) {
 type Outbound struct {
 	outbound.Adapter
 	logger logger.ContextLogger
-	client *sHTTP.Client
+	client N.Dialer
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPOutboundOptions) (adapter.Outbound, error) {
@@ -34,21 +34,60 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if err != nil {
 		return nil, err
 	}
-	detour, err := tls.NewDialerFromOptions(ctx, router, outboundDialer, options.Server, common.PtrValueOrDefault(options.TLS))
-	if err != nil {
-		return nil, err
-	}
-	return &Outbound{
-		Adapter: outbound.NewAdapterWithDialerOptions(C.TypeHTTP, tag, []string{N.NetworkTCP}, options.DialerOptions),
-		logger:  logger,
-		client: sHTTP.NewClient(sHTTP.Options{
+	network := []string{N.NetworkTCP}
+	var client N.Dialer
+	switch options.Version {
+	case "", "1.1":
+		detour, err := tls.NewDialerFromOptions(ctx, router, outboundDialer, options.Server, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		client = sHTTP.NewClient(sHTTP.Options{
 			Dialer:   detour,
 			Server:   options.ServerOptions.Build(),
 			Username: options.Username,
 			Password: options.Password,
 			Path:     options.Path,
 			Headers:  options.Headers.Build(),
-		}),
+		})
+	case "2", "3":
+		if options.TLS == nil || !options.TLS.Enabled {
+			return nil, E.New("TLS is required for HTTP/", options.Version)
+		}
+		if options.Path != "" {
+			return nil, E.New("path is only supported in HTTP/1.1")
+		}
+		tlsConfig, err := tls.NewClient(ctx, options.Server, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		connectOptions := connectOptions{
+			server:   options.ServerOptions.Build(),
+			username: options.Username,
+			password: options.Password,
+			headers:  options.Headers.Build(),
+		}
+		if options.Version == "2" {
+			client = newHTTP2Client(ctx, connectOptions, outboundDialer, tlsConfig)
+		} else {
+			h3Client, err := NewHTTP3ClientFunc(outboundDialer, connectOptions.server, tlsConfig)
+			if err != nil {
+				return nil, err
+			}
+			client = &http3Client{
+				connectOptions: connectOptions,
+				ctx:            ctx,
+				client:         h3Client,
+			}
+			network = append(network, N.NetworkUDP)
+		}
+	default:
+		return nil, E.New("unknown HTTP version: ", options.Version)
+	}
+	return &Outbound{
+		Adapter: outbound.NewAdapterWithDialerOptions(C.TypeHTTP, tag, network, options.DialerOptions),
+		logger:  logger,
+		client:  client,
 	}, nil
 }
 
@@ -61,5 +100,13 @@ func (h *Outbound) DialContext(ctx context.Context, network string, destination
 }
 
 func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	return nil, os.ErrInvalid
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+	return h.client.ListenPacket(ctx, destination)
+}
+
+func (h *Outbound) Close() error {
+	return common.Close(h.client)
 }
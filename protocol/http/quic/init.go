@@ -0,0 +1,240 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/tls"
+	protocolHTTP "github.com/sagernet/sing-box/protocol/http"
+	"github.com/sagernet/sing-quic"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func init() {
+	protocolHTTP.ConfigureHTTP3ListenerFunc = func(listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
+		if len(tlsConfig.NextProtos()) > 0 && !common.Contains(tlsConfig.NextProtos(), http3.NextProtoH3) {
+			tlsConfig.SetNextProtos(append(tlsConfig.NextProtos(), http3.NextProtoH3))
+		}
+		err := qtls.ConfigureHTTP3(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		udpConn, err := listener.ListenUDP()
+		if err != nil {
+			return nil, err
+		}
+
+		quicListener, err := qtls.ListenEarly(udpConn, tlsConfig, &quic.Config{
+			MaxIncomingStreams: 1 << 60,
+			Allow0RTT:          true,
+			EnableDatagrams:    true,
+		})
+		if err != nil {
+			udpConn.Close()
+			return nil, err
+		}
+
+		h3Server := &http3.Server{
+			Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				request.Body = &requestBody{request.Body}
+				handler.ServeHTTP(&datagramResponseWriter{writer, writer.(http3.HTTPStreamer)}, request)
+			}),
+			EnableDatagrams: true,
+		}
+
+		go func() {
+			sErr := h3Server.ServeListener(quicListener)
+			udpConn.Close()
+			if sErr != nil && !E.IsClosedOrCanceled(sErr) {
+				logger.Error("http3 server closed: ", sErr)
+			}
+		}()
+
+		return quicListener, nil
+	}
+	protocolHTTP.NewHTTP3ClientFunc = func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config) (protocolHTTP.HTTP3Client, error) {
+		if len(tlsConfig.NextProtos()) == 0 {
+			tlsConfig.SetNextProtos([]string{http3.NextProtoH3})
+		}
+		return &http3Client{
+			dialer:     dialer,
+			serverAddr: serverAddr,
+			tlsConfig:  tlsConfig,
+			transport: &http3.Transport{
+				EnableDatagrams:    true,
+				DisableCompression: true,
+			},
+		}, nil
+	}
+}
+
+var (
+	_ http.Flusher                  = (*datagramResponseWriter)(nil)
+	_ protocolHTTP.DatagramStreamer = (*datagramResponseWriter)(nil)
+)
+
+type datagramResponseWriter struct {
+	http.ResponseWriter
+	streamer http3.HTTPStreamer
+}
+
+func (w *datagramResponseWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *datagramResponseWriter) DatagramStream() protocolHTTP.DatagramStream {
+	return &datagramStream{w.streamer.HTTPStream()}
+}
+
+type requestBody struct {
+	io.ReadCloser
+}
+
+func (b *requestBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	return n, wrapError(err)
+}
+
+type datagramStream struct {
+	http3.Stream
+}
+
+func (s *datagramStream) Read(p []byte) (n int, err error) {
+	n, err = s.Stream.Read(p)
+	return n, wrapError(err)
+}
+
+func (s *datagramStream) Write(p []byte) (n int, err error) {
+	n, err = s.Stream.Write(p)
+	return n, wrapError(err)
+}
+
+func (s *datagramStream) SendDatagram(b []byte) error {
+	err := s.Stream.SendDatagram(b)
+	var tooLargeErr *quic.DatagramTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		return protocolHTTP.ErrDatagramTooLarge
+	}
+	return err
+}
+
+func (s *datagramStream) Close() error {
+	s.Stream.CancelRead(quic.StreamErrorCode(http3.ErrCodeNoError))
+	return s.Stream.Close()
+}
+
+// wrapError maps streams closed or canceled without an error by either side
+// to net.ErrClosed.
+func wrapError(err error) error {
+	var (
+		errorCode http3.ErrCode
+		h3Err     *http3.Error
+		streamErr *quic.StreamError
+	)
+	switch {
+	case errors.As(err, &h3Err):
+		errorCode = h3Err.ErrorCode
+	case errors.As(err, &streamErr):
+		errorCode = http3.ErrCode(streamErr.ErrorCode)
+	default:
+		return err
+	}
+	switch errorCode {
+	case http3.ErrCodeNoError, http3.ErrCodeRequestCanceled:
+		return net.ErrClosed
+	default:
+		return err
+	}
+}
+
+type http3Client struct {
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	tlsConfig  tls.Config
+	transport  *http3.Transport
+	access     sync.Mutex
+	quicConn   quic.Connection
+	clientConn *http3.ClientConn
+}
+
+func (c *http3Client) OpenStream(ctx context.Context, request *http.Request) (protocolHTTP.DatagramStream, *http.Response, error) {
+	clientConn, err := c.getClientConn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.Proto != "" {
+		select {
+		case <-clientConn.ReceivedSettings():
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		settings := clientConn.Settings()
+		if !settings.EnableExtendedConnect || !settings.EnableDatagrams {
+			return nil, nil, E.New("server does not support HTTP/3 datagrams")
+		}
+	}
+	stream, err := clientConn.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = stream.SendRequestHeader(request)
+	if err != nil {
+		stream.CancelRead(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		stream.CancelWrite(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		return nil, nil, err
+	}
+	response, err := stream.ReadResponse()
+	if err != nil {
+		stream.CancelWrite(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		return nil, nil, err
+	}
+	return &datagramStream{stream}, response, nil
+}
+
+func (c *http3Client) getClientConn(ctx context.Context) (*http3.ClientConn, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.quicConn != nil && c.quicConn.Context().Err() == nil {
+		return c.clientConn, nil
+	}
+	conn, err := c.dialer.DialContext(ctx, N.NetworkUDP, c.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	quicConn, err := qtls.Dial(ctx, bufio.NewUnbindPacketConn(conn), conn.RemoteAddr(), c.tlsConfig, &quic.Config{
+		EnableDatagrams: true,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-quicConn.Context().Done()
+		conn.Close()
+	}()
+	c.quicConn = quicConn
+	c.clientConn = c.transport.NewClientConn(quicConn)
+	return c.clientConn, nil
+}
+
+func (c *http3Client) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.quicConn == nil {
+		return nil
+	}
+	return c.quicConn.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeNoError), "")
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -28,14 +29,16 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.Hysteria2InboundOptions](registry, C.TypeHysteria2, NewInbound)
 }
 
+var _ adapter.ManagedUserServer = (*Inbound)(nil)
+
 type Inbound struct {
 	inbound.Adapter
-	router       adapter.Router
-	logger       log.ContextLogger
-	listener     *listener.Listener
-	tlsConfig    tls.ServerConfig
-	service      *hysteria2.Service[int]
-	userNameList []string
+	router    adapter.ConnectionRouterEx
+	logger    log.ContextLogger
+	listener  *listener.Listener
+	tlsConfig tls.ServerConfig
+	service   *hysteria2.Service[adapter.ManagedUserKey]
+	users     atomic.Pointer[[]adapter.ManagedUser]
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (adapter.Inbound, error) {
@@ -113,7 +116,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	} else {
 		udpTimeout = C.UDPTimeout
 	}
-	service, err := hysteria2.NewService[int](hysteria2.ServiceOptions{
+	service, err := hysteria2.NewService[adapter.ManagedUserKey](hysteria2.ServiceOptions{
 		Context:               ctx,
 		Logger:                logger,
 		BrutalDebug:           options.BrutalDebug,
@@ -129,20 +132,46 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
-	userList := make([]int, 0, len(options.Users))
-	userNameList := make([]string, 0, len(options.Users))
-	userPasswordList := make([]string, 0, len(options.Users))
-	for index, user := range options.Users {
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
-		userPasswordList = append(userPasswordList, user.Password)
-	}
-	service.UpdateUsers(userList, userPasswordList)
 	inbound.service = service
-	inbound.userNameList = userNameList
+	inbound.setUsers(common.Map(options.Users, func(it option.Hysteria2User) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	}))
 	return inbound, nil
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.Password == "" {
+			return E.New("missing password for user ", user.Name)
+		}
+	}
+	h.setUsers(users)
+	return nil
+}
+
+// setUsers replaces the users of the running service. It authenticates to
+// keys carrying the name, so connections are credited to the list they were
+// accepted with even while it is replaced.
+func (h *Inbound) setUsers(users []adapter.ManagedUser) {
+	h.service.UpdateUsers(adapter.NewManagedUserKeys(users, func(it adapter.ManagedUser) string {
+		return it.Name
+	}), common.Map(users, func(it adapter.ManagedUser) string {
+		return it.Password
+	}))
+	h.users.Store(&users)
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return *h.users.Load()
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
 	ctx = log.ContextWithNewID(ctx)
 	var metadata adapter.InboundContext
@@ -156,8 +185,8 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.S
 	metadata.Source = source
 	metadata.Destination = destination
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userKey, _ := auth.UserFromContext[adapter.ManagedUserKey](ctx)
+	if userName := userKey.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
@@ -179,8 +208,8 @@ func (h *Inbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	metadata.Source = source
 	metadata.Destination = destination
 	h.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userKey, _ := auth.UserFromContext[adapter.ManagedUserKey](ctx)
+	if userName := userKey.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
@@ -4,6 +4,7 @@ import (
 	std_bufio "bufio"
 	"context"
 	"net"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -27,24 +28,33 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.HTTPMixedInboundOptions](registry, C.TypeMixed, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
-	router        adapter.ConnectionRouterEx
-	logger        log.ContextLogger
-	listener      *listener.Listener
-	authenticator *auth.Authenticator
-	tlsConfig     tls.ServerConfig
+	router    adapter.ConnectionRouterEx
+	logger    log.ContextLogger
+	listener  *listener.Listener
+	users     atomic.Pointer[adapter.ManagedAuthenticator]
+	tlsConfig tls.ServerConfig
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) (adapter.Inbound, error) {
+	if options.Network != "" {
+		return nil, E.New("`network` is only supported by the http inbound")
+	}
 	inbound := &Inbound{
-		Adapter:       inbound.NewAdapter(C.TypeMixed, tag),
-		router:        uot.NewRouter(router, logger),
-		logger:        logger,
-		authenticator: auth.NewAuthenticator(options.Users),
+		Adapter: inbound.NewAdapter(C.TypeMixed, tag),
+		router:  uot.NewRouter(router, logger),
+		logger:  logger,
 	}
+	inbound.users.Store(&adapter.ManagedAuthenticator{
+		Authenticator: auth.NewAuthenticator(options.Users),
+		Users:         options.Users,
+	})
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
@@ -84,6 +94,23 @@ func (h *Inbound) Close() error {
 	)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	authenticator, err := adapter.NewManagedAuthenticator(users)
+	if err != nil {
+		return err
+	}
+	h.users.Store(authenticator)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return h.users.Load().ManagedUsers()
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	err := h.newConnection(ctx, conn, metadata, onClose)
 	N.CloseOnHandshakeFailure(conn, onClose, err)
@@ -111,9 +138,9 @@ func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata ada
 	}
 	switch headerBytes[0] {
 	case socks4.Version, socks5.Version:
-		return socks.HandleConnectionEx(ctx, conn, reader, h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), h.listener, metadata.Source, onClose)
+		return socks.HandleConnectionEx(ctx, conn, reader, h.users.Load().Authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), h.listener, metadata.Source, onClose)
 	default:
-		return http.HandleConnectionEx(ctx, conn, reader, h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
+		return http.HandleConnectionEx(ctx, conn, reader, h.users.Load().Authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -35,6 +36,8 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.NaiveInboundOptions](registry, C.TypeNaive, NewInbound)
 }
 
+var _ adapter.ManagedUserServer = (*Inbound)(nil)
+
 type Inbound struct {
 	inbound.Adapter
 	ctx              context.Context
@@ -43,7 +46,7 @@ type Inbound struct {
 	listener         *listener.Listener
 	network          []string
 	networkIsDefault bool
-	authenticator    *auth.Authenticator
+	users            atomic.Pointer[adapter.ManagedAuthenticator]
 	tlsConfig        tls.ServerConfig
 	httpServer       *http.Server
 	h3Server         io.Closer
@@ -62,8 +65,11 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		}),
 		networkIsDefault: options.Network == "",
 		network:          options.Network.Build(),
-		authenticator:    auth.NewAuthenticator(options.Users),
 	}
+	inbound.users.Store(&adapter.ManagedAuthenticator{
+		Authenticator: auth.NewAuthenticator(options.Users),
+		Users:         options.Users,
+	})
 	if common.Contains(inbound.network, N.NetworkUDP) {
 		if options.TLS == nil || !options.TLS.Enabled {
 			return nil, E.New("TLS is required for QUIC server")
@@ -143,6 +149,23 @@ func (n *Inbound) Close() error {
 	)
 }
 
+func (n *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	n.router = adapter.NewSSMTrackedRouter(n.router, tracker, n.logger)
+}
+
+func (n *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	authenticator, err := adapter.NewManagedAuthenticator(users)
+	if err != nil {
+		return err
+	}
+	n.users.Store(authenticator)
+	return nil
+}
+
+func (n *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return n.users.Load().ManagedUsers()
+}
+
 func (n *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	ctx := log.ContextWithNewID(request.Context())
 	if request.Method != "CONNECT" {
@@ -156,7 +179,7 @@ func (n *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	}
 	userName, password, authOk := sHttp.ParseBasicAuth(request.Header.Get("Proxy-Authorization"))
 	if authOk {
-		authOk = n.authenticator.Verify(userName, password)
+		authOk = n.users.Load().Authenticator.Verify(userName, password)
 	}
 	if !authOk {
 		rejectHTTP(writer, http.StatusProxyAuthRequired)
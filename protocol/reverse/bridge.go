@@ -0,0 +1,191 @@
+package reverse
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/endpoint"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-mux"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func RegisterEndpoint(registry *endpoint.Registry) {
+	endpoint.Register[option.ReverseEndpointOptions](registry, C.TypeReverse, NewEndpoint)
+}
+
+var _ adapter.Endpoint = (*Endpoint)(nil)
+
+// Endpoint is the bridge side of a reverse tunnel. It keeps connections to
+// a portal open and routes the streams the portal opens on them as inbound
+// connections.
+type Endpoint struct {
+	endpoint.Adapter
+	ctx         context.Context
+	cancel      context.CancelFunc
+	router      adapter.ConnectionRouterEx
+	logger      logger.ContextLogger
+	dialer      N.Dialer
+	server      M.Socksaddr
+	username    string
+	password    string
+	connections int
+}
+
+func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ReverseEndpointOptions) (adapter.Endpoint, error) {
+	if options.Server == "" {
+		return nil, E.New("missing server address")
+	}
+	if options.Password == "" {
+		return nil, E.New("missing password")
+	}
+	if len(options.Username) > 255 {
+		return nil, E.New("username too long")
+	}
+	outboundDialer, err := dialer.NewWithOptions(dialer.Options{
+		Context:        ctx,
+		Options:        options.DialerOptions,
+		RemoteIsDomain: options.ServerIsDomain(),
+		Outbound:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	connections := options.Connections
+	if connections <= 0 {
+		connections = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Endpoint{
+		Adapter:     endpoint.NewAdapterWithDialerOptions(C.TypeReverse, tag, nil, options.DialerOptions),
+		ctx:         ctx,
+		cancel:      cancel,
+		router:      router,
+		logger:      logger,
+		dialer:      outboundDialer,
+		server:      options.ServerOptions.Build(),
+		username:    options.Username,
+		password:    options.Password,
+		connections: connections,
+	}, nil
+}
+
+func (e *Endpoint) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStatePostStart {
+		return nil
+	}
+	for range e.connections {
+		go e.loopTunnel()
+	}
+	return nil
+}
+
+func (e *Endpoint) Close() error {
+	e.cancel()
+	return nil
+}
+
+func (e *Endpoint) loopTunnel() {
+	backoff := minBackoff
+	for {
+		established, err := e.runTunnel()
+		if e.ctx.Err() != nil {
+			return
+		}
+		if established {
+			backoff = minBackoff
+		}
+		e.logger.Error(E.Cause(err, "tunnel to ", e.server), ", reconnecting in ", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-e.ctx.Done():
+			return
+		}
+		if !established {
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+func (e *Endpoint) runTunnel() (established bool, err error) {
+	conn, err := e.dialer.DialContext(e.ctx, N.NetworkTCP, e.server)
+	if err != nil {
+		return false, err
+	}
+	conn.SetDeadline(time.Now().Add(C.TCPTimeout))
+	err = clientHandshake(conn, e.username, e.password)
+	if err != nil {
+		conn.Close()
+		return false, E.Cause(err, "handshake")
+	}
+	conn.SetDeadline(time.Time{})
+	e.logger.Info("tunnel established to ", e.server)
+	ctx, cancel := context.WithCancelCause(e.ctx)
+	defer cancel(nil)
+	service, err := mux.NewService(mux.ServiceOptions{
+		NewStreamContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return log.ContextWithNewID(ctx)
+		},
+		Logger:    e.logger,
+		HandlerEx: &bridgeHandler{e, cancel},
+	})
+	if err != nil {
+		conn.Close()
+		return true, err
+	}
+	service.NewConnectionEx(ctx, conn, M.Socksaddr{}, e.server, func(it error) {
+		err = it
+	})
+	if cause := context.Cause(ctx); cause != nil {
+		err = cause
+	} else if err == nil {
+		err = net.ErrClosed
+	}
+	return true, err
+}
+
+func (e *Endpoint) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return nil, os.ErrInvalid
+}
+
+func (e *Endpoint) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
+
+var _ mux.ServiceHandlerEx = (*bridgeHandler)(nil)
+
+type bridgeHandler struct {
+	*Endpoint
+	closeTunnel context.CancelCauseFunc
+}
+
+func (h *bridgeHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	if destination == controlDestination {
+		h.closeTunnel(E.Cause(keepAlive(ctx, conn), "control stream"))
+		return
+	}
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.Tag()
+	metadata.InboundType = h.Type()
+	metadata.Source = source
+	metadata.Destination = destination
+	h.router.RouteConnectionEx(ctx, conn, metadata, onClose)
+}
+
+func (h *bridgeHandler) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.Tag()
+	metadata.InboundType = h.Type()
+	metadata.Source = source
+	metadata.Destination = destination
+	h.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
+}
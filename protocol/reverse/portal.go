@@ -0,0 +1,195 @@
+package reverse
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-mux"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+func RegisterPortal(registry *outbound.Registry) {
+	outbound.Register[option.PortalOutboundOptions](registry, C.TypePortal, NewPortal)
+}
+
+var (
+	_ adapter.Outbound            = (*Portal)(nil)
+	_ adapter.ConnectionHandlerEx = (*Portal)(nil)
+)
+
+// Portal is the public side of a reverse tunnel. Bridge connections routed to
+// it become tunnels, and connections dialed through it are sent to a bridge
+// over one of them.
+type Portal struct {
+	outbound.Adapter
+	connection adapter.ConnectionManager
+	logger     logger.ContextLogger
+	domain     string
+	users      map[string]string
+	protocol   string
+	padding    bool
+	access     sync.Mutex
+	tunnels    []*mux.Client
+	index      int
+}
+
+func NewPortal(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.PortalOutboundOptions) (adapter.Outbound, error) {
+	if options.Domain == "" {
+		return nil, E.New("missing domain")
+	}
+	if len(options.Users) == 0 {
+		return nil, E.New("missing users")
+	}
+	switch options.Protocol {
+	case "", "h2mux", "smux", "yamux":
+	default:
+		return nil, E.New("unknown multiplex protocol: ", options.Protocol)
+	}
+	users := make(map[string]string)
+	for _, user := range options.Users {
+		if len(user.Username) > 255 {
+			return nil, E.New("username too long: ", user.Username)
+		}
+		if user.Password == "" {
+			return nil, E.New("missing password for user: ", user.Username)
+		}
+		users[user.Username] = user.Password
+	}
+	return &Portal{
+		Adapter:    outbound.NewAdapter(C.TypePortal, tag, []string{N.NetworkTCP, N.NetworkUDP}, nil),
+		connection: service.FromContext[adapter.ConnectionManager](ctx),
+		logger:     logger,
+		domain:     options.Domain,
+		users:      users,
+		protocol:   options.Protocol,
+		padding:    options.Padding,
+	}, nil
+}
+
+func (p *Portal) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	tunnel, err := p.selectTunnel()
+	if err != nil {
+		return nil, err
+	}
+	switch N.NetworkName(network) {
+	case N.NetworkTCP:
+		p.logger.InfoContext(ctx, "outbound connection to ", destination)
+	case N.NetworkUDP:
+		p.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+	}
+	return tunnel.DialContext(ctx, network, destination)
+}
+
+func (p *Portal) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	tunnel, err := p.selectTunnel()
+	if err != nil {
+		return nil, err
+	}
+	p.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+	return tunnel.ListenPacket(ctx, destination)
+}
+
+func (p *Portal) selectTunnel() (*mux.Client, error) {
+	p.access.Lock()
+	defer p.access.Unlock()
+	if len(p.tunnels) == 0 {
+		return nil, E.New("no bridge connected")
+	}
+	p.index = (p.index + 1) % len(p.tunnels)
+	return p.tunnels[p.index], nil
+}
+
+// NewConnectionEx accepts connections to the portal domain as bridge
+// connections and keeps each as a tunnel until the bridge stops answering
+// heartbeats. Other connections are dialed through a tunnel.
+func (p *Portal) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	if !metadata.Destination.IsFqdn() || !strings.EqualFold(metadata.Destination.Fqdn, p.domain) {
+		p.connection.NewConnection(ctx, p, conn, metadata, onClose)
+		return
+	}
+	err := p.newConnection(ctx, conn, metadata)
+	conn.Close()
+	if onClose != nil {
+		onClose(err)
+	}
+}
+
+func (p *Portal) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	conn.SetDeadline(time.Now().Add(C.TCPTimeout))
+	username, err := serverHandshake(conn, p.users)
+	if err != nil {
+		p.logger.ErrorContext(ctx, E.Cause(err, "bridge handshake from ", metadata.Source))
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	tunnel, err := mux.NewClient(mux.Options{
+		Dialer:         &tunnelDialer{conn: conn},
+		Logger:         p.logger,
+		Protocol:       p.protocol,
+		MaxConnections: 1,
+		Padding:        p.padding,
+	})
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+	controlConn, err := tunnel.DialContext(ctx, N.NetworkTCP, controlDestination)
+	if err != nil {
+		return E.Cause(err, "open control stream")
+	}
+	p.access.Lock()
+	p.tunnels = append(p.tunnels, tunnel)
+	p.access.Unlock()
+	p.logger.InfoContext(ctx, "bridge ", username, " connected from ", metadata.Source)
+	err = keepAlive(ctx, controlConn)
+	p.access.Lock()
+	p.tunnels = common.Filter(p.tunnels, func(it *mux.Client) bool {
+		return it != tunnel
+	})
+	p.access.Unlock()
+	p.logger.InfoContext(ctx, "bridge ", username, " disconnected: ", err)
+	return err
+}
+
+func (p *Portal) Close() error {
+	p.access.Lock()
+	defer p.access.Unlock()
+	for _, tunnel := range p.tunnels {
+		tunnel.Close()
+	}
+	p.tunnels = nil
+	return nil
+}
+
+// tunnelDialer hands the bridge connection to the multiplex client once, so
+// the client never opens a second session for a tunnel.
+type tunnelDialer struct {
+	conn net.Conn
+}
+
+func (d *tunnelDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if d.conn == nil {
+		return nil, E.New("bridge tunnel closed")
+	}
+	conn := d.conn
+	d.conn = nil
+	return conn, nil
+}
+
+func (d *tunnelDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
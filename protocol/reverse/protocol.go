@@ -0,0 +1,213 @@
+package reverse
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"net"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+const (
+	Version = 0
+
+	statusOK           = 0
+	statusUnauthorized = 1
+
+	nonceLength       = 32
+	heartbeatInterval = 15 * time.Second
+	heartbeatTimeout  = 3 * heartbeatInterval
+	minBackoff        = time.Second
+	maxBackoff        = time.Minute
+)
+
+// controlDestination is the destination of the stream the portal opens on a
+// new tunnel to exchange heartbeats with the bridge.
+var controlDestination = M.Socksaddr{
+	Fqdn: "sp.reverse.sing-box.arpa",
+	Port: 444,
+}
+
+var ErrUnauthorized = E.New("unauthorized")
+
+// The handshake authenticates both sides without sending the password:
+//
+//	bridge -> portal: version, username length, username, bridge nonce
+//	portal -> bridge: portal nonce
+//	bridge -> portal: HMAC("bridge", portal nonce, bridge nonce)
+//	portal -> bridge: status, HMAC("portal", bridge nonce, portal nonce)
+//
+// The bridge proves the password first, so the portal never sends anything
+// derived from it to an unauthenticated peer. Unknown users are answered
+// only after their MAC is read, exactly like a bad password. On success the
+// portal opens a multiplex client session on the connection.
+
+func clientHandshake(conn net.Conn, username string, password string) error {
+	if len(username) > 255 {
+		return E.New("username too long")
+	}
+	bridgeNonce := make([]byte, nonceLength)
+	_, err := rand.Read(bridgeNonce)
+	if err != nil {
+		return err
+	}
+	request := make([]byte, 0, 2+len(username)+nonceLength)
+	request = append(request, Version, byte(len(username)))
+	request = append(request, username...)
+	request = append(request, bridgeNonce...)
+	_, err = conn.Write(request)
+	if err != nil {
+		return err
+	}
+	portalNonce := make([]byte, nonceLength)
+	_, err = io.ReadFull(conn, portalNonce)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(handshakeMAC(password, "bridge", portalNonce, bridgeNonce))
+	if err != nil {
+		return err
+	}
+	err = readStatus(conn)
+	if err != nil {
+		return err
+	}
+	portalMAC := make([]byte, sha256.Size)
+	_, err = io.ReadFull(conn, portalMAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(portalMAC, handshakeMAC(password, "portal", bridgeNonce, portalNonce)) {
+		return E.New("portal authentication failed")
+	}
+	return nil
+}
+
+func serverHandshake(conn net.Conn, users map[string]string) (string, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return "", err
+	}
+	if header[0] != Version {
+		return "", E.New("unknown version: ", header[0])
+	}
+	request := make([]byte, int(header[1])+nonceLength)
+	_, err = io.ReadFull(conn, request)
+	if err != nil {
+		return "", err
+	}
+	username := string(request[:header[1]])
+	bridgeNonce := request[header[1]:]
+	portalNonce := make([]byte, nonceLength)
+	_, err = rand.Read(portalNonce)
+	if err != nil {
+		return "", err
+	}
+	_, err = conn.Write(portalNonce)
+	if err != nil {
+		return "", err
+	}
+	bridgeMAC := make([]byte, sha256.Size)
+	_, err = io.ReadFull(conn, bridgeMAC)
+	if err != nil {
+		return "", err
+	}
+	password, loaded := users[username]
+	if !loaded {
+		// verify against a random key so unknown users take the same path
+		// as a bad password
+		password = string(portalNonce)
+	}
+	if !hmac.Equal(bridgeMAC, handshakeMAC(password, "bridge", portalNonce, bridgeNonce)) || !loaded {
+		conn.Write([]byte{statusUnauthorized})
+		return "", E.Extend(ErrUnauthorized, "authentication failed for user: ", username)
+	}
+	response := make([]byte, 0, 1+sha256.Size)
+	response = append(response, statusOK)
+	response = append(response, handshakeMAC(password, "portal", bridgeNonce, portalNonce)...)
+	_, err = conn.Write(response)
+	if err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func readStatus(reader io.Reader) error {
+	var status [1]byte
+	_, err := io.ReadFull(reader, status[:])
+	if err != nil {
+		return err
+	}
+	switch status[0] {
+	case statusOK:
+		return nil
+	case statusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return E.New("unknown status: ", status[0])
+	}
+}
+
+func handshakeMAC(password string, label string, localNonce []byte, remoteNonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(label))
+	mac.Write(localNonce)
+	mac.Write(remoteNonce)
+	return mac.Sum(nil)
+}
+
+// keepAlive sends a heartbeat on the control stream every heartbeatInterval
+// and fails if the peer stays silent for heartbeatTimeout. The stream is
+// closed on return.
+func keepAlive(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	received := make(chan error)
+	go func() {
+		var heartbeat [1]byte
+		for {
+			_, err := conn.Read(heartbeat[:])
+			select {
+			case received <- err:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(heartbeatTimeout)
+	defer timeout.Stop()
+	_, err := conn.Write([]byte{0})
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case err = <-received:
+			if err != nil {
+				return err
+			}
+			timeout.Reset(heartbeatTimeout)
+		case <-ticker.C:
+			_, err = conn.Write([]byte{0})
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return E.New("heartbeat timeout")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
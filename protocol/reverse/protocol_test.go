@@ -0,0 +1,76 @@
+package reverse
+
+import (
+	"crypto/sha256"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+	users := map[string]string{"sekai": "password"}
+	for _, testCase := range []struct {
+		username string
+		password string
+		success  bool
+	}{
+		{"sekai", "password", true},
+		{"sekai", "wrong", false},
+		{"unknown", "password", false},
+	} {
+		clientConn, serverConn := net.Pipe()
+		serverDone := make(chan error, 1)
+		go func() {
+			username, err := serverHandshake(serverConn, users)
+			if err == nil {
+				require.Equal(t, testCase.username, username)
+			}
+			serverConn.Close()
+			serverDone <- err
+		}()
+		clientErr := clientHandshake(clientConn, testCase.username, testCase.password)
+		clientConn.Close()
+		serverErr := <-serverDone
+		if testCase.success {
+			require.NoError(t, clientErr)
+			require.NoError(t, serverErr)
+		} else {
+			require.Error(t, clientErr)
+			require.Error(t, serverErr)
+		}
+	}
+}
+
+func TestHandshakeRejectWithoutMAC(t *testing.T) {
+	t.Parallel()
+	users := map[string]string{"sekai": "password"}
+	var responses [][]byte
+	for _, username := range []string{"sekai", "unknown"} {
+		clientConn, serverConn := net.Pipe()
+		serverDone := make(chan error, 1)
+		go func() {
+			_, err := serverHandshake(serverConn, users)
+			serverConn.Close()
+			serverDone <- err
+		}()
+		request := append([]byte{Version, byte(len(username))}, username...)
+		request = append(request, make([]byte, nonceLength)...)
+		_, err := clientConn.Write(request)
+		require.NoError(t, err)
+		portalNonce := make([]byte, nonceLength)
+		_, err = io.ReadFull(clientConn, portalNonce)
+		require.NoError(t, err)
+		_, err = clientConn.Write(make([]byte, sha256.Size))
+		require.NoError(t, err)
+		response, err := io.ReadAll(clientConn)
+		require.NoError(t, err)
+		clientConn.Close()
+		require.ErrorIs(t, <-serverDone, ErrUnauthorized)
+		responses = append(responses, response)
+	}
+	require.Equal(t, []byte{statusUnauthorized}, responses[0])
+	require.Equal(t, responses[0], responses[1])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -30,7 +31,7 @@ import (
 
 var (
 	_ adapter.TCPInjectableInbound = (*MultiInbound)(nil)
-	_ adapter.ManagedSSMServer     = (*MultiInbound)(nil)
+	_ adapter.ManagedUserServer    = (*MultiInbound)(nil)
 )
 
 type MultiInbound struct {
@@ -39,9 +40,8 @@ type MultiInbound struct {
 	router   adapter.ConnectionRouterEx
 	logger   logger.ContextLogger
 	listener *listener.Listener
-	service  shadowsocks.MultiService[int]
-	users    []option.ShadowsocksUser
-	tracker  adapter.SSMTracker
+	service  shadowsocks.MultiService[adapter.ManagedUserKey]
+	users    atomic.Pointer[[]option.ShadowsocksUser]
 }
 
 func newMultiInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*MultiInbound, error) {
@@ -62,9 +62,9 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 	} else {
 		udpTimeout = C.UDPTimeout
 	}
-	var service shadowsocks.MultiService[int]
+	var service shadowsocks.MultiService[adapter.ManagedUserKey]
 	if common.Contains(shadowaead_2022.List, options.Method) {
-		service, err = shadowaead_2022.NewMultiServiceWithPassword[int](
+		service, err = shadowaead_2022.NewMultiServiceWithPassword[adapter.ManagedUserKey](
 			options.Method,
 			options.Password,
 			int64(udpTimeout.Seconds()),
@@ -72,7 +72,7 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 			ntp.TimeFuncFromContext(ctx),
 		)
 	} else if common.Contains(shadowaead.List, options.Method) {
-		service, err = shadowaead.NewMultiService[int](
+		service, err = shadowaead.NewMultiService[adapter.ManagedUserKey](
 			options.Method,
 			int64(udpTimeout.Seconds()),
 			adapter.NewUpstreamHandler(adapter.InboundContext{}, inbound.newConnection, inbound.newPacketConnection, inbound),
@@ -84,8 +84,8 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 		return nil, err
 	}
 	if len(options.Users) > 0 {
-		err = service.UpdateUsersWithPasswords(common.MapIndexed(options.Users, func(index int, user option.ShadowsocksUser) int {
-			return index
+		err = service.UpdateUsersWithPasswords(adapter.NewManagedUserKeys(options.Users, func(user option.ShadowsocksUser) string {
+			return user.Name
 		}), common.Map(options.Users, func(user option.ShadowsocksUser) string {
 			return user.Password
 		}))
@@ -94,7 +94,7 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 		}
 	}
 	inbound.service = service
-	inbound.users = options.Users
+	inbound.users.Store(&options.Users)
 	inbound.listener = listener.New(listener.Options{
 		Context:                  ctx,
 		Logger:                   logger,
@@ -119,24 +119,44 @@ func (h *MultiInbound) Close() error {
 }
 
 func (h *MultiInbound) SetTracker(tracker adapter.SSMTracker) {
-	h.tracker = tracker
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
 }
 
-func (h *MultiInbound) UpdateUsers(users []string, uPSKs []string) error {
-	err := h.service.UpdateUsersWithPasswords(common.MapIndexed(users, func(index int, user string) int {
-		return index
-	}), uPSKs)
+func (h *MultiInbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.Password == "" {
+			return E.New("missing uPSK for user ", user.Name)
+		}
+	}
+	// the service authenticates to keys carrying the name, so connections
+	// are credited to the list they were accepted with
+	err := h.service.UpdateUsersWithPasswords(adapter.NewManagedUserKeys(users, func(user adapter.ManagedUser) string {
+		return user.Name
+	}), common.Map(users, func(user adapter.ManagedUser) string {
+		return user.Password
+	}))
 	if err != nil {
 		return err
 	}
-	h.users = common.Map(users, func(user string) option.ShadowsocksUser {
+	newUsers := common.Map(users, func(user adapter.ManagedUser) option.ShadowsocksUser {
 		return option.ShadowsocksUser{
-			Name: user,
+			Name:     user.Name,
+			Password: user.Password,
 		}
 	})
+	h.users.Store(&newUsers)
 	return nil
 }
 
+func (h *MultiInbound) ManagedUsers() []adapter.ManagedUser {
+	return common.Map(*h.users.Load(), func(user option.ShadowsocksUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name:     user.Name,
+			Password: user.Password,
+		}
+	})
+}
+
 //nolint:staticcheck
 func (h *MultiInbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	err := h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
@@ -159,13 +179,13 @@ func (h *MultiInbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
 }
 
 func (h *MultiInbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -176,20 +196,17 @@ func (h *MultiInbound) newConnection(ctx context.Context, conn net.Conn, metadat
 	metadata.InboundDetour = h.listener.ListenOptions().Detour
 	//nolint:staticcheck
 	metadata.InboundOptions = h.listener.ListenOptions().InboundOptions
-	if h.tracker != nil {
-		conn = h.tracker.TrackConnection(conn, metadata)
-	}
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
 func (h *MultiInbound) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -202,9 +219,6 @@ func (h *MultiInbound) newPacketConnection(ctx context.Context, conn N.PacketCon
 	metadata.InboundDetour = h.listener.ListenOptions().Detour
 	//nolint:staticcheck
 	metadata.InboundOptions = h.listener.ListenOptions().InboundOptions
-	if h.tracker != nil {
-		conn = h.tracker.TrackPacketConnection(conn, metadata)
-	}
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
 }
 
@@ -0,0 +1,212 @@
+package shadowsocksr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"io"
+	"net"
+
+	"github.com/sagernet/sing-shadowsocks"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+var cipherList = []string{
+	"none",
+	"aes-128-ctr",
+	"aes-192-ctr",
+	"aes-256-ctr",
+	"aes-128-cfb",
+	"aes-192-cfb",
+	"aes-256-cfb",
+	"rc4-md5",
+	"chacha20-ietf",
+	"xchacha20",
+}
+
+type streamConstructor func(key []byte, iv []byte) (cipher.Stream, error)
+
+// streamCipher is the legacy shadowsocks stream cipher used by SSR, the key
+// and IV are exposed to the obfs and protocol plugins.
+type streamCipher struct {
+	key       []byte
+	ivSize    int
+	encrypter streamConstructor
+	decrypter streamConstructor
+}
+
+func newStreamCipher(method string, password string) (*streamCipher, error) {
+	if password == "" {
+		return nil, shadowsocks.ErrMissingPassword
+	}
+	c := &streamCipher{}
+	var keySize int
+	switch method {
+	case "none", "dummy", "":
+		keySize = 16
+	case "aes-128-ctr":
+		keySize, c.ivSize = 16, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCTR)
+		c.decrypter = blockStream(cipher.NewCTR)
+	case "aes-192-ctr":
+		keySize, c.ivSize = 24, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCTR)
+		c.decrypter = blockStream(cipher.NewCTR)
+	case "aes-256-ctr":
+		keySize, c.ivSize = 32, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCTR)
+		c.decrypter = blockStream(cipher.NewCTR)
+	case "aes-128-cfb":
+		keySize, c.ivSize = 16, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCFBEncrypter)
+		c.decrypter = blockStream(cipher.NewCFBDecrypter)
+	case "aes-192-cfb":
+		keySize, c.ivSize = 24, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCFBEncrypter)
+		c.decrypter = blockStream(cipher.NewCFBDecrypter)
+	case "aes-256-cfb":
+		keySize, c.ivSize = 32, aes.BlockSize
+		c.encrypter = blockStream(cipher.NewCFBEncrypter)
+		c.decrypter = blockStream(cipher.NewCFBDecrypter)
+	case "rc4-md5":
+		keySize, c.ivSize = 16, 16
+		c.encrypter = rc4MD5Stream
+		c.decrypter = rc4MD5Stream
+	case "chacha20-ietf":
+		keySize, c.ivSize = chacha20.KeySize, chacha20.NonceSize
+		c.encrypter = chacha20Stream
+		c.decrypter = chacha20Stream
+	case "xchacha20":
+		keySize, c.ivSize = chacha20.KeySize, chacha20.NonceSizeX
+		c.encrypter = chacha20Stream
+		c.decrypter = chacha20Stream
+	default:
+		return nil, E.New("unsupported method: ", method)
+	}
+	c.key = shadowsocks.Key([]byte(password), keySize)
+	return c, nil
+}
+
+func blockStream(streamCreator func(block cipher.Block, iv []byte) cipher.Stream) streamConstructor {
+	return func(key []byte, iv []byte) (cipher.Stream, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return streamCreator(block, iv), nil
+	}
+}
+
+func rc4MD5Stream(key []byte, iv []byte) (cipher.Stream, error) {
+	h := md5.New()
+	h.Write(key)
+	h.Write(iv)
+	return rc4.NewCipher(h.Sum(nil))
+}
+
+func chacha20Stream(key []byte, iv []byte) (cipher.Stream, error) {
+	return chacha20.NewUnauthenticatedCipher(key, iv)
+}
+
+func (c *streamCipher) newWriteIV() []byte {
+	iv := make([]byte, c.ivSize)
+	_, _ = io.ReadFull(rand.Reader, iv)
+	return iv
+}
+
+// StreamConn encrypts conn with writeIV, which is sent before the first
+// written byte.
+func (c *streamCipher) StreamConn(conn net.Conn, writeIV []byte) net.Conn {
+	if c.encrypter == nil {
+		return conn
+	}
+	return &cipherConn{Conn: conn, streamCipher: c, writeIV: writeIV}
+}
+
+func (c *streamCipher) EncryptPacket(b []byte) ([]byte, error) {
+	if c.encrypter == nil {
+		return b, nil
+	}
+	packet := make([]byte, c.ivSize+len(b))
+	iv := packet[:c.ivSize]
+	_, _ = io.ReadFull(rand.Reader, iv)
+	stream, err := c.encrypter(c.key, iv)
+	if err != nil {
+		return nil, err
+	}
+	stream.XORKeyStream(packet[c.ivSize:], b)
+	return packet, nil
+}
+
+func (c *streamCipher) DecryptPacket(b []byte) ([]byte, error) {
+	if c.encrypter == nil {
+		return b, nil
+	}
+	if len(b) < c.ivSize {
+		return nil, E.New("packet too short")
+	}
+	stream, err := c.decrypter(c.key, b[:c.ivSize])
+	if err != nil {
+		return nil, err
+	}
+	b = b[c.ivSize:]
+	stream.XORKeyStream(b, b)
+	return b, nil
+}
+
+type cipherConn struct {
+	net.Conn
+	*streamCipher
+	writeIV     []byte
+	readIV      []byte
+	readStream  cipher.Stream
+	writeStream cipher.Stream
+}
+
+func (c *cipherConn) Read(p []byte) (int, error) {
+	if c.readStream == nil {
+		iv := make([]byte, c.ivSize)
+		_, err := io.ReadFull(c.Conn, iv)
+		if err != nil {
+			return 0, err
+		}
+		c.readIV = iv
+		c.readStream, err = c.decrypter(c.key, iv)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.Conn.Read(p)
+	c.readStream.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+func (c *cipherConn) Write(p []byte) (int, error) {
+	var payload []byte
+	if c.writeStream == nil {
+		stream, err := c.encrypter(c.key, c.writeIV)
+		if err != nil {
+			return 0, err
+		}
+		c.writeStream = stream
+		payload = make([]byte, len(c.writeIV)+len(p))
+		copy(payload, c.writeIV)
+		stream.XORKeyStream(payload[len(c.writeIV):], p)
+	} else {
+		payload = make([]byte, len(p))
+		c.writeStream.XORKeyStream(payload, p)
+	}
+	_, err := c.Conn.Write(payload)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *cipherConn) Upstream() any {
+	return c.Conn
+}
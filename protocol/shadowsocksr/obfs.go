@@ -0,0 +1,191 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var obfsList = []string{
+	"plain",
+	"http_simple",
+	"http_post",
+	"tls1.2_ticket_auth",
+}
+
+// obfs disguises the encrypted stream, it is not applied to UDP.
+type obfs interface {
+	StreamConn(conn net.Conn) net.Conn
+}
+
+type obfsOptions struct {
+	host   string
+	port   uint16
+	key    []byte
+	ivSize int
+	param  string
+}
+
+// newObfs returns the obfs plugin and its per-packet overhead, which is
+// taken into account by the protocol plugin.
+func newObfs(name string, options obfsOptions) (obfs, int, error) {
+	switch name {
+	case "plain", "":
+		return plainObfs{}, 0, nil
+	case "http_simple":
+		return &httpObfs{obfsOptions: options}, 0, nil
+	case "http_post":
+		return &httpObfs{obfsOptions: options, post: true}, 0, nil
+	case "tls1.2_ticket_auth":
+		return newTLSTicketObfs(options), 5, nil
+	default:
+		return nil, 0, E.New("unsupported obfs: ", name)
+	}
+}
+
+type plainObfs struct{}
+
+func (plainObfs) StreamConn(conn net.Conn) net.Conn {
+	return conn
+}
+
+var httpUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+}
+
+// httpObfs implements http_simple and http_post: the first request carries
+// the head of the stream URL-encoded in the request path, and the first
+// response is prefixed with an HTTP response header.
+type httpObfs struct {
+	obfsOptions
+	post bool
+}
+
+func (o *httpObfs) StreamConn(conn net.Conn) net.Conn {
+	return &httpObfsConn{Conn: conn, httpObfs: o}
+}
+
+type httpObfsConn struct {
+	net.Conn
+	*httpObfs
+	requestSent      bool
+	responseReceived bool
+	pending          []byte
+}
+
+func (c *httpObfsConn) Write(b []byte) (int, error) {
+	if c.requestSent {
+		return c.Conn.Write(b)
+	}
+	headLength := len(b)
+	if headLength-c.ivSize > 64 {
+		headLength = c.ivSize + rand.Intn(64)
+	}
+	host, body := c.hostAndBody()
+	var request bytes.Buffer
+	if c.post {
+		request.WriteString("POST /")
+	} else {
+		request.WriteString("GET /")
+	}
+	for _, byteValue := range b[:headLength] {
+		request.WriteByte('%')
+		request.WriteString(hex.EncodeToString([]byte{byteValue}))
+	}
+	request.WriteString(" HTTP/1.1\r\nHost: ")
+	request.WriteString(host)
+	if c.port != 80 {
+		request.WriteString(":")
+		request.WriteString(strconv.Itoa(int(c.port)))
+	}
+	request.WriteString("\r\n")
+	if body != "" {
+		request.WriteString(body)
+		request.WriteString("\r\n\r\n")
+	} else {
+		request.WriteString("User-Agent: ")
+		request.WriteString(httpUserAgents[rand.Intn(len(httpUserAgents))])
+		request.WriteString("\r\nAccept: text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8\r\nAccept-Language: en-US,en;q=0.8\r\nAccept-Encoding: gzip, deflate\r\n")
+		if c.post {
+			request.WriteString("Content-Type: multipart/form-data; boundary=")
+			const boundaryCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+			for i := 0; i < 32; i++ {
+				request.WriteByte(boundaryCharset[rand.Intn(len(boundaryCharset))])
+			}
+			request.WriteString("\r\n")
+		}
+		request.WriteString("DNT: 1\r\nConnection: keep-alive\r\n\r\n")
+	}
+	request.Write(b[headLength:])
+	_, err := c.Conn.Write(request.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	c.requestSent = true
+	return len(b), nil
+}
+
+// hostAndBody parses obfs_param in the form `host1,host2#Header: value\nHeader: value`.
+func (c *httpObfsConn) hostAndBody() (string, string) {
+	host := c.host
+	var body string
+	if c.param != "" {
+		if index := strings.Index(c.param, "#"); index != -1 {
+			body = c.param[index+1:]
+			body = strings.ReplaceAll(body, "\\n", "\r\n")
+			body = strings.ReplaceAll(body, "\n", "\r\n")
+			body = strings.ReplaceAll(body, "\r\r\n", "\r\n")
+			host = c.param[:index]
+		} else {
+			host = c.param
+		}
+	}
+	hosts := strings.Split(host, ",")
+	return hosts[rand.Intn(len(hosts))], body
+}
+
+func (c *httpObfsConn) Read(b []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	if c.responseReceived {
+		return c.Conn.Read(b)
+	}
+	var response []byte
+	buffer := make([]byte, 4096)
+	for {
+		n, err := c.Conn.Read(buffer)
+		response = append(response, buffer[:n]...)
+		if index := bytes.Index(response, []byte("\r\n\r\n")); index != -1 {
+			c.responseReceived = true
+			c.pending = response[index+4:]
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(response) > 16*1024 {
+			return 0, E.New("http obfs: response header too large")
+		}
+	}
+	if len(c.pending) == 0 {
+		return c.Conn.Read(b)
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *httpObfsConn) Upstream() any {
+	return c.Conn
+}
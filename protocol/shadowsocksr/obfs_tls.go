@@ -0,0 +1,262 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	mRand "math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// tlsTicketObfs implements tls1.2_ticket_auth: the stream is disguised as a
+// TLS 1.2 session resumption, with client data sent as application data
+// records once the fake handshake completes.
+type tlsTicketObfs struct {
+	obfsOptions
+}
+
+func newTLSTicketObfs(options obfsOptions) *tlsTicketObfs {
+	return &tlsTicketObfs{options}
+}
+
+func (o *tlsTicketObfs) StreamConn(conn net.Conn) net.Conn {
+	c := &tlsTicketConn{Conn: conn, tlsTicketObfs: o}
+	_, _ = io.ReadFull(rand.Reader, c.clientID[:])
+	return c
+}
+
+func (o *tlsTicketObfs) serverName() string {
+	host := o.param
+	if host == "" {
+		host = o.host
+	}
+	if host != "" && host[len(host)-1] >= '0' && host[len(host)-1] <= '9' {
+		return ""
+	}
+	hosts := strings.Split(host, ",")
+	return hosts[mRand.Intn(len(hosts))]
+}
+
+func tlsTicketHMAC(key []byte, clientID []byte, data []byte) []byte {
+	hmacKey := make([]byte, 0, len(key)+len(clientID))
+	hmacKey = append(hmacKey, key...)
+	hmacKey = append(hmacKey, clientID...)
+	h := hmac.New(sha1.New, hmacKey)
+	h.Write(data)
+	return h.Sum(nil)[:10]
+}
+
+const (
+	tlsRecordHandshake        = 0x16
+	tlsRecordChangeCipherSpec = 0x14
+	tlsRecordApplicationData  = 0x17
+)
+
+// writeTLSRecords packs data into application data records of random size.
+func writeTLSRecords(buffer *bytes.Buffer, data []byte) {
+	for len(data) > 2048 {
+		size := mRand.Intn(4096) + 100
+		if size > len(data) {
+			size = len(data)
+		}
+		writeTLSRecord(buffer, tlsRecordApplicationData, data[:size])
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		writeTLSRecord(buffer, tlsRecordApplicationData, data)
+	}
+}
+
+func writeTLSRecord(buffer *bytes.Buffer, recordType byte, data []byte) {
+	buffer.Write([]byte{recordType, 3, 3})
+	_ = binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.Write(data)
+}
+
+func readTLSRecord(reader io.Reader) ([]byte, error) {
+	record := make([]byte, 5)
+	_, err := io.ReadFull(reader, record)
+	if err != nil {
+		return nil, err
+	}
+	record = append(record, make([]byte, binary.BigEndian.Uint16(record[3:5]))...)
+	_, err = io.ReadFull(reader, record[5:])
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// writeTLSAuthData writes the 32 bytes random field: timestamp, random bytes
+// and an HMAC proving the knowledge of the key.
+func writeTLSAuthData(buffer *bytes.Buffer, key []byte, clientID []byte) {
+	authData := make([]byte, 22, 32)
+	binary.BigEndian.PutUint32(authData, uint32(time.Now().Unix()))
+	_, _ = io.ReadFull(rand.Reader, authData[4:])
+	buffer.Write(append(authData, tlsTicketHMAC(key, clientID, authData)...))
+}
+
+type tlsTicketConn struct {
+	net.Conn
+	*tlsTicketObfs
+	clientID [32]byte
+
+	access        sync.Mutex
+	helloSent     bool
+	handshakeDone bool
+	pendingWrite  bytes.Buffer
+
+	readBuffer []byte
+}
+
+func (c *tlsTicketConn) Write(b []byte) (int, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.handshakeDone {
+		var buffer bytes.Buffer
+		writeTLSRecords(&buffer, b)
+		_, err := c.Conn.Write(buffer.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	writeTLSRecords(&c.pendingWrite, b)
+	if !c.helloSent {
+		_, err := c.Conn.Write(c.clientHello())
+		if err != nil {
+			return 0, err
+		}
+		c.helloSent = true
+	}
+	return len(b), nil
+}
+
+func (c *tlsTicketConn) clientHello() []byte {
+	var hello bytes.Buffer
+	hello.Write([]byte{3, 3})
+	writeTLSAuthData(&hello, c.key, c.clientID[:])
+	hello.WriteByte(32)
+	hello.Write(c.clientID[:])
+	// cipher suites and compression methods
+	hello.Write([]byte{0x00, 0x1c, 0xc0, 0x2b, 0xc0, 0x2f, 0xcc, 0xa9, 0xcc, 0xa8, 0xcc, 0x14, 0xcc, 0x13, 0xc0, 0x0a, 0xc0, 0x14, 0xc0, 0x09, 0xc0, 0x13, 0x00, 0x9c, 0x00, 0x35, 0x00, 0x2f, 0x00, 0x0a})
+	hello.Write([]byte{0x01, 0x00})
+
+	var extensions bytes.Buffer
+	extensions.Write([]byte{0xff, 0x01, 0x00, 0x01, 0x00})
+	serverName := c.serverName()
+	_ = binary.Write(&extensions, binary.BigEndian, uint16(0))
+	_ = binary.Write(&extensions, binary.BigEndian, uint16(len(serverName)+5))
+	_ = binary.Write(&extensions, binary.BigEndian, uint16(len(serverName)+3))
+	extensions.WriteByte(0)
+	_ = binary.Write(&extensions, binary.BigEndian, uint16(len(serverName)))
+	extensions.WriteString(serverName)
+	extensions.Write([]byte{0x00, 0x17, 0x00, 0x00})
+	ticketLength := 16 * (mRand.Intn(17) + 8)
+	extensions.Write([]byte{0x00, 0x23})
+	_ = binary.Write(&extensions, binary.BigEndian, uint16(ticketLength))
+	ticket := make([]byte, ticketLength)
+	_, _ = io.ReadFull(rand.Reader, ticket)
+	extensions.Write(ticket)
+	extensions.Write([]byte{0x00, 0x0d, 0x00, 0x16, 0x00, 0x14, 0x06, 0x01, 0x06, 0x03, 0x05, 0x01, 0x05, 0x03, 0x04, 0x01, 0x04, 0x03, 0x03, 0x01, 0x03, 0x03, 0x02, 0x01, 0x02, 0x03})
+	extensions.Write([]byte{0x00, 0x05, 0x00, 0x05, 0x01, 0x00, 0x00, 0x00, 0x00})
+	extensions.Write([]byte{0x33, 0x74, 0x00, 0x00})
+	extensions.Write([]byte{0x00, 0x10, 0x00, 0x30, 0x00, 0x2e, 0x02, 0x68, 0x32, 0x05, 0x68, 0x32, 0x2d, 0x31, 0x34, 0x05, 0x68, 0x32, 0x2d, 0x31, 0x35, 0x05, 0x68, 0x32, 0x2d, 0x31, 0x36, 0x08, 0x73, 0x70, 0x64, 0x79, 0x2f, 0x33, 0x2e, 0x31, 0x08, 0x68, 0x74, 0x74, 0x70, 0x2f, 0x31, 0x2e, 0x31})
+	extensions.Write([]byte{0x75, 0x50, 0x00, 0x00})
+	extensions.Write([]byte{0x00, 0x0b, 0x00, 0x02, 0x01, 0x00})
+	extensions.Write([]byte{0x00, 0x0a, 0x00, 0x06, 0x00, 0x04, 0x00, 0x17, 0x00, 0x18})
+	_ = binary.Write(&hello, binary.BigEndian, uint16(extensions.Len()))
+	hello.Write(extensions.Bytes())
+
+	var record bytes.Buffer
+	record.Write([]byte{tlsRecordHandshake, 3, 1})
+	_ = binary.Write(&record, binary.BigEndian, uint16(hello.Len()+4))
+	record.Write([]byte{1, 0})
+	_ = binary.Write(&record, binary.BigEndian, uint16(hello.Len()))
+	record.Write(hello.Bytes())
+	return record.Bytes()
+}
+
+// readServerHello reads the fake ServerHello up to the Finished record and
+// completes the handshake by sending ChangeCipherSpec, Finished and the
+// client data written so far.
+func (c *tlsTicketConn) readServerHello() error {
+	var handshake []byte
+	var changeCipherSpec bool
+	for {
+		record, err := readTLSRecord(c.Conn)
+		if err != nil {
+			return err
+		}
+		handshake = append(handshake, record...)
+		if changeCipherSpec {
+			break
+		}
+		switch record[0] {
+		case tlsRecordChangeCipherSpec:
+			changeCipherSpec = true
+		case tlsRecordHandshake:
+		default:
+			return E.New("tls1.2_ticket_auth: unexpected record type ", record[0])
+		}
+	}
+	if len(handshake) < 43+10 {
+		return E.New("tls1.2_ticket_auth: server hello too short")
+	}
+	if !hmac.Equal(handshake[33:43], tlsTicketHMAC(c.key, c.clientID[:], handshake[11:33])) ||
+		!hmac.Equal(handshake[len(handshake)-10:], tlsTicketHMAC(c.key, c.clientID[:], handshake[:len(handshake)-10])) {
+		return E.New("tls1.2_ticket_auth: server hello verification failed")
+	}
+	c.access.Lock()
+	defer c.access.Unlock()
+	var finished bytes.Buffer
+	finished.Write([]byte{tlsRecordChangeCipherSpec, 3, 3, 0, 1, 1, tlsRecordHandshake, 3, 3, 0, 32})
+	random := make([]byte, 22)
+	_, _ = io.ReadFull(rand.Reader, random)
+	finished.Write(random)
+	finished.Write(tlsTicketHMAC(c.key, c.clientID[:], finished.Bytes()))
+	finished.Write(c.pendingWrite.Bytes())
+	c.pendingWrite.Reset()
+	c.handshakeDone = true
+	_, err := c.Conn.Write(finished.Bytes())
+	return err
+}
+
+func (c *tlsTicketConn) Read(b []byte) (int, error) {
+	if len(c.readBuffer) == 0 {
+		c.access.Lock()
+		handshakeDone := c.handshakeDone
+		c.access.Unlock()
+		if !handshakeDone {
+			err := c.readServerHello()
+			if err != nil {
+				return 0, err
+			}
+		}
+		for len(c.readBuffer) == 0 {
+			record, err := readTLSRecord(c.Conn)
+			if err != nil {
+				return 0, err
+			}
+			if record[0] != tlsRecordApplicationData {
+				return 0, E.New("tls1.2_ticket_auth: unexpected record type ", record[0])
+			}
+			c.readBuffer = record[5:]
+		}
+	}
+	n := copy(b, c.readBuffer)
+	c.readBuffer = c.readBuffer[n:]
+	return n, nil
+}
+
+func (c *tlsTicketConn) Upstream() any {
+	return c.Conn
+}
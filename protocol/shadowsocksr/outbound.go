@@ -0,0 +1,138 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func RegisterOutbound(registry *outbound.Registry) {
+	outbound.Register[option.ShadowsocksROutboundOptions](registry, C.TypeShadowsocksR, NewOutbound)
+}
+
+var _ adapter.Outbound = (*Outbound)(nil)
+
+type Outbound struct {
+	outbound.Adapter
+	logger     logger.ContextLogger
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	cipher     *streamCipher
+	obfs       obfs
+	protocol   protocol
+}
+
+func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksROutboundOptions) (adapter.Outbound, error) {
+	streamCipher, err := newStreamCipher(options.Method, options.Password)
+	if err != nil {
+		return nil, err
+	}
+	serverAddr := options.ServerOptions.Build()
+	obfs, overhead, err := newObfs(options.Obfs, obfsOptions{
+		host:   serverAddr.AddrString(),
+		port:   serverAddr.Port,
+		key:    streamCipher.key,
+		ivSize: streamCipher.ivSize,
+		param:  options.ObfsParam,
+	})
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := newProtocol(options.Protocol, protocolOptions{
+		key:      streamCipher.key,
+		overhead: overhead,
+		param:    options.ProtocolParam,
+	})
+	if err != nil {
+		return nil, err
+	}
+	outboundDialer, err := dialer.New(ctx, options.DialerOptions, options.ServerIsDomain())
+	if err != nil {
+		return nil, err
+	}
+	return &Outbound{
+		Adapter:    outbound.NewAdapterWithDialerOptions(C.TypeShadowsocksR, tag, options.Network.Build(), options.DialerOptions),
+		logger:     logger,
+		dialer:     outboundDialer,
+		serverAddr: serverAddr,
+		cipher:     streamCipher,
+		obfs:       obfs,
+		protocol:   protocol,
+	}, nil
+}
+
+func (h *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	switch N.NetworkName(network) {
+	case N.NetworkTCP:
+		h.logger.InfoContext(ctx, "outbound connection to ", destination)
+		outConn, err := h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := h.streamConn(outConn, destination)
+		if err != nil {
+			outConn.Close()
+			return nil, err
+		}
+		return conn, nil
+	case N.NetworkUDP:
+		h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+		packetConn, err := h.listenPacket(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewBindPacketConn(packetConn, destination), nil
+	default:
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+}
+
+func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+	return h.listenPacket(ctx)
+}
+
+// streamConn stacks obfs, cipher and protocol on conn, and sends the target
+// address.
+func (h *Outbound) streamConn(conn net.Conn, destination M.Socksaddr) (net.Conn, error) {
+	conn = h.obfs.StreamConn(conn)
+	iv := h.cipher.newWriteIV()
+	conn = h.cipher.StreamConn(conn, iv)
+	conn = newProtocolConn(conn, h.protocol.NewStream(iv))
+	var request bytes.Buffer
+	err := M.SocksaddrSerializer.WriteAddrPort(&request, destination)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(request.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (h *Outbound) listenPacket(ctx context.Context) (net.PacketConn, error) {
+	outConn, err := h.dialer.DialContext(ctx, N.NetworkUDP, h.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &packetConn{Conn: outConn, cipher: h.cipher, protocol: h.protocol}, nil
+}
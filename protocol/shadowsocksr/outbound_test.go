@@ -0,0 +1,131 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func newTestOutbound(t *testing.T, serverAddr M.Socksaddr, method string, password string, obfsName string, obfsParam string, protocolName string, protocolParam string) *Outbound {
+	streamCipher, err := newStreamCipher(method, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obfs, overhead, err := newObfs(obfsName, obfsOptions{
+		host:   serverAddr.AddrString(),
+		port:   serverAddr.Port,
+		key:    streamCipher.key,
+		ivSize: streamCipher.ivSize,
+		param:  obfsParam,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocol, err := newProtocol(protocolName, protocolOptions{key: streamCipher.key, overhead: overhead, param: protocolParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Outbound{serverAddr: serverAddr, cipher: streamCipher, obfs: obfs, protocol: protocol}
+}
+
+func testTCPRoundTrip(t *testing.T, outbound *Outbound) {
+	tcpConn, err := net.Dial("tcp", outbound.serverAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := outbound.streamConn(tcpConn, M.ParseSocksaddrHostPort("example.com", 443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, size := range []int{1, 300, 1500, 9000, 40000} {
+		payload := make([]byte, size)
+		_, _ = io.ReadFull(rand.Reader, payload)
+		_, err = conn.Write(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := make([]byte, size)
+		_, err = io.ReadFull(conn, response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payload, response) {
+			t.Fatalf("TCP payload of %d bytes mismatch", size)
+		}
+	}
+}
+
+func testUDPRoundTrip(t *testing.T, outbound *Outbound, serverAddr net.Addr) {
+	udpConn, err := net.Dial("udp", serverAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &packetConn{Conn: udpConn, cipher: outbound.cipher, protocol: outbound.protocol}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	destination := M.ParseSocksaddrHostPort("1.1.1.1", 53)
+	for _, size := range []int{1, 512, 1400} {
+		payload := make([]byte, size)
+		_, _ = io.ReadFull(rand.Reader, payload)
+		_, err = conn.WriteTo(payload, destination.UDPAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := make([]byte, 2048)
+		n, addr, err := conn.ReadFrom(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if M.SocksaddrFromNet(addr) != destination {
+			t.Fatalf("unexpected source %s", addr)
+		}
+		if !bytes.Equal(payload, response[:n]) {
+			t.Fatalf("UDP payload of %d bytes mismatch", size)
+		}
+	}
+}
+
+func TestShadowsocksR(t *testing.T) {
+	const password = "password"
+	for _, protocolName := range protocolList {
+		for _, obfsName := range obfsList {
+			t.Run(protocolName+"/"+obfsName, func(t *testing.T) {
+				server := newTestServer(t, "aes-256-cfb", password, obfsName, protocolName, "")
+				outbound := newTestOutbound(t, server.Addr(), "aes-256-cfb", password, obfsName, "", protocolName, "")
+				testTCPRoundTrip(t, outbound)
+				testUDPRoundTrip(t, outbound, server.packetConn.LocalAddr())
+			})
+		}
+	}
+}
+
+func TestShadowsocksRCipher(t *testing.T) {
+	const password = "password"
+	for _, method := range cipherList {
+		t.Run(method, func(t *testing.T) {
+			server := newTestServer(t, method, password, "tls1.2_ticket_auth", "auth_chain_a", "")
+			outbound := newTestOutbound(t, server.Addr(), method, password, "tls1.2_ticket_auth", "", "auth_chain_a", "")
+			testTCPRoundTrip(t, outbound)
+			testUDPRoundTrip(t, outbound, server.packetConn.LocalAddr())
+		})
+	}
+}
+
+func TestShadowsocksRProtocolParam(t *testing.T) {
+	const password = "password"
+	for _, protocolName := range []string{"auth_aes128_md5", "auth_aes128_sha1", "auth_chain_a"} {
+		t.Run(protocolName, func(t *testing.T) {
+			server := newTestServer(t, "chacha20-ietf", password, "http_simple", protocolName, "1024:user-password")
+			outbound := newTestOutbound(t, server.Addr(), "chacha20-ietf", password, "http_simple", "cloudflare.com#User-Agent: curl\\nAccept: */*", protocolName, "1024:user-password")
+			testTCPRoundTrip(t, outbound)
+			testUDPRoundTrip(t, outbound, server.packetConn.LocalAddr())
+		})
+	}
+}
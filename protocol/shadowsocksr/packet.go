@@ -0,0 +1,75 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"net"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var _ net.PacketConn = (*packetConn)(nil)
+
+// packetConn sends each packet as the protocol encoded SOCKS address and
+// payload, encrypted with a fresh IV.
+type packetConn struct {
+	net.Conn
+	cipher   *streamCipher
+	protocol protocol
+}
+
+func (c *packetConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	packet := make([]byte, 65535)
+	for {
+		n, err := c.Conn.Read(packet)
+		if err != nil {
+			return 0, nil, err
+		}
+		// Datagrams that fail to decrypt or authenticate are dropped like
+		// the reference client does, without breaking the association.
+		payload, err := c.cipher.DecryptPacket(packet[:n])
+		if err != nil {
+			continue
+		}
+		payload, err = c.protocol.DecodePacket(payload)
+		if err != nil {
+			continue
+		}
+		reader := bytes.NewReader(payload)
+		source, err := M.SocksaddrSerializer.ReadAddrPort(reader)
+		if err != nil {
+			continue
+		}
+		n, _ = reader.Read(p)
+		if source.IsFqdn() {
+			return n, source, nil
+		}
+		return n, source.UDPAddr(), nil
+	}
+}
+
+func (c *packetConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	destination := M.SocksaddrFromNet(addr)
+	var buffer bytes.Buffer
+	err := M.SocksaddrSerializer.WriteAddrPort(&buffer, destination)
+	if err != nil {
+		return 0, err
+	}
+	buffer.Write(p)
+	payload, err := c.protocol.EncodePacket(buffer.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	payload, err = c.cipher.EncryptPacket(payload)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.Conn.Write(payload)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *packetConn) Upstream() any {
+	return c.Conn
+}
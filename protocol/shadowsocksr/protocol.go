@@ -0,0 +1,254 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	mRand "math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-shadowsocks"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var protocolList = []string{
+	"origin",
+	"auth_aes128_md5",
+	"auth_aes128_sha1",
+	"auth_chain_a",
+}
+
+// protocol authenticates and frames the plaintext before encryption.
+type protocol interface {
+	NewStream(iv []byte) protocolStream
+	EncodePacket(b []byte) ([]byte, error)
+	DecodePacket(b []byte) ([]byte, error)
+}
+
+type protocolStream interface {
+	Encode(buffer *bytes.Buffer, b []byte) error
+	// Decode moves the complete frames in src to dst.
+	Decode(dst *bytes.Buffer, src *bytes.Buffer) error
+}
+
+type protocolOptions struct {
+	key      []byte
+	overhead int
+	param    string
+}
+
+func newProtocol(name string, options protocolOptions) (protocol, error) {
+	switch name {
+	case "origin", "":
+		return originProtocol{}, nil
+	case "auth_aes128_md5":
+		return newAuthAES128(options, "auth_aes128_md5", md5.New), nil
+	case "auth_aes128_sha1":
+		return newAuthAES128(options, "auth_aes128_sha1", sha1.New), nil
+	case "auth_chain_a":
+		return newAuthChainA(options), nil
+	default:
+		return nil, E.New("unsupported protocol: ", name)
+	}
+}
+
+type originProtocol struct{}
+
+func (originProtocol) NewStream(iv []byte) protocolStream {
+	return originProtocol{}
+}
+
+func (originProtocol) Encode(buffer *bytes.Buffer, b []byte) error {
+	buffer.Write(b)
+	return nil
+}
+
+func (originProtocol) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	_, err := dst.ReadFrom(src)
+	return err
+}
+
+func (originProtocol) EncodePacket(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+func (originProtocol) DecodePacket(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+// maxFrameSize bounds the undecoded data held for a partial frame: an
+// auth_aes128 frame is shorter than 8192 bytes and an auth_chain_a frame
+// shorter than 4096 plus its 4 bytes of header and mac.
+const maxFrameSize = 8192
+
+type protocolConn struct {
+	net.Conn
+	stream     protocolStream
+	access     sync.Mutex
+	readBuffer []byte
+	raw        bytes.Buffer
+	decoded    bytes.Buffer
+}
+
+func newProtocolConn(conn net.Conn, stream protocolStream) *protocolConn {
+	return &protocolConn{Conn: conn, stream: stream}
+}
+
+func (c *protocolConn) Read(b []byte) (int, error) {
+	if c.decoded.Len() > 0 {
+		return c.decoded.Read(b)
+	}
+	if c.readBuffer == nil {
+		c.readBuffer = make([]byte, 16*1024)
+	}
+	for c.decoded.Len() == 0 {
+		n, err := c.Conn.Read(c.readBuffer)
+		c.raw.Write(c.readBuffer[:n])
+		if c.raw.Len() > 0 {
+			decodeErr := c.stream.Decode(&c.decoded, &c.raw)
+			if decodeErr != nil {
+				return 0, decodeErr
+			}
+			if c.raw.Len() > maxFrameSize {
+				return 0, E.New("frame too large: ", c.raw.Len())
+			}
+		}
+		if err != nil {
+			if c.decoded.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	return c.decoded.Read(b)
+}
+
+func (c *protocolConn) Write(b []byte) (int, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	var buffer bytes.Buffer
+	err := c.stream.Encode(&buffer, b)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *protocolConn) Upstream() any {
+	return c.Conn
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func randomBytes(buffer *bytes.Buffer, n int) {
+	if n <= 0 {
+		return
+	}
+	random := make([]byte, n)
+	_, _ = io.ReadFull(rand.Reader, random)
+	buffer.Write(random)
+}
+
+// headSize returns the size of the SOCKS address at the head of b.
+func headSize(b []byte, defaultValue int) int {
+	if len(b) < 2 {
+		return defaultValue
+	}
+	switch b[0] & 7 {
+	case 1:
+		return 7
+	case 4:
+		return 19
+	case 3:
+		return 4 + int(b[1])
+	}
+	return defaultValue
+}
+
+// firstPacketLength returns how much data is carried by the auth packet: the
+// target address plus some random bytes of the payload.
+func firstPacketLength(b []byte) int {
+	length := headSize(b, 30) + mRand.Intn(32)
+	if len(b) < length {
+		return len(b)
+	}
+	return length
+}
+
+// authUser is parsed from protocol_param `uid:password`; without it a random
+// uid and the cipher key are used.
+type authUser struct {
+	userID  [4]byte
+	userKey []byte
+}
+
+func parseAuthUser(param string, key []byte, hashKey func([]byte) []byte) authUser {
+	var user authUser
+	items := strings.SplitN(param, ":", 2)
+	if len(items) == 2 {
+		if userID, err := strconv.ParseUint(items[0], 10, 32); err == nil {
+			binary.LittleEndian.PutUint32(user.userID[:], uint32(userID))
+			user.userKey = hashKey([]byte(items[1]))
+		}
+	}
+	if len(user.userKey) == 0 {
+		user.userKey = key
+		_, _ = io.ReadFull(rand.Reader, user.userID[:])
+	}
+	return user
+}
+
+// authConnectionID hands out the client id and the increasing connection id
+// sent in the auth packet, which the server uses to reject replays.
+type authConnectionID struct {
+	access       sync.Mutex
+	clientID     [4]byte
+	connectionID uint32
+}
+
+func (a *authConnectionID) Next() ([4]byte, uint32) {
+	a.access.Lock()
+	defer a.access.Unlock()
+	if a.connectionID == 0 || a.connectionID > 0xff000000 {
+		_, _ = io.ReadFull(rand.Reader, a.clientID[:])
+		a.connectionID = mRand.Uint32() & 0xffffff
+	}
+	a.connectionID++
+	return a.clientID, a.connectionID
+}
+
+// authEncryptedData returns the AES-CBC encrypted auth block: timestamp,
+// client id, connection id and two protocol specific values.
+func authEncryptedData(userKey []byte, salt string, timestamp uint32, clientID [4]byte, connectionID uint32, value0 int, value1 int) ([]byte, error) {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint32(data, timestamp)
+	copy(data[4:], clientID[:])
+	binary.LittleEndian.PutUint32(data[8:], connectionID)
+	binary.LittleEndian.PutUint16(data[12:], uint16(value0))
+	binary.LittleEndian.PutUint16(data[14:], uint16(value1))
+	block, err := aes.NewCipher(shadowsocks.Key([]byte(base64.StdEncoding.EncodeToString(userKey)+salt), 16))
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, make([]byte, 16)).CryptBlocks(data, data)
+	return data, nil
+}
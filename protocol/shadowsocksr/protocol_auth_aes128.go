@@ -0,0 +1,232 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+	"math"
+	mRand "math/rand"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// authAES128 implements auth_aes128_md5 and auth_aes128_sha1.
+type authAES128 struct {
+	protocolOptions
+	authUser
+	salt         string
+	hash         func() hash.Hash
+	connectionID authConnectionID
+}
+
+func newAuthAES128(options protocolOptions, salt string, hashFunc func() hash.Hash) *authAES128 {
+	return &authAES128{
+		protocolOptions: options,
+		authUser: parseAuthUser(options.param, options.key, func(password []byte) []byte {
+			h := hashFunc()
+			h.Write(password)
+			return h.Sum(nil)
+		}),
+		salt: salt,
+		hash: hashFunc,
+	}
+}
+
+func (p *authAES128) NewStream(iv []byte) protocolStream {
+	clientID, connectionID := p.connectionID.Next()
+	return &authAES128Stream{
+		authAES128:   p,
+		iv:           iv,
+		clientID:     clientID,
+		connectionID: connectionID,
+		packID:       1,
+		recvID:       1,
+	}
+}
+
+func (p *authAES128) EncodePacket(b []byte) ([]byte, error) {
+	packet := make([]byte, 0, len(b)+8)
+	packet = append(packet, b...)
+	packet = append(packet, p.userID[:]...)
+	return append(packet, hmacSum(p.hash, p.userKey, packet)[:4]...), nil
+}
+
+func (p *authAES128) DecodePacket(b []byte) ([]byte, error) {
+	if len(b) < 4 {
+		return nil, E.New(p.salt, ": packet too short")
+	}
+	if !hmac.Equal(hmacSum(p.hash, p.key, b[:len(b)-4])[:4], b[len(b)-4:]) {
+		return nil, E.New(p.salt, ": packet checksum mismatch")
+	}
+	return b[:len(b)-4], nil
+}
+
+type authAES128Stream struct {
+	*authAES128
+	iv           []byte
+	clientID     [4]byte
+	connectionID uint32
+	headerSent   bool
+	packID       uint32
+	recvID       uint32
+	rawTrim      bool
+}
+
+func (s *authAES128Stream) Encode(buffer *bytes.Buffer, b []byte) error {
+	fullLength := len(b)
+	if !s.headerSent {
+		length := firstPacketLength(b)
+		err := s.packAuthData(buffer, b[:length])
+		if err != nil {
+			return err
+		}
+		b = b[length:]
+		s.headerSent = true
+	}
+	for len(b) > 8100 {
+		s.packData(buffer, b[:8100], fullLength)
+		b = b[8100:]
+	}
+	if len(b) > 0 {
+		s.packData(buffer, b, fullLength)
+	}
+	return nil
+}
+
+func (s *authAES128Stream) macKey(id uint32) []byte {
+	key := make([]byte, len(s.userKey)+4)
+	copy(key, s.userKey)
+	binary.LittleEndian.PutUint32(key[len(s.userKey):], id)
+	return key
+}
+
+func (s *authAES128Stream) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	if s.rawTrim {
+		_, err := dst.ReadFrom(src)
+		return err
+	}
+	for src.Len() > 4 {
+		packet := src.Bytes()
+		macKey := s.macKey(s.recvID)
+		if !hmac.Equal(hmacSum(s.hash, macKey, packet[:2])[:2], packet[2:4]) {
+			src.Reset()
+			return E.New(s.salt, ": length checksum mismatch")
+		}
+		length := int(binary.LittleEndian.Uint16(packet))
+		if length >= 8192 || length < 7 {
+			s.rawTrim = true
+			src.Reset()
+			return E.New(s.salt, ": invalid length ", length)
+		}
+		if length > len(packet) {
+			break
+		}
+		if !hmac.Equal(hmacSum(s.hash, macKey, packet[:length-4])[:4], packet[length-4:length]) {
+			s.rawTrim = true
+			src.Reset()
+			return E.New(s.salt, ": checksum mismatch")
+		}
+		s.recvID++
+		position := int(packet[4])
+		if position < 255 {
+			position += 4
+		} else {
+			position = int(binary.LittleEndian.Uint16(packet[5:7])) + 4
+		}
+		if position > length-4 {
+			src.Reset()
+			return E.New(s.salt, ": invalid padding")
+		}
+		dst.Write(packet[position : length-4])
+		src.Next(length)
+	}
+	return nil
+}
+
+// packData writes a data packet: length, length HMAC, random padding, data
+// and the packet HMAC.
+func (s *authAES128Stream) packData(buffer *bytes.Buffer, data []byte, fullLength int) {
+	randomLength := s.randomDataLength(len(data), fullLength)
+	packetLength := 2 + 2 + len(data) + randomLength + 4
+	if randomLength < 128 {
+		packetLength++
+	} else {
+		packetLength += 3
+	}
+	macKey := s.macKey(s.packID)
+	s.packID++
+	start := buffer.Len()
+	_ = binary.Write(buffer, binary.LittleEndian, uint16(packetLength))
+	buffer.Write(hmacSum(s.hash, macKey, buffer.Bytes()[start:])[:2])
+	if randomLength < 128 {
+		buffer.WriteByte(byte(randomLength + 1))
+	} else {
+		buffer.WriteByte(255)
+		_ = binary.Write(buffer, binary.LittleEndian, uint16(randomLength+3))
+	}
+	randomBytes(buffer, randomLength)
+	buffer.Write(data)
+	buffer.Write(hmacSum(s.hash, macKey, buffer.Bytes()[start:])[:4])
+}
+
+func (s *authAES128Stream) randomDataLength(length int, fullLength int) int {
+	if fullLength >= 32*1024-s.overhead {
+		return 0
+	}
+	const tcpMSS = 1460
+	reverseLength := tcpMSS - length - 9
+	if reverseLength == 0 {
+		return 0
+	}
+	if reverseLength < 0 {
+		if reverseLength > -tcpMSS {
+			return trapezoidRandom(reverseLength+tcpMSS, -0.3)
+		}
+		return mRand.Intn(32)
+	}
+	if length > 900 {
+		return mRand.Intn(reverseLength)
+	}
+	return trapezoidRandom(reverseLength, -0.3)
+}
+
+func trapezoidRandom(max int, d float64) int {
+	base := mRand.Float64()
+	if d-0 > 1e-6 || d-0 < -1e-6 {
+		a := 1 - d
+		base = (math.Sqrt(a*a+4*d*base) - a) / (2 * d)
+	}
+	return int(base * float64(max))
+}
+
+// packAuthData writes the auth packet that opens the stream, it carries the
+// target address and the first bytes of the payload.
+func (s *authAES128Stream) packAuthData(buffer *bytes.Buffer, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	randomLength := mRand.Intn(1024)
+	if len(data) > 400 {
+		randomLength = mRand.Intn(512)
+	}
+	packetLength := 7 + 4 + 16 + 4 + randomLength + len(data) + 4
+	encrypted, err := authEncryptedData(s.userKey, s.salt, uint32(time.Now().Unix()), s.clientID, s.connectionID, packetLength, randomLength)
+	if err != nil {
+		return err
+	}
+	macKey := make([]byte, 0, len(s.iv)+len(s.key))
+	macKey = append(macKey, s.iv...)
+	macKey = append(macKey, s.key...)
+	start := buffer.Len()
+	randomBytes(buffer, 1)
+	buffer.Write(hmacSum(s.hash, macKey, buffer.Bytes()[start:])[:6])
+	buffer.Write(s.userID[:])
+	buffer.Write(encrypted)
+	buffer.Write(hmacSum(s.hash, macKey, buffer.Bytes()[start+7:])[:4])
+	randomBytes(buffer, randomLength)
+	buffer.Write(data)
+	buffer.Write(hmacSum(s.hash, s.userKey, buffer.Bytes()[start:])[:4])
+	return nil
+}
@@ -0,0 +1,292 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/sagernet/sing-shadowsocks"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const authChainSalt = "auth_chain_a"
+
+// authChainA implements auth_chain_a: every packet is RC4 encrypted with a
+// key derived from the auth packet, and both its length and padding are
+// derived from the HMAC of the previous packet.
+type authChainA struct {
+	protocolOptions
+	authUser
+	connectionID authConnectionID
+}
+
+func newAuthChainA(options protocolOptions) *authChainA {
+	return &authChainA{
+		protocolOptions: options,
+		authUser: parseAuthUser(options.param, options.key, func(password []byte) []byte {
+			return password
+		}),
+	}
+}
+
+func (p *authChainA) NewStream(iv []byte) protocolStream {
+	clientID, connectionID := p.connectionID.Next()
+	return &authChainStream{
+		authChainA:   p,
+		iv:           iv,
+		clientID:     clientID,
+		connectionID: connectionID,
+		send:         authChainDirection{id: 1},
+		receive:      authChainDirection{id: 1},
+	}
+}
+
+func (p *authChainA) EncodePacket(b []byte) ([]byte, error) {
+	authData := make([]byte, 3)
+	_, _ = io.ReadFull(rand.Reader, authData)
+	md5Data := hmacSum(md5.New, p.key, authData)
+	var random shift128Plus
+	random.InitFromBin(md5Data)
+	randomLength := int(random.Next() % 127)
+	stream, err := authChainRC4(p.userKey, md5Data)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	encrypted := make([]byte, len(b))
+	stream.XORKeyStream(encrypted, b)
+	buffer.Write(encrypted)
+	randomBytes(&buffer, randomLength)
+	buffer.Write(authData)
+	_ = binary.Write(&buffer, binary.LittleEndian, binary.LittleEndian.Uint32(p.userID[:])^binary.LittleEndian.Uint32(md5Data))
+	buffer.Write(hmacSum(md5.New, p.userKey, buffer.Bytes())[:1])
+	return buffer.Bytes(), nil
+}
+
+func (p *authChainA) DecodePacket(b []byte) ([]byte, error) {
+	if len(b) < 9 {
+		return nil, E.New(authChainSalt, ": packet too short")
+	}
+	if !hmac.Equal(hmacSum(md5.New, p.userKey, b[:len(b)-1])[:1], b[len(b)-1:]) {
+		return nil, E.New(authChainSalt, ": packet checksum mismatch")
+	}
+	md5Data := hmacSum(md5.New, p.key, b[len(b)-8:len(b)-1])
+	var random shift128Plus
+	random.InitFromBin(md5Data)
+	randomLength := int(random.Next() % 127)
+	if len(b) < 8+randomLength {
+		return nil, E.New(authChainSalt, ": packet too short")
+	}
+	stream, err := authChainRC4(p.userKey, md5Data)
+	if err != nil {
+		return nil, err
+	}
+	b = b[:len(b)-8-randomLength]
+	stream.XORKeyStream(b, b)
+	return b, nil
+}
+
+func authChainRC4(userKey []byte, hash []byte) (cipher.Stream, error) {
+	return rc4.NewCipher(shadowsocks.Key([]byte(base64.StdEncoding.EncodeToString(userKey)+base64.StdEncoding.EncodeToString(hash)), 16))
+}
+
+// authChainDirection is the state of one direction of a stream.
+type authChainDirection struct {
+	hash   []byte
+	random shift128Plus
+	id     uint32
+	stream cipher.Stream
+}
+
+type authChainStream struct {
+	*authChainA
+	iv           []byte
+	clientID     [4]byte
+	connectionID uint32
+	headerSent   bool
+	rawTrim      bool
+	send         authChainDirection
+	receive      authChainDirection
+}
+
+func (s *authChainStream) Encode(buffer *bytes.Buffer, b []byte) error {
+	if !s.headerSent {
+		length := firstPacketLength(b)
+		err := s.packAuthData(buffer, b[:length])
+		if err != nil {
+			return err
+		}
+		b = b[length:]
+		s.headerSent = true
+	}
+	for len(b) > 2800 {
+		s.packData(buffer, b[:2800])
+		b = b[2800:]
+	}
+	if len(b) > 0 {
+		s.packData(buffer, b)
+	}
+	return nil
+}
+
+func (s *authChainStream) macKey(id uint32) []byte {
+	key := make([]byte, len(s.userKey)+4)
+	copy(key, s.userKey)
+	binary.LittleEndian.PutUint32(key[len(s.userKey):], id)
+	return key
+}
+
+func (s *authChainStream) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	if s.rawTrim {
+		_, err := dst.ReadFrom(src)
+		return err
+	}
+	for src.Len() > 4 {
+		packet := src.Bytes()
+		dataLength := int(binary.LittleEndian.Uint16(packet) ^ binary.LittleEndian.Uint16(s.receive.hash[14:16]))
+		randomLength := authChainRandomLength(dataLength, s.receive.hash, &s.receive.random)
+		length := dataLength + randomLength
+		if length >= 4096 {
+			s.rawTrim = true
+			src.Reset()
+			return E.New(authChainSalt, ": invalid length ", length)
+		}
+		if length+4 > len(packet) {
+			break
+		}
+		hash := hmacSum(md5.New, s.macKey(s.receive.id), packet[:length+2])
+		if !hmac.Equal(hash[:2], packet[length+2:length+4]) {
+			s.rawTrim = true
+			src.Reset()
+			return E.New(authChainSalt, ": checksum mismatch")
+		}
+		s.receive.hash = hash
+		position := 2
+		if dataLength > 0 && randomLength > 0 {
+			position += authChainRandomStart(randomLength, &s.receive.random)
+		}
+		data := make([]byte, dataLength)
+		s.receive.stream.XORKeyStream(data, packet[position:position+dataLength])
+		if s.receive.id == 1 {
+			// the first packet from the server starts with its TCP MSS
+			if len(data) < 2 {
+				src.Reset()
+				return E.New(authChainSalt, ": invalid first packet")
+			}
+			data = data[2:]
+		}
+		dst.Write(data)
+		s.receive.id++
+		src.Next(length + 4)
+	}
+	return nil
+}
+
+// packAuthData writes the auth packet and derives the RC4 keys and the
+// initial hashes of both directions.
+func (s *authChainStream) packAuthData(buffer *bytes.Buffer, data []byte) error {
+	macKey := make([]byte, 0, len(s.iv)+len(s.key))
+	macKey = append(macKey, s.iv...)
+	macKey = append(macKey, s.key...)
+	start := buffer.Len()
+	randomBytes(buffer, 4)
+	s.send.hash = hmacSum(md5.New, macKey, buffer.Bytes()[start:])
+	stream, err := authChainRC4(s.userKey, s.send.hash)
+	if err != nil {
+		return err
+	}
+	s.send.stream = stream
+	s.receive.stream, _ = authChainRC4(s.userKey, s.send.hash)
+	buffer.Write(s.send.hash[:8])
+	_ = binary.Write(buffer, binary.LittleEndian, binary.LittleEndian.Uint32(s.userID[:])^binary.LittleEndian.Uint32(s.send.hash[8:12]))
+	encrypted, err := authEncryptedData(s.userKey, authChainSalt, uint32(time.Now().Unix()), s.clientID, s.connectionID, s.overhead, 0)
+	if err != nil {
+		return err
+	}
+	buffer.Write(encrypted)
+	s.receive.hash = hmacSum(md5.New, s.userKey, buffer.Bytes()[start+12:])
+	buffer.Write(s.receive.hash[:4])
+	s.packData(buffer, data)
+	return nil
+}
+
+func (s *authChainStream) packData(buffer *bytes.Buffer, data []byte) {
+	encrypted := make([]byte, len(data))
+	s.send.stream.XORKeyStream(encrypted, data)
+	macKey := s.macKey(s.send.id)
+	s.send.id++
+	start := buffer.Len()
+	_ = binary.Write(buffer, binary.LittleEndian, uint16(len(data))^binary.LittleEndian.Uint16(s.send.hash[14:16]))
+	randomLength := authChainRandomLength(len(data), s.send.hash, &s.send.random)
+	if len(data) > 0 && randomLength > 0 {
+		randomStart := authChainRandomStart(randomLength, &s.send.random)
+		randomBytes(buffer, randomStart)
+		buffer.Write(encrypted)
+		randomBytes(buffer, randomLength-randomStart)
+	} else {
+		buffer.Write(encrypted)
+		randomBytes(buffer, randomLength)
+	}
+	s.send.hash = hmacSum(md5.New, macKey, buffer.Bytes()[start:])
+	buffer.Write(s.send.hash[:2])
+}
+
+func authChainRandomLength(length int, hash []byte, random *shift128Plus) int {
+	if length > 1440 {
+		return 0
+	}
+	random.InitFromBinAndLength(hash, length)
+	switch {
+	case length > 1300:
+		return int(random.Next() % 31)
+	case length > 900:
+		return int(random.Next() % 127)
+	case length > 400:
+		return int(random.Next() % 521)
+	default:
+		return int(random.Next() % 1021)
+	}
+}
+
+func authChainRandomStart(length int, random *shift128Plus) int {
+	if length == 0 {
+		return 0
+	}
+	return int(int64(random.Next()%8589934609) % int64(length))
+}
+
+type shift128Plus [2]uint64
+
+func (r *shift128Plus) InitFromBin(bin []byte) {
+	var seed [16]byte
+	copy(seed[:], bin)
+	r[0] = binary.LittleEndian.Uint64(seed[:8])
+	r[1] = binary.LittleEndian.Uint64(seed[8:])
+}
+
+func (r *shift128Plus) InitFromBinAndLength(bin []byte, length int) {
+	var seed [16]byte
+	copy(seed[:], bin)
+	binary.LittleEndian.PutUint16(seed[:2], uint16(length))
+	r[0] = binary.LittleEndian.Uint64(seed[:8])
+	r[1] = binary.LittleEndian.Uint64(seed[8:])
+	for i := 0; i < 4; i++ {
+		r.Next()
+	}
+}
+
+func (r *shift128Plus) Next() uint64 {
+	x, y := r[0], r[1]
+	r[0] = y
+	x ^= x << 23
+	x ^= y ^ (x >> 17) ^ (y >> 26)
+	r[1] = x
+	return x + y
+}
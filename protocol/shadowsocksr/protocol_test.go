@@ -0,0 +1,303 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// The vectors below were generated by a transcription of the shadowsocksr
+// reference implementation (obfsplugin/auth.py and auth_chain.py) with its
+// random inputs fixed: the key is derived from "test-password" for
+// aes-256-cfb, the protocol param is "1024:user-password", and the padding
+// bytes follow katPattern.
+const (
+	katTimestamp    = 1700000000
+	katConnectionID = 0xabcdef
+	katParam        = "1024:user-password"
+)
+
+var (
+	katKey      = katHex("dfb450efddbb5387197c84460623675b69f2cebcd8ef520a3dfddef7c3d540b2")
+	katIV       = katHex("000102030405060708090a0b0c0d0e0f")
+	katClientID = [4]byte{1, 2, 3, 4}
+)
+
+var katAES128 = []struct {
+	name         string
+	authBlock    string
+	clientPacket string
+	serverPacket string
+	serverStream string
+}{
+	{
+		name:         "auth_aes128_md5",
+		authBlock:    "672f884b01503fcb8316ed171315817b",
+		clientPacket: "01080f161d242b323940474e555c636a71787f860004000021847e16",
+		serverPacket: "020910171e252c333a41484f565d646b72798087e0cff44e",
+		serverStream: "" +
+			"1a0014b90b030a11181f262d343b4268656c6c6f2c20f4b83d5d3a014fdfff2d01040b121920272e353c434a51585f66" +
+			"6d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c232a31383f464d545b626970777e858c939aa1a8afb6" +
+			"bdc4cbd2d9e0e7eef5fc030a11181f262d343b424950575e656c737a81888f969da4abb2b9c0c7ced5dce3eaf1f8ff06" +
+			"0d141b222930373e454c535a61686f767d848b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e252c333a41484f56" +
+			"5d646b727980878e959ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e757c838a91989fa6" +
+			"adb4bbc2c9d0d7dee5ecf3fa01080f161d242b323940474e555c636a71787f868d949ba2a9b0b7bec5ccd3dae1e8eff6" +
+			"fd040b121920272e353c434a51585f666d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c23776f726c64" +
+			"2a714242",
+	},
+	{
+		name:         "auth_aes128_sha1",
+		authBlock:    "98225b8e2b378ee3567d6d15db9413d9",
+		clientPacket: "01080f161d242b323940474e555c636a71787f860004000054538b72",
+		serverPacket: "020910171e252c333a41484f565d646b72798087711eb9a3",
+		serverStream: "" +
+			"1a0016fc0b030a11181f262d343b4268656c6c6f2c202297eb613a0194b1ff2d01040b121920272e353c434a51585f66" +
+			"6d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c232a31383f464d545b626970777e858c939aa1a8afb6" +
+			"bdc4cbd2d9e0e7eef5fc030a11181f262d343b424950575e656c737a81888f969da4abb2b9c0c7ced5dce3eaf1f8ff06" +
+			"0d141b222930373e454c535a61686f767d848b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e252c333a41484f56" +
+			"5d646b727980878e959ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e757c838a91989fa6" +
+			"adb4bbc2c9d0d7dee5ecf3fa01080f161d242b323940474e555c636a71787f868d949ba2a9b0b7bec5ccd3dae1e8eff6" +
+			"fd040b121920272e353c434a51585f666d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c23776f726c64" +
+			"da983c66",
+	},
+}
+
+const (
+	katChainAuthBlock    = "ab676ebd976b28afcf539b3eb661dfb6"
+	katChainClientPacket = "" +
+		"75ad1568ddc10ceec96749c526577edbfd4cb6c3060d141b222930373e454c535a61686f767d848b9299a0a7aeb5bcc3" +
+		"cad1d8dfe6edf4fb020910171e252c333a41484f565d646b727980878e959ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c13" +
+		"1a21282f363d444b525960676e75090807cda224e7c4"
+	katChainServerPacket = "" +
+		"ced0c15d27e42653eaa5174abb1d916e1b5a35cb080f161d242b323940474e555c6301030507090b0d9f"
+	katChainClientHeader = "deadbeef8d455d767cdc3fb017f11842ab676ebd976b28afcf539b3eb661dfb67bd0b578"
+	katChainServerStream = "" +
+		"fe820a11181f262d343b424950575e656c737a81888f969da4abb2b9c0c7ced5dce3eaf1f8ff060d141b222930373e45" +
+		"4c535a61686f767d848b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e252c333a41484f565d646b727980878e95" +
+		"9ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e757c838a91989fa6adb4bbc2c9d0d7dee5" +
+		"ecf3fa01080f161d242b323940474e555c636a71787f868d949ba2a9b0b7bec5ccd3dae1e8eff6fd040b121920272e35" +
+		"3c434a51585f666d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c232a31383f464d545b626970777e85" +
+		"8c939aa1a8afb6bdc4cbd2d9e0e7eef5fc030a11181f262d343b424950575e656c737a81888f969da4abb2b9c0c7ced5" +
+		"dce3eaf1f8ff060d141b222930373e454c535a61686f767d848b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e25" +
+		"2c333a41484f565d646b727980878e959ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e75" +
+		"7c838a91989fa6adb4bbc2b98b16ef9f45cd1bb0c9d0d7dee5ecf3fa01080f161d242b323940474e555c636a71787f86" +
+		"8d949ba2a9b0b7bec5ccd3dae1e8eff6fd040b121920272e353c434a51585f666d747b828990979ea5acb3bac1c8cfd6" +
+		"dde4ebf2f900070e151c232abb4932870b121920272e353c434a51585f666d747b828990979ea5acb3bac1c8cfd6dde4" +
+		"ebf2f900070e151c232a31383f464d545b626970777e858c939aa1a8afb6bdc4cbd2d9e0e7eef5fc030a11181f262d34" +
+		"3b424950575e656c737a81888f969da4abb2b9c0c7ced5dce3eaf1f8ff060d141b222930373e454c535a61686f767d84" +
+		"8b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e252c333a41484f565d646b540e48cdf4727980878e959ca3aab1" +
+		"b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e757c838a91989fa6adb4bbc2c9d0d7dee5ecf3fa01" +
+		"080f161d242b323940474e555c636a71787f868d949ba2a9b0b7bec5ccd3dae1e8eff6fd040b121920272e353c434a51" +
+		"585f666d747b828990979ea5acb3bac1c8cfd6dde4ebf2f900070e151c232a31383f464d545b626970777e858c939aa1" +
+		"a8afb6bdc4cbd2d9e0e7eef5fc030a11181f262d343b424950575e656c737a81888f969da4abb2b9c0c7ced5dce3eaf1" +
+		"f8ff060d141b222930373e454c535a61686f767d848b9299a0a7aeb5bcc3cad1d8dfe6edf4fb020910171e252c333a41" +
+		"484f565d646b727980878e959ca3aab1b8bfc6cdd4dbe2e9f0f7fe050c131a21282f363d444b525960676e757c838a91" +
+		"989fa6adb4bbc2c9d0d7dee5ecf3fa01080f161d242b323940474e555c636a71787f868d949ba2a9b0b7bec5ccd3dae1" +
+		"e8eff6fd040b121920272e353c434a51585f66f481"
+)
+
+func katHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func katPattern(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = seed + byte(i*7)
+	}
+	return b
+}
+
+func newKATProtocol(t *testing.T, name string) protocol {
+	protocol, err := newProtocol(name, protocolOptions{key: katKey, overhead: 4, param: katParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return protocol
+}
+
+func TestAuthAES128Vectors(t *testing.T) {
+	for _, vector := range katAES128 {
+		t.Run(vector.name, func(t *testing.T) {
+			protocol := newKATProtocol(t, vector.name).(*authAES128)
+			authBlock, err := authEncryptedData(protocol.userKey, protocol.salt, katTimestamp, katClientID, katConnectionID, 300, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(authBlock, katHex(vector.authBlock)) {
+				t.Fatalf("auth block %x", authBlock)
+			}
+			packet, err := protocol.EncodePacket(katPattern(20, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(packet, katHex(vector.clientPacket)) {
+				t.Fatalf("client packet %x", packet)
+			}
+			payload, err := protocol.DecodePacket(katHex(vector.serverPacket))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(payload, katPattern(20, 2)) {
+				t.Fatalf("server packet payload %x", payload)
+			}
+			var data bytes.Buffer
+			err = protocol.NewStream(katIV).Decode(&data, bytes.NewBuffer(katHex(vector.serverStream)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.String() != "hello, world" {
+				t.Fatalf("server stream %q", data.String())
+			}
+		})
+	}
+}
+
+func TestAuthChainAVectors(t *testing.T) {
+	protocol := newKATProtocol(t, "auth_chain_a").(*authChainA)
+	authBlock, err := authEncryptedData(protocol.userKey, authChainSalt, katTimestamp, katClientID, katConnectionID, protocol.overhead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(authBlock, katHex(katChainAuthBlock)) {
+		t.Fatalf("auth block %x", authBlock)
+	}
+	payload, err := (&testServer{protocol: protocol}).decodePacket(katHex(katChainClientPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, katPattern(20, 5)) {
+		t.Fatalf("client packet payload %x", payload)
+	}
+	payload, err = protocol.DecodePacket(katHex(katChainServerPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, katPattern(20, 7)) {
+		t.Fatalf("server packet payload %x", payload)
+	}
+
+	// The header is checked field by field since its first bytes are random
+	// in packAuthData.
+	header := katHex(katChainClientHeader)
+	macKey := append(append([]byte(nil), katIV...), katKey...)
+	sendHash := hmacSum(md5.New, macKey, header[:4])
+	if !bytes.Equal(sendHash[:8], header[4:12]) {
+		t.Fatalf("client hash %x", sendHash[:8])
+	}
+	if userID := binary.LittleEndian.Uint32(header[12:16]) ^ binary.LittleEndian.Uint32(sendHash[8:12]); userID != 1024 {
+		t.Fatalf("user id %d", userID)
+	}
+	if !bytes.Equal(authBlock, header[16:32]) {
+		t.Fatalf("header auth block %x", header[16:32])
+	}
+	receiveHash := hmacSum(md5.New, protocol.userKey, header[12:32])
+	if !bytes.Equal(receiveHash[:4], header[32:36]) {
+		t.Fatalf("server hash %x", receiveHash[:4])
+	}
+
+	stream := protocol.NewStream(katIV).(*authChainStream)
+	stream.receive.hash = receiveHash
+	stream.receive.stream, err = authChainRC4(protocol.userKey, sendHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data bytes.Buffer
+	err = stream.Decode(&data, bytes.NewBuffer(katHex(katChainServerStream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.String() != "hello, world" {
+		t.Fatalf("server stream %q", data.String())
+	}
+}
+
+func TestPacketConnDropsBadDatagram(t *testing.T) {
+	streamCipher, err := newStreamCipher("aes-256-cfb", "test-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamCipher.key, katKey) {
+		t.Fatalf("key %x", streamCipher.key)
+	}
+	protocol := newKATProtocol(t, "auth_aes128_md5").(*authAES128)
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	conn := &packetConn{Conn: clientConn, cipher: streamCipher, protocol: protocol}
+
+	source := M.ParseSocksaddrHostPort("1.1.1.1", 53)
+	var buffer bytes.Buffer
+	err = M.SocksaddrSerializer.WriteAddrPort(&buffer, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer.WriteString("ping")
+	buffer.Write(hmacSum(protocol.hash, protocol.key, buffer.Bytes())[:4])
+	valid, err := streamCipher.EncryptPacket(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := append([]byte(nil), valid...)
+	forged[len(forged)-1] ^= 0xff
+	go func() {
+		for _, datagram := range [][]byte{[]byte("garbage"), forged, valid} {
+			_, err := serverConn.Write(datagram)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	response := make([]byte, 64)
+	n, addr, err := conn.ReadFrom(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if M.SocksaddrFromNet(addr) != source || string(response[:n]) != "ping" {
+		t.Fatalf("unexpected datagram %q from %s", response[:n], addr)
+	}
+}
+
+// partialStream never completes a frame.
+type partialStream struct{}
+
+func (partialStream) Encode(buffer *bytes.Buffer, b []byte) error {
+	buffer.Write(b)
+	return nil
+}
+
+func (partialStream) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	return nil
+}
+
+func TestProtocolConnFrameLimit(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	conn := newProtocolConn(clientConn, partialStream{})
+	go func() {
+		chunk := make([]byte, 1024)
+		for {
+			_, err := serverConn.Write(chunk)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	_, err := conn.Read(make([]byte, 64))
+	if err == nil {
+		t.Fatal("expected an oversized frame to fail")
+	}
+	if conn.raw.Len() > maxFrameSize+1024 {
+		t.Fatalf("buffered %d bytes", conn.raw.Len())
+	}
+}
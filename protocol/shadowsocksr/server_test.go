@@ -0,0 +1,459 @@
+package shadowsocksr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-shadowsocks"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// testServer is a minimal in-process SSR server that echoes TCP streams and
+// UDP packets back to the client.
+type testServer struct {
+	t            *testing.T
+	cipher       *streamCipher
+	obfsName     string
+	protocolName string
+	protocol     protocol
+	listener     net.Listener
+	packetConn   net.PacketConn
+}
+
+func newTestServer(t *testing.T, method string, password string, obfsName string, protocolName string, protocolParam string) *testServer {
+	streamCipher, err := newStreamCipher(method, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocol, err := newProtocol(protocolName, protocolOptions{key: streamCipher.key, param: protocolParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testServer{
+		t:            t,
+		cipher:       streamCipher,
+		obfsName:     obfsName,
+		protocolName: protocolName,
+		protocol:     protocol,
+		listener:     listener,
+		packetConn:   packetConn,
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		packetConn.Close()
+	})
+	go server.serveTCP()
+	go server.serveUDP()
+	return server
+}
+
+func (s *testServer) Addr() M.Socksaddr {
+	return M.SocksaddrFromNet(s.listener.Addr())
+}
+
+func (s *testServer) serveTCP() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			err := s.handleConn(conn)
+			if err != nil && !E.IsClosedOrCanceled(err) && err != io.EOF {
+				s.t.Log("server: ", err)
+			}
+		}()
+	}
+}
+
+func (s *testServer) handleConn(conn net.Conn) error {
+	switch s.obfsName {
+	case "http_simple", "http_post":
+		conn = &httpServerConn{Conn: conn, reader: bufio.NewReader(conn)}
+	case "tls1.2_ticket_auth":
+		conn = &tlsServerConn{Conn: conn, key: s.cipher.key}
+	}
+	var stream cipherConn
+	var serverConn net.Conn = conn
+	if s.cipher.encrypter != nil {
+		stream = cipherConn{Conn: conn, streamCipher: s.cipher, writeIV: s.cipher.newWriteIV()}
+		serverConn = &stream
+	}
+	var protocolStream protocolStream
+	switch protocol := s.protocol.(type) {
+	case *authAES128:
+		protocolStream = &authAES128ServerStream{
+			authAES128Stream: &authAES128Stream{authAES128: protocol, headerSent: true, packID: 1, recvID: 1},
+			clientIV:         func() []byte { return stream.readIV },
+		}
+	case *authChainA:
+		protocolStream = &authChainServerStream{
+			authChainStream: &authChainStream{authChainA: protocol, headerSent: true, send: authChainDirection{id: 1}, receive: authChainDirection{id: 1}},
+			clientIV:        func() []byte { return stream.readIV },
+		}
+	default:
+		protocolStream = originProtocol{}
+	}
+	serverConn = newProtocolConn(serverConn, protocolStream)
+	_, err := M.SocksaddrSerializer.ReadAddrPort(serverConn)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(serverConn, serverConn)
+	return err
+}
+
+func (s *testServer) serveUDP() {
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := s.packetConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		payload, err := s.cipher.DecryptPacket(append([]byte(nil), buffer[:n]...))
+		if err != nil {
+			s.t.Log("server: ", err)
+			continue
+		}
+		payload, err = s.decodePacket(payload)
+		if err != nil {
+			s.t.Log("server: ", err)
+			continue
+		}
+		// the request is echoed, including the target address
+		payload, err = s.encodePacket(payload)
+		if err != nil {
+			s.t.Log("server: ", err)
+			continue
+		}
+		payload, err = s.cipher.EncryptPacket(payload)
+		if err != nil {
+			s.t.Log("server: ", err)
+			continue
+		}
+		_, _ = s.packetConn.WriteTo(payload, addr)
+	}
+}
+
+func (s *testServer) decodePacket(b []byte) ([]byte, error) {
+	switch protocol := s.protocol.(type) {
+	case *authAES128:
+		if len(b) < 8 || !hmac.Equal(hmacSum(protocol.hash, protocol.userKey, b[:len(b)-4])[:4], b[len(b)-4:]) {
+			return nil, E.New("bad packet checksum")
+		}
+		return b[:len(b)-8], nil
+	case *authChainA:
+		if len(b) < 9 || !hmac.Equal(hmacSum(md5.New, protocol.userKey, b[:len(b)-1])[:1], b[len(b)-1:]) {
+			return nil, E.New("bad packet checksum")
+		}
+		md5Data := hmacSum(md5.New, protocol.key, b[len(b)-8:len(b)-5])
+		var random shift128Plus
+		random.InitFromBin(md5Data)
+		randomLength := int(random.Next() % 127)
+		stream, err := authChainRC4(protocol.userKey, md5Data)
+		if err != nil {
+			return nil, err
+		}
+		b = b[:len(b)-8-randomLength]
+		stream.XORKeyStream(b, b)
+		return b, nil
+	default:
+		return b, nil
+	}
+}
+
+func (s *testServer) encodePacket(b []byte) ([]byte, error) {
+	switch protocol := s.protocol.(type) {
+	case *authAES128:
+		return append(b, hmacSum(protocol.hash, protocol.key, b)[:4]...), nil
+	case *authChainA:
+		authData := make([]byte, 7)
+		_, _ = io.ReadFull(rand.Reader, authData)
+		md5Data := hmacSum(md5.New, protocol.key, authData)
+		var random shift128Plus
+		random.InitFromBin(md5Data)
+		randomLength := int(random.Next() % 127)
+		stream, err := authChainRC4(protocol.userKey, md5Data)
+		if err != nil {
+			return nil, err
+		}
+		var buffer bytes.Buffer
+		encrypted := make([]byte, len(b))
+		stream.XORKeyStream(encrypted, b)
+		buffer.Write(encrypted)
+		randomBytes(&buffer, randomLength)
+		buffer.Write(authData)
+		buffer.Write(hmacSum(md5.New, protocol.userKey, buffer.Bytes())[:1])
+		return buffer.Bytes(), nil
+	default:
+		return b, nil
+	}
+}
+
+type httpServerConn struct {
+	net.Conn
+	reader       *bufio.Reader
+	requestRead  bool
+	responseSent bool
+	pending      []byte
+}
+
+func (c *httpServerConn) Read(b []byte) (int, error) {
+	if !c.requestRead {
+		requestLine, err := c.reader.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		fields := strings.Fields(requestLine)
+		if len(fields) != 3 || (fields[0] != "GET" && fields[0] != "POST") {
+			return 0, E.New("bad request line: ", requestLine)
+		}
+		head, err := hex.DecodeString(strings.ReplaceAll(strings.TrimPrefix(fields[1], "/"), "%", ""))
+		if err != nil {
+			return 0, err
+		}
+		for {
+			line, err := c.reader.ReadString('\n')
+			if err != nil {
+				return 0, err
+			}
+			if line == "\r\n" {
+				break
+			}
+		}
+		c.pending = head
+		c.requestRead = true
+	}
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	return c.reader.Read(b)
+}
+
+func (c *httpServerConn) Write(b []byte) (int, error) {
+	if c.responseSent {
+		return c.Conn.Write(b)
+	}
+	c.responseSent = true
+	response := "HTTP/1.1 200 OK\r\nConnection: keep-alive\r\nContent-Encoding: gzip\r\nContent-Type: text/html\r\nServer: nginx\r\nVary: Accept-Encoding\r\n\r\n"
+	_, err := c.Conn.Write(append([]byte(response), b...))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+type tlsServerConn struct {
+	net.Conn
+	key           []byte
+	handshakeDone bool
+	readBuffer    []byte
+}
+
+func (c *tlsServerConn) handshake() error {
+	hello, err := readTLSRecord(c.Conn)
+	if err != nil {
+		return err
+	}
+	if len(hello) < 76 || hello[0] != tlsRecordHandshake || hello[43] != 32 {
+		return E.New("bad client hello")
+	}
+	clientID := hello[44:76]
+	if !hmac.Equal(hello[33:43], tlsTicketHMAC(c.key, clientID, hello[11:33])) {
+		return E.New("bad client hello auth")
+	}
+	var serverHello bytes.Buffer
+	serverHello.Write([]byte{3, 3})
+	writeTLSAuthData(&serverHello, c.key, clientID)
+	serverHello.WriteByte(32)
+	serverHello.Write(clientID)
+	serverHello.Write([]byte{0xc0, 0x2f, 0x00, 0x00, 0x05, 0xff, 0x01, 0x00, 0x01, 0x00})
+	var response bytes.Buffer
+	writeTLSRecord(&response, tlsRecordHandshake, append([]byte{2, 0, byte(serverHello.Len() >> 8), byte(serverHello.Len())}, serverHello.Bytes()...))
+	writeTLSRecord(&response, tlsRecordChangeCipherSpec, []byte{1})
+	finished := make([]byte, 32)
+	_, _ = io.ReadFull(rand.Reader, finished[:22])
+	response.Write([]byte{tlsRecordHandshake, 3, 3, 0, 32})
+	response.Write(finished[:22])
+	response.Write(tlsTicketHMAC(c.key, clientID, response.Bytes()))
+	_, err = c.Conn.Write(response.Bytes())
+	if err != nil {
+		return err
+	}
+	changeCipherSpec, err := readTLSRecord(c.Conn)
+	if err != nil {
+		return err
+	}
+	clientFinished, err := readTLSRecord(c.Conn)
+	if err != nil {
+		return err
+	}
+	clientHandshake := append(changeCipherSpec, clientFinished...)
+	if !hmac.Equal(clientHandshake[len(clientHandshake)-10:], tlsTicketHMAC(c.key, clientID, clientHandshake[:len(clientHandshake)-10])) {
+		return E.New("bad client finished")
+	}
+	c.handshakeDone = true
+	return nil
+}
+
+func (c *tlsServerConn) Read(b []byte) (int, error) {
+	if !c.handshakeDone {
+		err := c.handshake()
+		if err != nil {
+			return 0, err
+		}
+	}
+	for len(c.readBuffer) == 0 {
+		record, err := readTLSRecord(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if record[0] != tlsRecordApplicationData {
+			return 0, E.New("unexpected record type ", record[0])
+		}
+		c.readBuffer = record[5:]
+	}
+	n := copy(b, c.readBuffer)
+	c.readBuffer = c.readBuffer[n:]
+	return n, nil
+}
+
+func (c *tlsServerConn) Write(b []byte) (int, error) {
+	var buffer bytes.Buffer
+	writeTLSRecords(&buffer, b)
+	_, err := c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// authAES128ServerStream verifies the auth packet before decoding data
+// packets with the client stream implementation, whose format is symmetric.
+type authAES128ServerStream struct {
+	*authAES128Stream
+	clientIV       func() []byte
+	headerReceived bool
+}
+
+func (s *authAES128ServerStream) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	if !s.headerReceived {
+		packet := src.Bytes()
+		if len(packet) < 31 {
+			return nil
+		}
+		macKey := append(append([]byte(nil), s.clientIV()...), s.key...)
+		if !hmac.Equal(hmacSum(s.hash, macKey, packet[:1])[:6], packet[1:7]) {
+			return E.New("bad check head")
+		}
+		if !hmac.Equal(hmacSum(s.hash, macKey, packet[7:27])[:4], packet[27:31]) {
+			return E.New("bad auth data checksum")
+		}
+		block, err := aes.NewCipher(shadowsocks.Key([]byte(base64.StdEncoding.EncodeToString(s.userKey)+s.salt), 16))
+		if err != nil {
+			return err
+		}
+		authData := make([]byte, 16)
+		cipher.NewCBCDecrypter(block, make([]byte, 16)).CryptBlocks(authData, packet[11:27])
+		length := int(binary.LittleEndian.Uint16(authData[12:]))
+		randomLength := int(binary.LittleEndian.Uint16(authData[14:]))
+		if len(packet) < length {
+			return nil
+		}
+		if !hmac.Equal(hmacSum(s.hash, s.userKey, packet[:length-4])[:4], packet[length-4:length]) {
+			return E.New("bad auth packet checksum")
+		}
+		dst.Write(packet[31+randomLength : length-4])
+		src.Next(length)
+		s.headerReceived = true
+	}
+	return s.authAES128Stream.Decode(dst, src)
+}
+
+// authChainServerStream decodes client packets independently of the client
+// implementation and encodes server packets with it.
+type authChainServerStream struct {
+	*authChainStream
+	clientIV       func() []byte
+	headerReceived bool
+	mssSent        bool
+}
+
+func (s *authChainServerStream) Decode(dst *bytes.Buffer, src *bytes.Buffer) error {
+	if !s.headerReceived {
+		packet := src.Bytes()
+		if len(packet) < 36 {
+			return nil
+		}
+		macKey := append(append([]byte(nil), s.clientIV()...), s.key...)
+		clientHash := hmacSum(md5.New, macKey, packet[:4])
+		if !hmac.Equal(clientHash[:8], packet[4:12]) {
+			return E.New("bad check head")
+		}
+		serverHash := hmacSum(md5.New, s.userKey, packet[12:32])
+		if !hmac.Equal(serverHash[:4], packet[32:36]) {
+			return E.New("bad auth data checksum")
+		}
+		s.receive.hash = clientHash
+		s.receive.stream, _ = authChainRC4(s.userKey, clientHash)
+		s.send.hash = serverHash
+		s.send.stream, _ = authChainRC4(s.userKey, clientHash)
+		src.Next(36)
+		s.headerReceived = true
+	}
+	for src.Len() > 4 {
+		packet := src.Bytes()
+		dataLength := int(binary.LittleEndian.Uint16(packet) ^ binary.LittleEndian.Uint16(s.receive.hash[14:16]))
+		randomLength := authChainRandomLength(dataLength, s.receive.hash, &s.receive.random)
+		if dataLength+randomLength+4 > len(packet) {
+			return nil
+		}
+		hash := hmacSum(md5.New, s.macKey(s.receive.id), packet[:dataLength+randomLength+2])
+		if !hmac.Equal(hash[:2], packet[dataLength+randomLength+2:dataLength+randomLength+4]) {
+			return E.New("bad packet checksum")
+		}
+		s.receive.hash = hash
+		s.receive.id++
+		position := 2
+		if dataLength > 0 && randomLength > 0 {
+			position += authChainRandomStart(randomLength, &s.receive.random)
+		}
+		data := make([]byte, dataLength)
+		s.receive.stream.XORKeyStream(data, packet[position:position+dataLength])
+		dst.Write(data)
+		src.Next(dataLength + randomLength + 4)
+	}
+	return nil
+}
+
+func (s *authChainServerStream) Encode(buffer *bytes.Buffer, b []byte) error {
+	if !s.mssSent {
+		s.mssSent = true
+		b = append([]byte{0xdc, 0x05}, b...)
+	}
+	return s.authChainStream.Encode(buffer, b)
+}
@@ -48,7 +48,11 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		handshakeForServerName = make(map[string]shadowtls.HandshakeConfig)
 		if options.HandshakeForServerName != nil {
 			for _, entry := range options.HandshakeForServerName.Entries() {
-				handshakeDialer, err := dialer.New(ctx, entry.Value.DialerOptions, entry.Value.ServerIsDomain())
+				handshakeDialer, err := dialer.NewWithOptions(dialer.Options{
+					Context:        ctx,
+					Options:        entry.Value.DialerOptions,
+					RemoteIsDomain: entry.Value.ServerIsDomain(),
+				})
 				if err != nil {
 					return nil, err
 				}
@@ -63,7 +67,11 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.WildcardSNI != option.ShadowTLSWildcardSNIOff {
 		serverIsDomain = true
 	}
-	handshakeDialer, err := dialer.New(ctx, options.Handshake.DialerOptions, serverIsDomain)
+	handshakeDialer, err := dialer.NewWithOptions(dialer.Options{
+		Context:        ctx,
+		Options:        options.Handshake.DialerOptions,
+		RemoteIsDomain: serverIsDomain,
+	})
 	if err != nil {
 		return nil, err
 	}
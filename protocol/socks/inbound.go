@@ -4,6 +4,7 @@ import (
 	std_bufio "bufio"
 	"context"
 	"net"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -23,23 +24,29 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.SocksInboundOptions](registry, C.TypeSOCKS, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
-	router        adapter.ConnectionRouterEx
-	logger        logger.ContextLogger
-	listener      *listener.Listener
-	authenticator *auth.Authenticator
+	router   adapter.ConnectionRouterEx
+	logger   logger.ContextLogger
+	listener *listener.Listener
+	users    atomic.Pointer[adapter.ManagedAuthenticator]
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SocksInboundOptions) (adapter.Inbound, error) {
 	inbound := &Inbound{
-		Adapter:       inbound.NewAdapter(C.TypeSOCKS, tag),
-		router:        uot.NewRouter(router, logger),
-		logger:        logger,
-		authenticator: auth.NewAuthenticator(options.Users),
+		Adapter: inbound.NewAdapter(C.TypeSOCKS, tag),
+		router:  uot.NewRouter(router, logger),
+		logger:  logger,
 	}
+	inbound.users.Store(&adapter.ManagedAuthenticator{
+		Authenticator: auth.NewAuthenticator(options.Users),
+		Users:         options.Users,
+	})
 	inbound.listener = listener.New(listener.Options{
 		Context:           ctx,
 		Logger:            logger,
@@ -61,8 +68,25 @@ func (h *Inbound) Close() error {
 	return h.listener.Close()
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	authenticator, err := adapter.NewManagedAuthenticator(users)
+	if err != nil {
+		return err
+	}
+	h.users.Store(authenticator)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return h.users.Load().ManagedUsers()
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
-	err := socks.HandleConnectionEx(ctx, conn, std_bufio.NewReader(conn), h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), h.listener, metadata.Source, onClose)
+	err := socks.HandleConnectionEx(ctx, conn, std_bufio.NewReader(conn), h.users.Load().Authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), h.listener, metadata.Source, onClose)
 	N.CloseOnHandshakeFailure(conn, onClose, err)
 	if err != nil {
 		if E.IsClosedOrCanceled(err) {
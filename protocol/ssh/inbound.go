@@ -0,0 +1,295 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/listener"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func RegisterInbound(registry *inbound.Registry) {
+	inbound.Register[option.SSHInboundOptions](registry, C.TypeSSH, NewInbound)
+}
+
+var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+
+// Inbound is an SSH server that only serves port forwarding: direct-tcpip
+// channels opened by `ssh -L` or `ssh -D` are routed as TCP connections.
+// Session channels are accepted and idle, so clients started without `-N`
+// keep their forwards open.
+type Inbound struct {
+	inbound.Adapter
+	router   adapter.ConnectionRouterEx
+	logger   log.ContextLogger
+	listener *listener.Listener
+	config   *ssh.ServerConfig
+}
+
+func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SSHInboundOptions) (adapter.Inbound, error) {
+	config, err := newServerConfig(logger, options)
+	if err != nil {
+		return nil, err
+	}
+	inbound := &Inbound{
+		Adapter: inbound.NewAdapter(C.TypeSSH, tag),
+		router:  router,
+		logger:  logger,
+		config:  config,
+	}
+	inbound.listener = listener.New(listener.Options{
+		Context:           ctx,
+		Logger:            logger,
+		Network:           []string{N.NetworkTCP},
+		Listen:            options.ListenOptions,
+		ConnectionHandler: inbound,
+	})
+	return inbound, nil
+}
+
+func newServerConfig(logger log.ContextLogger, options option.SSHInboundOptions) (*ssh.ServerConfig, error) {
+	config := &ssh.ServerConfig{
+		ServerVersion: options.ServerVersion,
+	}
+	if config.ServerVersion == "" {
+		config.ServerVersion = randomVersion()
+	}
+	if len(options.Users) == 0 && !options.NoAuth {
+		return nil, E.New("missing users, set no_auth to accept clients without authentication")
+	}
+	if len(options.Users) > 0 && options.NoAuth {
+		return nil, E.New("no_auth conflicts with users")
+	}
+	var passwordUsers []auth.User
+	publicKeyUsers := make(map[string][]string)
+	for index, user := range options.Users {
+		if user.Username == "" {
+			return nil, E.New("missing username for user[", index, "]")
+		}
+		if user.Password != "" {
+			passwordUsers = append(passwordUsers, user.User)
+		}
+		for _, authorizedKey := range user.AuthorizedKeys {
+			publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+			if err != nil {
+				return nil, E.Cause(err, "parse authorized key for user ", user.Username)
+			}
+			key := string(publicKey.Marshal())
+			publicKeyUsers[key] = append(publicKeyUsers[key], user.Username)
+		}
+	}
+	config.NoClientAuth = options.NoAuth
+	if authenticator := auth.NewAuthenticator(passwordUsers); authenticator != nil {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if !authenticator.Verify(conn.User(), string(password)) {
+				return nil, E.New("password rejected for ", conn.User())
+			}
+			return userPermissions(conn.User()), nil
+		}
+	}
+	if len(publicKeyUsers) > 0 {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, userName := range publicKeyUsers[string(key.Marshal())] {
+				if userName == conn.User() {
+					return userPermissions(userName), nil
+				}
+			}
+			return nil, E.New("public key rejected for ", conn.User())
+		}
+	}
+	hostKeys := make([][]byte, 0, len(options.HostKey)+len(options.HostKeyPath))
+	for _, hostKey := range options.HostKey {
+		hostKeys = append(hostKeys, []byte(hostKey))
+	}
+	for _, hostKeyPath := range options.HostKeyPath {
+		hostKey, err := os.ReadFile(os.ExpandEnv(hostKeyPath))
+		if err != nil {
+			return nil, E.Cause(err, "read host key")
+		}
+		hostKeys = append(hostKeys, hostKey)
+	}
+	for _, hostKey := range hostKeys {
+		signer, err := ssh.ParsePrivateKey(hostKey)
+		if err != nil {
+			return nil, E.Cause(err, "parse host key")
+		}
+		config.AddHostKey(signer)
+	}
+	if len(hostKeys) == 0 {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.NewSignerFromKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		config.AddHostKey(signer)
+		logger.Warn("host key not configured, using ephemeral key ", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))))
+	}
+	return config, nil
+}
+
+const permissionUser = "user"
+
+// userPermissions records the authenticated user, which is left empty when
+// client authentication is disabled.
+func userPermissions(userName string) *ssh.Permissions {
+	return &ssh.Permissions{Extensions: map[string]string{permissionUser: userName}}
+}
+
+func (h *Inbound) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	return h.listener.Start()
+}
+
+func (h *Inbound) Close() error {
+	return h.listener.Close()
+}
+
+func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	err := conn.SetDeadline(time.Now().Add(C.TCPTimeout))
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		h.logger.ErrorContext(ctx, E.Cause(err, "set handshake deadline"))
+		return
+	}
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, h.config)
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
+		return
+	}
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		serverConn.Close()
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		h.logger.ErrorContext(ctx, E.Cause(err, "clear handshake deadline"))
+		return
+	}
+	var userName string
+	if serverConn.Permissions != nil {
+		userName = serverConn.Permissions.Extensions[permissionUser]
+	}
+	if userName != "" {
+		h.logger.InfoContext(ctx, "[", userName, "] inbound SSH connection from ", metadata.Source)
+	} else {
+		h.logger.InfoContext(ctx, "inbound SSH connection from ", metadata.Source)
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "session" {
+			channel, channelRequests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go idleSession(channel, channelRequests)
+			continue
+		}
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only port forwarding is supported")
+			continue
+		}
+		var request struct {
+			Host       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}
+		err = ssh.Unmarshal(newChannel.ExtraData(), &request)
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		channelMetadata := metadata
+		channelMetadata.Inbound = h.Tag()
+		channelMetadata.InboundType = h.Type()
+		channelMetadata.User = userName
+		channelMetadata.Destination = M.ParseSocksaddrHostPort(request.Host, uint16(request.Port)).Unwrap()
+		channelCtx := log.ContextWithNewID(ctx)
+		if userName != "" {
+			h.logger.InfoContext(channelCtx, "[", userName, "] inbound connection to ", channelMetadata.Destination)
+		} else {
+			h.logger.InfoContext(channelCtx, "inbound connection to ", channelMetadata.Destination)
+		}
+		go h.router.RouteConnectionEx(channelCtx, &channelConn{
+			Channel:    channel,
+			localAddr:  serverConn.LocalAddr(),
+			remoteAddr: serverConn.RemoteAddr(),
+		}, channelMetadata, nil)
+	}
+	_ = serverConn.Close()
+	if onClose != nil {
+		onClose(nil)
+	}
+}
+
+// idleSession serves a session channel without running anything: the shell
+// and its terminal are acknowledged so the client keeps the connection, and
+// input is discarded until the client closes the channel. Commands and
+// subsystems are refused.
+func idleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	go func() {
+		_, _ = io.Copy(io.Discard, channel)
+		_ = channel.Close()
+	}()
+	for request := range requests {
+		switch request.Type {
+		case "shell", "pty-req", "env", "window-change":
+			_ = request.Reply(true, nil)
+		default:
+			_ = request.Reply(false, nil)
+		}
+	}
+}
+
+var _ net.Conn = (*channelConn)(nil)
+
+type channelConn struct {
+	ssh.Channel
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *channelConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *channelConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *channelConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *channelConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *channelConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/auth"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type echoRouter struct {
+	metadata chan adapter.InboundContext
+}
+
+func (r *echoRouter) RouteConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return os.ErrInvalid
+}
+
+func (r *echoRouter) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return os.ErrInvalid
+}
+
+func (r *echoRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	r.metadata <- metadata
+	_, _ = io.Copy(conn, conn)
+	_ = conn.Close()
+}
+
+func (r *echoRouter) RoutePacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+}
+
+func newTestInbound(t *testing.T, options option.SSHInboundOptions) (*Inbound, *echoRouter) {
+	config, err := newServerConfig(log.StdLogger(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := &echoRouter{metadata: make(chan adapter.InboundContext, 1)}
+	inbound := &Inbound{router: router, logger: log.StdLogger(), config: config}
+	return inbound, router
+}
+
+func dialTestInbound(t *testing.T, inbound *Inbound, config *ssh.ClientConfig) (*ssh.Client, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		serverConn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		inbound.NewConnectionEx(context.Background(), serverConn, adapter.InboundContext{}, nil)
+	}()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, channels, requests, err := ssh.NewClientConn(clientConn, listener.Addr().String(), config)
+	if err != nil {
+		clientConn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(conn, channels, requests), nil
+}
+
+func TestInbound_DirectTCPIP(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbound, router := newTestInbound(t, option.SSHInboundOptions{
+		Users: []option.SSHUser{
+			{User: auth.User{Username: "alice", Password: "password"}},
+			{User: auth.User{Username: "bob"}, AuthorizedKeys: []string{string(ssh.MarshalAuthorizedKey(sshPublicKey))}},
+		},
+	})
+
+	for _, testCase := range []struct {
+		user string
+		auth ssh.AuthMethod
+	}{
+		{"alice", ssh.Password("password")},
+		{"bob", ssh.PublicKeys(signer)},
+	} {
+		client, err := dialTestInbound(t, inbound, &ssh.ClientConfig{
+			User:            testCase.user,
+			Auth:            []ssh.AuthMethod{testCase.auth},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := client.Dial("tcp", "example.com:443")
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata := <-router.metadata
+		if metadata.User != testCase.user || metadata.Destination.String() != "example.com:443" {
+			t.Fatalf("unexpected metadata: user=%s destination=%s", metadata.User, metadata.Destination)
+		}
+		_, err = conn.Write([]byte("ping"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := make([]byte, 4)
+		_, err = io.ReadFull(conn, response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response) != "ping" {
+			t.Fatalf("unexpected response: %q", response)
+		}
+		conn.Close()
+		client.Close()
+	}
+}
+
+func TestInbound_AuthRejected(t *testing.T) {
+	inbound, _ := newTestInbound(t, option.SSHInboundOptions{
+		Users: []option.SSHUser{{User: auth.User{Username: "alice", Password: "password"}}},
+	})
+	_, err := dialTestInbound(t, inbound, &ssh.ClientConfig{
+		User:            "alice",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		t.Fatal("expected wrong password to be rejected")
+	}
+	_, err = dialTestInbound(t, inbound, &ssh.ClientConfig{
+		User:            "mallory",
+		Auth:            []ssh.AuthMethod{ssh.Password("password")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		t.Fatal("expected unknown user to be rejected")
+	}
+}
+
+func TestInbound_NoAuth(t *testing.T) {
+	_, err := newServerConfig(log.StdLogger(), option.SSHInboundOptions{})
+	if err == nil {
+		t.Fatal("expected missing users to be rejected without no_auth")
+	}
+	inbound, router := newTestInbound(t, option.SSHInboundOptions{NoAuth: true})
+	client, err := dialTestInbound(t, inbound, &ssh.ClientConfig{
+		User:            "anyone",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := client.Dial("tcp", "example.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if metadata := <-router.metadata; metadata.User != "" {
+		t.Fatalf("unexpected user: %s", metadata.User)
+	}
+}
+
+func TestInbound_Session(t *testing.T) {
+	inbound, router := newTestInbound(t, option.SSHInboundOptions{
+		Users: []option.SSHUser{{User: auth.User{Username: "alice", Password: "password"}}},
+	})
+	client, err := dialTestInbound(t, inbound, &ssh.ClientConfig{
+		User:            "alice",
+		Auth:            []ssh.AuthMethod{ssh.Password("password")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	err = session.RequestPty("xterm", 24, 80, ssh.TerminalModes{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Shell()
+	if err != nil {
+		t.Fatal(err)
+	}
+	execSession, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer execSession.Close()
+	if execSession.Run("id") == nil {
+		t.Fatal("expected exec to be rejected")
+	}
+	conn, err := client.Dial("tcp", "example.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if metadata := <-router.metadata; metadata.User != "alice" {
+		t.Fatalf("unexpected user: %s", metadata.User)
+	}
+}
@@ -129,6 +129,7 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 		RemoteIsDomain:   remoteIsDomain,
 		ResolverOnDetour: true,
 		NewDialer:        true,
+		Outbound:         true,
 	})
 	if err != nil {
 		return nil, err
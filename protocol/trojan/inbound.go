@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -27,15 +28,18 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.TrojanInboundOptions](registry, C.TypeTrojan, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
 	router                   adapter.ConnectionRouterEx
 	logger                   log.ContextLogger
 	listener                 *listener.Listener
-	service                  *trojan.Service[int]
-	users                    []option.TrojanUser
+	fallbackHandler          N.TCPConnectionHandlerEx
+	users                    atomic.Pointer[inboundUsers]
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
 	fallbackAddrTLSNextProto map[string]M.Socksaddr
@@ -47,7 +51,6 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		Adapter: inbound.NewAdapter(C.TypeTrojan, tag),
 		router:  router,
 		logger:  logger,
-		users:   options.Users,
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -56,7 +59,6 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		}
 		inbound.tlsConfig = tlsConfig
 	}
-	var fallbackHandler N.TCPConnectionHandlerEx
 	if options.Fallback != nil && options.Fallback.Server != "" || len(options.FallbackForALPN) > 0 {
 		if options.Fallback != nil && options.Fallback.Server != "" {
 			inbound.fallbackAddr = options.Fallback.Build()
@@ -78,17 +80,13 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 			}
 			inbound.fallbackAddrTLSNextProto = fallbackAddrNextProto
 		}
-		fallbackHandler = adapter.NewUpstreamContextHandlerEx(inbound.fallbackConnection, nil)
+		inbound.fallbackHandler = adapter.NewUpstreamContextHandlerEx(inbound.fallbackConnection, nil)
 	}
-	service := trojan.NewService[int](adapter.NewUpstreamContextHandlerEx(inbound.newConnection, inbound.newPacketConnection), fallbackHandler, logger)
-	err := service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.TrojanUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.TrojanUser) string {
-		return it.Password
-	}))
+	users, err := inbound.newUsers(options.Users)
 	if err != nil {
 		return nil, err
 	}
+	inbound.users.Store(users)
 	if options.Transport != nil {
 		inbound.transport, err = v2ray.NewServerTransport(ctx, logger, common.PtrValueOrDefault(options.Transport), inbound.tlsConfig, (*inboundTransportHandler)(inbound))
 		if err != nil {
@@ -99,7 +97,6 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
-	inbound.service = service
 	inbound.listener = listener.New(listener.Options{
 		Context:           ctx,
 		Logger:            logger,
@@ -158,6 +155,38 @@ func (h *Inbound) Close() error {
 	)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.Password == "" {
+			return E.New("missing password for user ", user.Name)
+		}
+	}
+	newUsers, err := h.newUsers(common.Map(users, func(it adapter.ManagedUser) option.TrojanUser {
+		return option.TrojanUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	}))
+	if err != nil {
+		return err
+	}
+	h.users.Store(newUsers)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return common.Map(h.users.Load().users, func(it option.TrojanUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	})
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil && h.transport == nil {
 		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
@@ -168,7 +197,7 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	err := h.users.Load().service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
 	if err != nil {
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
@@ -178,14 +207,14 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -196,14 +225,14 @@ func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata ada
 func (h *Inbound) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -255,3 +284,24 @@ func (h *inboundTransportHandler) NewConnectionEx(ctx context.Context, conn net.
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 	(*Inbound)(h).NewConnectionEx(ctx, conn, metadata, onClose)
 }
+
+// inboundUsers is the user list together with the service authenticating
+// it. UpdateUsers replaces both at once instead of updating the service in
+// place, which would race with connections being authenticated.
+type inboundUsers struct {
+	users   []option.TrojanUser
+	service *trojan.Service[adapter.ManagedUserKey]
+}
+
+func (h *Inbound) newUsers(users []option.TrojanUser) (*inboundUsers, error) {
+	service := trojan.NewService[adapter.ManagedUserKey](adapter.NewUpstreamContextHandlerEx(h.newConnection, h.newPacketConnection), h.fallbackHandler, h.logger)
+	err := service.UpdateUsers(adapter.NewManagedUserKeys(users, func(it option.TrojanUser) string {
+		return it.Name
+	}), common.Map(users, func(it option.TrojanUser) string {
+		return it.Password
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &inboundUsers{users, service}, nil
+}
@@ -3,6 +3,7 @@ package tuic
 import (
 	"context"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -27,14 +28,16 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.TUICInboundOptions](registry, C.TypeTUIC, NewInbound)
 }
 
+var _ adapter.ManagedUserServer = (*Inbound)(nil)
+
 type Inbound struct {
 	inbound.Adapter
-	router       adapter.ConnectionRouterEx
-	logger       log.ContextLogger
-	listener     *listener.Listener
-	tlsConfig    tls.ServerConfig
-	server       *tuic.Service[int]
-	userNameList []string
+	router    adapter.ConnectionRouterEx
+	logger    log.ContextLogger
+	listener  *listener.Listener
+	tlsConfig tls.ServerConfig
+	server    *tuic.Service[adapter.ManagedUserKey]
+	users     atomic.Pointer[[]adapter.ManagedUser]
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (adapter.Inbound, error) {
@@ -63,7 +66,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	} else {
 		udpTimeout = C.UDPTimeout
 	}
-	service, err := tuic.NewService[int](tuic.ServiceOptions{
+	service, err := tuic.NewService[adapter.ManagedUserKey](tuic.ServiceOptions{
 		Context:           ctx,
 		Logger:            logger,
 		TLSConfig:         tlsConfig,
@@ -77,8 +80,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
-	var userList []int
-	var userNameList []string
+	var userList []adapter.ManagedUserKey
 	var userUUIDList [][16]byte
 	var userPasswordList []string
 	for index, user := range options.Users {
@@ -89,17 +91,54 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		if err != nil {
 			return nil, E.Cause(err, "invalid uuid for user ", index)
 		}
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
+		userList = append(userList, adapter.ManagedUserKey{Index: index, Name: user.Name})
 		userUUIDList = append(userUUIDList, userUUID)
 		userPasswordList = append(userPasswordList, user.Password)
 	}
 	service.UpdateUsers(userList, userUUIDList, userPasswordList)
 	inbound.server = service
-	inbound.userNameList = userNameList
+	users := common.Map(options.Users, func(it option.TUICUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name:     it.Name,
+			UUID:     it.UUID,
+			Password: it.Password,
+		}
+	})
+	inbound.users.Store(&users)
 	return inbound, nil
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	var userList []adapter.ManagedUserKey
+	var userUUIDList [][16]byte
+	var userPasswordList []string
+	for index, user := range users {
+		if user.UUID == "" {
+			return E.New("missing uuid for user ", user.Name)
+		}
+		userUUID, err := uuid.FromString(user.UUID)
+		if err != nil {
+			return E.Cause(err, "invalid uuid for user ", user.Name)
+		}
+		userList = append(userList, adapter.ManagedUserKey{Index: index, Name: user.Name})
+		userUUIDList = append(userUUIDList, userUUID)
+		userPasswordList = append(userPasswordList, user.Password)
+	}
+	// the keys carry the name, so connections are credited to the list they
+	// were accepted with even while the server replaces it
+	h.server.UpdateUsers(userList, userUUIDList, userPasswordList)
+	h.users.Store(&users)
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return *h.users.Load()
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
 	ctx = log.ContextWithNewID(ctx)
 	var metadata adapter.InboundContext
@@ -113,8 +152,8 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.S
 	metadata.Source = source
 	metadata.Destination = destination
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userKey, _ := auth.UserFromContext[adapter.ManagedUserKey](ctx)
+	if userName := userKey.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
@@ -136,8 +175,8 @@ func (h *Inbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	metadata.Source = source
 	metadata.Destination = destination
 	h.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userKey, _ := auth.UserFromContext[adapter.ManagedUserKey](ctx)
+	if userName := userKey.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
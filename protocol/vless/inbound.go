@@ -4,9 +4,11 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/fallback"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/mux"
 	"github.com/sagernet/sing-box/common/tls"
@@ -31,7 +33,10 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.VLESSInboundOptions](registry, C.TypeVLESS, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
@@ -39,10 +44,10 @@ type Inbound struct {
 	router    adapter.ConnectionRouterEx
 	logger    logger.ContextLogger
 	listener  *listener.Listener
-	users     []option.VLESSUser
-	service   *vless.Service[int]
+	users     atomic.Pointer[inboundUsers]
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
+	fallback  *fallback.Handler
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.VLESSInboundOptions) (adapter.Inbound, error) {
@@ -51,28 +56,28 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		ctx:     ctx,
 		router:  uot.NewRouter(router, logger),
 		logger:  logger,
-		users:   options.Users,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
 	if err != nil {
 		return nil, err
 	}
-	service := vless.NewService[int](logger, adapter.NewUpstreamContextHandlerEx(inbound.newConnectionEx, inbound.newPacketConnectionEx))
-	service.UpdateUsers(common.MapIndexed(inbound.users, func(index int, _ option.VLESSUser) int {
-		return index
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.UUID
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.Flow
-	}))
-	inbound.service = service
+	inbound.users.Store(inbound.newUsers(options.Users))
 	if options.TLS != nil {
 		inbound.tlsConfig, err = tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
 			return nil, err
 		}
 	}
+	if len(options.Fallbacks) > 0 {
+		if options.Transport != nil && options.Transport.Type != "" {
+			return nil, E.New("fallbacks is not supported with transport")
+		}
+		inbound.fallback, err = fallback.NewHandler(inbound.router, logger, options.Fallbacks, inbound.tlsConfig != nil)
+		if err != nil {
+			return nil, E.Cause(err, "create fallbacks")
+		}
+	}
 	if options.Transport != nil {
 		inbound.transport, err = v2ray.NewServerTransport(ctx, logger, common.PtrValueOrDefault(options.Transport), inbound.tlsConfig, (*inboundTransportHandler)(inbound))
 		if err != nil {
@@ -131,13 +136,43 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 
 func (h *Inbound) Close() error {
 	return common.Close(
-		h.service,
+		h.users.Load().service,
 		h.listener,
 		h.tlsConfig,
 		h.transport,
 	)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.UUID == "" {
+			return E.New("missing uuid for user ", user.Name)
+		}
+	}
+	h.users.Store(h.newUsers(common.Map(users, func(it adapter.ManagedUser) option.VLESSUser {
+		return option.VLESSUser{
+			Name: it.Name,
+			UUID: it.UUID,
+			Flow: it.Flow,
+		}
+	})))
+	return nil
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return common.Map(h.users.Load().users, func(it option.VLESSUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name: it.Name,
+			UUID: it.UUID,
+			Flow: it.Flow,
+		}
+	})
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil && h.transport == nil {
 		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
@@ -148,24 +183,39 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	var fallbackConn *fallback.Conn
+	if h.fallback != nil {
+		fallbackConn = h.fallback.NewConn(conn)
+		ctx = fallback.ContextWithConn(ctx, fallbackConn)
+		conn = fallbackConn
+	}
+	err := h.users.Load().service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
 	if err != nil {
+		if fallbackConn != nil {
+			metadata.Inbound = h.Tag()
+			metadata.InboundType = h.Type()
+			if h.fallback.NewConnectionEx(ctx, fallbackConn, metadata, onClose) {
+				h.logger.DebugContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": fallback"))
+				return
+			}
+		}
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
 	}
 }
 
 func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	fallback.Commit(ctx)
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -174,16 +224,17 @@ func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata a
 }
 
 func (h *Inbound) newPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	fallback.Commit(ctx)
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -212,3 +263,23 @@ func (h *inboundTransportHandler) NewConnectionEx(ctx context.Context, conn net.
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 	(*Inbound)(h).NewConnectionEx(ctx, conn, metadata, onClose)
 }
+
+// inboundUsers is the user list together with the service authenticating
+// it. UpdateUsers replaces both at once instead of updating the service in
+// place, which would race with connections being authenticated.
+type inboundUsers struct {
+	users   []option.VLESSUser
+	service *vless.Service[adapter.ManagedUserKey]
+}
+
+func (h *Inbound) newUsers(users []option.VLESSUser) *inboundUsers {
+	service := vless.NewService[adapter.ManagedUserKey](h.logger, adapter.NewUpstreamContextHandlerEx(h.newConnectionEx, h.newPacketConnectionEx))
+	service.UpdateUsers(adapter.NewManagedUserKeys(users, func(it option.VLESSUser) string {
+		return it.Name
+	}), common.Map(users, func(it option.VLESSUser) string {
+		return it.UUID
+	}), common.Map(users, func(it option.VLESSUser) string {
+		return it.Flow
+	}))
+	return &inboundUsers{users, service}
+}
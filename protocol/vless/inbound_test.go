@@ -0,0 +1,170 @@
+package vless
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	sTLS "github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-vmess/vless"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type routedConnection struct {
+	conn     net.Conn
+	metadata adapter.InboundContext
+}
+
+type captureRouter struct {
+	adapter.Router
+	routed chan routedConnection
+}
+
+func (r *captureRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	r.routed <- routedConnection{conn, metadata}
+}
+
+func TestInbound_FallbackByServerNameAndALPN(t *testing.T) {
+	privateKey, certificate, err := sTLS.GenerateCertificate(nil, nil, time.Now, "example.com", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	inbound, err := NewInbound(context.Background(), router, log.StdLogger(), "vless-in", option.VLESSInboundOptions{
+		Users: []option.VLESSUser{{Name: "user", UUID: "b831381d-6324-4d53-ad4f-8cda48b30811"}},
+		InboundTLSOptionsContainer: option.InboundTLSOptionsContainer{TLS: &option.InboundTLSOptions{
+			Enabled:     true,
+			ALPN:        []string{"h2", "http/1.1"},
+			Certificate: strings.Split(string(certificate), "\n"),
+			Key:         strings.Split(string(privateKey), "\n"),
+		}},
+		Fallbacks: []option.V2RayFallbackOptions{
+			{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 1001}, ServerName: []string{"other.example.com"}},
+			{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 1002}, ALPN: []string{"h2"}},
+			{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 1003}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = inbound.Start(adapter.StartStateStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inbound.Close()
+
+	for _, testCase := range []struct {
+		serverName string
+		alpn       []string
+		port       uint16
+	}{
+		{"other.example.com", []string{"h2"}, 1001},
+		{"example.com", []string{"h2"}, 1002},
+		{"example.com", []string{"http/1.1"}, 1003},
+	} {
+		routed := dialFallback(t, inbound, router, testCase.serverName, testCase.alpn)
+		if routed.metadata.Destination.Port != testCase.port {
+			t.Errorf("server name %s, ALPN %v: unexpected destination %s", testCase.serverName, testCase.alpn, routed.metadata.Destination)
+		}
+	}
+}
+
+func TestInbound_UpdateUsersCredit(t *testing.T) {
+	userA := adapter.ManagedUser{Name: "a", UUID: "b831381d-6324-4d53-ad4f-8cda48b30811"}
+	userB := adapter.ManagedUser{Name: "b", UUID: "e4f2c4c0-0b8d-4c5a-9f4e-7c7f6a0a3a21"}
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	inbound, err := NewInbound(context.Background(), router, log.StdLogger(), "vless-in", option.VLESSInboundOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	managed := inbound.(adapter.ManagedUserServer)
+	err = managed.UpdateUsers([]adapter.ManagedUser{userA, userB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			managed.UpdateUsers([]adapter.ManagedUser{userB, userA})
+			managed.UpdateUsers([]adapter.ManagedUser{userA, userB})
+		}
+	}()
+	client, err := vless.NewClient(userB.UUID, "", log.StdLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		clientConn, serverConn := net.Pipe()
+		go inbound.(adapter.TCPInjectableInbound).NewConnectionEx(context.Background(), serverConn, adapter.InboundContext{Source: M.ParseSocksaddr("127.0.0.1:10000")}, nil)
+		conn, err := client.DialEarlyConn(clientConn, M.ParseSocksaddr("example.com:80"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		go conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		var routed routedConnection
+		select {
+		case routed = <-router.routed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection not routed")
+		}
+		routed.conn.Close()
+		conn.Close()
+		if routed.metadata.User != userB.Name {
+			t.Fatalf("connection credited to %q", routed.metadata.User)
+		}
+	}
+}
+
+func dialFallback(t *testing.T, inbound adapter.Inbound, router *captureRouter, serverName string, alpn []string) routedConnection {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go inbound.(adapter.TCPInjectableInbound).NewConnectionEx(context.Background(), serverConn, adapter.InboundContext{Source: M.ParseSocksaddr("127.0.0.1:10000")}, nil)
+	tlsConn := tls.Client(clientConn, &tls.Config{ServerName: serverName, NextProtos: alpn, InsecureSkipVerify: true})
+	request := "GET / HTTP/1.1\r\nHost: " + serverName + "\r\n\r\n"
+	_, err = tlsConn.Write([]byte(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routed routedConnection
+	select {
+	case routed = <-router.routed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fallback not taken")
+	}
+	defer routed.conn.Close()
+	received := make([]byte, len(request))
+	_, err = io.ReadFull(routed.conn, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != request {
+		t.Fatalf("unexpected replay: %q", received)
+	}
+	return routed
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/fallback"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/mux"
 	"github.com/sagernet/sing-box/common/tls"
@@ -32,18 +34,22 @@ func RegisterInbound(registry *inbound.Registry) {
 	inbound.Register[option.VMessInboundOptions](registry, C.TypeVMess, NewInbound)
 }
 
-var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.ManagedUserServer    = (*Inbound)(nil)
+)
 
 type Inbound struct {
 	inbound.Adapter
-	ctx       context.Context
-	router    adapter.ConnectionRouterEx
-	logger    logger.ContextLogger
-	listener  *listener.Listener
-	service   *vmess.Service[int]
-	users     []option.VMessUser
-	tlsConfig tls.ServerConfig
-	transport adapter.V2RayServerTransport
+	ctx            context.Context
+	router         adapter.ConnectionRouterEx
+	logger         logger.ContextLogger
+	listener       *listener.Listener
+	serviceOptions []vmess.ServiceOption
+	users          atomic.Pointer[inboundUsers]
+	tlsConfig      tls.ServerConfig
+	transport      adapter.V2RayServerTransport
+	fallback       *fallback.Handler
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.VMessInboundOptions) (adapter.Inbound, error) {
@@ -52,38 +58,38 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		ctx:     ctx,
 		router:  uot.NewRouter(router, logger),
 		logger:  logger,
-		users:   options.Users,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
 	if err != nil {
 		return nil, err
 	}
-	var serviceOptions []vmess.ServiceOption
 	if timeFunc := ntp.TimeFuncFromContext(ctx); timeFunc != nil {
-		serviceOptions = append(serviceOptions, vmess.ServiceWithTimeFunc(timeFunc))
+		inbound.serviceOptions = append(inbound.serviceOptions, vmess.ServiceWithTimeFunc(timeFunc))
 	}
 	if options.Transport != nil && options.Transport.Type != "" {
-		serviceOptions = append(serviceOptions, vmess.ServiceWithDisableHeaderProtection())
+		inbound.serviceOptions = append(inbound.serviceOptions, vmess.ServiceWithDisableHeaderProtection())
 	}
-	service := vmess.NewService[int](adapter.NewUpstreamContextHandlerEx(inbound.newConnectionEx, inbound.newPacketConnectionEx), serviceOptions...)
-	inbound.service = service
-	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.VMessUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.VMessUser) string {
-		return it.UUID
-	}), common.Map(options.Users, func(it option.VMessUser) int {
-		return it.AlterId
-	}))
+	users, err := inbound.newUsers(options.Users)
 	if err != nil {
 		return nil, err
 	}
+	inbound.users.Store(users)
 	if options.TLS != nil {
 		inbound.tlsConfig, err = tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
 			return nil, err
 		}
 	}
+	if len(options.Fallbacks) > 0 {
+		if options.Transport != nil && options.Transport.Type != "" {
+			return nil, E.New("fallbacks is not supported with transport")
+		}
+		inbound.fallback, err = fallback.NewHandler(inbound.router, logger, options.Fallbacks, inbound.tlsConfig != nil)
+		if err != nil {
+			return nil, E.Cause(err, "create fallbacks")
+		}
+	}
 	if options.Transport != nil {
 		inbound.transport, err = v2ray.NewServerTransport(ctx, logger, common.PtrValueOrDefault(options.Transport), inbound.tlsConfig, (*inboundTransportHandler)(inbound))
 		if err != nil {
@@ -104,7 +110,7 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
-	err := h.service.Start()
+	err := h.users.Load().service.Start()
 	if err != nil {
 		return err
 	}
@@ -146,13 +152,46 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 
 func (h *Inbound) Close() error {
 	return common.Close(
-		h.service,
+		h.users.Load().service,
 		h.listener,
 		h.tlsConfig,
 		h.transport,
 	)
 }
 
+func (h *Inbound) SetTracker(tracker adapter.SSMTracker) {
+	h.router = adapter.NewSSMTrackedRouter(h.router, tracker, h.logger)
+}
+
+func (h *Inbound) UpdateUsers(users []adapter.ManagedUser) error {
+	for _, user := range users {
+		if user.UUID == "" {
+			return E.New("missing uuid for user ", user.Name)
+		}
+	}
+	newUsers, err := h.newUsers(common.Map(users, func(it adapter.ManagedUser) option.VMessUser {
+		return option.VMessUser{
+			Name: it.Name,
+			UUID: it.UUID,
+		}
+	}))
+	if err != nil {
+		return err
+	}
+	// managed users have no alter ids, so the new service has nothing to
+	// start, while the old one may have to stop generating legacy keys
+	return h.users.Swap(newUsers).service.Close()
+}
+
+func (h *Inbound) ManagedUsers() []adapter.ManagedUser {
+	return common.Map(h.users.Load().users, func(it option.VMessUser) adapter.ManagedUser {
+		return adapter.ManagedUser{
+			Name: it.Name,
+			UUID: it.UUID,
+		}
+	})
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil && h.transport == nil {
 		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
@@ -163,24 +202,39 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	var fallbackConn *fallback.Conn
+	if h.fallback != nil {
+		fallbackConn = h.fallback.NewConn(conn)
+		ctx = fallback.ContextWithConn(ctx, fallbackConn)
+		conn = fallbackConn
+	}
+	err := h.users.Load().service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
 	if err != nil {
+		if fallbackConn != nil {
+			metadata.Inbound = h.Tag()
+			metadata.InboundType = h.Type()
+			if h.fallback.NewConnectionEx(ctx, fallbackConn, metadata, onClose) {
+				h.logger.DebugContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": fallback"))
+				return
+			}
+		}
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
 	}
 }
 
 func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	fallback.Commit(ctx)
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -189,16 +243,17 @@ func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata a
 }
 
 func (h *Inbound) newPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	fallback.Commit(ctx)
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
-	userIndex, loaded := auth.UserFromContext[int](ctx)
+	userKey, loaded := auth.UserFromContext[adapter.ManagedUserKey](ctx)
 	if !loaded {
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := userKey.Name
 	if user == "" {
-		user = F.ToString(userIndex)
+		user = F.ToString(userKey.Index)
 	} else {
 		metadata.User = user
 	}
@@ -227,3 +282,26 @@ func (h *inboundTransportHandler) NewConnectionEx(ctx context.Context, conn net.
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 	(*Inbound)(h).NewConnectionEx(ctx, conn, metadata, onClose)
 }
+
+// inboundUsers is the user list together with the service authenticating
+// it. UpdateUsers replaces both at once instead of updating the service in
+// place, which would race with connections being authenticated.
+type inboundUsers struct {
+	users   []option.VMessUser
+	service *vmess.Service[adapter.ManagedUserKey]
+}
+
+func (h *Inbound) newUsers(users []option.VMessUser) (*inboundUsers, error) {
+	service := vmess.NewService[adapter.ManagedUserKey](adapter.NewUpstreamContextHandlerEx(h.newConnectionEx, h.newPacketConnectionEx), h.serviceOptions...)
+	err := service.UpdateUsers(adapter.NewManagedUserKeys(users, func(it option.VMessUser) string {
+		return it.Name
+	}), common.Map(users, func(it option.VMessUser) string {
+		return it.UUID
+	}), common.Map(users, func(it option.VMessUser) int {
+		return it.AlterId
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &inboundUsers{users, service}, nil
+}
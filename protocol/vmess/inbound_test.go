@@ -0,0 +1,94 @@
+package vmess
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type routedConnection struct {
+	conn     net.Conn
+	metadata adapter.InboundContext
+}
+
+type captureRouter struct {
+	adapter.Router
+	routed chan routedConnection
+}
+
+func (r *captureRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	r.routed <- routedConnection{conn, metadata}
+}
+
+func TestInbound_FallbackByPath(t *testing.T) {
+	router := &captureRouter{routed: make(chan routedConnection, 1)}
+	inbound, err := NewInbound(context.Background(), router, log.StdLogger(), "vmess-in", option.VMessInboundOptions{
+		Users: []option.VMessUser{{Name: "user", UUID: "b831381d-6324-4d53-ad4f-8cda48b30811"}},
+		Fallbacks: []option.V2RayFallbackOptions{
+			{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 1001}, Path: "/ws"},
+			{ServerOptions: option.ServerOptions{Server: "127.0.0.1", ServerPort: 1002}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		path string
+		port uint16
+	}{
+		{"/ws?ed=2048", 1001},
+		{"/index.html", 1002},
+	} {
+		routed := dialFallback(t, inbound, router, "GET "+testCase.path+" HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		if routed.metadata.Destination.Port != testCase.port {
+			t.Errorf("path %s: unexpected destination %s", testCase.path, routed.metadata.Destination)
+		}
+	}
+}
+
+func dialFallback(t *testing.T, inbound adapter.Inbound, router *captureRouter, request string) routedConnection {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go inbound.(adapter.TCPInjectableInbound).NewConnectionEx(context.Background(), serverConn, adapter.InboundContext{Source: M.ParseSocksaddr("127.0.0.1:10000")}, nil)
+	_, err = clientConn.Write([]byte(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routed routedConnection
+	select {
+	case routed = <-router.routed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fallback not taken")
+	}
+	defer routed.conn.Close()
+	received := make([]byte, len(request))
+	_, err = io.ReadFull(routed.conn, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != request {
+		t.Fatalf("unexpected replay: %q", received)
+	}
+	return routed
+}
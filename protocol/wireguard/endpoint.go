@@ -55,6 +55,7 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 			return !M.ParseAddr(it.Address).IsValid()
 		}),
 		ResolverOnDetour: true,
+		Outbound:         true,
 	})
 	if err != nil {
 		return nil, err
@@ -97,9 +98,11 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 				AllowedIPs:                  it.AllowedIPs,
 				PersistentKeepaliveInterval: it.PersistentKeepaliveInterval,
 				Reserved:                    it.Reserved,
+				Amnezia:                     amneziaOptions(it.Amnezia),
 			}
 		}),
 		Workers: options.Workers,
+		Amnezia: amneziaOptions(options.Amnezia),
 	})
 	if err != nil {
 		return nil, err
@@ -210,3 +213,20 @@ func (w *Endpoint) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 	}
 	return w.endpoint.ListenPacket(ctx, destination)
 }
+
+func amneziaOptions(options *option.WireGuardAmneziaOptions) *wireguard.AmneziaOptions {
+	if options == nil {
+		return nil
+	}
+	return &wireguard.AmneziaOptions{
+		JunkPacketCount:            options.JunkPacketCount,
+		JunkPacketMinSize:          options.JunkPacketMinSize,
+		JunkPacketMaxSize:          options.JunkPacketMaxSize,
+		InitPacketJunkSize:         options.InitPacketJunkSize,
+		ResponsePacketJunkSize:     options.ResponsePacketJunkSize,
+		InitPacketMagicHeader:      options.InitPacketMagicHeader,
+		ResponsePacketMagicHeader:  options.ResponsePacketMagicHeader,
+		UnderloadPacketMagicHeader: options.UnderloadPacketMagicHeader,
+		TransportPacketMagicHeader: options.TransportPacketMagicHeader,
+	}
+}
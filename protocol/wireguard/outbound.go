@@ -56,6 +56,7 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 			return it.ServerIsDomain()
 		}),
 		ResolverOnDetour: true,
+		Outbound:         true,
 	})
 	if err != nil {
 		return nil, err
@@ -31,6 +31,7 @@ var _ adapter.ConnectionManager = (*ConnectionManager)(nil)
 
 type ConnectionManager struct {
 	logger      logger.ContextLogger
+	capture     adapter.ConnectionCapture
 	access      sync.Mutex
 	connections list.List[io.Closer]
 }
@@ -41,6 +42,10 @@ func NewConnectionManager(logger logger.ContextLogger) *ConnectionManager {
 	}
 }
 
+func (m *ConnectionManager) SetCapture(capture adapter.ConnectionCapture) {
+	m.capture = capture
+}
+
 func (m *ConnectionManager) Start(stage adapter.StartStage) error {
 	return nil
 }
@@ -105,6 +110,9 @@ func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, co
 	if metadata.TLSFragment || metadata.TLSRecordFragment {
 		remoteConn = tf.NewConn(remoteConn, ctx, metadata.TLSFragment, metadata.TLSRecordFragment, metadata.TLSFragmentFallbackDelay)
 	}
+	if m.capture != nil {
+		conn = m.capture.CaptureConnection(ctx, conn, remoteConn, metadata, this)
+	}
 	m.access.Lock()
 	element := m.connections.PushBack(conn)
 	m.access.Unlock()
@@ -240,6 +248,9 @@ func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dial
 	if udpTimeout > 0 {
 		ctx, conn = canceler.NewPacketConn(ctx, conn, udpTimeout)
 	}
+	if m.capture != nil {
+		conn = m.capture.CapturePacketConnection(ctx, conn, metadata, this)
+	}
 	destination := bufio.NewPacketConn(remotePacketConn)
 	m.access.Lock()
 	element := m.connections.PushBack(conn)
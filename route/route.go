@@ -372,7 +372,7 @@ func (r *Router) matchRule(
 	}
 
 match:
-	for currentRuleIndex, currentRule := range r.rules {
+	for currentRuleIndex, currentRule := range r.Rules() {
 		metadata.ResetRuleCache()
 		if !currentRule.Match(metadata) {
 			continue
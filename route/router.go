@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/process"
@@ -30,6 +31,7 @@ type Router struct {
 	dnsTransport      adapter.DNSTransportManager
 	connection        adapter.ConnectionManager
 	network           adapter.NetworkManager
+	access            sync.RWMutex
 	rules             []adapter.Rule
 	needFindProcess   bool
 	ruleSets          []adapter.RuleSet
@@ -62,112 +64,220 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 }
 
 func (r *Router) Initialize(rules []option.Rule, ruleSets []option.RuleSet) error {
+	newRules, err := r.newRules(rules)
+	if err != nil {
+		return err
+	}
+	newRuleSets, newRuleSetMap, err := r.newRuleSets(ruleSets)
+	if err != nil {
+		return err
+	}
+	r.rules = newRules
+	r.ruleSets = newRuleSets
+	r.ruleSetMap = newRuleSetMap
+	return nil
+}
+
+func (r *Router) newRules(rules []option.Rule) ([]adapter.Rule, error) {
+	newRules := make([]adapter.Rule, 0, len(rules))
 	for i, options := range rules {
 		rule, err := R.NewRule(r.ctx, r.logger, options, false)
 		if err != nil {
-			return E.Cause(err, "parse rule[", i, "]")
+			return nil, E.Cause(err, "parse rule[", i, "]")
 		}
-		r.rules = append(r.rules, rule)
+		newRules = append(newRules, rule)
 	}
+	return newRules, nil
+}
+
+func (r *Router) newRuleSets(ruleSets []option.RuleSet) ([]adapter.RuleSet, map[string]adapter.RuleSet, error) {
+	newRuleSets := make([]adapter.RuleSet, 0, len(ruleSets))
+	newRuleSetMap := make(map[string]adapter.RuleSet)
 	for i, options := range ruleSets {
-		if _, exists := r.ruleSetMap[options.Tag]; exists {
-			return E.New("duplicate rule-set tag: ", options.Tag)
+		if _, exists := newRuleSetMap[options.Tag]; exists {
+			return nil, nil, E.New("duplicate rule-set tag: ", options.Tag)
 		}
 		ruleSet, err := R.NewRuleSet(r.ctx, r.logger, options)
 		if err != nil {
-			return E.Cause(err, "parse rule-set[", i, "]")
+			return nil, nil, E.Cause(err, "parse rule-set[", i, "]")
 		}
-		r.ruleSets = append(r.ruleSets, ruleSet)
-		r.ruleSetMap[options.Tag] = ruleSet
+		newRuleSets = append(newRuleSets, ruleSet)
+		newRuleSetMap[options.Tag] = ruleSet
 	}
-	return nil
+	return newRuleSets, newRuleSetMap, nil
 }
 
 func (r *Router) Start(stage adapter.StartStage) error {
-	monitor := taskmonitor.New(r.logger, C.StartTimeout)
 	switch stage {
 	case adapter.StartStateStart:
-		var cacheContext *adapter.HTTPStartContext
-		if len(r.ruleSets) > 0 {
-			monitor.Start("initialize rule-set")
-			cacheContext = adapter.NewHTTPStartContext(r.ctx)
-			var ruleSetStartGroup task.Group
-			for i, ruleSet := range r.ruleSets {
-				ruleSetInPlace := ruleSet
-				ruleSetStartGroup.Append0(func(ctx context.Context) error {
-					err := ruleSetInPlace.StartContext(ctx, cacheContext)
-					if err != nil {
-						return E.Cause(err, "initialize rule-set[", i, "]")
-					}
-					return nil
-				})
-			}
-			ruleSetStartGroup.Concurrency(5)
-			ruleSetStartGroup.FastFail()
-			err := ruleSetStartGroup.Run(r.ctx)
-			monitor.Finish()
-			if err != nil {
-				return err
-			}
+		err := r.startRuleSets(r.ruleSets)
+		if err != nil {
+			return err
 		}
-		if cacheContext != nil {
-			cacheContext.Close()
+		r.updateRuleSetMetadata(r.ruleSets)
+	case adapter.StartStatePostStart:
+		err := r.startRules(r.rules)
+		if err != nil {
+			return err
 		}
-		needFindProcess := r.needFindProcess
-		for _, ruleSet := range r.ruleSets {
-			metadata := ruleSet.Metadata()
-			if metadata.ContainsProcessRule {
-				needFindProcess = true
-			}
-			if metadata.ContainsWIFIRule {
-				r.needWIFIState = true
-			}
+		err = r.postStartRuleSets(r.ruleSets)
+		if err != nil {
+			return err
 		}
-		if needFindProcess {
-			if r.platformInterface != nil {
-				r.processSearcher = r.platformInterface
-			} else {
-				monitor.Start("initialize process searcher")
-				searcher, err := process.NewSearcher(process.Config{
-					Logger:         r.logger,
-					PackageManager: r.network.PackageManager(),
-				})
-				monitor.Finish()
-				if err != nil {
-					if err != os.ErrInvalid {
-						r.logger.Warn(E.Cause(err, "create process searcher"))
-					}
-				} else {
-					r.processSearcher = searcher
-				}
-			}
+		r.started = true
+		return nil
+	case adapter.StartStateStarted:
+		for _, ruleSet := range r.RuleSets() {
+			ruleSet.Cleanup()
 		}
-	case adapter.StartStatePostStart:
-		for i, rule := range r.rules {
-			monitor.Start("initialize rule[", i, "]")
-			err := rule.Start()
-			monitor.Finish()
+		runtime.GC()
+	}
+	return nil
+}
+
+func (r *Router) startRuleSets(ruleSets []adapter.RuleSet) error {
+	if len(ruleSets) == 0 {
+		return nil
+	}
+	monitor := taskmonitor.New(r.logger, C.StartTimeout)
+	monitor.Start("initialize rule-set")
+	cacheContext := adapter.NewHTTPStartContext(r.ctx)
+	defer cacheContext.Close()
+	var ruleSetStartGroup task.Group
+	for i, ruleSet := range ruleSets {
+		ruleSetInPlace := ruleSet
+		ruleSetStartGroup.Append0(func(ctx context.Context) error {
+			err := ruleSetInPlace.StartContext(ctx, cacheContext)
 			if err != nil {
-				return E.Cause(err, "initialize rule[", i, "]")
+				return E.Cause(err, "initialize rule-set[", i, "]")
 			}
+			return nil
+		})
+	}
+	ruleSetStartGroup.Concurrency(5)
+	ruleSetStartGroup.FastFail()
+	err := ruleSetStartGroup.Run(r.ctx)
+	monitor.Finish()
+	return err
+}
+
+func (r *Router) postStartRuleSets(ruleSets []adapter.RuleSet) error {
+	monitor := taskmonitor.New(r.logger, C.StartTimeout)
+	for _, ruleSet := range ruleSets {
+		monitor.Start("post start rule_set[", ruleSet.Name(), "]")
+		err := ruleSet.PostStart()
+		monitor.Finish()
+		if err != nil {
+			return E.Cause(err, "post start rule_set[", ruleSet.Name(), "]")
+		}
+	}
+	return nil
+}
+
+func (r *Router) startRules(rules []adapter.Rule) error {
+	monitor := taskmonitor.New(r.logger, C.StartTimeout)
+	for i, rule := range rules {
+		monitor.Start("initialize rule[", i, "]")
+		err := rule.Start()
+		monitor.Finish()
+		if err != nil {
+			return E.Cause(err, "initialize rule[", i, "]")
 		}
-		for _, ruleSet := range r.ruleSets {
-			monitor.Start("post start rule_set[", ruleSet.Name(), "]")
-			err := ruleSet.PostStart()
+	}
+	return nil
+}
+
+func (r *Router) updateRuleSetMetadata(ruleSets []adapter.RuleSet) {
+	needFindProcess := r.needFindProcess
+	for _, ruleSet := range ruleSets {
+		metadata := ruleSet.Metadata()
+		if metadata.ContainsProcessRule {
+			needFindProcess = true
+		}
+		if metadata.ContainsWIFIRule {
+			r.needWIFIState = true
+		}
+	}
+	if needFindProcess && r.processSearcher == nil {
+		if r.platformInterface != nil {
+			r.processSearcher = r.platformInterface
+		} else {
+			monitor := taskmonitor.New(r.logger, C.StartTimeout)
+			monitor.Start("initialize process searcher")
+			searcher, err := process.NewSearcher(process.Config{
+				Logger:         r.logger,
+				PackageManager: r.network.PackageManager(),
+			})
 			monitor.Finish()
 			if err != nil {
-				return E.Cause(err, "post start rule_set[", ruleSet.Name(), "]")
+				if err != os.ErrInvalid {
+					r.logger.Warn(E.Cause(err, "create process searcher"))
+				}
+			} else {
+				r.processSearcher = searcher
 			}
 		}
-		r.started = true
-		return nil
-	case adapter.StartStateStarted:
-		for _, ruleSet := range r.ruleSets {
-			ruleSet.Cleanup()
+	}
+}
+
+// Reload replaces the rules and rule-sets of a started router. The new
+// rule-sets are started before they are swapped in, the previous ones are
+// returned so that the caller can close them once no DNS rule references
+// them anymore.
+func (r *Router) Reload(rules []option.Rule, ruleSets []option.RuleSet) ([]adapter.RuleSet, error) {
+	newRules, err := r.newRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	newRuleSets, newRuleSetMap, err := r.newRuleSets(ruleSets)
+	if err != nil {
+		return nil, err
+	}
+	closeRuleSets := func() {
+		for _, ruleSet := range newRuleSets {
+			ruleSet.Close()
 		}
-		runtime.GC()
 	}
-	return nil
+	err = r.startRuleSets(newRuleSets)
+	if err != nil {
+		closeRuleSets()
+		return nil, err
+	}
+	err = r.postStartRuleSets(newRuleSets)
+	if err != nil {
+		closeRuleSets()
+		return nil, err
+	}
+	r.access.Lock()
+	previousRuleSets, previousRuleSetMap := r.ruleSets, r.ruleSetMap
+	r.ruleSets, r.ruleSetMap = newRuleSets, newRuleSetMap
+	r.access.Unlock()
+	err = r.startRules(newRules)
+	if err != nil {
+		r.access.Lock()
+		r.ruleSets, r.ruleSetMap = previousRuleSets, previousRuleSetMap
+		r.access.Unlock()
+		for _, rule := range newRules {
+			rule.Close()
+		}
+		closeRuleSets()
+		return nil, err
+	}
+	r.updateRuleSetMetadata(newRuleSets)
+	if hasRule(rules, isWIFIRule) {
+		r.needWIFIState = true
+	}
+	r.access.Lock()
+	previousRules := r.rules
+	r.rules = newRules
+	r.access.Unlock()
+	for i, rule := range previousRules {
+		err = rule.Close()
+		if err != nil {
+			r.logger.Warn(E.Cause(err, "close previous rule[", i, "]"))
+		}
+	}
+	return previousRuleSets, nil
 }
 
 func (r *Router) Close() error {
@@ -191,15 +301,25 @@ func (r *Router) Close() error {
 }
 
 func (r *Router) RuleSet(tag string) (adapter.RuleSet, bool) {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	ruleSet, loaded := r.ruleSetMap[tag]
 	return ruleSet, loaded
 }
 
+func (r *Router) RuleSets() []adapter.RuleSet {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.ruleSets
+}
+
 func (r *Router) NeedWIFIState() bool {
 	return r.needWIFIState
 }
 
 func (r *Router) Rules() []adapter.Rule {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.rules
 }
 
@@ -138,6 +138,16 @@ func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
 	return !r.invert
 }
 
+func (r *abstractDefaultRule) RuleSets() []string {
+	var tags []string
+	for _, item := range r.allItems {
+		if ruleSetItem, isRuleSet := item.(*RuleSetItem); isRuleSet {
+			tags = append(tags, ruleSetItem.tagList...)
+		}
+	}
+	return tags
+}
+
 func (r *abstractDefaultRule) Action() adapter.RuleAction {
 	return r.action
 }
@@ -206,6 +216,16 @@ func (r *abstractLogicalRule) Match(metadata *adapter.InboundContext) bool {
 	}
 }
 
+func (r *abstractLogicalRule) RuleSets() []string {
+	var tags []string
+	for _, rule := range r.rules {
+		if referrer, isReferrer := rule.(adapter.RuleSetReferrer); isReferrer {
+			tags = append(tags, referrer.RuleSets()...)
+		}
+	}
+	return common.Uniq(tags)
+}
+
 func (r *abstractLogicalRule) Action() adapter.RuleAction {
 	return r.action
 }
@@ -58,7 +58,10 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 			TLSRecordFragment:         action.RouteOptionsOptions.TLSRecordFragment,
 		}, nil
 	case C.RuleActionTypeDirect:
-		directDialer, err := dialer.New(ctx, option.DialerOptions(action.DirectOptions), false)
+		directDialer, err := dialer.NewWithOptions(dialer.Options{
+			Context: ctx,
+			Options: option.DialerOptions(action.DirectOptions),
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -67,6 +67,16 @@ func NewLocalRuleSet(ctx context.Context, logger logger.Logger, options option.R
 				uErr := ruleSet.reloadFile(path)
 				if uErr != nil {
 					logger.Error(E.Cause(uErr, "reload rule-set ", options.Tag))
+					adapter.EmitEvent(ctx, adapter.Event{
+						Type:    adapter.EventRuleSetFailed,
+						Tag:     options.Tag,
+						Message: uErr.Error(),
+					})
+				} else {
+					adapter.EmitEvent(ctx, adapter.Event{
+						Type: adapter.EventRuleSetUpdated,
+						Tag:  options.Tag,
+					})
 				}
 			},
 		})
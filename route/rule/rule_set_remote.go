@@ -204,12 +204,7 @@ func (s *RemoteRuleSet) loadBytes(content []byte) error {
 
 func (s *RemoteRuleSet) loopUpdate() {
 	if time.Since(s.lastUpdated) > s.updateInterval {
-		err := s.fetch(s.ctx, nil)
-		if err != nil {
-			s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err)
-		} else if s.refs.Load() == 0 {
-			s.rules = nil
-		}
+		s.updateOnce()
 	}
 	for {
 		runtime.GC()
@@ -226,6 +221,11 @@ func (s *RemoteRuleSet) updateOnce() {
 	err := s.fetch(s.ctx, nil)
 	if err != nil {
 		s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err)
+		adapter.EmitEvent(s.ctx, adapter.Event{
+			Type:    adapter.EventRuleSetFailed,
+			Tag:     s.options.Tag,
+			Message: err.Error(),
+		})
 	} else if s.refs.Load() == 0 {
 		s.rules = nil
 	}
@@ -309,6 +309,12 @@ func (s *RemoteRuleSet) fetch(ctx context.Context, startContext *adapter.HTTPSta
 		}
 	}
 	s.logger.Info("updated rule-set ", s.options.Tag)
+	if startContext == nil {
+		adapter.EmitEvent(s.ctx, adapter.Event{
+			Type: adapter.EventRuleSetUpdated,
+			Tag:  s.options.Tag,
+		})
+	}
 	return nil
 }
 
@@ -53,6 +53,14 @@ func (s *APIServer) getServerInfo(writer http.ResponseWriter, request *http.Requ
 type UserObject struct {
 	UserName        string `json:"username"`
 	Password        string `json:"uPSK,omitempty"`
+	UUID            string `json:"uuid,omitempty"`
+	Flow            string `json:"flow,omitempty"`
+	Quota           int64  `json:"quota,omitempty"`
+	UsedBytes       int64  `json:"usedBytes"`
+	ExpiresAt       int64  `json:"expiresAt,omitempty"`
+	MaxConnections  int    `json:"maxConnections,omitempty"`
+	MaxIPs          int    `json:"maxIPs,omitempty"`
+	Connections     int    `json:"connections"`
 	DownlinkBytes   int64  `json:"downlinkBytes"`
 	UplinkBytes     int64  `json:"uplinkBytes"`
 	DownlinkPackets int64  `json:"downlinkPackets"`
@@ -67,18 +75,39 @@ func (s *APIServer) listUser(writer http.ResponseWriter, request *http.Request)
 	})
 }
 
-func (s *APIServer) addUser(writer http.ResponseWriter, request *http.Request) {
-	var addRequest struct {
-		UserName string `json:"username"`
-		Password string `json:"uPSK"`
+type userRequest struct {
+	UserName       string `json:"username"`
+	Password       string `json:"uPSK"`
+	UUID           string `json:"uuid"`
+	Flow           string `json:"flow"`
+	Quota          int64  `json:"quota"`
+	ExpiresAt      int64  `json:"expiresAt"`
+	MaxConnections int    `json:"maxConnections"`
+	MaxIPs         int    `json:"maxIPs"`
+	ResetUsage     bool   `json:"resetUsage"`
+}
+
+func (r *userRequest) settings() UserSettings {
+	return UserSettings{
+		Password:       r.Password,
+		UUID:           r.UUID,
+		Flow:           r.Flow,
+		Quota:          r.Quota,
+		ExpiresAt:      r.ExpiresAt,
+		MaxConnections: r.MaxConnections,
+		MaxIPs:         r.MaxIPs,
 	}
+}
+
+func (s *APIServer) addUser(writer http.ResponseWriter, request *http.Request) {
+	var addRequest userRequest
 	err := render.DecodeJSON(request.Body, &addRequest)
 	if err != nil {
 		render.Status(request, http.StatusBadRequest)
 		render.PlainText(writer, request, err.Error())
 		return
 	}
-	err = s.user.Add(addRequest.UserName, addRequest.Password)
+	err = s.user.Add(addRequest.UserName, addRequest.settings())
 	if err != nil {
 		render.Status(request, http.StatusBadRequest)
 		render.PlainText(writer, request, err.Error())
@@ -93,16 +122,12 @@ func (s *APIServer) getUser(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	uPSK, loaded := s.user.Get(userName)
+	user, loaded := s.user.Get(userName)
 	if !loaded {
 		writer.WriteHeader(http.StatusNotFound)
 		return
 	}
-	user := UserObject{
-		UserName: userName,
-		Password: uPSK,
-	}
-	s.traffic.ReadUser(&user)
+	s.traffic.ReadUser(user)
 	render.JSON(writer, request, user)
 }
 
@@ -112,9 +137,7 @@ func (s *APIServer) updateUser(writer http.ResponseWriter, request *http.Request
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	var updateRequest struct {
-		Password string `json:"uPSK"`
-	}
+	var updateRequest userRequest
 	err := render.DecodeJSON(request.Body, &updateRequest)
 	if err != nil {
 		render.Status(request, http.StatusBadRequest)
@@ -126,7 +149,7 @@ func (s *APIServer) updateUser(writer http.ResponseWriter, request *http.Request
 		writer.WriteHeader(http.StatusNotFound)
 		return
 	}
-	err = s.user.Update(userName, updateRequest.Password)
+	err = s.user.Update(userName, updateRequest.settings(), updateRequest.ResetUsage)
 	if err != nil {
 		render.Status(request, http.StatusBadRequest)
 		render.PlainText(writer, request, err.Error())
@@ -162,6 +185,7 @@ func (s *APIServer) getStats(writer http.ResponseWriter, request *http.Request)
 	s.traffic.ReadUsers(users, requireClear)
 	for i := range users {
 		users[i].Password = ""
+		users[i].UUID = ""
 	}
 	uplinkBytes, downlinkBytes, uplinkPackets, downlinkPackets, tcpSessions, udpSessions := s.traffic.ReadGlobal(requireClear)
 
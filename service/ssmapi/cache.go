@@ -17,19 +17,31 @@ type Cache struct {
 }
 
 type EndpointCache struct {
-	GlobalUplink          int64                             `json:"global_uplink"`
-	GlobalDownlink        int64                             `json:"global_downlink"`
-	GlobalUplinkPackets   int64                             `json:"global_uplink_packets"`
-	GlobalDownlinkPackets int64                             `json:"global_downlink_packets"`
-	GlobalTCPSessions     int64                             `json:"global_tcp_sessions"`
-	GlobalUDPSessions     int64                             `json:"global_udp_sessions"`
-	UserUplink            *badjson.TypedMap[string, int64]  `json:"user_uplink"`
-	UserDownlink          *badjson.TypedMap[string, int64]  `json:"user_downlink"`
-	UserUplinkPackets     *badjson.TypedMap[string, int64]  `json:"user_uplink_packets"`
-	UserDownlinkPackets   *badjson.TypedMap[string, int64]  `json:"user_downlink_packets"`
-	UserTCPSessions       *badjson.TypedMap[string, int64]  `json:"user_tcp_sessions"`
-	UserUDPSessions       *badjson.TypedMap[string, int64]  `json:"user_udp_sessions"`
-	Users                 *badjson.TypedMap[string, string] `json:"users"`
+	GlobalUplink          int64                                 `json:"global_uplink"`
+	GlobalDownlink        int64                                 `json:"global_downlink"`
+	GlobalUplinkPackets   int64                                 `json:"global_uplink_packets"`
+	GlobalDownlinkPackets int64                                 `json:"global_downlink_packets"`
+	GlobalTCPSessions     int64                                 `json:"global_tcp_sessions"`
+	GlobalUDPSessions     int64                                 `json:"global_udp_sessions"`
+	UserUplink            *badjson.TypedMap[string, int64]      `json:"user_uplink"`
+	UserDownlink          *badjson.TypedMap[string, int64]      `json:"user_downlink"`
+	UserUplinkPackets     *badjson.TypedMap[string, int64]      `json:"user_uplink_packets"`
+	UserDownlinkPackets   *badjson.TypedMap[string, int64]      `json:"user_downlink_packets"`
+	UserTCPSessions       *badjson.TypedMap[string, int64]      `json:"user_tcp_sessions"`
+	UserUDPSessions       *badjson.TypedMap[string, int64]      `json:"user_udp_sessions"`
+	Users                 *badjson.TypedMap[string, string]     `json:"users,omitempty"`
+	ManagedUsers          *badjson.TypedMap[string, *UserCache] `json:"managed_users,omitempty"`
+}
+
+type UserCache struct {
+	Password       string `json:"password,omitempty"`
+	UUID           string `json:"uuid,omitempty"`
+	Flow           string `json:"flow,omitempty"`
+	Quota          int64  `json:"quota,omitempty"`
+	UsedBytes      int64  `json:"used_bytes,omitempty"`
+	ExpiresAt      int64  `json:"expires_at,omitempty"`
+	MaxConnections int    `json:"max_connections,omitempty"`
+	MaxIPs         int    `json:"max_ips,omitempty"`
 }
 
 func (s *Service) loadCache() error {
@@ -117,8 +129,7 @@ func (s *Service) decodeCache(cacheBinary []byte) error {
 		if !loaded {
 			continue
 		}
-		userManager.usersMap = typedMap(entry.Value.Users)
-		_ = userManager.postUpdate(false)
+		userManager.loadCache(entry.Value)
 	}
 	return nil
 }
@@ -133,7 +144,6 @@ func (s *Service) encodeCache() ([]byte, error) {
 			userDownlinkPackets = new(badjson.TypedMap[string, int64])
 			userTCPSessions     = new(badjson.TypedMap[string, int64])
 			userUDPSessions     = new(badjson.TypedMap[string, int64])
-			userMap             = new(badjson.TypedMap[string, string])
 		)
 		for user, uplink := range traffic.userUplink {
 			if uplink.Load() > 0 {
@@ -165,14 +175,9 @@ func (s *Service) encodeCache() ([]byte, error) {
 				userUDPSessions.Put(user, udpSessions.Load())
 			}
 		}
-		userManager := s.users[tag]
-		if userManager != nil && len(userManager.usersMap) > 0 {
-			userMap = new(badjson.TypedMap[string, string])
-			for username, password := range userManager.usersMap {
-				if username != "" && password != "" {
-					userMap.Put(username, password)
-				}
-			}
+		var managedUsers *badjson.TypedMap[string, *UserCache]
+		if userManager := s.users[tag]; userManager != nil {
+			managedUsers = userManager.encodeCache()
 		}
 		endpoints.Put(tag, &EndpointCache{
 			GlobalUplink:          traffic.globalUplink.Load(),
@@ -187,7 +192,7 @@ func (s *Service) encodeCache() ([]byte, error) {
 			UserDownlinkPackets:   sortTypedMap(userDownlinkPackets),
 			UserTCPSessions:       sortTypedMap(userTCPSessions),
 			UserUDPSessions:       sortTypedMap(userUDPSessions),
-			Users:                 sortTypedMap(userMap),
+			ManagedUsers:          sortTypedMap(managedUsers),
 		})
 	}
 	var buffer bytes.Buffer
@@ -228,12 +233,53 @@ func typedAtomicInt64Map(trafficMap *badjson.TypedMap[string, int64]) map[string
 	return result
 }
 
-func typedMap[T comparable](trafficMap *badjson.TypedMap[string, T]) map[string]T {
-	result := make(map[string]T)
-	if trafficMap != nil {
-		for _, entry := range trafficMap.Entries() {
-			result[entry.Key] = entry.Value
+func (m *UserManager) loadCache(cache *EndpointCache) {
+	m.access.Lock()
+	defer m.access.Unlock()
+	m.usersMap = make(map[string]*managedUser)
+	if cache.ManagedUsers != nil {
+		for _, entry := range cache.ManagedUsers.Entries() {
+			user := newManagedUser(UserSettings{
+				Password:       entry.Value.Password,
+				UUID:           entry.Value.UUID,
+				Flow:           entry.Value.Flow,
+				Quota:          entry.Value.Quota,
+				ExpiresAt:      entry.Value.ExpiresAt,
+				MaxConnections: entry.Value.MaxConnections,
+				MaxIPs:         entry.Value.MaxIPs,
+			})
+			user.usedBytes.Store(entry.Value.UsedBytes)
+			m.usersMap[entry.Key] = user
+		}
+	} else if cache.Users != nil {
+		for _, entry := range cache.Users.Entries() {
+			m.usersMap[entry.Key] = newManagedUser(UserSettings{Password: entry.Value})
 		}
 	}
-	return result
+	_ = m.postUpdate(false)
+}
+
+func (m *UserManager) encodeCache() *badjson.TypedMap[string, *UserCache] {
+	m.access.Lock()
+	defer m.access.Unlock()
+	if len(m.usersMap) == 0 {
+		return nil
+	}
+	userMap := new(badjson.TypedMap[string, *UserCache])
+	for username, user := range m.usersMap {
+		if username == "" {
+			continue
+		}
+		userMap.Put(username, &UserCache{
+			Password:       user.Password,
+			UUID:           user.UUID,
+			Flow:           user.Flow,
+			Quota:          user.Quota,
+			UsedBytes:      user.usedBytes.Load(),
+			ExpiresAt:      user.ExpiresAt,
+			MaxConnections: user.MaxConnections,
+			MaxIPs:         user.MaxIPs,
+		})
+	}
+	return userMap
 }
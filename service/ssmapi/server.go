@@ -24,10 +24,16 @@ import (
 	"golang.org/x/net/http2"
 )
 
+var _ adapter.ManagedUserOwner = (*Service)(nil)
+
 func RegisterService(registry *boxService.Registry) {
 	boxService.Register[option.SSMAPIServiceOptions](registry, C.TypeSSMAPI, NewService)
 }
 
+// enforceInterval bounds how long an established connection outlives the
+// expiry or quota of its user.
+const enforceInterval = 5 * time.Second
+
 type Service struct {
 	boxService.Adapter
 	ctx            context.Context
@@ -40,6 +46,7 @@ type Service struct {
 	users          map[string]*UserManager
 	cachePath      string
 	saveTicker     *time.Ticker
+	enforceTicker  *time.Ticker
 	lastSavedCache []byte
 	cacheMutex     sync.Mutex
 }
@@ -74,13 +81,13 @@ func NewService(ctx context.Context, logger log.ContextLogger, tag string, optio
 		if !loaded {
 			return nil, E.New("parse SSM server[", i, "]: inbound ", entry.Value, " not found")
 		}
-		managedServer, isManaged := inbound.(adapter.ManagedSSMServer)
+		managedServer, isManaged := inbound.(adapter.ManagedUserServer)
 		if !isManaged {
-			return nil, E.New("parse SSM server[", i, "]: inbound/", inbound.Type(), "[", inbound.Tag(), "] is not a SSM server")
+			return nil, E.New("parse SSM server[", i, "]: inbound/", inbound.Type(), "[", inbound.Tag(), "] does not support managed users")
 		}
 		traffic := NewTrafficManager()
-		managedServer.SetTracker(traffic)
 		user := NewUserManager(managedServer, traffic)
+		managedServer.SetTracker(user)
 		chiRouter.Route(entry.Key, NewAPIServer(logger, traffic, user).Route)
 		s.traffics[entry.Key] = traffic
 		s.users[entry.Key] = user
@@ -95,6 +102,15 @@ func NewService(ctx context.Context, logger log.ContextLogger, tag string, optio
 	return s, nil
 }
 
+func (s *Service) ManagedUserEditor(inboundTag string) (adapter.ManagedUserEditor, bool) {
+	for _, user := range s.users {
+		if user.server.Tag() == inboundTag {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
 func (s *Service) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
@@ -104,6 +120,7 @@ func (s *Service) Start(stage adapter.StartStage) error {
 		s.logger.Error(E.Cause(err, "load cache"))
 	}
 	s.saveTicker = time.NewTicker(1 * time.Minute)
+	s.enforceTicker = time.NewTicker(enforceInterval)
 	go s.loopSaveCache()
 	if s.tlsConfig != nil {
 		err = s.tlsConfig.Start()
@@ -135,6 +152,12 @@ func (s *Service) loopSaveCache() {
 		select {
 		case <-s.ctx.Done():
 			return
+		case <-s.enforceTicker.C:
+			// limits are checked when a connection is accepted; quota and
+			// expiry of established connections only here
+			for _, user := range s.users {
+				user.Enforce()
+			}
 		case <-s.saveTicker.C:
 			err := s.saveCache()
 			if err != nil {
@@ -150,6 +173,7 @@ func (s *Service) Close() error {
 	}
 	if s.saveTicker != nil {
 		s.saveTicker.Stop()
+		s.enforceTicker.Stop()
 	}
 	err := s.saveCache()
 	if err != nil {
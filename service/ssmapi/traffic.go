@@ -10,8 +10,6 @@ import (
 	N "github.com/sagernet/sing/common/network"
 )
 
-var _ adapter.SSMTracker = (*TrafficManager)(nil)
-
 type TrafficManager struct {
 	globalUplink          atomic.Int64
 	globalDownlink        atomic.Int64
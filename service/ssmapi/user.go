@@ -1,35 +1,86 @@
 package ssmapi
 
 import (
+	"io"
+	"net"
+	"net/netip"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/bufio"
 	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/x/list"
+)
+
+var (
+	_ adapter.SSMTracker        = (*UserManager)(nil)
+	_ adapter.ManagedUserEditor = (*UserManager)(nil)
 )
 
 type UserManager struct {
 	access         sync.Mutex
-	usersMap       map[string]string
-	server         adapter.ManagedSSMServer
+	usersMap       map[string]*managedUser
+	server         adapter.ManagedUserServer
 	trafficManager *TrafficManager
 }
 
-func NewUserManager(inbound adapter.ManagedSSMServer, trafficManager *TrafficManager) *UserManager {
+// UserSettings are the credentials and limits of a managed user.
+type UserSettings struct {
+	Password       string
+	UUID           string
+	Flow           string
+	Quota          int64
+	ExpiresAt      int64
+	MaxConnections int
+	MaxIPs         int
+}
+
+type managedUser struct {
+	UserSettings
+	usedBytes   atomic.Int64
+	sourceIPs   map[netip.Addr]int
+	connections list.List[io.Closer]
+}
+
+func NewUserManager(inbound adapter.ManagedUserServer, trafficManager *TrafficManager) *UserManager {
 	return &UserManager{
-		usersMap:       make(map[string]string),
+		usersMap:       make(map[string]*managedUser),
 		server:         inbound,
 		trafficManager: trafficManager,
 	}
 }
 
+func newManagedUser(settings UserSettings) *managedUser {
+	return &managedUser{
+		UserSettings: settings,
+		sourceIPs:    make(map[netip.Addr]int),
+	}
+}
+
+// postUpdate pushes the users to the inbound sorted by name, so that users
+// keep their position in the list unless one before them is added or
+// deleted.
 func (m *UserManager) postUpdate(updated bool) error {
 	users := make([]string, 0, len(m.usersMap))
-	uPSKs := make([]string, 0, len(m.usersMap))
-	for username, password := range m.usersMap {
+	for username := range m.usersMap {
 		users = append(users, username)
-		uPSKs = append(uPSKs, password)
 	}
-	err := m.server.UpdateUsers(users, uPSKs)
+	slices.Sort(users)
+	managedUsers := make([]adapter.ManagedUser, 0, len(users))
+	for _, username := range users {
+		user := m.usersMap[username]
+		managedUsers = append(managedUsers, adapter.ManagedUser{
+			Name:     username,
+			Password: user.Password,
+			UUID:     user.UUID,
+			Flow:     user.Flow,
+		})
+	}
+	err := m.server.UpdateUsers(managedUsers)
 	if err != nil {
 		return err
 	}
@@ -44,44 +95,206 @@ func (m *UserManager) List() []*UserObject {
 	defer m.access.Unlock()
 
 	users := make([]*UserObject, 0, len(m.usersMap))
-	for username, password := range m.usersMap {
-		users = append(users, &UserObject{
-			UserName: username,
-			Password: password,
-		})
+	for username, user := range m.usersMap {
+		users = append(users, user.object(username))
 	}
 	return users
 }
 
-func (m *UserManager) Add(username string, password string) error {
+func (m *UserManager) Add(username string, settings UserSettings) error {
 	m.access.Lock()
 	defer m.access.Unlock()
 	if _, found := m.usersMap[username]; found {
 		return E.New("user ", username, " already exists")
 	}
-	m.usersMap[username] = password
-	return m.postUpdate(true)
+	m.usersMap[username] = newManagedUser(settings)
+	err := m.postUpdate(true)
+	if err != nil {
+		delete(m.usersMap, username)
+		return err
+	}
+	return nil
 }
 
-func (m *UserManager) Get(username string) (string, bool) {
+func (m *UserManager) Get(username string) (*UserObject, bool) {
 	m.access.Lock()
 	defer m.access.Unlock()
-	if password, found := m.usersMap[username]; found {
-		return password, true
+	if user, found := m.usersMap[username]; found {
+		return user.object(username), true
 	}
-	return "", false
+	return nil, false
 }
 
-func (m *UserManager) Update(username string, password string) error {
+func (m *UserManager) Update(username string, settings UserSettings, resetUsage bool) error {
+	var closers []io.Closer
+	defer closeAll(&closers)
 	m.access.Lock()
 	defer m.access.Unlock()
-	m.usersMap[username] = password
-	return m.postUpdate(true)
+	user, found := m.usersMap[username]
+	if !found {
+		return E.New("user ", username, " not found")
+	}
+	oldSettings := user.UserSettings
+	user.UserSettings = settings
+	err := m.postUpdate(true)
+	if err != nil {
+		user.UserSettings = oldSettings
+		return err
+	}
+	if resetUsage {
+		user.usedBytes.Store(0)
+	}
+	if settings.Password != oldSettings.Password || settings.UUID != oldSettings.UUID {
+		closers = user.connections.Array()
+	}
+	return nil
 }
 
 func (m *UserManager) Delete(username string) error {
+	return m.delete(username, false)
+}
+
+func (m *UserManager) delete(username string, mustExist bool) error {
+	var closers []io.Closer
+	defer closeAll(&closers)
 	m.access.Lock()
 	defer m.access.Unlock()
+	user, found := m.usersMap[username]
+	if !found {
+		if mustExist {
+			return E.New("user ", username, " not found")
+		}
+		return nil
+	}
 	delete(m.usersMap, username)
-	return m.postUpdate(true)
+	err := m.postUpdate(true)
+	if err != nil {
+		m.usersMap[username] = user
+		return err
+	}
+	closers = user.connections.Array()
+	return nil
+}
+
+// AddUser and RemoveUser edit the users on behalf of other services, such
+// as the V2Ray API, so that the manager stays the only one updating the
+// inbound.
+func (m *UserManager) AddUser(user adapter.ManagedUser) error {
+	return m.Add(user.Name, UserSettings{
+		Password: user.Password,
+		UUID:     user.UUID,
+		Flow:     user.Flow,
+	})
+}
+
+func (m *UserManager) RemoveUser(name string) error {
+	return m.delete(name, true)
+}
+
+// Enforce closes the connections of users that have expired or used up
+// their quota since the connections were accepted.
+func (m *UserManager) Enforce() {
+	var closers []io.Closer
+	defer closeAll(&closers)
+	m.access.Lock()
+	defer m.access.Unlock()
+	now := time.Now().Unix()
+	for _, user := range m.usersMap {
+		if user.check(now) != nil {
+			closers = append(closers, user.connections.Array()...)
+		}
+	}
+}
+
+func (m *UserManager) TrackConnection(conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) (net.Conn, N.CloseHandlerFunc, error) {
+	user, release, err := m.acquire(metadata, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn = m.trafficManager.TrackConnection(conn, metadata)
+	if user == nil {
+		return conn, onClose, nil
+	}
+	usedCounter := []*atomic.Int64{&user.usedBytes}
+	return bufio.NewInt64CounterConn(conn, usedCounter, usedCounter), N.AppendClose(onClose, release), nil
+}
+
+func (m *UserManager) TrackPacketConnection(conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) (N.PacketConn, N.CloseHandlerFunc, error) {
+	user, release, err := m.acquire(metadata, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn = m.trafficManager.TrackPacketConnection(conn, metadata)
+	if user == nil {
+		return conn, onClose, nil
+	}
+	usedCounter := []*atomic.Int64{&user.usedBytes}
+	return bufio.NewInt64CounterPacketConn(conn, usedCounter, nil, usedCounter, nil), N.AppendClose(onClose, release), nil
+}
+
+// acquire checks the limits of the connection's user and reserves a
+// connection slot. Connections of users not managed by the API are not
+// limited.
+func (m *UserManager) acquire(metadata adapter.InboundContext, conn io.Closer) (*managedUser, N.CloseHandlerFunc, error) {
+	m.access.Lock()
+	defer m.access.Unlock()
+	user, loaded := m.usersMap[metadata.User]
+	if !loaded {
+		return nil, nil, nil
+	}
+	err := user.check(time.Now().Unix())
+	if err != nil {
+		return nil, nil, E.Cause(err, "user ", metadata.User)
+	}
+	if user.MaxConnections > 0 && user.connections.Len() >= user.MaxConnections {
+		return nil, nil, E.New("user ", metadata.User, ": too many connections")
+	}
+	sourceIP := metadata.Source.Addr.Unmap()
+	if user.MaxIPs > 0 && user.sourceIPs[sourceIP] == 0 && len(user.sourceIPs) >= user.MaxIPs {
+		return nil, nil, E.New("user ", metadata.User, ": too many source IPs")
+	}
+	user.sourceIPs[sourceIP]++
+	element := user.connections.PushBack(conn)
+	return user, N.OnceClose(func(it error) {
+		m.access.Lock()
+		defer m.access.Unlock()
+		user.connections.Remove(element)
+		user.sourceIPs[sourceIP]--
+		if user.sourceIPs[sourceIP] == 0 {
+			delete(user.sourceIPs, sourceIP)
+		}
+	}), nil
+}
+
+func (u *managedUser) check(now int64) error {
+	if u.ExpiresAt > 0 && now >= u.ExpiresAt {
+		return E.New("expired")
+	}
+	if u.Quota > 0 && u.usedBytes.Load() >= u.Quota {
+		return E.New("traffic quota exceeded")
+	}
+	return nil
+}
+
+// closeAll closes connections collected under the lock after it is
+// released, as closing may call back into the manager.
+func closeAll(closers *[]io.Closer) {
+	for _, closer := range *closers {
+		closer.Close()
+	}
+}
+
+func (u *managedUser) object(username string) *UserObject {
+	return &UserObject{
+		UserName:       username,
+		Password:       u.Password,
+		UUID:           u.UUID,
+		Flow:           u.Flow,
+		Quota:          u.Quota,
+		UsedBytes:      u.usedBytes.Load(),
+		ExpiresAt:      u.ExpiresAt,
+		MaxConnections: u.MaxConnections,
+		MaxIPs:         u.MaxIPs,
+		Connections:    u.connections.Len(),
+	}
 }
@@ -0,0 +1,117 @@
+package ssmapi
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubUserServer struct {
+	adapter.Inbound
+	users []adapter.ManagedUser
+}
+
+func (s *stubUserServer) SetTracker(tracker adapter.SSMTracker) {
+}
+
+func (s *stubUserServer) UpdateUsers(users []adapter.ManagedUser) error {
+	s.users = users
+	return nil
+}
+
+func (s *stubUserServer) ManagedUsers() []adapter.ManagedUser {
+	return s.users
+}
+
+func trackTestConnection(t *testing.T, manager *UserManager, user string, source string) (func(), error) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	_, onClose, err := manager.TrackConnection(server, adapter.InboundContext{
+		User:   user,
+		Source: M.SocksaddrFrom(netip.MustParseAddr(source), 10000),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func() { onClose(nil) }, nil
+}
+
+func TestUserManagerLimits(t *testing.T) {
+	server := &stubUserServer{}
+	manager := NewUserManager(server, NewTrafficManager())
+	require.NoError(t, manager.Add("limited", UserSettings{
+		Password:       "password",
+		MaxConnections: 2,
+		MaxIPs:         1,
+	}))
+	require.Len(t, server.users, 1)
+
+	release, err := trackTestConnection(t, manager, "limited", "10.0.0.1")
+	require.NoError(t, err)
+	_, err = trackTestConnection(t, manager, "limited", "10.0.0.2")
+	require.Error(t, err)
+	_, err = trackTestConnection(t, manager, "limited", "10.0.0.1")
+	require.NoError(t, err)
+	_, err = trackTestConnection(t, manager, "limited", "10.0.0.1")
+	require.Error(t, err)
+	release()
+	user, loaded := manager.Get("limited")
+	require.True(t, loaded)
+	require.Equal(t, 1, user.Connections)
+
+	_, err = trackTestConnection(t, manager, "unmanaged", "10.0.0.3")
+	require.NoError(t, err)
+}
+
+func TestUserManagerQuotaAndExpiry(t *testing.T) {
+	manager := NewUserManager(&stubUserServer{}, NewTrafficManager())
+	require.NoError(t, manager.Add("quota", UserSettings{
+		Password: "password",
+		Quota:    1024,
+	}))
+	require.NoError(t, manager.Add("expired", UserSettings{
+		Password:  "password",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}))
+
+	_, err := trackTestConnection(t, manager, "expired", "10.0.0.1")
+	require.Error(t, err)
+
+	_, err = trackTestConnection(t, manager, "quota", "10.0.0.1")
+	require.NoError(t, err)
+	manager.usersMap["quota"].usedBytes.Store(1024)
+	_, err = trackTestConnection(t, manager, "quota", "10.0.0.1")
+	require.Error(t, err)
+
+	require.NoError(t, manager.Update("quota", UserSettings{
+		Password: "password",
+		Quota:    1024,
+	}, true))
+	_, err = trackTestConnection(t, manager, "quota", "10.0.0.1")
+	require.NoError(t, err)
+}
+
+func TestUserManagerSortedUpdate(t *testing.T) {
+	server := &stubUserServer{}
+	manager := NewUserManager(server, NewTrafficManager())
+	for _, username := range []string{"delta", "alpha", "charlie", "bravo"} {
+		require.NoError(t, manager.Add(username, UserSettings{Password: username}))
+	}
+	require.NoError(t, manager.Delete("charlie"))
+	var names []string
+	for _, user := range server.users {
+		names = append(names, user.Name)
+		require.Equal(t, user.Name, user.Password)
+	}
+	require.Equal(t, []string{"alpha", "bravo", "delta"}, names)
+}
@@ -43,9 +43,11 @@ func _TestProxyProtocol(t *testing.T) {
 				Type: C.TypeDirect,
 				Tag:  "proxy-out",
 				Options: &option.DirectOutboundOptions{
+					DialerOptions: option.DialerOptions{
+						ProxyProtocol: 2,
+					},
 					OverrideAddress: "127.0.0.1",
 					OverridePort:    serverPort,
-					ProxyProtocol:   2,
 				},
 			},
 		},
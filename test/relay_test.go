@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json/badoption"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/protocol/socks"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRelayMux chains a multiplexed exit behind an entry hop. The exit is
+// configured with a closed server port that only the entry server rewrites
+// to the real one, so the exit works through the relay only. The exit keeps a
+// single multiplexed connection, which the shared exit outbound must not pick
+// up from the relay.
+func TestRelayMux(t *testing.T) {
+	method := shadowaead_2022.List[0]
+	password := mkBase64(t, 16)
+	closedPort := otherClientPort + 1
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "relay-in",
+				Options: &option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+						ListenPort: clientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeMixed,
+				Tag:  "exit-in",
+				Options: &option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+						ListenPort: otherClientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeShadowsocks,
+				Tag:  "entry-server",
+				Options: &option.ShadowsocksInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+						ListenPort: serverPort,
+					},
+					Method:   method,
+					Password: password,
+				},
+			},
+			{
+				Type: C.TypeShadowsocks,
+				Tag:  "exit-server",
+				Options: &option.ShadowsocksInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+						ListenPort: otherPort,
+					},
+					Method:   method,
+					Password: password,
+					Multiplex: &option.InboundMultiplexOptions{
+						Enabled: true,
+					},
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+			{
+				Type: C.TypeShadowsocks,
+				Tag:  "entry",
+				Options: &option.ShadowsocksOutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: serverPort,
+					},
+					Method:   method,
+					Password: password,
+				},
+			},
+			{
+				Type: C.TypeShadowsocks,
+				Tag:  "exit",
+				Options: &option.ShadowsocksOutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: closedPort,
+					},
+					Method:   method,
+					Password: password,
+					Multiplex: &option.OutboundMultiplexOptions{
+						Enabled:        true,
+						Protocol:       "smux",
+						MaxConnections: 1,
+					},
+				},
+			},
+			{
+				Type: C.TypeRelay,
+				Tag:  "relay",
+				Options: &option.RelayOutboundOptions{
+					Outbounds: []string{"entry", "exit"},
+				},
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				relayTestRule("relay-in", option.RuleAction{
+					Action:       C.RuleActionTypeRoute,
+					RouteOptions: option.RouteActionOptions{Outbound: "relay"},
+				}),
+				relayTestRule("exit-in", option.RuleAction{
+					Action:       C.RuleActionTypeRoute,
+					RouteOptions: option.RouteActionOptions{Outbound: "exit"},
+				}),
+				relayTestRule("entry-server", option.RuleAction{
+					Action: C.RuleActionTypeRouteOptions,
+					RouteOptionsOptions: option.RouteOptionsActionOptions{
+						OverridePort: otherPort,
+					},
+				}),
+			},
+		},
+	})
+	testSuit(t, clientPort, testPort)
+
+	listener, err := listen("tcp", ":"+F.ToString(testPort))
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+	dialer := socks.NewClient(N.SystemDialer, M.ParseSocksaddrHostPort("127.0.0.1", otherClientPort), socks.Version5, "", "")
+	conn, err := dialer.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("127.0.0.1", testPort))
+	if err == nil {
+		// Multiplexed streams report dial errors on first use.
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Write([]byte("ping"))
+		if err == nil {
+			_, err = io.ReadFull(conn, make([]byte, 4))
+		}
+		conn.Close()
+	}
+	require.Error(t, err, "shared exit outbound reached its server outside the relay")
+}
+
+func relayTestRule(inbound string, action option.RuleAction) option.Rule {
+	return option.Rule{
+		Type: C.RuleTypeDefault,
+		DefaultOptions: option.DefaultRule{
+			RawDefaultRule: option.RawDefaultRule{
+				Inbound: []string{inbound},
+			},
+			RuleAction: action,
+		},
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json/badoption"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/protocol/socks"
+
+	"github.com/stretchr/testify/require"
+)
+
+func reloadTestOptions(inboundPorts ...uint16) option.Options {
+	var inbounds []option.Inbound
+	for i, port := range inboundPorts {
+		inbounds = append(inbounds, option.Inbound{
+			Type: C.TypeMixed,
+			Tag:  "mixed-in-" + string(rune('a'+i)),
+			Options: &option.HTTPMixedInboundOptions{
+				ListenOptions: option.ListenOptions{
+					Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+					ListenPort: port,
+				},
+			},
+		})
+	}
+	return option.Options{
+		Log: &option.LogOptions{
+			Level: "warning",
+		},
+		Inbounds: inbounds,
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+				Tag:  "direct",
+			},
+			{
+				Type: C.TypeSelector,
+				Tag:  "proxy",
+				Options: &option.SelectorOutboundOptions{
+					Outbounds: []string{"direct"},
+				},
+			},
+		},
+		Route: &option.RouteOptions{
+			Final: "proxy",
+		},
+	}
+}
+
+// startEchoServer serves TCP echo on otherPort until the test ends. Accepted
+// connections are closed on cleanup, so that no goroutine outlives the test.
+func startEchoServer(t *testing.T) {
+	listener, err := net.Listen("tcp", M.ParseSocksaddrHostPort("127.0.0.1", otherPort).String())
+	require.NoError(t, err)
+	var (
+		access    sync.Mutex
+		conns     []net.Conn
+		waitGroup sync.WaitGroup
+	)
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			access.Lock()
+			conns = append(conns, conn)
+			access.Unlock()
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		access.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		access.Unlock()
+		waitGroup.Wait()
+	})
+}
+
+func dialEcho(t *testing.T, port uint16) func() {
+	dialer := socks.NewClient(N.SystemDialer, M.ParseSocksaddrHostPort("127.0.0.1", port), socks.Version5, "", "")
+	conn, err := dialer.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddrHostPort("127.0.0.1", otherPort))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return func() {
+		_, err := conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		buffer := make([]byte, 4)
+		_, err = io.ReadFull(conn, buffer)
+		require.NoError(t, err)
+		require.Equal(t, "ping", string(buffer))
+	}
+}
+
+func TestReload(t *testing.T) {
+	startEchoServer(t)
+	instance := startInstance(t, reloadTestOptions(clientPort))
+	echo := dialEcho(t, clientPort)
+	echo()
+
+	options := reloadTestOptions(clientPort, otherClientPort)
+	options.Outbounds[0].Options = &option.DirectOutboundOptions{
+		DialerOptions: option.DialerOptions{
+			ReuseAddr: true,
+		},
+	}
+	require.NoError(t, instance.Reload(options))
+	echo()
+	testTCP(t, otherClientPort, testPort)
+
+	options.Log = &option.LogOptions{
+		Level: "debug",
+	}
+	require.ErrorIs(t, instance.Reload(options), box.ErrReloadRequiresRestart)
+}
+
+func TestReloadRollback(t *testing.T) {
+	startEchoServer(t)
+	instance := startInstance(t, reloadTestOptions(clientPort))
+	dialEcho(t, clientPort)()
+
+	// the replaced inbound cannot listen on its new port, so the previous
+	// one is restored
+	occupied, err := net.Listen("tcp", M.ParseSocksaddrHostPort("0.0.0.0", otherClientPort).String())
+	require.NoError(t, err)
+	options := reloadTestOptions(otherClientPort, clientPort)
+	err = instance.Reload(options)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, box.ErrReloadRequiresRestart)
+	dialEcho(t, clientPort)()
+
+	occupied.Close()
+	require.NoError(t, instance.Reload(options))
+	dialEcho(t, otherClientPort)()
+	dialEcho(t, clientPort)()
+}
+
+func TestReloadCircularDetour(t *testing.T) {
+	socksOutbound := func(tag string, detour string) option.Outbound {
+		return option.Outbound{
+			Type: C.TypeSOCKS,
+			Tag:  tag,
+			Options: &option.SOCKSOutboundOptions{
+				DialerOptions: option.DialerOptions{
+					Detour: detour,
+				},
+				ServerOptions: option.ServerOptions{
+					Server:     "127.0.0.1",
+					ServerPort: otherPort,
+				},
+			},
+		}
+	}
+	options := reloadTestOptions(clientPort)
+	options.Outbounds = append(options.Outbounds, socksOutbound("a", "b"), socksOutbound("b", ""))
+	instance := startInstance(t, options)
+	options = reloadTestOptions(clientPort)
+	options.Outbounds = append(options.Outbounds, socksOutbound("a", "b"), socksOutbound("b", "a"))
+	done := make(chan error, 1)
+	go func() {
+		done <- instance.Reload(options)
+	}()
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, "circular dependency")
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload did not return")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2raykcp"
+)
+
+func TestV2RayKCPSelf(t *testing.T) {
+	testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeKCP,
+	})
+}
+
+func TestV2RayKCPPlainSelf(t *testing.T) {
+	for _, headerType := range []string{v2raykcp.HeaderTypeNone, v2raykcp.HeaderTypeSRTP, v2raykcp.HeaderTypeWechatVideo, v2raykcp.HeaderTypeDTLS} {
+		t.Run(headerType, func(t *testing.T) {
+			testV2RayTransportNOTLSSelf(t, &option.V2RayTransportOptions{
+				Type: C.V2RayTransportTypeKCP,
+				KCPOptions: option.V2RayKCPOptions{
+					UplinkCapacity:   100,
+					DownlinkCapacity: 100,
+					HeaderType:       headerType,
+				},
+			})
+		})
+	}
+}
+
+func TestV2RayKCPSeedSelf(t *testing.T) {
+	testV2RayTransportNOTLSSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeKCP,
+		KCPOptions: option.V2RayKCPOptions{
+			UplinkCapacity:   100,
+			DownlinkCapacity: 100,
+			Seed:             "radio-box",
+		},
+	})
+}
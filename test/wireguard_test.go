@@ -0,0 +1,118 @@
+//go:build with_gvisor && with_wireguard
+
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+const (
+	wireGuardServerPrivateKey = "uMvJngCFQXvVayXuMm+uDtgnCAwed79/bNwC0dgzEX4="
+	wireGuardServerPublicKey  = "axk5E3lbKDiWfVyZ8Q22a0oF46kZOYiSjIXnW+1M21Y="
+	wireGuardClientPrivateKey = "yEqDMJIdTaHoEnQuHUQm2Qy25qwVxE/+2IFKhC3CFlk="
+	wireGuardClientPublicKey  = "8kVEdelwONkne0wJ1bGwaMUTPK5jGNqz02XhyjmGHCA="
+)
+
+func TestWireGuardSelf(t *testing.T) {
+	testWireGuardSelf(t, nil)
+}
+
+func TestWireGuardAmneziaSelf(t *testing.T) {
+	testWireGuardSelf(t, &option.WireGuardAmneziaOptions{
+		JunkPacketCount:            4,
+		JunkPacketMinSize:          40,
+		JunkPacketMaxSize:          70,
+		InitPacketJunkSize:         15,
+		ResponsePacketJunkSize:     18,
+		InitPacketMagicHeader:      1020325451,
+		ResponsePacketMagicHeader:  3288052141,
+		UnderloadPacketMagicHeader: 1766607858,
+		TransportPacketMagicHeader: 2528465083,
+	})
+}
+
+func testWireGuardSelf(t *testing.T, amnezia *option.WireGuardAmneziaOptions) {
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in",
+				Options: &option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+						ListenPort: clientPort,
+					},
+				},
+			},
+		},
+		Endpoints: []option.Endpoint{
+			{
+				Type: C.TypeWireGuard,
+				Tag:  "wg-in",
+				Options: &option.WireGuardEndpointOptions{
+					Address:    []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")},
+					PrivateKey: wireGuardServerPrivateKey,
+					ListenPort: serverPort,
+					Peers: []option.WireGuardPeer{
+						{
+							PublicKey:  wireGuardClientPublicKey,
+							AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+						},
+					},
+					Amnezia: amnezia,
+				},
+			},
+			{
+				Type: C.TypeWireGuard,
+				Tag:  "wg-out",
+				Options: &option.WireGuardEndpointOptions{
+					Address:    []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+					PrivateKey: wireGuardClientPrivateKey,
+					Peers: []option.WireGuardPeer{
+						{
+							Address:    "127.0.0.1",
+							Port:       serverPort,
+							PublicKey:  wireGuardServerPublicKey,
+							AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")},
+						},
+					},
+					Amnezia: amnezia,
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				{
+					Type: C.RuleTypeDefault,
+					DefaultOptions: option.DefaultRule{
+						RawDefaultRule: option.RawDefaultRule{
+							Inbound: []string{"mixed-in"},
+						},
+						RuleAction: option.RuleAction{
+							Action: C.RuleActionTypeRoute,
+							RouteOptions: option.RouteActionOptions{
+								Outbound: "wg-out",
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	testSuitWg(t, clientPort, testPort)
+}
+
+func TestWireGuardAmneziaDefaultSelf(t *testing.T) {
+	testWireGuardSelf(t, &option.WireGuardAmneziaOptions{})
+}
@@ -0,0 +1,101 @@
+package v2raykcp
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.V2RayClientTransport = (*Client)(nil)
+
+type Client struct {
+	ctx        context.Context
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	config     *config
+	tlsConfig  tls.Config
+	access     sync.Mutex
+	conns      map[*conn]struct{}
+}
+
+func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RayKCPOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
+	kcpConfig, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		ctx:        ctx,
+		dialer:     dialer,
+		serverAddr: serverAddr,
+		config:     kcpConfig,
+		tlsConfig:  tlsConfig,
+		conns:      make(map[*conn]struct{}),
+	}, nil
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	udpConn, err := c.dialer.DialContext(ctx, N.NetworkUDP, c.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	writer := c.config.newPacketWriter(func(b []byte) error {
+		_, err := udpConn.Write(b)
+		return err
+	})
+	var kcpConn *conn
+	kcpConn = newConn(udpConn.LocalAddr(), udpConn.RemoteAddr(), uint16(rand.Uint32()), writer, func() {
+		udpConn.Close()
+		c.access.Lock()
+		delete(c.conns, kcpConn)
+		c.access.Unlock()
+	}, c.config)
+	c.access.Lock()
+	c.conns[kcpConn] = struct{}{}
+	c.access.Unlock()
+	go c.fetchInput(udpConn, kcpConn)
+	if c.tlsConfig == nil {
+		return kcpConn, nil
+	}
+	tlsConn, err := tls.ClientHandshake(ctx, kcpConn, c.tlsConfig)
+	if err != nil {
+		kcpConn.terminateNow()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (c *Client) fetchInput(udpConn net.Conn, kcpConn *conn) {
+	reader := c.config.newPacketReader()
+	buffer := make([]byte, maxPacketSize)
+	for {
+		n, err := udpConn.Read(buffer)
+		if err != nil {
+			kcpConn.terminateNow()
+			return
+		}
+		segments := reader.read(buffer[:n])
+		if len(segments) > 0 {
+			kcpConn.input(segments)
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	c.access.Lock()
+	conns := make([]*conn, 0, len(c.conns))
+	for kcpConn := range c.conns {
+		conns = append(conns, kcpConn)
+	}
+	c.access.Unlock()
+	for _, kcpConn := range conns {
+		kcpConn.terminateNow()
+	}
+	return nil
+}
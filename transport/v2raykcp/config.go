@@ -0,0 +1,93 @@
+package v2raykcp
+
+import (
+	"crypto/cipher"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const maxPacketSize = 2048
+
+type config struct {
+	mtu              uint32
+	tti              uint32
+	uplinkCapacity   uint32
+	downlinkCapacity uint32
+	congestion       bool
+	writeBufferSize  uint32
+	maxSessions      int
+	newHeader        func() packetHeader
+	security         cipher.AEAD
+}
+
+func newConfig(options option.V2RayKCPOptions) (*config, error) {
+	kcpConfig := &config{
+		mtu:              options.MTU,
+		tti:              options.TTI,
+		uplinkCapacity:   options.UplinkCapacity,
+		downlinkCapacity: options.DownlinkCapacity,
+		congestion:       options.Congestion,
+		writeBufferSize:  options.WriteBufferSize,
+		maxSessions:      options.MaxSessions,
+		security:         newSecurity(options.Seed),
+	}
+	if kcpConfig.mtu == 0 {
+		kcpConfig.mtu = 1350
+	} else if kcpConfig.mtu < 576 || kcpConfig.mtu > 1460 {
+		return nil, E.New("kcp mtu must be between 576 and 1460")
+	}
+	if kcpConfig.tti == 0 {
+		kcpConfig.tti = 50
+	} else if kcpConfig.tti < 10 || kcpConfig.tti > 100 {
+		return nil, E.New("kcp tti must be between 10 and 100")
+	}
+	if kcpConfig.uplinkCapacity == 0 {
+		kcpConfig.uplinkCapacity = 5
+	}
+	if kcpConfig.downlinkCapacity == 0 {
+		kcpConfig.downlinkCapacity = 20
+	}
+	if kcpConfig.writeBufferSize == 0 {
+		kcpConfig.writeBufferSize = 2
+	}
+	if kcpConfig.maxSessions == 0 {
+		kcpConfig.maxSessions = 1024
+	}
+	var err error
+	kcpConfig.newHeader, err = newPacketHeader(options.HeaderType)
+	if err != nil {
+		return nil, err
+	}
+	return kcpConfig, nil
+}
+
+func (c *config) newPacketReader() *packetReader {
+	reader := &packetReader{security: c.security}
+	if c.newHeader != nil {
+		reader.headerSize = c.newHeader().size()
+	}
+	return reader
+}
+
+func (c *config) newPacketWriter(write func(b []byte) error) *packetWriter {
+	var header packetHeader
+	if c.newHeader != nil {
+		header = c.newHeader()
+	}
+	return newPacketWriter(header, c.security, write)
+}
+
+// Capacities are in MB/s and buffer sizes in MB; windows are in segments.
+
+func (c *config) sendingInFlightSize() uint32 {
+	return max(c.uplinkCapacity*1024*1024/c.mtu/(1000/c.tti), 8)
+}
+
+func (c *config) sendingBufferSize() uint32 {
+	return c.writeBufferSize * 1024 * 1024 / c.mtu
+}
+
+func (c *config) receivingInFlightSize() uint32 {
+	return max(c.downlinkCapacity*1024*1024/c.mtu/(1000/c.tti), 8)
+}
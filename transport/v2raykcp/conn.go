@@ -0,0 +1,479 @@
+package v2raykcp
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connState int32
+
+const (
+	stateActive          connState = 0
+	stateReadyToClose    connState = 1
+	statePeerClosed      connState = 2
+	stateTerminating     connState = 3
+	statePeerTerminating connState = 4
+	stateTerminated      connState = 5
+)
+
+func (s connState) is(states ...connState) bool {
+	for _, state := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+type roundTripInfo struct {
+	access           sync.RWMutex
+	variation        uint32
+	srtt             uint32
+	rto              uint32
+	minRtt           uint32
+	updatedTimestamp uint32
+}
+
+func (i *roundTripInfo) updatePeerRTO(rto uint32, current uint32) {
+	i.access.Lock()
+	defer i.access.Unlock()
+	if current-i.updatedTimestamp < 3000 {
+		return
+	}
+	i.updatedTimestamp = current
+	i.rto = rto
+}
+
+// update follows RFC 6298.
+func (i *roundTripInfo) update(rtt uint32, current uint32) {
+	if rtt > 0x7FFFFFFF {
+		return
+	}
+	i.access.Lock()
+	defer i.access.Unlock()
+	if i.srtt == 0 {
+		i.srtt = rtt
+		i.variation = rtt / 2
+	} else {
+		delta := rtt - i.srtt
+		if i.srtt > rtt {
+			delta = i.srtt - rtt
+		}
+		i.variation = (3*i.variation + delta) / 4
+		i.srtt = (7*i.srtt + rtt) / 8
+		if i.srtt < i.minRtt {
+			i.srtt = i.minRtt
+		}
+	}
+	var rto uint32
+	if i.minRtt < 4*i.variation {
+		rto = i.srtt + 4*i.variation
+	} else {
+		rto = i.srtt + i.variation
+	}
+	if rto > 10000 {
+		rto = 10000
+	}
+	i.rto = rto * 5 / 4
+	i.updatedTimestamp = current
+}
+
+func (i *roundTripInfo) timeout() uint32 {
+	i.access.RLock()
+	defer i.access.RUnlock()
+	return i.rto
+}
+
+// updater runs the update function every interval while shouldContinue
+// holds, and is restarted by wakeUp.
+type updater struct {
+	interval        atomic.Int64
+	shouldContinue  func() bool
+	shouldTerminate func() bool
+	updateFunc      func()
+	notifier        chan struct{}
+	done            <-chan struct{}
+}
+
+func newUpdater(interval time.Duration, shouldContinue func() bool, shouldTerminate func() bool, updateFunc func(), done <-chan struct{}) *updater {
+	u := &updater{
+		shouldContinue:  shouldContinue,
+		shouldTerminate: shouldTerminate,
+		updateFunc:      updateFunc,
+		notifier:        make(chan struct{}, 1),
+		done:            done,
+	}
+	u.interval.Store(int64(interval))
+	u.notifier <- struct{}{}
+	return u
+}
+
+func (u *updater) wakeUp() {
+	select {
+	case <-u.notifier:
+		go u.run()
+	default:
+	}
+}
+
+func (u *updater) run() {
+	defer func() {
+		u.notifier <- struct{}{}
+	}()
+	if u.shouldTerminate() {
+		return
+	}
+	timer := time.NewTimer(time.Duration(u.interval.Load()))
+	defer timer.Stop()
+	for u.shouldContinue() {
+		u.updateFunc()
+		timer.Reset(time.Duration(u.interval.Load()))
+		select {
+		case <-timer.C:
+		case <-u.done:
+			return
+		}
+	}
+}
+
+func (u *updater) setInterval(interval time.Duration) {
+	u.interval.Store(int64(interval))
+}
+
+var _ net.Conn = (*conn)(nil)
+
+type conn struct {
+	localAddr        net.Addr
+	remoteAddr       net.Addr
+	conv             uint16
+	config           *config
+	onTerminate      func()
+	output           *packetWriter
+	since            time.Time
+	readDeadline     atomic.Int64
+	writeDeadline    atomic.Int64
+	dataInput        chan struct{}
+	dataOutput       chan struct{}
+	state            atomic.Int32
+	stateBeginTime   atomic.Uint32
+	lastIncomingTime atomic.Uint32
+	lastPingTime     atomic.Uint32
+	mss              uint32
+	roundTrip        *roundTripInfo
+	receivingWorker  *receivingWorker
+	sendingWorker    *sendingWorker
+	dataUpdater      *updater
+	pingUpdater      *updater
+	terminateOnce    sync.Once
+	done             chan struct{}
+}
+
+func newConn(localAddr net.Addr, remoteAddr net.Addr, conv uint16, output *packetWriter, onTerminate func(), config *config) *conn {
+	c := &conn{
+		localAddr:   localAddr,
+		remoteAddr:  remoteAddr,
+		conv:        conv,
+		config:      config,
+		onTerminate: onTerminate,
+		output:      output,
+		since:       time.Now(),
+		dataInput:   make(chan struct{}, 1),
+		dataOutput:  make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		mss:         config.mtu - uint32(output.overhead()) - dataSegmentOverhead,
+		roundTrip: &roundTripInfo{
+			rto:    100,
+			minRtt: config.tti,
+		},
+	}
+	c.receivingWorker = newReceivingWorker(c)
+	c.sendingWorker = newSendingWorker(c)
+	isTerminating := func() bool {
+		return c.currentState().is(stateTerminating, stateTerminated)
+	}
+	isTerminated := func() bool {
+		return c.currentState() == stateTerminated
+	}
+	c.dataUpdater = newUpdater(time.Duration(config.tti)*time.Millisecond, func() bool {
+		return !isTerminating() && (c.currentState() == stateReadyToClose || c.sendingWorker.updateNecessary() || c.receivingWorker.updateNecessary())
+	}, isTerminating, c.flush, c.done)
+	c.pingUpdater = newUpdater(5*time.Second, func() bool {
+		return !isTerminated()
+	}, isTerminated, c.flush, c.done)
+	c.pingUpdater.wakeUp()
+	return c
+}
+
+func (c *conn) elapsed() uint32 {
+	return uint32(time.Since(c.since).Milliseconds())
+}
+
+func (c *conn) currentState() connState {
+	return connState(c.state.Load())
+}
+
+func signal(notifier chan struct{}) {
+	select {
+	case notifier <- struct{}{}:
+	default:
+	}
+}
+
+func waitSignal(notifier chan struct{}, deadline int64) error {
+	duration := 16 * time.Second
+	if deadline != 0 {
+		duration = time.Until(time.Unix(0, deadline))
+		if duration <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-notifier:
+	case <-timer.C:
+		if deadline != 0 && time.Now().UnixNano() >= deadline {
+			return os.ErrDeadlineExceeded
+		}
+	}
+	return nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		if c.currentState().is(stateReadyToClose, stateTerminating, stateTerminated) {
+			return 0, io.EOF
+		}
+		n := c.receivingWorker.read(b)
+		if n > 0 {
+			c.dataUpdater.wakeUp()
+			return n, nil
+		}
+		if c.currentState() == statePeerTerminating {
+			return 0, io.EOF
+		}
+		err := waitSignal(c.dataInput, c.readDeadline.Load())
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	var n int
+	for {
+		for n < len(b) {
+			if c.currentState() != stateActive {
+				return n, io.ErrClosedPipe
+			}
+			chunk := b[n:min(n+int(c.mss), len(b))]
+			if !c.sendingWorker.push(append([]byte(nil), chunk...)) {
+				break
+			}
+			n += len(chunk)
+		}
+		c.dataUpdater.wakeUp()
+		if n == len(b) {
+			return n, nil
+		}
+		err := waitSignal(c.dataOutput, c.writeDeadline.Load())
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+func (c *conn) setState(state connState) {
+	c.state.Store(int32(state))
+	c.stateBeginTime.Store(c.elapsed())
+	switch state {
+	case statePeerClosed:
+		c.sendingWorker.closeWrite()
+	case stateTerminating:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+	case statePeerTerminating:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+	case stateTerminated:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+		c.dataUpdater.wakeUp()
+		c.pingUpdater.wakeUp()
+		go c.terminate()
+	}
+}
+
+// terminateNow drops the connection without the closing handshake.
+func (c *conn) terminateNow() {
+	if c.currentState() != stateTerminated {
+		c.setState(stateTerminated)
+	}
+}
+
+func (c *conn) Close() error {
+	signal(c.dataInput)
+	signal(c.dataOutput)
+	switch c.currentState() {
+	case stateReadyToClose, stateTerminating, stateTerminated:
+		return net.ErrClosed
+	case stateActive:
+		c.setState(stateReadyToClose)
+	case statePeerClosed:
+		c.setState(stateTerminating)
+	case statePeerTerminating:
+		c.ping(c.elapsed(), commandTerminate)
+		c.setState(stateTerminated)
+	}
+	return nil
+}
+
+func (c *conn) terminate() {
+	c.terminateOnce.Do(func() {
+		close(c.done)
+		signal(c.dataInput)
+		signal(c.dataOutput)
+		c.onTerminate()
+		c.sendingWorker.release()
+		c.receivingWorker.release()
+	})
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func deadlineNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.readDeadline.Store(deadlineNano(t))
+	c.writeDeadline.Store(deadlineNano(t))
+	signal(c.dataInput)
+	signal(c.dataOutput)
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(deadlineNano(t))
+	signal(c.dataInput)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.Store(deadlineNano(t))
+	signal(c.dataOutput)
+	return nil
+}
+
+func (c *conn) handleOption(option segmentOption) {
+	if option&segmentOptionClose == segmentOptionClose {
+		switch c.currentState() {
+		case stateReadyToClose:
+			c.setState(stateTerminating)
+		case stateActive:
+			c.setState(statePeerClosed)
+		}
+	}
+}
+
+func (c *conn) input(segments []segment) {
+	current := c.elapsed()
+	c.lastIncomingTime.Store(current)
+	for _, seg := range segments {
+		if seg.conversation() != c.conv {
+			break
+		}
+		switch seg := seg.(type) {
+		case *dataSegment:
+			c.handleOption(seg.option)
+			c.receivingWorker.processSegment(seg)
+			if c.receivingWorker.isDataAvailable() {
+				signal(c.dataInput)
+			}
+			c.dataUpdater.wakeUp()
+		case *ackSegment:
+			c.handleOption(seg.option)
+			c.sendingWorker.processSegment(current, seg, c.roundTrip.timeout())
+			signal(c.dataOutput)
+			c.dataUpdater.wakeUp()
+		case *cmdOnlySegment:
+			c.handleOption(seg.option)
+			if seg.cmd == commandTerminate {
+				switch c.currentState() {
+				case stateActive, statePeerClosed:
+					c.setState(statePeerTerminating)
+				case stateReadyToClose:
+					c.setState(stateTerminating)
+				case stateTerminating:
+					c.setState(stateTerminated)
+				}
+			}
+			if seg.option == segmentOptionClose || seg.cmd == commandTerminate {
+				signal(c.dataInput)
+				signal(c.dataOutput)
+			}
+			c.sendingWorker.processReceivingNext(seg.receivingNext)
+			c.receivingWorker.processSendingNext(seg.sendingNext)
+			c.roundTrip.updatePeerRTO(seg.peerRTO, current)
+		}
+	}
+}
+
+func (c *conn) flush() {
+	current := c.elapsed()
+	if c.currentState() == stateTerminated {
+		return
+	}
+	if c.currentState() == stateActive && current-c.lastIncomingTime.Load() >= 30000 {
+		c.Close()
+	}
+	if c.currentState() == stateReadyToClose && c.sendingWorker.isEmpty() {
+		c.setState(stateTerminating)
+	}
+	if c.currentState() == stateTerminating {
+		c.ping(current, commandTerminate)
+		if current-c.stateBeginTime.Load() > 8000 {
+			c.setState(stateTerminated)
+		}
+		return
+	}
+	if c.currentState() == statePeerTerminating && current-c.stateBeginTime.Load() > 4000 {
+		c.setState(stateTerminating)
+	}
+	if c.currentState() == stateReadyToClose && current-c.stateBeginTime.Load() > 15000 {
+		c.setState(stateTerminating)
+	}
+	c.receivingWorker.flush(current)
+	c.sendingWorker.flush(current)
+	if current-c.lastPingTime.Load() >= 3000 {
+		c.ping(current, commandPing)
+	}
+}
+
+func (c *conn) ping(current uint32, cmd command) {
+	seg := &cmdOnlySegment{
+		conv:          c.conv,
+		cmd:           cmd,
+		receivingNext: c.receivingWorker.nextNumber(),
+		sendingNext:   c.sendingWorker.firstUnacknowledged(),
+		peerRTO:       c.roundTrip.timeout(),
+	}
+	if c.currentState() == stateReadyToClose {
+		seg.option = segmentOptionClose
+	}
+	c.output.writeSegment(seg)
+	c.lastPingTime.Store(current)
+}
@@ -0,0 +1,275 @@
+package v2raykcp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	mRand "math/rand"
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	HeaderTypeNone        = "none"
+	HeaderTypeSRTP        = "srtp"
+	HeaderTypeUTP         = "utp"
+	HeaderTypeWechatVideo = "wechat-video"
+	HeaderTypeDTLS        = "dtls"
+	HeaderTypeWireGuard   = "wireguard"
+)
+
+// packetHeader disguises every packet as another UDP protocol. The header is
+// ignored by the receiver.
+type packetHeader interface {
+	size() int
+	serialize(b []byte)
+}
+
+func newPacketHeader(headerType string) (func() packetHeader, error) {
+	switch headerType {
+	case "", HeaderTypeNone:
+		return nil, nil
+	case HeaderTypeSRTP:
+		return func() packetHeader {
+			return &srtpHeader{header: 0xB5E8, number: uint16(mRand.Uint32())}
+		}, nil
+	case HeaderTypeUTP:
+		return func() packetHeader {
+			return &utpHeader{header: 1, connectionID: uint16(mRand.Uint32())}
+		}, nil
+	case HeaderTypeWechatVideo:
+		return func() packetHeader {
+			return &wechatVideoHeader{sn: uint32(uint16(mRand.Uint32()))}
+		}, nil
+	case HeaderTypeDTLS:
+		return func() packetHeader {
+			return &dtlsHeader{epoch: uint16(mRand.Uint32()), length: 17}
+		}, nil
+	case HeaderTypeWireGuard:
+		return func() packetHeader {
+			return wireGuardHeader{}
+		}, nil
+	default:
+		return nil, E.New("unknown kcp header type: ", headerType)
+	}
+}
+
+type srtpHeader struct {
+	header uint16
+	number uint16
+}
+
+func (h *srtpHeader) size() int {
+	return 4
+}
+
+func (h *srtpHeader) serialize(b []byte) {
+	h.number++
+	binary.BigEndian.PutUint16(b, h.header)
+	binary.BigEndian.PutUint16(b[2:], h.number)
+}
+
+type utpHeader struct {
+	header       byte
+	extension    byte
+	connectionID uint16
+}
+
+func (h *utpHeader) size() int {
+	return 4
+}
+
+func (h *utpHeader) serialize(b []byte) {
+	binary.BigEndian.PutUint16(b, h.connectionID)
+	b[2] = h.header
+	b[3] = h.extension
+}
+
+type wechatVideoHeader struct {
+	sn uint32
+}
+
+func (h *wechatVideoHeader) size() int {
+	return 13
+}
+
+func (h *wechatVideoHeader) serialize(b []byte) {
+	h.sn++
+	b[0] = 0xa1
+	b[1] = 0x08
+	binary.BigEndian.PutUint32(b[2:], h.sn)
+	copy(b[6:], []byte{0x00, 0x10, 0x11, 0x18, 0x30, 0x22, 0x30})
+}
+
+type dtlsHeader struct {
+	epoch    uint16
+	length   uint16
+	sequence uint32
+}
+
+func (h *dtlsHeader) size() int {
+	return 13
+}
+
+func (h *dtlsHeader) serialize(b []byte) {
+	b[0] = 23
+	b[1] = 254
+	b[2] = 253
+	binary.BigEndian.PutUint16(b[3:], h.epoch)
+	b[5] = 0
+	b[6] = 0
+	binary.BigEndian.PutUint32(b[7:], h.sequence)
+	h.sequence++
+	binary.BigEndian.PutUint16(b[11:], h.length)
+	h.length += 17
+	if h.length > 100 {
+		h.length -= 50
+	}
+}
+
+type wireGuardHeader struct{}
+
+func (wireGuardHeader) size() int {
+	return 4
+}
+
+func (wireGuardHeader) serialize(b []byte) {
+	copy(b, []byte{0x04, 0x00, 0x00, 0x00})
+}
+
+func newSecurity(seed string) cipher.AEAD {
+	if seed == "" {
+		return simpleAuthenticator{}
+	}
+	hashedSeed := sha256.Sum256([]byte(seed))
+	block, _ := aes.NewCipher(hashedSeed[:16])
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// simpleAuthenticator is the checksum and obfuscation used by mKCP without a
+// seed: an FNV-1a hash and the length, xored forward in 4-byte steps.
+type simpleAuthenticator struct{}
+
+func (simpleAuthenticator) NonceSize() int {
+	return 0
+}
+
+func (simpleAuthenticator) Overhead() int {
+	return 6
+}
+
+func (simpleAuthenticator) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(dst[start+4:], uint16(len(plaintext)))
+	dst = append(dst, plaintext...)
+	hash := fnv.New32a()
+	hash.Write(dst[start+4:])
+	binary.BigEndian.PutUint32(dst[start:], hash.Sum32())
+	sealed := dst[start:]
+	for i := 4; i < len(sealed); i++ {
+		sealed[i] ^= sealed[i-4]
+	}
+	return dst
+}
+
+func (simpleAuthenticator) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, ciphertext...)
+	opened := dst[start:]
+	for i := len(opened) - 1; i >= 4; i-- {
+		opened[i] ^= opened[i-4]
+	}
+	if len(opened) < 6 {
+		return nil, E.New("invalid auth")
+	}
+	hash := fnv.New32a()
+	hash.Write(opened[4:])
+	if binary.BigEndian.Uint32(opened) != hash.Sum32() {
+		return nil, E.New("invalid auth")
+	}
+	if int(binary.BigEndian.Uint16(opened[4:])) != len(opened)-6 {
+		return nil, E.New("invalid auth")
+	}
+	return append(dst[:start], opened[6:]...), nil
+}
+
+type packetReader struct {
+	headerSize int
+	security   cipher.AEAD
+}
+
+func (r *packetReader) read(b []byte) []segment {
+	if len(b) <= r.headerSize {
+		return nil
+	}
+	b = b[r.headerSize:]
+	nonceSize := r.security.NonceSize()
+	if len(b) <= nonceSize+r.security.Overhead() {
+		return nil
+	}
+	b, err := r.security.Open(b[nonceSize:nonceSize], b[:nonceSize], b[nonceSize:], nil)
+	if err != nil {
+		return nil
+	}
+	var segments []segment
+	for len(b) > 0 {
+		seg, remaining := readSegment(b)
+		if seg == nil {
+			break
+		}
+		segments = append(segments, seg)
+		b = remaining
+	}
+	return segments
+}
+
+type packetWriter struct {
+	access   sync.Mutex
+	header   packetHeader
+	security cipher.AEAD
+	write    func(b []byte) error
+	segment  []byte
+	packet   []byte
+}
+
+func newPacketWriter(header packetHeader, security cipher.AEAD, write func(b []byte) error) *packetWriter {
+	return &packetWriter{
+		header:   header,
+		security: security,
+		write:    write,
+		segment:  make([]byte, maxPacketSize),
+		packet:   make([]byte, 0, maxPacketSize),
+	}
+}
+
+func (w *packetWriter) overhead() int {
+	overhead := w.security.NonceSize() + w.security.Overhead()
+	if w.header != nil {
+		overhead += w.header.size()
+	}
+	return overhead
+}
+
+func (w *packetWriter) writeSegment(seg segment) error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	plaintext := w.segment[:seg.byteSize()]
+	seg.serialize(plaintext)
+	packet := w.packet[:0]
+	if w.header != nil {
+		packet = packet[:w.header.size()]
+		w.header.serialize(packet)
+	}
+	nonceSize := w.security.NonceSize()
+	packet = packet[:len(packet)+nonceSize]
+	nonce := packet[len(packet)-nonceSize:]
+	rand.Read(nonce)
+	packet = w.security.Seal(packet, nonce, plaintext, nil)
+	return w.write(packet)
+}
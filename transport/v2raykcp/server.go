@@ -0,0 +1,150 @@
+package v2raykcp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.V2RayServerTransport = (*Server)(nil)
+
+type Server struct {
+	ctx        context.Context
+	logger     logger.ContextLogger
+	config     *config
+	tlsConfig  tls.ServerConfig
+	handler    adapter.V2RayServerTransportHandler
+	packetConn net.PacketConn
+	access     sync.Mutex
+	sessions   map[sessionKey]*conn
+	closed     bool
+}
+
+type sessionKey struct {
+	source netip.AddrPort
+	conv   uint16
+}
+
+func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayKCPOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (adapter.V2RayServerTransport, error) {
+	kcpConfig, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		ctx:       ctx,
+		logger:    logger,
+		config:    kcpConfig,
+		tlsConfig: tlsConfig,
+		handler:   handler,
+		sessions:  make(map[sessionKey]*conn),
+	}, nil
+}
+
+func (s *Server) Network() []string {
+	return []string{N.NetworkUDP}
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	return os.ErrInvalid
+}
+
+func (s *Server) ServePacket(listener net.PacketConn) error {
+	s.packetConn = listener
+	go s.loopInput(listener)
+	return nil
+}
+
+func (s *Server) loopInput(packetConn net.PacketConn) {
+	reader := s.config.newPacketReader()
+	buffer := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := packetConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		segments := reader.read(buffer[:n])
+		if len(segments) == 0 {
+			continue
+		}
+		source := M.SocksaddrFromNet(addr).Unwrap()
+		key := sessionKey{source.AddrPort(), segments[0].conversation()}
+		s.access.Lock()
+		if s.closed {
+			s.access.Unlock()
+			return
+		}
+		kcpConn, loaded := s.sessions[key]
+		if !loaded {
+			if segments[0].command() == commandTerminate {
+				s.access.Unlock()
+				continue
+			}
+			if len(s.sessions) >= s.config.maxSessions {
+				s.access.Unlock()
+				s.logger.Debug("drop new session from ", source, ": too many sessions")
+				continue
+			}
+			writer := s.config.newPacketWriter(func(b []byte) error {
+				_, err := packetConn.WriteTo(b, addr)
+				return err
+			})
+			var sessionConn *conn
+			sessionConn = newConn(packetConn.LocalAddr(), addr, key.conv, writer, func() {
+				s.access.Lock()
+				if s.sessions[key] == sessionConn {
+					delete(s.sessions, key)
+				}
+				s.access.Unlock()
+			}, s.config)
+			kcpConn = sessionConn
+			s.sessions[key] = kcpConn
+			go s.newConnection(kcpConn, source)
+		}
+		s.access.Unlock()
+		kcpConn.input(segments)
+	}
+}
+
+func (s *Server) newConnection(kcpConn *conn, source M.Socksaddr) {
+	ctx := log.ContextWithNewID(s.ctx)
+	var conn net.Conn = kcpConn
+	if s.tlsConfig != nil {
+		tlsConn, err := tls.ServerHandshake(ctx, kcpConn, s.tlsConfig)
+		if err != nil {
+			kcpConn.terminateNow()
+			s.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", source))
+			return
+		}
+		conn = tlsConn
+	}
+	s.handler.NewConnectionEx(ctx, conn, source, M.Socksaddr{}, nil)
+}
+
+func (s *Server) Close() error {
+	s.access.Lock()
+	s.closed = true
+	conns := make([]*conn, 0, len(s.sessions))
+	for _, kcpConn := range s.sessions {
+		conns = append(conns, kcpConn)
+	}
+	s.access.Unlock()
+	for _, kcpConn := range conns {
+		kcpConn.terminateNow()
+	}
+	// The inbound listener may have closed the packet conn already, closing
+	// it again here stops loopInput when the transport is closed first.
+	_ = common.Close(s.packetConn)
+	return nil
+}
@@ -0,0 +1,70 @@
+package v2raykcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type testHandler struct {
+	conns chan net.Conn
+}
+
+func (h *testHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	h.conns <- conn
+}
+
+func TestServerMaxSessions(t *testing.T) {
+	options := option.V2RayKCPOptions{MaxSessions: 1}
+	handler := &testHandler{conns: make(chan net.Conn, 2)}
+	transport, err := NewServer(context.Background(), logger.NOP(), options, nil, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := transport.(*Server)
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.ServePacket(packetConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(context.Background(), N.SystemDialer, M.SocksaddrFromNet(packetConn.LocalAddr()), options, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for i := 0; i < 2; i++ {
+		conn, err := client.DialContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write([]byte("ping"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-handler.conns
+	select {
+	case <-handler.conns:
+		t.Fatal("session over the limit was accepted")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	err = server.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close stops loopInput along with the packet conn.
+	_, _, err = packetConn.ReadFrom(make([]byte, 1))
+	if err == nil {
+		t.Fatal("packet conn is still open after Close")
+	}
+}
@@ -0,0 +1,424 @@
+package v2raykcp
+
+import (
+	"container/list"
+	"sync"
+)
+
+type ackList struct {
+	timestamps      []uint32
+	numbers         []uint32
+	nextFlush       []uint32
+	flushCandidates []uint32
+	dirty           bool
+}
+
+func (l *ackList) add(number uint32, timestamp uint32) {
+	l.timestamps = append(l.timestamps, timestamp)
+	l.numbers = append(l.numbers, number)
+	l.nextFlush = append(l.nextFlush, 0)
+	l.dirty = true
+}
+
+func (l *ackList) clear(una uint32) {
+	count := 0
+	for i := range l.numbers {
+		if l.numbers[i] < una {
+			continue
+		}
+		if i != count {
+			l.numbers[count] = l.numbers[i]
+			l.timestamps[count] = l.timestamps[i]
+			l.nextFlush[count] = l.nextFlush[i]
+		}
+		count++
+	}
+	if count < len(l.numbers) {
+		l.numbers = l.numbers[:count]
+		l.timestamps = l.timestamps[:count]
+		l.nextFlush = l.nextFlush[:count]
+		l.dirty = true
+	}
+}
+
+func (l *ackList) flush(current uint32, rto uint32, write func(seg *ackSegment)) {
+	l.flushCandidates = l.flushCandidates[:0]
+	seg := &ackSegment{}
+	for i := range l.numbers {
+		if l.nextFlush[i] > current {
+			if len(l.flushCandidates) < ackNumberLimit {
+				l.flushCandidates = append(l.flushCandidates, l.numbers[i])
+			}
+			continue
+		}
+		seg.putNumber(l.numbers[i])
+		seg.putTimestamp(l.timestamps[i])
+		l.nextFlush[i] = current + max(rto/2, 20)
+		if seg.isFull() {
+			write(seg)
+			seg = &ackSegment{}
+			l.dirty = false
+		}
+	}
+	if l.dirty || !seg.isEmpty() {
+		for _, number := range l.flushCandidates {
+			if seg.isFull() {
+				break
+			}
+			seg.putNumber(number)
+		}
+		write(seg)
+		l.dirty = false
+	}
+}
+
+type receivingWorker struct {
+	access     sync.RWMutex
+	conn       *conn
+	leftOver   []byte
+	window     map[uint32]*dataSegment
+	acks       ackList
+	next       uint32
+	windowSize uint32
+}
+
+func newReceivingWorker(conn *conn) *receivingWorker {
+	return &receivingWorker{
+		conn:       conn,
+		window:     make(map[uint32]*dataSegment),
+		windowSize: conn.config.receivingInFlightSize(),
+	}
+}
+
+func (w *receivingWorker) release() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.leftOver = nil
+	clear(w.window)
+}
+
+func (w *receivingWorker) processSendingNext(number uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.acks.clear(number)
+}
+
+func (w *receivingWorker) processSegment(seg *dataSegment) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if seg.number-w.next >= w.windowSize {
+		return
+	}
+	w.acks.clear(seg.sendingNext)
+	w.acks.add(seg.number, seg.timestamp)
+	if _, loaded := w.window[seg.number]; !loaded {
+		w.window[seg.number] = seg
+	}
+}
+
+func (w *receivingWorker) read(b []byte) int {
+	w.access.Lock()
+	defer w.access.Unlock()
+	var n int
+	for n < len(b) {
+		if len(w.leftOver) == 0 {
+			seg, loaded := w.window[w.next]
+			if !loaded {
+				break
+			}
+			delete(w.window, w.next)
+			w.next++
+			w.leftOver = seg.payload
+		}
+		copied := copy(b[n:], w.leftOver)
+		w.leftOver = w.leftOver[copied:]
+		n += copied
+	}
+	return n
+}
+
+func (w *receivingWorker) isDataAvailable() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	_, loaded := w.window[w.next]
+	return loaded
+}
+
+func (w *receivingWorker) nextNumber() uint32 {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.next
+}
+
+func (w *receivingWorker) flush(current uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.acks.flush(current, w.conn.roundTrip.timeout(), func(seg *ackSegment) {
+		seg.conv = w.conn.conv
+		seg.receivingNext = w.next
+		seg.receivingWindow = w.next + w.windowSize
+		if w.conn.currentState() == stateReadyToClose {
+			seg.option = segmentOptionClose
+		}
+		w.conn.output.writeSegment(seg)
+	})
+}
+
+func (w *receivingWorker) updateNecessary() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return len(w.acks.numbers) > 0
+}
+
+type sendingWindow struct {
+	cache             *list.List
+	totalInFlightSize uint32
+}
+
+func (w *sendingWindow) isEmpty() bool {
+	return w.cache.Len() == 0
+}
+
+func (w *sendingWindow) firstNumber() uint32 {
+	return w.cache.Front().Value.(*dataSegment).number
+}
+
+func (w *sendingWindow) clear(una uint32) {
+	for !w.isEmpty() {
+		if w.cache.Front().Value.(*dataSegment).number >= una {
+			break
+		}
+		w.cache.Remove(w.cache.Front())
+	}
+}
+
+func (w *sendingWindow) handleFastAck(number uint32, rto uint32) {
+	for element := w.cache.Front(); element != nil; element = element.Next() {
+		seg := element.Value.(*dataSegment)
+		if number == seg.number || number-seg.number > 0x7FFFFFFF {
+			break
+		}
+		if seg.transmit > 0 && seg.timeout > rto/3 {
+			seg.timeout -= rto / 3
+		}
+	}
+}
+
+// flush sends the segments whose retransmission timeout has passed and
+// returns the loss rate among them in percent.
+func (w *sendingWindow) flush(current uint32, rto uint32, maxInFlightSize uint32, write func(seg *dataSegment)) (lossRate uint32, sent bool) {
+	var lost, inFlightSize uint32
+	for element := w.cache.Front(); element != nil && inFlightSize < maxInFlightSize; element = element.Next() {
+		seg := element.Value.(*dataSegment)
+		if current-seg.timeout >= 0x7FFFFFFF {
+			continue
+		}
+		if seg.transmit == 0 {
+			w.totalInFlightSize++
+		} else {
+			lost++
+		}
+		seg.timeout = current + rto
+		seg.timestamp = current
+		seg.transmit++
+		write(seg)
+		inFlightSize++
+	}
+	if inFlightSize == 0 || w.totalInFlightSize == 0 {
+		return 0, false
+	}
+	return lost * 100 / w.totalInFlightSize, true
+}
+
+func (w *sendingWindow) remove(number uint32) bool {
+	for element := w.cache.Front(); element != nil; element = element.Next() {
+		seg := element.Value.(*dataSegment)
+		if seg.number > number {
+			return false
+		} else if seg.number == number {
+			if w.totalInFlightSize > 0 {
+				w.totalInFlightSize--
+			}
+			w.cache.Remove(element)
+			return true
+		}
+	}
+	return false
+}
+
+type sendingWorker struct {
+	access                     sync.RWMutex
+	conn                       *conn
+	window                     sendingWindow
+	firstUnacked               uint32
+	next                       uint32
+	remoteNextNumber           uint32
+	controlWindow              uint32
+	windowSize                 uint32
+	firstUnacknowledgedUpdated bool
+	closed                     bool
+}
+
+func newSendingWorker(conn *conn) *sendingWorker {
+	return &sendingWorker{
+		conn:             conn,
+		window:           sendingWindow{cache: list.New()},
+		remoteNextNumber: 32,
+		controlWindow:    conn.config.sendingInFlightSize(),
+		windowSize:       conn.config.sendingBufferSize(),
+	}
+}
+
+func (w *sendingWorker) release() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.window.cache.Init()
+	w.closed = true
+}
+
+func (w *sendingWorker) processReceivingNext(nextNumber uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.processReceivingNextWithoutLock(nextNumber)
+}
+
+func (w *sendingWorker) processReceivingNextWithoutLock(nextNumber uint32) {
+	w.window.clear(nextNumber)
+	w.findFirstUnacknowledged()
+}
+
+func (w *sendingWorker) findFirstUnacknowledged() {
+	first := w.firstUnacked
+	if !w.window.isEmpty() {
+		w.firstUnacked = w.window.firstNumber()
+	} else {
+		w.firstUnacked = w.next
+	}
+	if first != w.firstUnacked {
+		w.firstUnacknowledgedUpdated = true
+	}
+}
+
+func (w *sendingWorker) processAck(number uint32) bool {
+	if number-w.firstUnacked > 0x7FFFFFFF || number-w.next < 0x7FFFFFFF {
+		return false
+	}
+	removed := w.window.remove(number)
+	if removed {
+		w.findFirstUnacknowledged()
+	}
+	return removed
+}
+
+func (w *sendingWorker) processSegment(current uint32, seg *ackSegment, rto uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed {
+		return
+	}
+	if w.remoteNextNumber < seg.receivingWindow {
+		w.remoteNextNumber = seg.receivingWindow
+	}
+	w.processReceivingNextWithoutLock(seg.receivingNext)
+	if seg.isEmpty() {
+		return
+	}
+	var maxAck uint32
+	var maxAckRemoved bool
+	for _, number := range seg.numberList {
+		removed := w.processAck(number)
+		if maxAck < number {
+			maxAck = number
+			maxAckRemoved = removed
+		}
+	}
+	if maxAckRemoved {
+		w.window.handleFastAck(maxAck, rto)
+		if current-seg.timestamp < 10000 {
+			w.conn.roundTrip.update(current-seg.timestamp, current)
+		}
+	}
+}
+
+func (w *sendingWorker) push(payload []byte) bool {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed || uint32(w.window.cache.Len()) > w.windowSize {
+		return false
+	}
+	w.window.cache.PushBack(&dataSegment{
+		number:  w.next,
+		payload: payload,
+	})
+	w.next++
+	return true
+}
+
+func (w *sendingWorker) onPacketLoss(lossRate uint32) {
+	if !w.conn.config.congestion || w.conn.roundTrip.timeout() == 0 {
+		return
+	}
+	if lossRate >= 15 {
+		w.controlWindow = 3 * w.controlWindow / 4
+	} else if lossRate <= 5 {
+		w.controlWindow += w.controlWindow / 4
+	}
+	w.controlWindow = max(w.controlWindow, 16)
+	w.controlWindow = min(w.controlWindow, 2*w.conn.config.sendingInFlightSize())
+}
+
+func (w *sendingWorker) flush(current uint32) {
+	w.access.Lock()
+	if w.closed {
+		w.access.Unlock()
+		return
+	}
+	cwnd := min(w.conn.config.sendingInFlightSize(), w.remoteNextNumber-w.firstUnacked)
+	if w.conn.config.congestion {
+		cwnd = min(cwnd, w.controlWindow)
+	}
+	cwnd *= 20
+	if !w.window.isEmpty() {
+		lossRate, sent := w.window.flush(current, w.conn.roundTrip.timeout(), cwnd, func(seg *dataSegment) {
+			seg.conv = w.conn.conv
+			seg.sendingNext = w.firstUnacked
+			seg.option = 0
+			if w.conn.currentState() == stateReadyToClose {
+				seg.option = segmentOptionClose
+			}
+			w.conn.output.writeSegment(seg)
+		})
+		if sent {
+			w.onPacketLoss(lossRate)
+		}
+		w.firstUnacknowledgedUpdated = false
+	}
+	updated := w.firstUnacknowledgedUpdated
+	w.firstUnacknowledgedUpdated = false
+	w.access.Unlock()
+	if updated {
+		w.conn.ping(current, commandPing)
+	}
+}
+
+func (w *sendingWorker) closeWrite() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.window.clear(0xFFFFFFFF)
+}
+
+func (w *sendingWorker) isEmpty() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.window.isEmpty()
+}
+
+func (w *sendingWorker) updateNecessary() bool {
+	return !w.isEmpty()
+}
+
+func (w *sendingWorker) firstUnacknowledged() uint32 {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.firstUnacked
+}
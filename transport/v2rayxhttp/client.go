@@ -0,0 +1,246 @@
+package v2rayxhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid/v5"
+	"golang.org/x/net/http2"
+)
+
+// NewHTTP3TransportFunc returns the constructor of HTTP/3 round trippers used
+// when the TLS ALPN is h3. It is replaced when QUIC support is included.
+var NewHTTP3TransportFunc func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config, keepAlivePeriod time.Duration) (func() http.RoundTripper, error)
+
+var _ adapter.V2RayClientTransport = (*Client)(nil)
+
+type Client struct {
+	config     *config
+	mode       string
+	requestURL url.URL
+	xmux       *xmuxManager
+}
+
+func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RayXHTTPOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
+	xConfig, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	var keepAlivePeriod time.Duration
+	if options.Xmux != nil {
+		keepAlivePeriod = time.Duration(options.Xmux.HKeepAlivePeriod)
+	}
+	if keepAlivePeriod == 0 {
+		keepAlivePeriod = 45 * time.Second
+	}
+	var newTransport func() http.RoundTripper
+	switch {
+	case tlsConfig == nil:
+		newTransport = func() http.RoundTripper {
+			return &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return dialer.DialContext(ctx, network, serverAddr)
+				},
+			}
+		}
+	case len(tlsConfig.NextProtos()) == 1 && tlsConfig.NextProtos()[0] == "http/1.1":
+		newTransport = func() http.RoundTripper {
+			return &http.Transport{
+				DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					conn, err := dialer.DialContext(ctx, network, serverAddr)
+					if err != nil {
+						return nil, err
+					}
+					return tls.ClientHandshake(ctx, conn, tlsConfig)
+				},
+			}
+		}
+	case len(tlsConfig.NextProtos()) == 1 && tlsConfig.NextProtos()[0] == "h3":
+		newTransport, err = NewHTTP3TransportFunc(dialer, serverAddr, tlsConfig, keepAlivePeriod)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		if len(tlsConfig.NextProtos()) == 0 {
+			tlsConfig.SetNextProtos([]string{http2.NextProtoTLS})
+		}
+		newTransport = func() http.RoundTripper {
+			return &http2.Transport{
+				ReadIdleTimeout: keepAlivePeriod,
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.STDConfig) (net.Conn, error) {
+					conn, err := dialer.DialContext(ctx, network, serverAddr)
+					if err != nil {
+						return nil, err
+					}
+					return tls.ClientHandshake(ctx, conn, tlsConfig)
+				},
+			}
+		}
+	}
+	xmux, err := newXmuxManager(options.Xmux, newTransport)
+	if err != nil {
+		return nil, err
+	}
+	var requestURL url.URL
+	if tlsConfig == nil {
+		requestURL.Scheme = "http"
+	} else {
+		requestURL.Scheme = "https"
+	}
+	requestURL.Host = serverAddr.String()
+	requestURL.Path = xConfig.path
+	requestURL.RawQuery = xConfig.query
+	mode := xConfig.mode
+	if mode == ModeAuto {
+		mode = ModePacketUp
+	}
+	return &Client{
+		config:     xConfig,
+		mode:       mode,
+		requestURL: requestURL,
+		xmux:       xmux,
+	}, nil
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	client := c.xmux.getClient()
+	client.openUsage.Add(1)
+	onClose := func() {
+		cancel()
+		client.openUsage.Add(-1)
+	}
+	if c.mode == ModeStreamOne {
+		pipeReader, pipeWriter := io.Pipe()
+		conn := newClientConn(pipeWriter, onClose)
+		go conn.setup(c.roundTrip(client, c.newRequest(ctx, http.MethodPost, c.requestURL, pipeReader, true)))
+		return conn, nil
+	}
+	sessionURL := c.requestURL
+	sessionURL.Path += uuid.Must(uuid.NewV4()).String()
+	var writer io.WriteCloser
+	if c.mode == ModeStreamUp {
+		pipeReader, pipeWriter := io.Pipe()
+		writer = pipeWriter
+		go func() {
+			response, err := c.roundTrip(client, c.newRequest(ctx, http.MethodPost, sessionURL, pipeReader, true))
+			if err != nil {
+				pipeReader.CloseWithError(err)
+				return
+			}
+			// The response only carries padding, but closing it early would
+			// abort the upload.
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}()
+	} else {
+		uploader := newPacketUploader(int(c.config.scMaxEachPostBytes.to))
+		writer = uploader
+		go c.uploadLoop(ctx, client, sessionURL, uploader)
+	}
+	conn := newClientConn(writer, onClose)
+	go conn.setup(c.roundTrip(client, c.newRequest(ctx, http.MethodGet, sessionURL, nil, false)))
+	return conn, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, requestURL url.URL, body io.Reader, streaming bool) *http.Request {
+	request, _ := http.NewRequestWithContext(ctx, method, requestURL.String(), body)
+	request.Header = c.config.requestHeader(requestURL)
+	if streaming && !c.config.noGRPCHeader {
+		request.Header.Set("Content-Type", "application/grpc")
+	}
+	if c.config.host != "" {
+		request.Host = c.config.host
+	}
+	return request
+}
+
+func (c *Client) roundTrip(client *xmuxClient, request *http.Request) (*http.Response, error) {
+	client.leftRequests.Add(-1)
+	response, err := client.transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, E.New("xhttp: unexpected status: ", response.Status)
+	}
+	return response, nil
+}
+
+func (c *Client) uploadLoop(ctx context.Context, client *xmuxClient, sessionURL url.URL, uploader *packetUploader) {
+	var (
+		seq       uint64
+		lastWrite time.Time
+	)
+	for {
+		if interval := time.Duration(c.config.scMinPostsIntervalMs.rand()) * time.Millisecond; interval > 0 {
+			timer := time.NewTimer(time.Until(lastWrite.Add(interval)))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				uploader.CloseWithError(ctx.Err())
+				return
+			}
+		}
+		chunk, err := uploader.next(int(c.config.scMaxEachPostBytes.rand()))
+		if err != nil {
+			return
+		}
+		lastWrite = time.Now()
+		if !client.reusable() {
+			client = c.xmux.getClient()
+		}
+		postURL := sessionURL
+		postURL.Path += "/" + strconv.FormatUint(seq, 10)
+		seq++
+		var wroteOnce sync.Once
+		wroteRequest := make(chan struct{})
+		failed := make(chan struct{})
+		request := c.newRequest(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: func(httptrace.WroteRequestInfo) {
+				wroteOnce.Do(func() {
+					close(wroteRequest)
+				})
+			},
+		}), http.MethodPost, postURL, bytes.NewReader(chunk), false)
+		go func(client *xmuxClient) {
+			response, err := c.roundTrip(client, request)
+			if err != nil {
+				uploader.CloseWithError(E.Cause(err, "xhttp: upload"))
+				close(failed)
+				return
+			}
+			response.Body.Close()
+		}(client)
+		select {
+		case <-wroteRequest:
+		case <-failed:
+			return
+		case <-ctx.Done():
+			uploader.CloseWithError(ctx.Err())
+			return
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	c.xmux.Close()
+	return nil
+}
@@ -0,0 +1,140 @@
+package v2rayxhttp
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	ModeAuto      = "auto"
+	ModePacketUp  = "packet-up"
+	ModeStreamUp  = "stream-up"
+	ModeStreamOne = "stream-one"
+)
+
+const minPostBytes = 8 * 1024
+
+type xRange struct {
+	from int32
+	to   int32
+}
+
+func newRange(value *option.V2RayXHTTPRange, defaultFrom int32, defaultTo int32) xRange {
+	if value == nil || value.To == 0 {
+		return xRange{defaultFrom, defaultTo}
+	}
+	return xRange{value.From, value.To}
+}
+
+func (r xRange) rand() int32 {
+	if r.from >= r.to {
+		return r.to
+	}
+	return r.from + rand.Int31n(r.to-r.from+1)
+}
+
+type config struct {
+	host                 string
+	path                 string
+	query                string
+	mode                 string
+	headers              http.Header
+	paddingBytes         xRange
+	noGRPCHeader         bool
+	noSSEHeader          bool
+	scMaxEachPostBytes   xRange
+	scMinPostsIntervalMs xRange
+	scMaxBufferedPosts   int
+	scMaxBufferedBytes   int64
+	scMaxSessions        int
+	scStreamUpServerSecs xRange
+}
+
+func newConfig(options option.V2RayXHTTPOptions) (*config, error) {
+	switch options.Mode {
+	case "", ModeAuto:
+		options.Mode = ModeAuto
+	case ModePacketUp, ModeStreamUp, ModeStreamOne:
+	default:
+		return nil, E.New("unknown xhttp mode: ", options.Mode)
+	}
+	path, query, _ := strings.Cut(options.Path, "?")
+	path = "/" + strings.Trim(path, "/")
+	if path != "/" {
+		path += "/"
+	}
+	headers := options.Headers.Build()
+	host := options.Host
+	if host == "" {
+		host = headers.Get("Host")
+	}
+	headers.Del("Host")
+	xConfig := &config{
+		host:                 host,
+		path:                 path,
+		query:                query,
+		mode:                 options.Mode,
+		headers:              headers,
+		paddingBytes:         newRange(options.XPaddingBytes, 100, 1000),
+		noGRPCHeader:         options.NoGRPCHeader,
+		noSSEHeader:          options.NoSSEHeader,
+		scMaxEachPostBytes:   newRange(options.ScMaxEachPostBytes, 1000000, 1000000),
+		scMinPostsIntervalMs: newRange(options.ScMinPostsIntervalMs, 30, 30),
+		scMaxBufferedPosts:   options.ScMaxBufferedPosts,
+		scMaxBufferedBytes:   options.ScMaxBufferedBytes,
+		scMaxSessions:        options.ScMaxSessions,
+		scStreamUpServerSecs: newRange(options.ScStreamUpServerSecs, 20, 80),
+	}
+	if xConfig.scMaxBufferedPosts == 0 {
+		xConfig.scMaxBufferedPosts = 30
+	}
+	if xConfig.scMaxBufferedBytes == 0 {
+		xConfig.scMaxBufferedBytes = 64 * 1024 * 1024
+	}
+	if xConfig.scMaxSessions == 0 {
+		xConfig.scMaxSessions = 1024
+	}
+	if xConfig.scMaxEachPostBytes.from < minPostBytes {
+		return nil, E.New("sc_max_each_post_bytes must be at least ", minPostBytes)
+	}
+	return xConfig, nil
+}
+
+// requestHeader returns the headers of a client request, carrying the padding
+// in the Referer query so that HPACK/QPACK keeps its length on the wire.
+func (c *config) requestHeader(requestURL url.URL) http.Header {
+	header := c.headers.Clone()
+	requestURL.RawQuery = "x_padding=" + strings.Repeat("X", int(c.paddingBytes.rand()))
+	header.Set("Referer", requestURL.String())
+	return header
+}
+
+func (c *config) writeResponseHeader(writer http.ResponseWriter) {
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+	writer.Header().Set("X-Padding", strings.Repeat("X", int(c.paddingBytes.rand())))
+}
+
+func (c *config) checkPadding(request *http.Request) bool {
+	var paddingLength int
+	referrer := request.Header.Get("Referer")
+	if referrer != "" {
+		referrerURL, err := url.Parse(referrer)
+		if err == nil {
+			paddingLength = len(referrerURL.Query().Get("x_padding"))
+		}
+	} else {
+		paddingLength = len(request.URL.Query().Get("x_padding"))
+	}
+	return int32(paddingLength) >= c.paddingBytes.from && int32(paddingLength) <= c.paddingBytes.to
+}
+
+func (c *config) allowMode(mode string) bool {
+	return common.Contains([]string{ModeAuto, mode}, c.mode)
+}
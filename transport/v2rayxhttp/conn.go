@@ -0,0 +1,158 @@
+package v2rayxhttp
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/baderror"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var _ net.Conn = (*clientConn)(nil)
+
+// clientConn reads from the download response, which arrives
+// asynchronously, and writes to the upload side of the session.
+type clientConn struct {
+	writer    io.WriteCloser
+	create    chan struct{}
+	reader    io.ReadCloser
+	err       error
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newClientConn(writer io.WriteCloser, onClose func()) *clientConn {
+	return &clientConn{
+		writer:  writer,
+		create:  make(chan struct{}),
+		onClose: onClose,
+	}
+}
+
+func (c *clientConn) setup(response *http.Response, err error) {
+	if err == nil {
+		c.reader = response.Body
+	} else {
+		c.err = err
+		c.writer.Close()
+	}
+	close(c.create)
+}
+
+func (c *clientConn) Read(b []byte) (n int, err error) {
+	<-c.create
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err = c.reader.Read(b)
+	return n, baderror.WrapH2(err)
+}
+
+func (c *clientConn) Write(b []byte) (n int, err error) {
+	n, err = c.writer.Write(b)
+	return n, baderror.WrapH2(err)
+}
+
+func (c *clientConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.writer.Close()
+		c.onClose()
+		go func() {
+			<-c.create
+			if c.reader != nil {
+				c.reader.Close()
+			}
+		}()
+	})
+	return nil
+}
+
+func (c *clientConn) LocalAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *clientConn) RemoteAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *clientConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *clientConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *clientConn) NeedAdditionalReadDeadline() bool {
+	return true
+}
+
+// packetUploader buffers writes of a packet-up session until the upload loop
+// takes them as the body of the next post.
+type packetUploader struct {
+	access  sync.Mutex
+	cond    *sync.Cond
+	buffer  []byte
+	maxSize int
+	err     error
+}
+
+func newPacketUploader(maxSize int) *packetUploader {
+	uploader := &packetUploader{maxSize: maxSize}
+	uploader.cond = sync.NewCond(&uploader.access)
+	return uploader
+}
+
+func (u *packetUploader) Write(p []byte) (n int, err error) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	for u.err == nil && len(u.buffer) >= u.maxSize {
+		u.cond.Wait()
+	}
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.buffer = append(u.buffer, p...)
+	u.cond.Broadcast()
+	return len(p), nil
+}
+
+func (u *packetUploader) next(maxSize int) ([]byte, error) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	for u.err == nil && len(u.buffer) == 0 {
+		u.cond.Wait()
+	}
+	if len(u.buffer) == 0 {
+		return nil, u.err
+	}
+	chunk := u.buffer
+	if len(chunk) > maxSize {
+		chunk = chunk[:maxSize]
+	}
+	u.buffer = append([]byte(nil), u.buffer[len(chunk):]...)
+	u.cond.Broadcast()
+	return chunk, nil
+}
+
+func (u *packetUploader) CloseWithError(err error) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	if u.err == nil {
+		u.err = err
+	}
+	u.cond.Broadcast()
+}
+
+func (u *packetUploader) Close() error {
+	u.CloseWithError(io.ErrClosedPipe)
+	return nil
+}
@@ -0,0 +1,71 @@
+package quic
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/transport/v2rayxhttp"
+	"github.com/sagernet/sing-quic"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func init() {
+	v2rayxhttp.ServeHTTP3Func = func(packetConn net.PacketConn, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
+		err := qtls.ConfigureHTTP3(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		quicListener, err := qtls.ListenEarly(packetConn, tlsConfig, &quic.Config{
+			MaxIncomingStreams: 1 << 60,
+			Allow0RTT:          true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		h3Server := &http3.Server{
+			Handler: handler,
+		}
+
+		go func() {
+			sErr := h3Server.ServeListener(quicListener)
+			if sErr != nil && !E.IsClosedOrCanceled(sErr) {
+				logger.Error("http3 server closed: ", sErr)
+			}
+		}()
+
+		return quicListener, nil
+	}
+	v2rayxhttp.NewHTTP3TransportFunc = func(dialer N.Dialer, serverAddr M.Socksaddr, tlsConfig tls.Config, keepAlivePeriod time.Duration) (func() http.RoundTripper, error) {
+		return func() http.RoundTripper {
+			return &http3.Transport{
+				QUICConfig: &quic.Config{
+					KeepAlivePeriod: keepAlivePeriod,
+				},
+				Dial: func(ctx context.Context, addr string, tlsCfg *tls.STDConfig, cfg *quic.Config) (quic.EarlyConnection, error) {
+					conn, err := dialer.DialContext(ctx, N.NetworkUDP, serverAddr)
+					if err != nil {
+						return nil, err
+					}
+					quicConn, err := qtls.DialEarly(ctx, bufio.NewUnbindPacketConn(conn), conn.RemoteAddr(), tlsConfig, cfg)
+					if err != nil {
+						conn.Close()
+						return nil, err
+					}
+					return quicConn, nil
+				},
+			}
+		}, nil
+	}
+}
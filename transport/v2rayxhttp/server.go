@@ -0,0 +1,323 @@
+package v2rayxhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+	sHttp "github.com/sagernet/sing/protocol/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ServeHTTP3Func serves the handler over HTTP/3 on the packet conn. It is
+// replaced when QUIC support is included.
+var ServeHTTP3Func func(packetConn net.PacketConn, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error)
+
+const sessionTimeout = 30 * time.Second
+
+var _ adapter.V2RayServerTransport = (*Server)(nil)
+
+type Server struct {
+	ctx           context.Context
+	logger        logger.ContextLogger
+	tlsConfig     tls.ServerConfig
+	handler       adapter.V2RayServerTransportHandler
+	httpServer    *http.Server
+	h2cHandler    http.Handler
+	h3Server      io.Closer
+	config        *config
+	sessionAccess sync.Mutex
+	sessions      map[string]*serverSession
+	bufferBudget  *bufferBudget
+}
+
+type serverSession struct {
+	queue     *uploadQueue
+	connected bool
+	timer     *time.Timer
+}
+
+func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayXHTTPOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
+	xConfig, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		ctx:       ctx,
+		logger:    logger,
+		tlsConfig: tlsConfig,
+		handler:   handler,
+		config:    xConfig,
+		sessions:  make(map[string]*serverSession),
+		bufferBudget: &bufferBudget{
+			max: xConfig.scMaxBufferedBytes,
+		},
+	}
+	server.httpServer = &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: C.TCPTimeout,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return log.ContextWithNewID(ctx)
+		},
+	}
+	server.h2cHandler = h2c.NewHandler(server, &http2.Server{})
+	return server, nil
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method == "PRI" && len(request.Header) == 0 && request.URL.Path == "*" && request.Proto == "HTTP/2.0" {
+		s.h2cHandler.ServeHTTP(writer, request)
+		return
+	}
+	if s.config.host != "" && requestHost(request) != s.config.host {
+		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad host: ", request.Host))
+		return
+	}
+	if !strings.HasPrefix(request.URL.Path, s.config.path) {
+		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+		return
+	}
+	s.config.writeResponseHeader(writer)
+	if !s.config.checkPadding(request) {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.New("invalid padding"))
+		return
+	}
+	sessionID, seq, _ := strings.Cut(strings.Trim(request.URL.Path[len(s.config.path):], "/"), "/")
+	switch {
+	case request.Method == http.MethodPost && sessionID == "" && s.config.allowMode(ModeStreamOne):
+		s.serveDownload(writer, request, request.Body, nil)
+	case request.Method == http.MethodGet && sessionID != "" && seq == "" && s.config.mode != ModeStreamOne:
+		session := s.upsertSession(writer, request, sessionID)
+		if session == nil {
+			return
+		}
+		s.sessionAccess.Lock()
+		session.connected = true
+		session.timer.Stop()
+		s.sessionAccess.Unlock()
+		s.serveDownload(writer, request, session.queue, func() {
+			s.sessionAccess.Lock()
+			if s.sessions[sessionID] == session {
+				delete(s.sessions, sessionID)
+			}
+			s.sessionAccess.Unlock()
+		})
+	case request.Method == http.MethodPost && sessionID != "" && seq != "" && s.config.allowMode(ModePacketUp):
+		session := s.upsertSession(writer, request, sessionID)
+		if session != nil {
+			s.servePacketUp(writer, request, session, seq)
+		}
+	case request.Method == http.MethodPost && sessionID != "" && seq == "" && s.config.allowMode(ModeStreamUp):
+		session := s.upsertSession(writer, request, sessionID)
+		if session != nil {
+			s.serveStreamUp(writer, request, session)
+		}
+	default:
+		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad request: ", request.Method, " ", request.URL.Path))
+	}
+}
+
+func requestHost(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.Host)
+	if err != nil {
+		return request.Host
+	}
+	return host
+}
+
+// upsertSession returns the session, creating it unless the server already
+// holds sc_max_sessions sessions, in which case the request is rejected and
+// nil is returned.
+func (s *Server) upsertSession(writer http.ResponseWriter, request *http.Request, sessionID string) *serverSession {
+	s.sessionAccess.Lock()
+	defer s.sessionAccess.Unlock()
+	session, loaded := s.sessions[sessionID]
+	if loaded {
+		return session
+	}
+	if len(s.sessions) >= s.config.scMaxSessions {
+		s.invalidRequest(writer, request, http.StatusServiceUnavailable, E.New("too many sessions"))
+		return nil
+	}
+	session = &serverSession{
+		queue: newUploadQueue(s.config.scMaxBufferedPosts, s.bufferBudget),
+	}
+	session.timer = time.AfterFunc(sessionTimeout, func() {
+		s.sessionAccess.Lock()
+		defer s.sessionAccess.Unlock()
+		if !session.connected && s.sessions[sessionID] == session {
+			delete(s.sessions, sessionID)
+			session.queue.Close()
+		}
+	})
+	s.sessions[sessionID] = session
+	return session
+}
+
+func (s *Server) serveDownload(writer http.ResponseWriter, request *http.Request, reader io.ReadCloser, onFinish func()) {
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Cache-Control", "no-store")
+	if !s.config.noSSEHeader {
+		writer.Header().Set("Content-Type", "text/event-stream")
+	}
+	if request.ProtoMajor == 1 {
+		http.NewResponseController(writer).EnableFullDuplex()
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+	done := make(chan struct{})
+	conn := v2rayhttp.NewHTTP2Wrapper(&v2rayhttp.ServerHTTPConn{
+		HTTP2Conn: v2rayhttp.NewHTTPConn(reader, writer),
+		Flusher:   writer.(http.Flusher),
+	})
+	s.handler.NewConnectionEx(request.Context(), conn, sHttp.SourceAddress(request), M.Socksaddr{}, N.OnceClose(func(it error) {
+		close(done)
+	}))
+	select {
+	case <-done:
+		conn.CloseWrapper()
+		reader.Close()
+	case <-request.Context().Done():
+		conn.Close()
+	}
+	if onFinish != nil {
+		onFinish()
+	}
+}
+
+func (s *Server) servePacketUp(writer http.ResponseWriter, request *http.Request, session *serverSession, seq string) {
+	seqNumber, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.Cause(err, "parse seq"))
+		return
+	}
+	maxPostBytes := int64(s.config.scMaxEachPostBytes.to)
+	payload, err := io.ReadAll(io.LimitReader(request.Body, maxPostBytes+1))
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusInternalServerError, E.Cause(err, "read post"))
+		return
+	}
+	if int64(len(payload)) > maxPostBytes {
+		s.invalidRequest(writer, request, http.StatusRequestEntityTooLarge, E.New("too large post: ", len(payload)))
+		return
+	}
+	err = session.queue.Push(seqNumber, payload)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusInternalServerError, E.Cause(err, "push post"))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) serveStreamUp(writer http.ResponseWriter, request *http.Request, session *serverSession) {
+	body := &streamUpBody{ReadCloser: request.Body, done: make(chan struct{})}
+	err := session.queue.PushReader(body)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusConflict, err)
+		return
+	}
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Cache-Control", "no-store")
+	if request.ProtoMajor == 1 {
+		http.NewResponseController(writer).EnableFullDuplex()
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+	// Keep intermediate proxies from timing out the idle response of a
+	// browser-like client by streaming padding back.
+	var paddingTimer <-chan time.Time
+	if request.Header.Get("Referer") != "" && s.config.scStreamUpServerSecs.to > 0 {
+		paddingTimer = time.After(time.Duration(s.config.scStreamUpServerSecs.rand()) * time.Second)
+	}
+	for {
+		select {
+		case <-body.done:
+			return
+		case <-request.Context().Done():
+			return
+		case <-paddingTimer:
+			_, err = writer.Write(bytes.Repeat([]byte{'X'}, int(s.config.paddingBytes.rand())))
+			if err != nil {
+				return
+			}
+			writer.(http.Flusher).Flush()
+			paddingTimer = time.After(time.Duration(s.config.scStreamUpServerSecs.rand()) * time.Second)
+		}
+	}
+}
+
+type streamUpBody struct {
+	io.ReadCloser
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (b *streamUpBody) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+	return nil
+}
+
+func (s *Server) invalidRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	if statusCode > 0 {
+		writer.WriteHeader(statusCode)
+	}
+	s.logger.ErrorContext(request.Context(), E.Cause(err, "process connection from ", request.RemoteAddr))
+}
+
+func (s *Server) Network() []string {
+	if s.tlsConfig != nil && common.Contains(s.tlsConfig.NextProtos(), "h3") {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	return []string{N.NetworkTCP}
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		if len(s.tlsConfig.NextProtos()) == 0 {
+			s.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+		} else if !common.Contains(s.tlsConfig.NextProtos(), http2.NextProtoTLS) {
+			s.tlsConfig.SetNextProtos(append([]string{http2.NextProtoTLS}, s.tlsConfig.NextProtos()...))
+		}
+		listener = aTLS.NewListener(listener, s.tlsConfig)
+	}
+	return s.httpServer.Serve(listener)
+}
+
+func (s *Server) ServePacket(listener net.PacketConn) error {
+	h3Server, err := ServeHTTP3Func(listener, s, s.tlsConfig, s.logger)
+	if err != nil {
+		return err
+	}
+	s.h3Server = h3Server
+	return nil
+}
+
+func (s *Server) Close() error {
+	return common.Close(common.PtrOrNil(s.httpServer), s.h3Server)
+}
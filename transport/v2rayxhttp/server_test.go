@@ -0,0 +1,63 @@
+package v2rayxhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+)
+
+func newTestServer(t *testing.T, options option.V2RayXHTTPOptions) *Server {
+	options.XPaddingBytes = &option.V2RayXHTTPRange{From: 0, To: 1}
+	server, err := NewServer(context.Background(), logger.NOP(), options, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func postPacket(server *Server, sessionID string, seq string, size int) int {
+	request := httptest.NewRequest(http.MethodPost, "/"+sessionID+"/"+seq, strings.NewReader(strings.Repeat("X", size)))
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestServerMaxSessions(t *testing.T) {
+	server := newTestServer(t, option.V2RayXHTTPOptions{ScMaxSessions: 2})
+	for _, sessionID := range []string{"a", "b"} {
+		if code := postPacket(server, sessionID, "1", 1); code != http.StatusOK {
+			t.Fatalf("session %s: unexpected status %d", sessionID, code)
+		}
+	}
+	if code := postPacket(server, "c", "1", 1); code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status %d for a session over the limit", code)
+	}
+	if code := postPacket(server, "a", "2", 1); code != http.StatusOK {
+		t.Fatalf("unexpected status %d for an existing session", code)
+	}
+}
+
+func TestServerMaxBufferedBytes(t *testing.T) {
+	server := newTestServer(t, option.V2RayXHTTPOptions{ScMaxBufferedBytes: 3 * minPostBytes})
+	// Posts after a missing first one stay buffered, across sessions.
+	for _, sessionID := range []string{"a", "b"} {
+		if code := postPacket(server, sessionID, "1", minPostBytes); code != http.StatusOK {
+			t.Fatalf("session %s: unexpected status %d", sessionID, code)
+		}
+	}
+	if code := postPacket(server, "c", "1", 2*minPostBytes); code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %d for a post over the budget", code)
+	}
+	server.sessionAccess.Lock()
+	queue := server.sessions["a"].queue
+	server.sessionAccess.Unlock()
+	queue.Close()
+	if code := postPacket(server, "c", "1", 2*minPostBytes); code != http.StatusOK {
+		t.Fatalf("unexpected status %d after a session released its posts", code)
+	}
+}
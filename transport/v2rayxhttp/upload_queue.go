@@ -0,0 +1,160 @@
+package v2rayxhttp
+
+import (
+	"container/heap"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type uploadPacket struct {
+	seq     uint64
+	payload []byte
+}
+
+type packetHeap []uploadPacket
+
+func (h packetHeap) Len() int           { return len(h) }
+func (h packetHeap) Less(i, j int) bool { return h[i].seq < h[j].seq }
+func (h packetHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *packetHeap) Push(x any)        { *h = append(*h, x.(uploadPacket)) }
+
+func (h *packetHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// bufferBudget limits the packet-up payloads buffered by all sessions of a
+// server.
+type bufferBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+func (b *bufferBudget) reserve(n int) bool {
+	for {
+		used := b.used.Load()
+		if used+int64(n) > b.max {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+int64(n)) {
+			return true
+		}
+	}
+}
+
+func (b *bufferBudget) release(n int) {
+	b.used.Add(-int64(n))
+}
+
+// uploadQueue is the upload side of a server session: packet-up posts are
+// reordered by sequence number, while a stream-up post is read through.
+type uploadQueue struct {
+	access     sync.Mutex
+	cond       *sync.Cond
+	packets    packetHeap
+	nextSeq    uint64
+	current    []byte
+	reader     io.ReadCloser
+	maxPackets int
+	budget     *bufferBudget
+	closed     bool
+}
+
+func newUploadQueue(maxPackets int, budget *bufferBudget) *uploadQueue {
+	queue := &uploadQueue{maxPackets: maxPackets, budget: budget}
+	queue.cond = sync.NewCond(&queue.access)
+	return queue
+}
+
+func (q *uploadQueue) Push(seq uint64, payload []byte) error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	if q.closed {
+		return io.ErrClosedPipe
+	}
+	if q.reader != nil {
+		return E.New("session is already uploading with stream-up")
+	}
+	if seq < q.nextSeq {
+		return nil
+	}
+	if len(q.packets) >= q.maxPackets {
+		return E.New("too many buffered posts")
+	}
+	if !q.budget.reserve(len(payload)) {
+		return E.New("too many buffered bytes")
+	}
+	heap.Push(&q.packets, uploadPacket{seq, payload})
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *uploadQueue) PushReader(reader io.ReadCloser) error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	if q.closed {
+		return io.ErrClosedPipe
+	}
+	if q.reader != nil || q.nextSeq > 0 || len(q.packets) > 0 {
+		return E.New("session is already uploading")
+	}
+	q.reader = reader
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *uploadQueue) Read(p []byte) (n int, err error) {
+	q.access.Lock()
+	for {
+		if len(q.current) > 0 {
+			n = copy(p, q.current)
+			q.current = q.current[n:]
+			q.access.Unlock()
+			return
+		}
+		if q.reader != nil {
+			reader := q.reader
+			q.access.Unlock()
+			n, err = reader.Read(p)
+			if err != nil {
+				reader.Close()
+			}
+			return
+		}
+		if len(q.packets) > 0 && q.packets[0].seq == q.nextSeq {
+			q.current = heap.Pop(&q.packets).(uploadPacket).payload
+			q.budget.release(len(q.current))
+			q.nextSeq++
+			continue
+		}
+		if q.closed {
+			q.access.Unlock()
+			return 0, io.EOF
+		}
+		q.cond.Wait()
+	}
+}
+
+func (q *uploadQueue) Close() error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	for _, packet := range q.packets {
+		q.budget.release(len(packet.payload))
+	}
+	q.packets = nil
+	q.cond.Broadcast()
+	if q.reader != nil {
+		return q.reader.Close()
+	}
+	return nil
+}
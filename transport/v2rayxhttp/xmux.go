@@ -0,0 +1,140 @@
+package v2rayxhttp
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type xmuxClient struct {
+	transport    http.RoundTripper
+	openUsage    atomic.Int32
+	leftUsage    int32
+	leftRequests atomic.Int32
+	unreusableAt time.Time
+}
+
+func (c *xmuxClient) reusable() bool {
+	return c.leftRequests.Load() > 0 && (c.unreusableAt.IsZero() || time.Now().Before(c.unreusableAt))
+}
+
+// xmuxManager spreads sessions over a pool of HTTP clients, each of which is
+// retired after a random number of sessions, requests or seconds.
+type xmuxManager struct {
+	access           sync.Mutex
+	maxConcurrency   xRange
+	maxConnections   xRange
+	cMaxReuseTimes   xRange
+	hMaxRequestTimes xRange
+	hMaxReusableSecs xRange
+	concurrency      int32
+	connections      int32
+	newTransport     func() http.RoundTripper
+	clients          []*xmuxClient
+}
+
+func newXmuxManager(options *option.V2RayXHTTPXmuxOptions, newTransport func() http.RoundTripper) (*xmuxManager, error) {
+	if options == nil {
+		options = &option.V2RayXHTTPXmuxOptions{
+			MaxConcurrency:   &option.V2RayXHTTPRange{From: 16, To: 32},
+			HMaxRequestTimes: &option.V2RayXHTTPRange{From: 600, To: 900},
+			HMaxReusableSecs: &option.V2RayXHTTPRange{From: 1800, To: 3000},
+		}
+	}
+	manager := &xmuxManager{
+		maxConcurrency:   newRange(options.MaxConcurrency, 0, 0),
+		maxConnections:   newRange(options.MaxConnections, 0, 0),
+		cMaxReuseTimes:   newRange(options.CMaxReuseTimes, 0, 0),
+		hMaxRequestTimes: newRange(options.HMaxRequestTimes, 0, 0),
+		hMaxReusableSecs: newRange(options.HMaxReusableSecs, 0, 0),
+		newTransport:     newTransport,
+	}
+	if manager.maxConcurrency.to > 0 && manager.maxConnections.to > 0 {
+		return nil, E.New("xmux: max_concurrency and max_connections cannot be set at the same time")
+	}
+	manager.concurrency = manager.maxConcurrency.rand()
+	manager.connections = manager.maxConnections.rand()
+	return manager, nil
+}
+
+func (m *xmuxManager) newClient() *xmuxClient {
+	client := &xmuxClient{
+		transport: m.newTransport(),
+		leftUsage: -1,
+	}
+	if reuseTimes := m.cMaxReuseTimes.rand(); reuseTimes > 0 {
+		client.leftUsage = reuseTimes - 1
+	}
+	if requestTimes := m.hMaxRequestTimes.rand(); requestTimes > 0 {
+		client.leftRequests.Store(requestTimes)
+	} else {
+		client.leftRequests.Store(math.MaxInt32)
+	}
+	if reusableSecs := m.hMaxReusableSecs.rand(); reusableSecs > 0 {
+		client.unreusableAt = time.Now().Add(time.Duration(reusableSecs) * time.Second)
+	}
+	m.clients = append(m.clients, client)
+	return client
+}
+
+func (m *xmuxManager) getClient() *xmuxClient {
+	m.access.Lock()
+	defer m.access.Unlock()
+	clients := m.clients[:0]
+	for _, client := range m.clients {
+		if client.leftUsage != 0 && client.reusable() {
+			clients = append(clients, client)
+		} else {
+			v2rayhttp.CloseIdleConnections(client.transport)
+		}
+	}
+	for i := len(clients); i < len(m.clients); i++ {
+		m.clients[i] = nil
+	}
+	m.clients = clients
+	if len(m.clients) == 0 || int(m.connections) > len(m.clients) {
+		return m.newClient()
+	}
+	available := m.clients
+	if m.concurrency > 0 {
+		available = nil
+		for _, client := range m.clients {
+			if client.openUsage.Load() < m.concurrency {
+				available = append(available, client)
+			}
+		}
+	}
+	if len(available) == 0 {
+		return m.newClient()
+	}
+	client := available[rand.Intn(len(available))]
+	if client.leftUsage > 0 {
+		client.leftUsage--
+	}
+	return client
+}
+
+func (m *xmuxManager) Close() {
+	m.access.Lock()
+	defer m.access.Unlock()
+	for _, client := range m.clients {
+		closeTransport(client.transport)
+	}
+	m.clients = nil
+}
+
+func closeTransport(transport http.RoundTripper) {
+	if closer, isCloser := transport.(io.Closer); isCloser {
+		closer.Close()
+	} else {
+		v2rayhttp.ResetTransport(transport)
+	}
+}
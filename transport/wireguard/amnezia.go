@@ -0,0 +1,153 @@
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	mRand "math/rand"
+	"net/netip"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/wireguard-go/device"
+)
+
+// AmneziaOptions are the AmneziaWG obfuscation parameters. Both sides must
+// agree on the padding sizes and message headers; junk packets are only
+// sent by the handshake initiator and need not match.
+type AmneziaOptions struct {
+	JunkPacketCount            int
+	JunkPacketMinSize          int
+	JunkPacketMaxSize          int
+	InitPacketJunkSize         int
+	ResponsePacketJunkSize     int
+	InitPacketMagicHeader      uint32
+	ResponsePacketMagicHeader  uint32
+	UnderloadPacketMagicHeader uint32
+	TransportPacketMagicHeader uint32
+}
+
+type amneziaObfuscator struct {
+	AmneziaOptions
+}
+
+func newAmneziaObfuscator(options AmneziaOptions) (*amneziaObfuscator, error) {
+	if options.JunkPacketCount < 0 || options.JunkPacketCount > 128 {
+		return nil, E.New("amnezia: jc must be between 0 and 128")
+	}
+	if options.JunkPacketCount > 0 {
+		if options.JunkPacketMinSize < 0 || options.JunkPacketMaxSize > 1280 || options.JunkPacketMinSize > options.JunkPacketMaxSize {
+			return nil, E.New("amnezia: jmin and jmax must satisfy 0 <= jmin <= jmax <= 1280")
+		}
+	}
+	if options.InitPacketJunkSize < 0 || options.InitPacketJunkSize > 1280-device.MessageInitiationSize {
+		return nil, E.New("amnezia: s1 must be between 0 and ", 1280-device.MessageInitiationSize)
+	}
+	if options.ResponsePacketJunkSize < 0 || options.ResponsePacketJunkSize > 1280-device.MessageResponseSize {
+		return nil, E.New("amnezia: s2 must be between 0 and ", 1280-device.MessageResponseSize)
+	}
+	if options.InitPacketJunkSize+device.MessageInitiationSize == options.ResponsePacketJunkSize+device.MessageResponseSize {
+		return nil, E.New("amnezia: s1 + ", device.MessageInitiationSize-device.MessageResponseSize, " must not equal s2")
+	}
+	if options.InitPacketMagicHeader == 0 {
+		options.InitPacketMagicHeader = device.MessageInitiationType
+	}
+	if options.ResponsePacketMagicHeader == 0 {
+		options.ResponsePacketMagicHeader = device.MessageResponseType
+	}
+	if options.UnderloadPacketMagicHeader == 0 {
+		options.UnderloadPacketMagicHeader = device.MessageCookieReplyType
+	}
+	if options.TransportPacketMagicHeader == 0 {
+		options.TransportPacketMagicHeader = device.MessageTransportType
+	}
+	headers := []uint32{
+		options.InitPacketMagicHeader,
+		options.ResponsePacketMagicHeader,
+		options.UnderloadPacketMagicHeader,
+		options.TransportPacketMagicHeader,
+	}
+	for i := range headers {
+		for j := i + 1; j < len(headers); j++ {
+			if headers[i] == headers[j] {
+				return nil, E.New("amnezia: h1, h2, h3 and h4 must be different")
+			}
+		}
+	}
+	return &amneziaObfuscator{options}, nil
+}
+
+// encode rewrites an outgoing WireGuard message and returns the packets to
+// send in order: junk packets before a handshake initiation, then the
+// padded message. Handshake MACs are computed again over the new header.
+func (o *amneziaObfuscator) encode(b []byte, macs *amneziaMACs, destination netip.AddrPort) [][]byte {
+	if len(b) < 4 {
+		return [][]byte{b}
+	}
+	var (
+		packets [][]byte
+		header  uint32
+		padding int
+	)
+	switch binary.LittleEndian.Uint32(b) {
+	case device.MessageInitiationType:
+		for range o.JunkPacketCount {
+			junk := make([]byte, o.JunkPacketMinSize+mRand.Intn(o.JunkPacketMaxSize-o.JunkPacketMinSize+1))
+			rand.Read(junk)
+			packets = append(packets, junk)
+		}
+		header = o.InitPacketMagicHeader
+		padding = o.InitPacketJunkSize
+	case device.MessageResponseType:
+		header = o.ResponsePacketMagicHeader
+		padding = o.ResponsePacketJunkSize
+	case device.MessageCookieReplyType:
+		header = o.UnderloadPacketMagicHeader
+	case device.MessageTransportType:
+		header = o.TransportPacketMagicHeader
+	default:
+		return [][]byte{b}
+	}
+	macs.seal(b, header, destination)
+	if padding > 0 {
+		padded := make([]byte, padding+len(b))
+		rand.Read(padded[:padding])
+		copy(padded[padding:], b)
+		b = padded
+	}
+	return append(packets, b)
+}
+
+// decode restores an incoming message in place and returns its length, or
+// false for junk, packets that do not match the expected headers and
+// handshake messages with invalid MACs.
+func (o *amneziaObfuscator) decode(b []byte, macs *amneziaMACs, source netip.AddrPort) (int, bool) {
+	var (
+		messageType uint32
+		padding     int
+	)
+	switch {
+	case len(b) == o.InitPacketJunkSize+device.MessageInitiationSize && o.hasHeader(b[o.InitPacketJunkSize:], o.InitPacketMagicHeader):
+		messageType = device.MessageInitiationType
+		padding = o.InitPacketJunkSize
+	case len(b) == o.ResponsePacketJunkSize+device.MessageResponseSize && o.hasHeader(b[o.ResponsePacketJunkSize:], o.ResponsePacketMagicHeader):
+		messageType = device.MessageResponseType
+		padding = o.ResponsePacketJunkSize
+	case len(b) == device.MessageCookieReplySize && o.hasHeader(b, o.UnderloadPacketMagicHeader):
+		messageType = device.MessageCookieReplyType
+	case len(b) >= device.MessageTransportSize && o.hasHeader(b, o.TransportPacketMagicHeader):
+		messageType = device.MessageTransportType
+	default:
+		return 0, false
+	}
+	if padding > 0 {
+		copy(b, b[padding:])
+	}
+	n := len(b) - padding
+	if !macs.open(b[:n], messageType, source) {
+		return 0, false
+	}
+	return n, true
+}
+
+func (o *amneziaObfuscator) hasHeader(b []byte, header uint32) bool {
+	return binary.LittleEndian.Uint32(b) == header
+}
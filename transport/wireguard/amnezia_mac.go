@@ -0,0 +1,219 @@
+package wireguard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/wireguard-go/device"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	cookieReplyNonceOffset  = 8
+	cookieReplyCookieOffset = cookieReplyNonceOffset + chacha20poly1305.NonceSizeX
+	maxAmneziaSources       = 4096
+)
+
+// amneziaMACs keeps the mac1 and mac2 fields of handshake messages valid
+// across the header rewrite. AmneziaWG computes them over the message with
+// its custom type while wireguard-go uses the standard one, so outgoing
+// messages are signed again for the peer, incoming ones are checked and
+// signed again for the device, and cookie replies are carried over.
+type amneziaMACs struct {
+	access    sync.Mutex
+	mac1Key   [blake2s.Size]byte
+	cookieKey [chacha20poly1305.KeySize]byte
+	peers     []*amneziaPeerMACs
+	sources   map[netip.AddrPort]*amneziaSourceMACs
+}
+
+// amneziaPeerMACs signs the messages sent to a peer, using the cookie the
+// peer replied with when it is under load.
+type amneziaPeerMACs struct {
+	mac1Key     [blake2s.Size]byte
+	cookieKey   [chacha20poly1305.KeySize]byte
+	lastMAC1    [blake2s.Size128]byte
+	hasLastMAC1 bool
+	cookie      [blake2s.Size128]byte
+	cookieSet   time.Time
+}
+
+// amneziaSourceMACs pairs the mac1 of the last message from a source with
+// the one handed to the device, so that a cookie reply of the device can be
+// encrypted again for the source and its mac2 translated afterwards.
+type amneziaSourceMACs struct {
+	mac1       [blake2s.Size128]byte
+	deviceMAC1 [blake2s.Size128]byte
+	cookie     [blake2s.Size128]byte
+	cookieSet  time.Time
+	updated    time.Time
+}
+
+func newAmneziaMACs(privateKey []byte, peerPublicKeys [][]byte) (*amneziaMACs, error) {
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	macs := &amneziaMACs{
+		mac1Key:   deriveMACKey(device.WGLabelMAC1, publicKey),
+		cookieKey: deriveMACKey(device.WGLabelCookie, publicKey),
+		sources:   make(map[netip.AddrPort]*amneziaSourceMACs),
+	}
+	for _, peerPublicKey := range peerPublicKeys {
+		macs.peers = append(macs.peers, &amneziaPeerMACs{
+			mac1Key:   deriveMACKey(device.WGLabelMAC1, peerPublicKey),
+			cookieKey: deriveMACKey(device.WGLabelCookie, peerPublicKey),
+		})
+	}
+	return macs, nil
+}
+
+// seal writes header into an outgoing message of wireguard-go.
+func (m *amneziaMACs) seal(msg []byte, header uint32, destination netip.AddrPort) {
+	m.access.Lock()
+	defer m.access.Unlock()
+	switch binary.LittleEndian.Uint32(msg) {
+	case device.MessageInitiationType, device.MessageResponseType:
+		smac2 := len(msg) - blake2s.Size128
+		smac1 := smac2 - blake2s.Size128
+		// The device signed the message for its peer, find the peer by
+		// checking its mac1.
+		var peer *amneziaPeerMACs
+		for _, it := range m.peers {
+			mac1 := messageMAC(it.mac1Key[:], msg[:smac1])
+			if hmac.Equal(mac1[:], msg[smac1:smac2]) {
+				peer = it
+				break
+			}
+		}
+		binary.LittleEndian.PutUint32(msg, header)
+		if peer == nil {
+			return
+		}
+		peer.lastMAC1 = messageMAC(peer.mac1Key[:], msg[:smac1])
+		peer.hasLastMAC1 = true
+		copy(msg[smac1:smac2], peer.lastMAC1[:])
+		if time.Since(peer.cookieSet) > device.CookieRefreshTime {
+			clear(msg[smac2:])
+		} else {
+			mac2 := messageMAC(peer.cookie[:], msg[:smac2])
+			copy(msg[smac2:], mac2[:])
+		}
+	case device.MessageCookieReplyType:
+		binary.LittleEndian.PutUint32(msg, header)
+		source := m.sources[destination]
+		if source == nil {
+			return
+		}
+		aead, _ := chacha20poly1305.NewX(m.cookieKey[:])
+		nonce := msg[cookieReplyNonceOffset:cookieReplyCookieOffset]
+		sealed := msg[cookieReplyCookieOffset:]
+		cookie, err := aead.Open(nil, nonce, sealed, source.deviceMAC1[:])
+		if err != nil {
+			return
+		}
+		copy(source.cookie[:], cookie)
+		source.cookieSet = time.Now()
+		rand.Read(nonce)
+		aead.Seal(sealed[:0], nonce, cookie, source.mac1[:])
+	default:
+		binary.LittleEndian.PutUint32(msg, header)
+	}
+}
+
+// open restores messageType in an incoming message, returning false if it
+// must not reach the device.
+func (m *amneziaMACs) open(msg []byte, messageType uint32, source netip.AddrPort) bool {
+	m.access.Lock()
+	defer m.access.Unlock()
+	switch messageType {
+	case device.MessageInitiationType, device.MessageResponseType:
+		smac2 := len(msg) - blake2s.Size128
+		smac1 := smac2 - blake2s.Size128
+		mac1 := messageMAC(m.mac1Key[:], msg[:smac1])
+		if !hmac.Equal(mac1[:], msg[smac1:smac2]) {
+			return false
+		}
+		state := m.source(source)
+		state.mac1 = mac1
+		var validMAC2 bool
+		if time.Since(state.cookieSet) <= device.CookieRefreshTime {
+			mac2 := messageMAC(state.cookie[:], msg[:smac2])
+			validMAC2 = hmac.Equal(mac2[:], msg[smac2:])
+		}
+		binary.LittleEndian.PutUint32(msg, messageType)
+		state.deviceMAC1 = messageMAC(m.mac1Key[:], msg[:smac1])
+		copy(msg[smac1:smac2], state.deviceMAC1[:])
+		if validMAC2 {
+			mac2 := messageMAC(state.cookie[:], msg[:smac2])
+			copy(msg[smac2:], mac2[:])
+		} else {
+			clear(msg[smac2:])
+		}
+		return true
+	case device.MessageCookieReplyType:
+		// The device could not decrypt the cookie as it was bound to the
+		// mac1 we sent, so it is consumed here.
+		nonce := msg[cookieReplyNonceOffset:cookieReplyCookieOffset]
+		sealed := msg[cookieReplyCookieOffset:]
+		for _, peer := range m.peers {
+			if !peer.hasLastMAC1 {
+				continue
+			}
+			aead, _ := chacha20poly1305.NewX(peer.cookieKey[:])
+			cookie, err := aead.Open(nil, nonce, sealed, peer.lastMAC1[:])
+			if err == nil {
+				copy(peer.cookie[:], cookie)
+				peer.cookieSet = time.Now()
+				break
+			}
+		}
+		return false
+	default:
+		binary.LittleEndian.PutUint32(msg, messageType)
+		return true
+	}
+}
+
+func (m *amneziaMACs) source(address netip.AddrPort) *amneziaSourceMACs {
+	state := m.sources[address]
+	now := time.Now()
+	if state == nil {
+		if len(m.sources) >= maxAmneziaSources {
+			for it, stale := range m.sources {
+				if now.Sub(stale.updated) > device.CookieRefreshTime {
+					delete(m.sources, it)
+				}
+			}
+			if len(m.sources) >= maxAmneziaSources {
+				clear(m.sources)
+			}
+		}
+		state = &amneziaSourceMACs{}
+		m.sources[address] = state
+	}
+	state.updated = now
+	return state
+}
+
+func deriveMACKey(label string, publicKey []byte) (key [blake2s.Size]byte) {
+	hash, _ := blake2s.New256(nil)
+	hash.Write([]byte(label))
+	hash.Write(publicKey)
+	hash.Sum(key[:0])
+	return
+}
+
+func messageMAC(key []byte, data []byte) (sum [blake2s.Size128]byte) {
+	hash, _ := blake2s.New128(key)
+	hash.Write(data)
+	hash.Sum(sum[:0])
+	return
+}
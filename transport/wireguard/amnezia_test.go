@@ -0,0 +1,184 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/wireguard-go/device"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Packets captured from a handshake between two amneziawg-go v1.0.4
+// devices with jc=3, jmin=10, jmax=50, s1=15, s2=18, h1=1020325451,
+// h2=3288052141, h3=1766607858 and h4=2528465083. The cookie reply is the
+// one amneziawg-go sends under load for the initiation, and the last
+// message is the initiation signed again after consuming it.
+const (
+	amneziaTestPrivateKeyA = "0002030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f60"
+	amneziaTestPublicKeyA  = "07a37cbc142093c8b755dc1b10e86cb426374ad16aa853ed0bdfc0b2b86d1c7c"
+	amneziaTestPrivateKeyB = "60666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f8081828344"
+	amneziaTestPublicKeyB  = "5714769d116bf76436ae74bc793d2c30ad1903c59ac5273805c7e2698b410c36"
+
+	amneziaTestJunk       = "209703229a74c9ebf82eb353fdb4"
+	amneziaTestInitiation = "2f057f73cf37a88c1f3a2b49bada254beed03cca1f40b1328b6cafda6d1595bb447757299e8bb802bb3f83b4d4e0aa05be316e86a7e21649b661843ea012d901ff13b733b9df85ee709df47db4eab06f82e5b852c2349f172d65670d5c633e54c26536b00a34e60fb1e71ed2e8cb034516328e8058d4eab7d52946ffb7dc64d8f4fbd7956a72e73f4c2996497cec453835320b00000000000000000000000000000000"
+	amneziaTestResponse   = "b3f999aa1cd619880ba7e648ca22bf8911f8adb1fbc3d1bf5ab7ca1f40b13f5d5149d8b1d47f768dc70606d2a8621b4b8077a83c898d4068301916d3ea6a3802fca4dc8bb1d49e0ab8fbd7152204d6b93141cf185fb7987a9435b576c87800000000000000000000000000000000"
+	amneziaTestTransport  = "bb50b596d1bf5ab700000000000000008f6aff7f77d936c0c97c1e9900f89e741f50c1a4cbdecdab6d330c88cdd030a07cf0f12d72e41b3e9722bce5db7ef35f"
+	amneziaTestCookie     = "f24b4c69ca1f40b146a15e4ee688dd273d73af6a6738c8632515a40ac39f628ee84731cc27f649d5b07e62a6a5f24660a89527a1dcec9d1c9060c136cf1a05bd"
+	amneziaTestMAC2       = "4beed03cca1f40b1328b6cafda6d1595bb447757299e8bb802bb3f83b4d4e0aa05be316e86a7e21649b661843ea012d901ff13b733b9df85ee709df47db4eab06f82e5b852c2349f172d65670d5c633e54c26536b00a34e60fb1e71ed2e8cb034516328e8058d4eab7d52946ffb7dc64d8f4fbd7956a72e73f4c2996497cec453835320b72113571c78b97cbd725ead924e691c6"
+)
+
+var amneziaTestAddress = netip.MustParseAddrPort("127.0.0.1:51820")
+
+func TestAmneziaDecodeCaptured(t *testing.T) {
+	t.Parallel()
+	obfuscator, macsA, macsB := newAmneziaTestPeers(t)
+
+	_, loaded := obfuscator.decode(amneziaTestPacket(t, amneziaTestJunk), macsB, amneziaTestAddress)
+	require.False(t, loaded)
+
+	initiation := amneziaTestPacket(t, amneziaTestInitiation)
+	n, loaded := obfuscator.decode(initiation, macsB, amneziaTestAddress)
+	require.True(t, loaded)
+	require.Equal(t, device.MessageInitiationSize, n)
+	require.Equal(t, uint32(device.MessageInitiationType), binary.LittleEndian.Uint32(initiation))
+	checkerB := amneziaTestChecker(t, amneziaTestPublicKeyB)
+	require.True(t, checkerB.CheckMAC1(initiation[:n]))
+
+	response := amneziaTestPacket(t, amneziaTestResponse)
+	n, loaded = obfuscator.decode(response, macsA, amneziaTestAddress)
+	require.True(t, loaded)
+	require.Equal(t, device.MessageResponseSize, n)
+	checkerA := amneziaTestChecker(t, amneziaTestPublicKeyA)
+	require.True(t, checkerA.CheckMAC1(response[:n]))
+
+	transport := amneziaTestPacket(t, amneziaTestTransport)
+	n, loaded = obfuscator.decode(transport, macsA, amneziaTestAddress)
+	require.True(t, loaded)
+	require.Equal(t, len(transport), n)
+	require.Equal(t, uint32(device.MessageTransportType), binary.LittleEndian.Uint32(transport))
+
+	tampered := amneziaTestPacket(t, amneziaTestInitiation)
+	tampered[20] ^= 1
+	_, loaded = obfuscator.decode(tampered, macsB, amneziaTestAddress)
+	require.False(t, loaded)
+}
+
+func TestAmneziaEncodeCaptured(t *testing.T) {
+	t.Parallel()
+	obfuscator, macsA, macsB := newAmneziaTestPeers(t)
+
+	// The device of A signs the initiation with the standard header.
+	initiation := amneziaTestMessage(t, amneziaTestInitiation, obfuscator.InitPacketJunkSize, amneziaTestPublicKeyB)
+	packets := obfuscator.encode(initiation, macsA, amneziaTestAddress)
+	require.Len(t, packets, obfuscator.JunkPacketCount+1)
+	require.Equal(t, amneziaTestPacket(t, amneziaTestInitiation)[obfuscator.InitPacketJunkSize:], packets[len(packets)-1][obfuscator.InitPacketJunkSize:])
+
+	response := amneziaTestMessage(t, amneziaTestResponse, obfuscator.ResponsePacketJunkSize, amneziaTestPublicKeyA)
+	packets = obfuscator.encode(response, macsB, amneziaTestAddress)
+	require.Len(t, packets, 1)
+	require.Equal(t, amneziaTestPacket(t, amneziaTestResponse)[obfuscator.ResponsePacketJunkSize:], packets[0][obfuscator.ResponsePacketJunkSize:])
+}
+
+func TestAmneziaCookieReplyCaptured(t *testing.T) {
+	t.Parallel()
+	obfuscator, macsA, _ := newAmneziaTestPeers(t)
+
+	obfuscator.encode(amneziaTestMessage(t, amneziaTestInitiation, obfuscator.InitPacketJunkSize, amneziaTestPublicKeyB), macsA, amneziaTestAddress)
+	_, loaded := obfuscator.decode(amneziaTestPacket(t, amneziaTestCookie), macsA, amneziaTestAddress)
+	require.False(t, loaded)
+
+	packets := obfuscator.encode(amneziaTestMessage(t, amneziaTestInitiation, obfuscator.InitPacketJunkSize, amneziaTestPublicKeyB), macsA, amneziaTestAddress)
+	require.Equal(t, amneziaTestPacket(t, amneziaTestMAC2), packets[len(packets)-1][obfuscator.InitPacketJunkSize:])
+}
+
+func TestAmneziaCookieReplyUnderLoad(t *testing.T) {
+	t.Parallel()
+	obfuscator, _, macsB := newAmneziaTestPeers(t)
+	source := amneziaTestAddress.Addr().AsSlice()
+
+	initiation := amneziaTestPacket(t, amneziaTestInitiation)
+	n, loaded := obfuscator.decode(initiation, macsB, amneziaTestAddress)
+	require.True(t, loaded)
+
+	// The device of B replies with a cookie bound to the mac1 it received,
+	// the peer only accepts it bound to the mac1 it sent.
+	checkerB := amneziaTestChecker(t, amneziaTestPublicKeyB)
+	reply, err := checkerB.CreateReply(initiation[:n], 1, source)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	require.NoError(t, binary.Write(&buffer, binary.LittleEndian, reply))
+	packets := obfuscator.encode(buffer.Bytes(), macsB, amneziaTestAddress)
+	require.Len(t, packets, 1)
+	require.Equal(t, obfuscator.UnderloadPacketMagicHeader, binary.LittleEndian.Uint32(packets[0]))
+
+	var generator device.CookieGenerator
+	generator.Init(amneziaTestKey(t, amneziaTestPublicKeyB))
+	message := amneziaTestPacket(t, amneziaTestInitiation)[obfuscator.InitPacketJunkSize:]
+	generator.AddMacs(message)
+	var peerReply device.MessageCookieReply
+	require.NoError(t, binary.Read(bytes.NewReader(packets[0]), binary.LittleEndian, &peerReply))
+	require.True(t, generator.ConsumeReply(&peerReply))
+	generator.AddMacs(message)
+
+	initiation = append(make([]byte, obfuscator.InitPacketJunkSize), message...)
+	n, loaded = obfuscator.decode(initiation, macsB, amneziaTestAddress)
+	require.True(t, loaded)
+	require.True(t, checkerB.CheckMAC2(initiation[:n], source))
+}
+
+func newAmneziaTestPeers(t *testing.T) (*amneziaObfuscator, *amneziaMACs, *amneziaMACs) {
+	obfuscator, err := newAmneziaObfuscator(AmneziaOptions{
+		JunkPacketCount:            3,
+		JunkPacketMinSize:          10,
+		JunkPacketMaxSize:          50,
+		InitPacketJunkSize:         15,
+		ResponsePacketJunkSize:     18,
+		InitPacketMagicHeader:      1020325451,
+		ResponsePacketMagicHeader:  3288052141,
+		UnderloadPacketMagicHeader: 1766607858,
+		TransportPacketMagicHeader: 2528465083,
+	})
+	require.NoError(t, err)
+	macsA, err := newAmneziaMACs(amneziaTestPacket(t, amneziaTestPrivateKeyA), [][]byte{amneziaTestPacket(t, amneziaTestPublicKeyB)})
+	require.NoError(t, err)
+	macsB, err := newAmneziaMACs(amneziaTestPacket(t, amneziaTestPrivateKeyB), [][]byte{amneziaTestPacket(t, amneziaTestPublicKeyA)})
+	require.NoError(t, err)
+	return obfuscator, macsA, macsB
+}
+
+// amneziaTestMessage returns a captured handshake message as wireguard-go
+// sends it to the peer with publicKey.
+func amneziaTestMessage(t *testing.T, packet string, padding int, publicKey string) []byte {
+	message := amneziaTestPacket(t, packet)[padding:]
+	switch len(message) {
+	case device.MessageInitiationSize:
+		binary.LittleEndian.PutUint32(message, device.MessageInitiationType)
+	case device.MessageResponseSize:
+		binary.LittleEndian.PutUint32(message, device.MessageResponseType)
+	}
+	var generator device.CookieGenerator
+	generator.Init(amneziaTestKey(t, publicKey))
+	generator.AddMacs(message)
+	return message
+}
+
+func amneziaTestChecker(t *testing.T, publicKey string) *device.CookieChecker {
+	var checker device.CookieChecker
+	checker.Init(amneziaTestKey(t, publicKey))
+	return &checker
+}
+
+func amneziaTestKey(t *testing.T, publicKey string) (key device.NoisePublicKey) {
+	copy(key[:], amneziaTestPacket(t, publicKey))
+	return
+}
+
+func amneziaTestPacket(t *testing.T, packet string) []byte {
+	b, err := hex.DecodeString(packet)
+	require.NoError(t, err)
+	return b
+}
@@ -7,9 +7,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/bufio"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -20,6 +22,8 @@ import (
 
 var _ conn.Bind = (*ClientBind)(nil)
 
+const socketBufferSize = 7 << 20
+
 type ClientBind struct {
 	ctx                 context.Context
 	logger              logger.Logger
@@ -28,12 +32,17 @@ type ClientBind struct {
 	bindDone            context.CancelFunc
 	dialer              N.Dialer
 	reservedForEndpoint map[netip.AddrPort][3]uint8
+	amneziaForEndpoint  map[netip.AddrPort]*amneziaObfuscator
 	connAccess          sync.Mutex
 	conn                *wireConn
 	done                chan struct{}
 	isConnect           bool
 	connectAddr         netip.AddrPort
+	listenPort          uint16
 	reserved            [3]uint8
+	obfuscated          bool
+	amnezia             *amneziaObfuscator
+	amneziaMACs         *amneziaMACs
 }
 
 func NewClientBind(ctx context.Context, logger logger.Logger, dialer N.Dialer, isConnect bool, connectAddr netip.AddrPort, reserved [3]uint8) *ClientBind {
@@ -43,6 +52,7 @@ func NewClientBind(ctx context.Context, logger logger.Logger, dialer N.Dialer, i
 		pauseManager:        service.FromContext[pause.Manager](ctx),
 		dialer:              dialer,
 		reservedForEndpoint: make(map[netip.AddrPort][3]uint8),
+		amneziaForEndpoint:  make(map[netip.AddrPort]*amneziaObfuscator),
 		done:                make(chan struct{}),
 		isConnect:           isConnect,
 		connectAddr:         connectAddr,
@@ -85,6 +95,15 @@ func (c *ClientBind) connect() (*wireConn, error) {
 			PacketConn: bufio.NewUnbindPacketConn(udpConn),
 			done:       make(chan struct{}),
 		}
+	} else if c.obfuscated {
+		udpConn, err := c.listenObfuscated()
+		if err != nil {
+			return nil, err
+		}
+		c.conn = &wireConn{
+			PacketConn: bufio.NewPacketConn(udpConn),
+			done:       make(chan struct{}),
+		}
 	} else {
 		udpConn, err := c.dialer.ListenPacket(c.bindCtx, M.Socksaddr{Addr: netip.IPv4Unspecified()})
 		if err != nil {
@@ -95,9 +114,30 @@ func (c *ClientBind) connect() (*wireConn, error) {
 			done:       make(chan struct{}),
 		}
 	}
+	if c.obfuscated {
+		setSocketBuffer(c.conn.PacketConn)
+	}
 	return c.conn, nil
 }
 
+// listenObfuscated listens for AmneziaWG endpoints, which replace the
+// standard bind. The listen port is kept when the dialer listens directly.
+func (c *ClientBind) listenObfuscated() (net.PacketConn, error) {
+	if listener, isListener := dialer.PacketAddrListenerOf(c.dialer); isListener {
+		return listener.ListenPacketAddr(N.NetworkUDP, ":"+F.ToString(c.listenPort))
+	}
+	return c.dialer.ListenPacket(c.bindCtx, M.Socksaddr{Addr: netip.IPv4Unspecified()})
+}
+
+// setSocketBuffer raises the buffers like the standard bind does, since
+// packets are read one by one here and bursts overflow the default size.
+func setSocketBuffer(packetConn net.PacketConn) {
+	if udpConn, isUDPConn := common.Cast[*net.UDPConn](packetConn); isUDPConn {
+		_ = udpConn.SetReadBuffer(socketBufferSize)
+		_ = udpConn.SetWriteBuffer(socketBufferSize)
+	}
+}
+
 func (c *ClientBind) Open(port uint16) (fns []conn.ReceiveFunc, actualPort uint16, err error) {
 	select {
 	case <-c.done:
@@ -105,6 +145,7 @@ func (c *ClientBind) Open(port uint16) (fns []conn.ReceiveFunc, actualPort uint1
 	default:
 	}
 	c.bindCtx, c.bindDone = context.WithCancel(c.ctx)
+	c.listenPort = port
 	return []conn.ReceiveFunc{c.receive}, 0, nil
 }
 
@@ -133,6 +174,22 @@ func (c *ClientBind) receive(packets [][]byte, sizes []int, eps []conn.Endpoint)
 		}
 		return
 	}
+	if c.obfuscated {
+		source := M.SocksaddrFromNet(addr).Unwrap().AddrPort()
+		if obfuscator := c.amneziaFor(source); obfuscator != nil {
+			var loaded bool
+			n, loaded = obfuscator.decode(packets[0][:n], c.amneziaMACs, source)
+			if !loaded {
+				return
+			}
+		} else if n > 3 {
+			common.ClearArray(packets[0][1:4])
+		}
+		sizes[0] = n
+		eps[0] = remoteEndpoint(source)
+		count = 1
+		return
+	}
 	sizes[0] = n
 	if n > 3 {
 		b := packets[0]
@@ -170,7 +227,21 @@ func (c *ClientBind) Send(bufs [][]byte, ep conn.Endpoint) error {
 		return err
 	}
 	destination := netip.AddrPort(ep.(remoteEndpoint))
+	var obfuscator *amneziaObfuscator
+	if c.obfuscated {
+		obfuscator = c.amneziaFor(destination)
+	}
 	for _, b := range bufs {
+		if obfuscator != nil {
+			for _, packet := range obfuscator.encode(b, c.amneziaMACs, destination) {
+				_, err = udpConn.WriteToUDPAddrPort(packet, destination)
+				if err != nil {
+					udpConn.Close()
+					return err
+				}
+			}
+			continue
+		}
 		if len(b) > 3 {
 			reserved, loaded := c.reservedForEndpoint[destination]
 			if !loaded {
@@ -203,6 +274,29 @@ func (c *ClientBind) SetReservedForEndpoint(destination netip.AddrPort, reserved
 	c.reservedForEndpoint[destination] = reserved
 }
 
+// setAmnezia switches the bind to AmneziaWG: the listen port is honored,
+// socket buffers are raised and packets of obfuscated peers are encoded with
+// obfuscator, or with the obfuscator of their endpoint. macs signs the
+// handshake messages of the device again for the AmneziaWG headers.
+func (c *ClientBind) setAmnezia(obfuscator *amneziaObfuscator, macs *amneziaMACs) {
+	c.obfuscated = true
+	c.amnezia = obfuscator
+	c.amneziaMACs = macs
+}
+
+func (c *ClientBind) setAmneziaForEndpoint(destination netip.AddrPort, obfuscator *amneziaObfuscator) {
+	c.obfuscated = true
+	c.amneziaForEndpoint[destination] = obfuscator
+}
+
+func (c *ClientBind) amneziaFor(destination netip.AddrPort) *amneziaObfuscator {
+	obfuscator, loaded := c.amneziaForEndpoint[destination]
+	if !loaded {
+		obfuscator = c.amnezia
+	}
+	return obfuscator
+}
+
 type wireConn struct {
 	net.PacketConn
 	conn   net.Conn
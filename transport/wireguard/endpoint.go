@@ -25,6 +25,8 @@ import (
 
 type Endpoint struct {
 	options        EndpointOptions
+	amnezia        *amneziaObfuscator
+	amneziaMACs    *amneziaMACs
 	peers          []peerConfig
 	ipcConf        string
 	allowedAddress []netip.Prefix
@@ -47,7 +49,17 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 	if options.ListenPort != 0 {
 		ipcConf += "\nlisten_port=" + F.ToString(options.ListenPort)
 	}
-	var peers []peerConfig
+	var amnezia *amneziaObfuscator
+	if options.Amnezia != nil {
+		amnezia, err = newAmneziaObfuscator(*options.Amnezia)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var (
+		peers          []peerConfig
+		peerPublicKeys [][]byte
+	)
 	for peerIndex, rawPeer := range options.Peers {
 		peer := peerConfig{
 			allowedIPs: rawPeer.AllowedIPs,
@@ -63,6 +75,7 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 			return nil, E.Cause(err, "decode public key for peer ", peerIndex)
 		}
 		peer.publicKeyHex = hex.EncodeToString(publicKeyBytes)
+		peerPublicKeys = append(peerPublicKeys, publicKeyBytes)
 		if rawPeer.PreSharedKey != "" {
 			preSharedKeyBytes, err := base64.StdEncoding.DecodeString(rawPeer.PreSharedKey)
 			if err != nil {
@@ -79,8 +92,26 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 			}
 			copy(peer.reserved[:], rawPeer.Reserved[:])
 		}
+		if rawPeer.Amnezia != nil {
+			peer.amnezia, err = newAmneziaObfuscator(*rawPeer.Amnezia)
+			if err != nil {
+				return nil, E.Cause(err, "peer ", peerIndex)
+			}
+		}
+		if peer.reserved != [3]uint8{} && (peer.amnezia != nil || amnezia != nil) {
+			return nil, E.New("reserved is conflict with amnezia for peer ", peerIndex)
+		}
 		peers = append(peers, peer)
 	}
+	var macs *amneziaMACs
+	if amnezia != nil || common.Any(peers, func(peer peerConfig) bool {
+		return peer.amnezia != nil
+	}) {
+		macs, err = newAmneziaMACs(privateKeyBytes, peerPublicKeys)
+		if err != nil {
+			return nil, E.Cause(err, "amnezia")
+		}
+	}
 	var allowedPrefixBuilder netipx.IPSetBuilder
 	for _, peer := range options.Peers {
 		for _, prefix := range peer.AllowedIPs {
@@ -113,6 +144,8 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 	}
 	return &Endpoint{
 		options:        options,
+		amnezia:        amnezia,
+		amneziaMACs:    macs,
 		peers:          peers,
 		ipcConf:        ipcConf,
 		allowedAddress: allowedAddresses,
@@ -142,7 +175,15 @@ func (e *Endpoint) Start(resolve bool) error {
 	}
 	var bind conn.Bind
 	wgListener, isWgListener := common.Cast[conn.Listener](e.options.Dialer)
-	if isWgListener {
+	useAmnezia := e.amnezia != nil || common.Any(e.peers, func(peer peerConfig) bool {
+		return peer.amnezia != nil
+	})
+	if useAmnezia {
+		// The standard bind clears the reserved bytes of every received
+		// packet, which would destroy the AmneziaWG message headers.
+		isWgListener = false
+		bind = e.newAmneziaBind()
+	} else if isWgListener {
 		bind = conn.NewStdNetBind(wgListener)
 	} else {
 		var (
@@ -227,6 +268,32 @@ func (e *Endpoint) onPauseUpdated(event int) {
 	}
 }
 
+// newAmneziaBind creates the bind of an endpoint with AmneziaWG peers. A
+// single peer is connected to unless a listen port is configured, so that
+// the port stays open for the peer to reach.
+func (e *Endpoint) newAmneziaBind() *ClientBind {
+	var (
+		isConnect   bool
+		connectAddr netip.AddrPort
+		amnezia     = e.amnezia
+	)
+	if len(e.peers) == 1 && e.peers[0].endpoint.IsValid() && e.options.ListenPort == 0 {
+		isConnect = true
+		connectAddr = e.peers[0].endpoint
+		if e.peers[0].amnezia != nil {
+			amnezia = e.peers[0].amnezia
+		}
+	}
+	bind := NewClientBind(e.options.Context, e.options.Logger, e.options.Dialer, isConnect, connectAddr, [3]uint8{})
+	bind.setAmnezia(amnezia, e.amneziaMACs)
+	for _, peer := range e.peers {
+		if peer.amnezia != nil && peer.endpoint.IsValid() {
+			bind.setAmneziaForEndpoint(peer.endpoint, peer.amnezia)
+		}
+	}
+	return bind
+}
+
 type peerConfig struct {
 	destination     M.Socksaddr
 	endpoint        netip.AddrPort
@@ -235,6 +302,7 @@ type peerConfig struct {
 	allowedIPs      []netip.Prefix
 	keepalive       uint16
 	reserved        [3]uint8
+	amnezia         *amneziaObfuscator
 }
 
 func (c peerConfig) GenerateIpcLines() string {
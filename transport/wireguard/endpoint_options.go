@@ -27,6 +27,7 @@ type EndpointOptions struct {
 	ResolvePeer  func(domain string) (netip.Addr, error)
 	Peers        []PeerOptions
 	Workers      int
+	Amnezia      *AmneziaOptions
 }
 
 type PeerOptions struct {
@@ -36,4 +37,5 @@ type PeerOptions struct {
 	AllowedIPs                  []netip.Prefix
 	PersistentKeepaliveInterval uint16
 	Reserved                    []uint8
+	Amnezia                     *AmneziaOptions
 }